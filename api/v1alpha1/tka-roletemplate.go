@@ -0,0 +1,45 @@
+// +kubebuilder:object:generate=true
+// +groupName=tka.specht-labs.de
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TkaRoleTemplateSpec defines the resources provisioned with every sign-in to a role.
+type TkaRoleTemplateSpec struct {
+	// Role is the ClusterRole whose sign-ins get the resources of this template.
+	Role string `json:"role"`
+	// Manifests are Go templates of the Kubernetes objects to create for each sign-in.
+	// An entry may hold several YAML documents separated by "---". Templates can refer to
+	// .User, .Role, .Session and .Cluster.
+	// +kubebuilder:validation:MinItems=1
+	Manifests []string `json:"manifests"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=roletemplate
+// +kubebuilder:printcolumn:name="role",type=string,JSONPath=`.spec.role`,description="The ClusterRole whose sign-ins get the resources"
+
+// TkaRoleTemplate represents a Kubernetes custom resource holding the resources provisioned with each sign-in to a role,
+// e.g. a personal scratch namespace.
+type TkaRoleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TkaRoleTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TkaRoleTemplateList contains a list of TkaRoleTemplate resources.
+type TkaRoleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TkaRoleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TkaRoleTemplate{}, &TkaRoleTemplateList{})
+}
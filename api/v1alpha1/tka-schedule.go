@@ -0,0 +1,56 @@
+// +kubebuilder:object:generate=true
+// +groupName=tka.specht-labs.de
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TkaScheduleSpec defines who may sign in with a role during which time ranges, e.g. an on-call rotation.
+type TkaScheduleSpec struct {
+	// Role is the ClusterRole granted to the users of a shift while it lasts.
+	Role string `json:"role"`
+	// Shifts are the time ranges in which the listed users may obtain the role.
+	// +kubebuilder:validation:MinItems=1
+	Shifts []TkaScheduleShift `json:"shifts"`
+}
+
+// TkaScheduleShift grants the role of a schedule to some users for a time range.
+type TkaScheduleShift struct {
+	// Users are the Tailscale login names of the users on shift.
+	// +kubebuilder:validation:MinItems=1
+	Users []string `json:"users"`
+	// Start is when the shift begins (RFC3339).
+	// +kubebuilder:validation:Format=date-time
+	Start string `json:"start"`
+	// End is when the shift ends (RFC3339). Sign-ins of the shift are revoked at this time.
+	// +kubebuilder:validation:Format=date-time
+	End string `json:"end"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=schedule
+// +kubebuilder:printcolumn:name="role",type=string,JSONPath=`.spec.role`,description="The ClusterRole granted during the shifts"
+
+// TkaSchedule represents a Kubernetes custom resource granting a role to users during scheduled shifts,
+// in addition to the capability rules of the tailnet policy.
+type TkaSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TkaScheduleSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TkaScheduleList contains a list of TkaSchedule resources.
+type TkaScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TkaSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TkaSchedule{}, &TkaScheduleList{})
+}
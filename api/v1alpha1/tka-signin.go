@@ -12,6 +12,57 @@ type TkaSigninSpec struct {
 	Username       string `json:"username"`
 	Role           string `json:"role"`
 	ValidityPeriod string `json:"validity_period"`
+	// TokenTTL caps the lifetime of each token handed out for this sign-in.
+	// Clients re-fetch their kubeconfig until the sign-in itself expires.
+	TokenTTL string `json:"token_ttl,omitempty"`
+	// Reason is the justification the user gave for signing in, e.g. a ticket reference.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// LoginName is the Tailscale login name of the person who signed in, e.g. alice@example.com.
+	// Username drops the domain, so this tells apart people with the same name on different domains.
+	// +optional
+	LoginName string `json:"login_name,omitempty"`
+	// Device records the Tailscale device the user signed in from.
+	// +optional
+	Device *TkaSigninDevice `json:"device,omitempty"`
+	// Suspended temporarily withdraws the access granted by the sign-in without ending it.
+	// The bindings are removed while suspended and restored on resume; the sign-in still expires at its usual time.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+	// NotBefore delays the access until the given time (RFC3339), e.g. to sign in ahead of an on-call shift.
+	// The validity period starts at this time.
+	// +kubebuilder:validation:Format=date-time
+	// +optional
+	NotBefore string `json:"not_before,omitempty"`
+	// NotAfter revokes the access at the given time (RFC3339) even if the validity period lasts longer,
+	// e.g. at the end of an on-call shift.
+	// +kubebuilder:validation:Format=date-time
+	// +optional
+	NotAfter string `json:"not_after,omitempty"`
+	// Parent is the name of the TkaSignin this sign-in was delegated from.
+	// A delegated sign-in never outlives its parent and is revoked together with it.
+	// +optional
+	Parent string `json:"parent,omitempty"`
+	// Namespace restricts the access to a single namespace by binding the role with a RoleBinding
+	// instead of a ClusterRoleBinding.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// TkaSigninDevice describes the Tailscale device a sign-in was requested from.
+type TkaSigninDevice struct {
+	// NodeName is the device's machine name.
+	NodeName string `json:"node_name,omitempty"`
+	// NodeID is the device's stable Tailscale node ID.
+	NodeID string `json:"node_id,omitempty"`
+	// OS is the operating system reported by the device.
+	OS string `json:"os,omitempty"`
+	// ClientVersion is the version of the Tailscale client running on the device.
+	ClientVersion string `json:"client_version,omitempty"`
+	// KeyExpiry is when the device's node key expires (RFC3339). Empty if key expiry is disabled.
+	KeyExpiry string `json:"key_expiry,omitempty"`
+	// NodeAttributes lists the node attributes granted to the device.
+	NodeAttributes []string `json:"node_attributes,omitempty"`
 }
 
 // TkaSigninStatus defines the observed state of a TkaSignin resource.
@@ -19,8 +70,56 @@ type TkaSigninStatus struct {
 	Provisioned bool   `json:"provisioned"`
 	ValidUntil  string `json:"valid_until"`
 	SignedInAt  string `json:"signed_in"`
+
+	// LastActivity is when the sign-in's ServiceAccount last made a request to the API server (RFC3339),
+	// as reported by the audit webhook. It is recorded with a resolution of about a minute.
+	// +kubebuilder:validation:Format=date-time
+	// +optional
+	LastActivity string `json:"last_activity,omitempty"`
+
+	// Conditions report problems that keep the sign-in from granting access,
+	// e.g. a ClusterRole that does not exist.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Resources lists the objects created from TkaRoleTemplates for this sign-in.
+	// They are deleted when the user signs out.
+	// +optional
+	Resources []TkaSigninResource `json:"resources,omitempty"`
+}
+
+// TkaSigninResource references an object created from a TkaRoleTemplate.
+type TkaSigninResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace is empty for cluster-scoped objects.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Template is the name of the TkaRoleTemplate the object was created from.
+	Template string `json:"template"`
 }
 
+// Condition types and reasons reported in TkaSigninStatus.Conditions.
+const (
+	// ConditionRoleAvailable is True while the ClusterRole referenced by the sign-in exists.
+	ConditionRoleAvailable = "RoleAvailable"
+
+	// ReasonRoleFound is set when the referenced ClusterRole exists.
+	ReasonRoleFound = "RoleFound"
+	// ReasonRoleNotFound is set when the referenced ClusterRole does not exist.
+	ReasonRoleNotFound = "RoleNotFound"
+
+	// ConditionSuspended is True while the bindings of a suspended sign-in are removed.
+	ConditionSuspended = "Suspended"
+
+	// ReasonSuspended is set when the bindings were removed because the sign-in is suspended.
+	ReasonSuspended = "SessionSuspended"
+	// ReasonResumed is set when the bindings were restored after the sign-in was resumed.
+	ReasonResumed = "SessionResumed"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Namespaced,shortName=signin
@@ -28,6 +127,7 @@ type TkaSigninStatus struct {
 // +kubebuilder:printcolumn:name="since",type=string,JSONPath=`.status.signed_in`,description="timestamp when the user signed in"
 // +kubebuilder:printcolumn:name="period",type=string,JSONPath=`.status.validity_period`,description="For how long this session is valid"
 // +kubebuilder:printcolumn:name="until",type=string,JSONPath=`.spec.valid_until`,description="timestamp until when the signin is valid"
+// +kubebuilder:printcolumn:name="last activity",type=string,JSONPath=`.status.last_activity`,description="timestamp of the last request made with the signin's credentials"
 
 // TkaSignin represents a Kubernetes custom resource for managing temporary user sign-ins with specific roles and validity.
 type TkaSignin struct {
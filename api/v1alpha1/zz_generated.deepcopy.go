@@ -5,16 +5,195 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaRoleTemplate) DeepCopyInto(out *TkaRoleTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaRoleTemplate.
+func (in *TkaRoleTemplate) DeepCopy() *TkaRoleTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaRoleTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TkaRoleTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaRoleTemplateList) DeepCopyInto(out *TkaRoleTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TkaRoleTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaRoleTemplateList.
+func (in *TkaRoleTemplateList) DeepCopy() *TkaRoleTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaRoleTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TkaRoleTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaRoleTemplateSpec) DeepCopyInto(out *TkaRoleTemplateSpec) {
+	*out = *in
+	if in.Manifests != nil {
+		in, out := &in.Manifests, &out.Manifests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaRoleTemplateSpec.
+func (in *TkaRoleTemplateSpec) DeepCopy() *TkaRoleTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaRoleTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaSchedule) DeepCopyInto(out *TkaSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSchedule.
+func (in *TkaSchedule) DeepCopy() *TkaSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TkaSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaScheduleList) DeepCopyInto(out *TkaScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TkaSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaScheduleList.
+func (in *TkaScheduleList) DeepCopy() *TkaScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TkaScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaScheduleShift) DeepCopyInto(out *TkaScheduleShift) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaScheduleShift.
+func (in *TkaScheduleShift) DeepCopy() *TkaScheduleShift {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaScheduleShift)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaScheduleSpec) DeepCopyInto(out *TkaScheduleSpec) {
+	*out = *in
+	if in.Shifts != nil {
+		in, out := &in.Shifts, &out.Shifts
+		*out = make([]TkaScheduleShift, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaScheduleSpec.
+func (in *TkaScheduleSpec) DeepCopy() *TkaScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TkaSignin) DeepCopyInto(out *TkaSignin) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSignin.
@@ -35,6 +214,26 @@ func (in *TkaSignin) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaSigninDevice) DeepCopyInto(out *TkaSigninDevice) {
+	*out = *in
+	if in.NodeAttributes != nil {
+		in, out := &in.NodeAttributes, &out.NodeAttributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSigninDevice.
+func (in *TkaSigninDevice) DeepCopy() *TkaSigninDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaSigninDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TkaSigninList) DeepCopyInto(out *TkaSigninList) {
 	*out = *in
@@ -67,9 +266,29 @@ func (in *TkaSigninList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TkaSigninResource) DeepCopyInto(out *TkaSigninResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSigninResource.
+func (in *TkaSigninResource) DeepCopy() *TkaSigninResource {
+	if in == nil {
+		return nil
+	}
+	out := new(TkaSigninResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TkaSigninSpec) DeepCopyInto(out *TkaSigninSpec) {
 	*out = *in
+	if in.Device != nil {
+		in, out := &in.Device, &out.Device
+		*out = new(TkaSigninDevice)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSigninSpec.
@@ -85,6 +304,18 @@ func (in *TkaSigninSpec) DeepCopy() *TkaSigninSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TkaSigninStatus) DeepCopyInto(out *TkaSigninStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]TkaSigninResource, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TkaSigninStatus.
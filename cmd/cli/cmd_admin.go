@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cmdAdmin = &cobra.Command{
+	Use:   "admin <command>",
+	Short: "Manage other users' sessions",
+	Long: `Manage the sessions of other users. These commands require a capability
+grant with admin set to true.`,
+	Example: `# Temporarily withdraw a user's access during an incident
+tka admin suspend alice@example.com
+
+# Give the access back
+tka admin resume alice@example.com`,
+	Args: cobra.ExactArgs(0),
+}
+
+var cmdAdminSuspend = &cobra.Command{
+	Use:   "suspend <username>",
+	Short: "Suspend a user's session without revoking it",
+	Long: `Suspend a user's session. The user's cluster role binding is removed so their
+credentials stop working, but the session itself is kept. Resuming the session
+restores the access until the session would have expired anyway.`,
+	Example: `# Suspend a session
+tka admin suspend alice@example.com`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSessionSuspended(cmd.Context(), args[0], true)
+	},
+}
+
+var cmdAdminResume = &cobra.Command{
+	Use:   "resume <username>",
+	Short: "Resume a suspended session",
+	Long: `Resume a previously suspended session. The user's cluster role binding is
+restored; the session still expires at its original time.`,
+	Example: `# Resume a session
+tka admin resume alice@example.com`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSessionSuspended(cmd.Context(), args[0], false)
+	},
+}
+
+func setSessionSuspended(ctx context.Context, username string, suspended bool) error {
+	action := "resume"
+	if suspended {
+		action = "suspend"
+	}
+
+	uri := fmt.Sprintf("%s/%s/%s", api.AdminSessionsApiRoute, url.PathEscape(username), action)
+	_, _, err := doRequestAndDecode[models.UserLoginResponse](ctx, http.MethodPost, uri, nil, http.StatusOK)
+	if err != nil {
+		pretty_print.PrintError(err.Cause())
+		os.Exit(1)
+	}
+
+	if quiet := viper.GetBool("output.quiet"); !quiet {
+		pretty_print.PrintOk(fmt.Sprintf("Session of %s has been %sd", username, action))
+	}
+
+	return nil
+}
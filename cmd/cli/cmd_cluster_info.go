@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"net/http"
 	"os"
 
@@ -24,8 +23,8 @@ tka get cluster-info
 	RunE:      getClusterInfo,
 }
 
-func getClusterInfo(_ *cobra.Command, _ []string) error {
-	clusterInfo, _, err := doRequestAndDecode[models.TkaClusterInfo](context.Background(), http.MethodGet, api.ClusterInfoApiRoute, nil, http.StatusOK, http.StatusProcessing)
+func getClusterInfo(cmd *cobra.Command, _ []string) error {
+	clusterInfo, _, err := doRequestAndDecode[models.TkaClusterInfo](cmd.Context(), http.MethodGet, api.ClusterInfoApiRoute, nil, http.StatusOK, http.StatusProcessing)
 	if err != nil {
 		pretty_print.PrintError(err.Cause())
 		os.Exit(1)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	cmdExtend.Flags().Duration("by", 0, "Extend the session by this duration instead of the full period of your grant")
+}
+
+var cmdExtend = &cobra.Command{
+	Use:   "extend",
+	Short: "Extend your current session without signing in again",
+	Long: `Push the end of your current session forward. Your credentials stay the same,
+so kubeconfigs you already fetched keep working.
+
+Without --by the session is extended to the full period of your grant, counted
+from now. Extensions add up to at most the server's daily limit across all of
+your sessions, and never reach beyond the end of a scheduled shift the session
+was started for.`,
+	Example: `# Extend the session to the full period of your grant
+tka extend
+
+# Extend the session by 30 more minutes
+tka extend --by 30m`,
+	Args:      cobra.ExactArgs(0),
+	ValidArgs: []string{},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		by, _ := cmd.Flags().GetDuration("by")
+
+		loginInfo, _, err := doRequestAndDecode[models.UserLoginResponse](cmd.Context(), http.MethodPost, api.ExtendApiRoute, extendRequestBody(by), http.StatusOK)
+		if err != nil {
+			pretty_print.PrintError(err.Cause())
+			os.Exit(1)
+		}
+
+		if quiet := viper.GetBool("output.quiet"); !quiet {
+			pretty_print.PrintOk("Session extended")
+			pretty_print.PrintLoginInformation(loginInfo)
+		}
+
+		return nil
+	},
+}
+
+// extendRequestBody encodes the optional duration to extend the session by as the body of the extend request.
+func extendRequestBody(by time.Duration) io.Reader {
+	if by <= 0 {
+		return nil
+	}
+
+	data, _ := json.Marshal(models.UserExtendRequest{By: by.String()})
+	return bytes.NewReader(data)
+}
@@ -26,7 +26,10 @@ var cmdKubeconfig = &cobra.Command{
 This command downloads the kubeconfig from the TKA server and writes it to a temp file.
 It also sets KUBECONFIG for this process so that subsequent kubectl calls from this process
 use the new file.
-To update your interactive shell, export KUBECONFIG yourself`,
+To update your interactive shell, export KUBECONFIG yourself.
+
+If the server hands out tokens that expire before your session ends, a background process
+keeps the file up to date with a fresh token until you sign out or the session expires.`,
 	Example: `# Fetch and save your current ephemeral kubeconfig
 tka kubeconfig
 tka get kubeconfig
@@ -36,38 +39,74 @@ tka get kubeconfig
 	RunE:      getKubeconfig,
 }
 
-func getKubeconfig(_ *cobra.Command, _ []string) error {
+func getKubeconfig(cmd *cobra.Command, _ []string) error {
 	quiet := viper.GetBool("output.quiet")
-	kubecfg, err := fetchKubeConfig(quiet)
+	kubecfg, err := fetchKubeConfig(cmd.Context(), quiet)
 	if err != nil {
 		pretty_print.PrintError(err)
 		os.Exit(1)
 	}
 
-	file, err := serializeKubeconfig(kubecfg)
+	file, err := serializeKubeconfig(&kubecfg.Config)
 	if err != nil {
 		pretty_print.PrintError(err)
 		os.Exit(1)
 	}
 
+	startBackgroundRefresh(file, kubecfg.ExpiresAt, quiet)
 	printUseStatement(file, quiet)
 
 	return nil
 }
 
-func fetchKubeConfig(quiet bool) (*api.Config, humane.Error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// kubeconfigResult is a kubeconfig returned by the server and the expiry of the token in it.
+type kubeconfigResult struct {
+	Config api.Config
+	// ExpiresAt is zero if the token lives until the user signs out.
+	ExpiresAt time.Time
+}
+
+// requestKubeconfig fetches the kubeconfig from uri once. The returned status code lets callers tell
+// a finished session apart from transient failures.
+func requestKubeconfig(ctx context.Context, uri string) (*kubeconfigResult, int, humane.Error) {
+	resp, err := doRequest[api.Config](ctx, http.MethodGet, uri, nil, http.StatusOK)
+	if err != nil {
+		if resp == nil {
+			return nil, 0, err
+		}
+		return nil, resp.StatusCode, err
+	}
+
+	result := &kubeconfigResult{Config: *resp.Body}
+	if raw := resp.Header.Get(tkaApi.TokenExpiresAtHeader); raw != "" {
+		expiresAt, perr := time.Parse(time.RFC3339, raw)
+		if perr != nil {
+			return nil, resp.StatusCode, humane.Wrap(perr, "failed to parse token expiry", "the server returned an unexpected "+tkaApi.TokenExpiresAtHeader+" header")
+		}
+		result.ExpiresAt = expiresAt
+	}
+
+	return result, resp.StatusCode, nil
+}
+
+func fetchKubeConfig(ctx context.Context, quiet bool) (*kubeconfigResult, humane.Error) {
+	return fetchKubeConfigFrom(ctx, tkaApi.KubeconfigApiRoute, quiet)
+}
+
+// fetchKubeConfigFrom polls uri until the kubeconfig it serves is ready.
+func fetchKubeConfigFrom(ctx context.Context, uri string, quiet bool) (*kubeconfigResult, humane.Error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	pollFunc := func() (api.Config, humane.Error) {
-		if cfg, _, err := doRequestAndDecode[api.Config](ctx, http.MethodGet, tkaApi.KubeconfigApiRoute, nil, http.StatusOK); err == nil {
-			return *cfg, nil
+	pollFunc := func() (kubeconfigResult, humane.Error) {
+		if result, _, err := requestKubeconfig(ctx, uri); err == nil {
+			return *result, nil
 		} else {
-			return api.Config{}, err
+			return kubeconfigResult{}, err
 		}
 	}
 
-	operation := async_op2.NewSpinner[api.Config](pollFunc,
+	operation := async_op2.NewSpinner[kubeconfigResult](pollFunc,
 		async_op2.WithInProgressMessage("Waiting for kubeconfig to be ready..."),
 		async_op2.WithDoneMessage("Kubeconfig is ready."),
 		async_op2.WithFailedMessage("Fetching kubeconfig failed."),
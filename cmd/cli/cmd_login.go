@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"os"
 	"time"
@@ -13,6 +16,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
+func init() {
+	for _, cmd := range []*cobra.Command{cmdSignIn, cmdReauth} {
+		addReasonFlag(cmd)
+	}
+}
+
+// addReasonFlag adds the --reason flag to a command that signs in.
+func addReasonFlag(cmd *cobra.Command) {
+	cmd.Flags().String("reason", "", "Reason for signing in, e.g. a ticket reference (required for some roles)")
+}
+
 var cmdGetSignIn = &cobra.Command{
 	Use:     "login",
 	Aliases: []string{"signin"},
@@ -24,7 +38,7 @@ tka get login`,
 	Args:      cobra.ExactArgs(0),
 	ValidArgs: []string{},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		loginInfo, code, err := doRequestAndDecode[models.UserLoginResponse](context.Background(), http.MethodGet, api.LoginApiRoute, nil, http.StatusOK, http.StatusProcessing)
+		loginInfo, code, err := doRequestAndDecode[models.UserLoginResponse](cmd.Context(), http.MethodGet, api.LoginApiRoute, nil, http.StatusOK, http.StatusProcessing)
 		if err != nil {
 			pretty_print.PrintError(err.Cause())
 			os.Exit(1)
@@ -37,14 +51,16 @@ tka get login`,
 	},
 }
 
-func signIn(quiet bool) (string, error) {
-	loginInfo, _, err := doRequestAndDecode[models.UserLoginResponse](context.Background(), http.MethodPost, api.LoginApiRoute, nil, http.StatusCreated, http.StatusAccepted)
+// signIn signs the user in and writes the kubeconfig to a temporary file. It returns the file
+// and the expiry of the token in it, which is zero if the token lives as long as the session.
+func signIn(ctx context.Context, quiet bool, reason string) (string, time.Time, error) {
+	loginInfo, _, err := doRequestAndDecode[models.UserLoginResponse](ctx, http.MethodPost, api.LoginApiRoute, loginRequestBody(reason), http.StatusCreated, http.StatusAccepted)
 	if err != nil {
 		// Unwrap to get the original cause for cleaner error messages
 		if err.Cause() != nil {
-			return "", humane.Wrap(err.Cause(), "sign-in failed", "ensure you are connected to the Tailscale network", "check that the TKA server is running")
+			return "", time.Time{}, humane.Wrap(err.Cause(), "sign-in failed", "ensure you are connected to the Tailscale network", "check that the TKA server is running")
 		}
-		return "", humane.Wrap(err, "sign-in failed", "ensure you are connected to the Tailscale network", "check that the TKA server is running")
+		return "", time.Time{}, humane.Wrap(err, "sign-in failed", "ensure you are connected to the Tailscale network", "check that the TKA server is running")
 	}
 
 	if !quiet {
@@ -52,17 +68,34 @@ func signIn(quiet bool) (string, error) {
 		pretty_print.PrintLoginInformation(loginInfo)
 	}
 
+	// Sign-ins ahead of a scheduled shift are only provisioned once the shift begins
+	if loginInfo.NotBefore != "" {
+		return "", time.Time{}, humane.New("access starts at "+loginInfo.NotBefore,
+			"your sign-in for the upcoming shift is registered",
+			"run 'tka kubeconfig' once the shift has started to fetch your credentials")
+	}
+
 	time.Sleep(100 * time.Millisecond) //nolint:golint-sl // brief delay for server processing
 
-	kubecfg, err := fetchKubeConfig(quiet)
+	kubecfg, err := fetchKubeConfig(ctx, quiet)
 	if err != nil {
-		return "", humane.Wrap(err, "failed to fetch kubeconfig after successful sign-in", "try running 'tka login' again or check server connectivity")
+		return "", time.Time{}, humane.Wrap(err, "failed to fetch kubeconfig after successful sign-in", "try running 'tka login' again or check server connectivity")
 	}
 
-	file, err := serializeKubeconfig(kubecfg)
+	file, err := serializeKubeconfig(&kubecfg.Config)
 	if err != nil {
-		return "", err // already wrapped by serializeKubeconfig
+		return "", time.Time{}, err // already wrapped by serializeKubeconfig
+	}
+
+	return file, kubecfg.ExpiresAt, nil
+}
+
+// loginRequestBody encodes the optional reason for signing in as the body of the login request.
+func loginRequestBody(reason string) io.Reader {
+	if reason == "" {
+		return nil
 	}
 
-	return file, nil
+	data, _ := json.Marshal(models.UserLoginRequest{Reason: reason})
+	return bytes.NewReader(data)
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Output formats of `tka login --ci`.
+const (
+	ciFormatKubeconfig     = "kubeconfig"
+	ciFormatExecCredential = "exec-credential"
+)
+
+func init() {
+	cmdSignIn.Flags().Bool("ci", false, "Sign in non-interactively and print the credentials to stdout (for pipelines)")
+	cmdSignIn.Flags().String("ci-format", ciFormatKubeconfig, "Output format for --ci: kubeconfig or exec-credential")
+}
+
+// signInCI signs in without any interactive output and writes the credentials to out, either
+// as a kubeconfig or as an ExecCredential for use as a kubectl exec credential plugin.
+// Nothing is written to disk and no background refresh is started.
+func signInCI(ctx context.Context, out io.Writer, format, reason string) humane.Error {
+	if format != ciFormatKubeconfig && format != ciFormatExecCredential {
+		return humane.New("unknown --ci-format "+format, "use one of: "+ciFormatKubeconfig+", "+ciFormatExecCredential)
+	}
+
+	loginInfo, _, err := doRequestAndDecode[models.UserLoginResponse](ctx, http.MethodPost, api.LoginApiRoute, loginRequestBody(reason), http.StatusCreated, http.StatusAccepted)
+	if err != nil {
+		return humane.Wrap(err, "sign-in failed", "check that a capability rule in the Tailscale ACL is scoped to this device's tags")
+	}
+
+	kubecfg, err := fetchKubeConfig(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	if format == ciFormatKubeconfig {
+		data, err := clientcmd.Write(kubecfg.Config)
+		if err != nil {
+			return humane.Wrap(err, "failed to serialize kubeconfig", "this is likely a bug; please report it")
+		}
+		if _, err := out.Write(data); err != nil {
+			return humane.Wrap(err, "failed to write kubeconfig", "check that stdout is writable")
+		}
+		return nil
+	}
+
+	expiresAt := kubecfg.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt, _ = time.Parse(time.RFC3339, loginInfo.Until)
+	}
+
+	cred, err := newExecCredential(&kubecfg.Config, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(out).Encode(cred); err != nil {
+		return humane.Wrap(err, "failed to write ExecCredential", "check that stdout is writable")
+	}
+	return nil
+}
+
+// newExecCredential wraps the token of the kubeconfig's current context in an ExecCredential.
+func newExecCredential(kubecfg *clientcmdapi.Config, expiresAt time.Time) (*clientauthv1.ExecCredential, humane.Error) {
+	kubeCtx, ok := kubecfg.Contexts[kubecfg.CurrentContext]
+	if !ok {
+		return nil, humane.New("kubeconfig has no current context", "this is likely a bug in the TKA server; please report it")
+	}
+
+	authInfo, ok := kubecfg.AuthInfos[kubeCtx.AuthInfo]
+	if !ok || authInfo.Token == "" {
+		return nil, humane.New("kubeconfig has no token for "+kubeCtx.AuthInfo, "this is likely a bug in the TKA server; please report it")
+	}
+
+	status := &clientauthv1.ExecCredentialStatus{Token: authInfo.Token}
+	if !expiresAt.IsZero() {
+		status.ExpirationTimestamp = &metav1.Time{Time: expiresAt}
+	}
+
+	return &clientauthv1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: clientauthv1.SchemeGroupVersion.String(),
+			Kind:       "ExecCredential",
+		},
+		Status: status,
+	}, nil
+}
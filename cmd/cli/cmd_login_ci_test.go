@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	tkaApi "github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func newCITestServer(t *testing.T, tokenExpiresAt time.Time) {
+	t.Helper()
+
+	kubecfg := api.Config{
+		CurrentContext: "tka-context-ci",
+		Contexts:       map[string]*api.Context{"tka-context-ci": {Cluster: "tka-cluster", AuthInfo: "tka-user-ci"}},
+		AuthInfos:      map[string]*api.AuthInfo{"tka-user-ci": {Token: "ci-token"}},
+		Clusters:       map[string]*api.Cluster{"tka-cluster": {Server: "https://127.0.0.1:6443"}},
+	}
+
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case tkaApi.ApiRouteV1Alpha1 + tkaApi.LoginApiRoute:
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(models.NewUserLoginResponse("tag-ci.runner-1", "edit", time.Now().Add(time.Hour).Format(time.RFC3339)))
+		case tkaApi.ApiRouteV1Alpha1 + tkaApi.KubeconfigApiRoute:
+			if !tokenExpiresAt.IsZero() {
+				w.Header().Set(tkaApi.TokenExpiresAtHeader, tokenExpiresAt.Format(time.RFC3339))
+			}
+			_ = json.NewEncoder(w).Encode(kubecfg)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestSignInCI_Kubeconfig(t *testing.T) {
+	newCITestServer(t, time.Time{})
+
+	var out bytes.Buffer
+	require.Nil(t, signInCI(context.Background(), &out, ciFormatKubeconfig, ""))
+
+	cfg, err := clientcmd.Load(out.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, "tka-context-ci", cfg.CurrentContext)
+	require.Equal(t, "ci-token", cfg.AuthInfos["tka-user-ci"].Token)
+}
+
+func TestSignInCI_ExecCredential(t *testing.T) {
+	tokenExpiresAt := time.Now().Add(15 * time.Minute).Truncate(time.Second)
+	newCITestServer(t, tokenExpiresAt)
+
+	var out bytes.Buffer
+	require.Nil(t, signInCI(context.Background(), &out, ciFormatExecCredential, ""))
+
+	var cred clientauthv1.ExecCredential
+	require.NoError(t, json.Unmarshal(out.Bytes(), &cred))
+	require.Equal(t, "ExecCredential", cred.Kind)
+	require.Equal(t, "client.authentication.k8s.io/v1", cred.APIVersion)
+	require.Equal(t, "ci-token", cred.Status.Token)
+	require.True(t, tokenExpiresAt.Equal(cred.Status.ExpirationTimestamp.Time))
+}
+
+func TestSignInCI_UnknownFormat(t *testing.T) {
+	err := signInCI(context.Background(), &bytes.Buffer{}, "yaml", "")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "unknown --ci-format")
+}
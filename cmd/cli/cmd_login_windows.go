@@ -11,30 +11,53 @@ import (
 )
 
 var cmdSignIn = &cobra.Command{
-	Use:     "login [--quiet|-q] [--long|-l|--no-eval|-e]",
+	Use:     "login [--quiet|-q] [--long|-l|--no-eval|-e] [--reason <reason>] [--ci [--ci-format kubeconfig|exec-credential]]",
 	Aliases: []string{"signin", "auth"},
 	Short:   "Sign in and configure kubectl with temporary access",
 	Long: `Authenticate using your Tailscale identity and retrieve a temporary
 Kubernetes access token. This command automatically fetches your kubeconfig,
-writes it to a temporary file, sets the KUBECONFIG environment variable.`,
+writes it to a temporary file, sets the KUBECONFIG environment variable.
+
+With --ci the credentials are printed to stdout instead, either as a kubeconfig or
+as an ExecCredential for kubectl's exec credential plugin mechanism. Tagged devices
+such as CI runners sign in with a service identity derived from their tags.`,
 	Example: `# Sign in with user friendly output
 tka login --no-eval
 
 # Login and start using your session
 tka login
-kubectl get pods`,
+kubectl get pods
+
+# Sign in with a reason, e.g. referencing the ticket you are working on
+tka login --reason "INC-1234 investigating failing pods"
+
+# Sign in from a CI pipeline running on a tagged device
+tka login --ci > kubeconfig.yaml
+KUBECONFIG=kubeconfig.yaml kubectl get pods`,
 
 	Args:      cobra.ExactArgs(0),
 	ValidArgs: []string{},
 	Run: func(cmd *cobra.Command, args []string) {
+		if ci, _ := cmd.Flags().GetBool("ci"); ci {
+			format, _ := cmd.Flags().GetString("ci-format")
+			reason, _ := cmd.Flags().GetString("reason")
+			if err := signInCI(cmd.Context(), cmd.OutOrStdout(), format, reason); err != nil {
+				pretty_print.PrintError(err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		quiet := viper.GetBool("output.quiet")
 
-		file, err := signIn(quiet)
+		reason, _ := cmd.Flags().GetString("reason")
+		file, expiresAt, err := signIn(cmd.Context(), quiet, reason)
 		if err != nil {
 			pretty_print.PrintError(err)
 			os.Exit(1)
 		}
 
+		startBackgroundRefresh(file, expiresAt, quiet)
 		printUseStatement(file, quiet)
 	},
 }
@@ -9,7 +9,7 @@ import (
 )
 
 var cmdReauth = &cobra.Command{
-	Use:     "reauthenticate [--quiet|-q] [--long|-l|--no-eval|-e]",
+	Use:     "reauthenticate [--quiet|-q] [--long|-l|--no-eval|-e] [--reason <reason>]",
 	Aliases: []string{"reauth", "refresh"},
 	Short:   "Reauthenticate and configure kubectl with temporary access",
 	Long: `Reauthenticate by signing out and then signing in again to refresh your temporary access.
@@ -32,12 +32,14 @@ tka reauthenticate`,
 
 		quiet := viper.GetBool("output.quiet")
 
-		file, err := signIn(quiet)
+		reason, _ := cmd.Flags().GetString("reason")
+		file, expiresAt, err := signIn(cmd.Context(), quiet, reason)
 		if err != nil {
 			pretty_print.PrintError(err)
 			os.Exit(1)
 		}
 
+		startBackgroundRefresh(file, expiresAt, quiet)
 		printUseStatement(file, quiet)
 	},
 }
@@ -22,10 +22,11 @@ import (
 
 func init() {
 	cmdRoot.AddCommand(cmdShell)
+	addReasonFlag(cmdShell)
 }
 
 var cmdShell = &cobra.Command{
-	Use:   "shell",
+	Use:   "shell [--reason <reason>]",
 	Short: "Start a subshell with temporary Kubernetes access via Tailscale identity",
 	Long: `# Shell Command
 
@@ -38,6 +39,7 @@ This provides a clean and secure workflow:
 
 - Your existing shell environment remains untouched.
 - All Kubernetes operations inside the subshell use the temporary credentials.
+- Short-lived tokens are refreshed in the background while the subshell is open.
 - When you exit the subshell, the credentials are automatically revoked and
   the temporary kubeconfig file is deleted.
 
@@ -64,30 +66,45 @@ func forkShell(cmd *cobra.Command, args []string) error {
 	quiet := viper.GetBool("output.quiet")
 
 	// 1. Login and get kubeconfig path
-	kubeCfgPath, err := signIn(quiet)
+	reason, _ := cmd.Flags().GetString("reason")
+	kubeCfgPath, expiresAt, err := signIn(cmd.Context(), quiet, reason)
 	if err != nil {
 		return err //nolint:golint-sl // already wrapped by signIn
 	}
 
-	// 2. Run subshell
-	err = runShellWithContext(cmd.Context(), kubeCfgPath)
+	// 2. Run subshell, refreshing short-lived tokens while it is open
+	ctx, cancel := context.WithCancel(cmd.Context())
+	go refreshShellKubeconfig(ctx, kubeCfgPath, expiresAt, quiet)
+	err = runShellWithContext(ctx, kubeCfgPath)
+	cancel()
 
 	// 3. Do cleanup
-	cleanup(quiet, kubeCfgPath)
+	cleanup(cmd.Context(), quiet, kubeCfgPath)
 	if err != nil {
 		return humane.Wrap(err, "shell execution failed", "the subshell exited with an error")
 	}
 	return nil
 }
 
-func cleanup(quiet bool, kubeCfgPath string) {
+// refreshShellKubeconfig keeps the subshell's kubeconfig supplied with a valid token.
+func refreshShellKubeconfig(ctx context.Context, kubeCfgPath string, expiresAt time.Time, quiet bool) {
+	if expiresAt.IsZero() {
+		return
+	}
+
+	if err := refreshKubeconfig(ctx, kubeCfgPath, expiresAt); err != nil && !quiet {
+		pretty_print.PrintError(err)
+	}
+}
+
+func cleanup(ctx context.Context, quiet bool, kubeCfgPath string) {
 	var wg sync.WaitGroup
 
 	// sign out (revoke credentials)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := signOut(nil, nil); err != nil && !quiet {
+		if err := signOutWithContext(ctx); err != nil && !quiet {
 			pretty_print.PrintError(humane.Wrap(err, "failed to sign out cleanly", "your session may still be active; run 'tka logout' to sign out manually"))
 		}
 	}()
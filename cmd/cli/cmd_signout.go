@@ -34,8 +34,12 @@ tka signout --quiet`,
 	RunE:      signOut,
 }
 
-func signOut(_ *cobra.Command, _ []string) error {
-	_, _, err := doRequestAndDecode[models.UserLoginResponse](context.Background(), http.MethodPost, api.LogoutApiRoute, nil, http.StatusOK, http.StatusProcessing)
+func signOut(cmd *cobra.Command, _ []string) error {
+	return signOutWithContext(cmd.Context())
+}
+
+func signOutWithContext(ctx context.Context) error {
+	_, _, err := doRequestAndDecode[models.UserLoginResponse](ctx, http.MethodPost, api.LogoutApiRoute, nil, http.StatusOK, http.StatusProcessing)
 	if err != nil {
 		pretty_print.PrintError(err.Cause())
 		os.Exit(1)
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	tkaApi "github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// minRefreshMargin and maxRefreshMargin bound how long before expiry a token is replaced.
+	minRefreshMargin = 30 * time.Second
+	maxRefreshMargin = 2 * time.Minute
+
+	// refreshRetryInterval is how long to wait after a failed refresh before trying again.
+	refreshRetryInterval = 10 * time.Second
+)
+
+func init() {
+	cmdRefreshKubeconfig.Flags().String("expires-at", "", "RFC3339 expiry of the token currently in the kubeconfig")
+	cmdRoot.AddCommand(cmdRefreshKubeconfig)
+}
+
+// cmdRefreshKubeconfig is started in the background by login and kubeconfig to keep a
+// kubeconfig with a short-lived token up to date. It is not meant to be called by users.
+var cmdRefreshKubeconfig = &cobra.Command{
+	Use:    "refresh-kubeconfig <file> --expires-at <time>",
+	Short:  "Keep a kubeconfig file supplied with a valid token",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		raw, _ := cmd.Flags().GetString("expires-at")
+		expiresAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return humane.Wrap(err, "invalid --expires-at", "pass the token expiry as an RFC3339 timestamp")
+		}
+
+		if herr := refreshKubeconfig(cmd.Context(), args[0], expiresAt); herr != nil {
+			return herr
+		}
+		return nil
+	},
+}
+
+// startBackgroundRefresh spawns a detached `tka refresh-kubeconfig` process if the token in file
+// expires before the session does. The process outlives this command and exits once the session
+// ends or the file is removed.
+func startBackgroundRefresh(file string, expiresAt time.Time, quiet bool) {
+	if expiresAt.IsZero() {
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		if !quiet {
+			pretty_print.PrintError(humane.Wrap(err, "failed to start kubeconfig refresh", "run 'tka kubeconfig' again before "+expiresAt.Local().Format(time.Kitchen)))
+		}
+		return
+	}
+
+	args := []string{"refresh-kubeconfig", file,
+		"--expires-at", expiresAt.Format(time.RFC3339),
+		"--server", viper.GetString("tailscale.hostname"),
+		"--port", strconv.Itoa(viper.GetInt("tailscale.port")),
+	}
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		args = append(args, "--config", configFile)
+	}
+
+	refresher := exec.Command(executable, args...)
+	refresher.SysProcAttr = detachedProcAttr()
+	if err := refresher.Start(); err != nil {
+		if !quiet {
+			pretty_print.PrintError(humane.Wrap(err, "failed to start kubeconfig refresh", "run 'tka kubeconfig' again before "+expiresAt.Local().Format(time.Kitchen)))
+		}
+		return
+	}
+	_ = refresher.Process.Release()
+}
+
+// refreshKubeconfig re-fetches the kubeconfig shortly before its token expires and rewrites
+// path in place. It returns once ctx is done, path has been removed, or the session has ended.
+func refreshKubeconfig(ctx context.Context, path string, expiresAt time.Time) humane.Error {
+	next := refreshAt(expiresAt, time.Now())
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		result, status, err := requestKubeconfig(ctx, tkaApi.KubeconfigApiRoute)
+		switch {
+		case err == nil:
+			if werr := writeKubeconfig(path, &result.Config); werr != nil {
+				return werr
+			}
+			if result.ExpiresAt.IsZero() {
+				return nil
+			}
+			expiresAt = result.ExpiresAt
+			next = refreshAt(expiresAt, time.Now())
+
+		case sessionEnded(status):
+			return nil
+
+		case time.Now().After(expiresAt):
+			return humane.Wrap(err, "failed to refresh kubeconfig before the token expired", "run 'tka kubeconfig' to fetch a new token")
+
+		default:
+			next = time.Now().Add(refreshRetryInterval)
+		}
+	}
+}
+
+// refreshAt returns when a token expiring at expiresAt should be replaced: a fifth of its
+// remaining lifetime before expiry, bounded by minRefreshMargin and maxRefreshMargin.
+func refreshAt(expiresAt, now time.Time) time.Time {
+	margin := expiresAt.Sub(now) / 5
+	margin = max(margin, minRefreshMargin)
+	margin = min(margin, maxRefreshMargin)
+	return expiresAt.Add(-margin)
+}
+
+// sessionEnded reports whether the server rejected a kubeconfig request because the user is
+// no longer signed in, as opposed to a transient failure worth retrying.
+func sessionEnded(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound
+}
+
+// writeKubeconfig replaces the kubeconfig at path atomically so concurrent kubectl
+// invocations never read a partially written file.
+func writeKubeconfig(path string, kubecfg *api.Config) humane.Error {
+	out, err := clientcmd.Write(*kubecfg)
+	if err != nil {
+		return humane.Wrap(err, "failed to serialize kubeconfig", "this is likely a bug; please report it")
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".kubeconfig-*.yaml")
+	if err != nil {
+		return humane.Wrap(err, "failed to create temp kubeconfig", "check you have write permissions to "+filepath.Dir(path))
+	}
+	defer func() { _ = os.Remove(tempFile.Name()) }()
+
+	if _, err := tempFile.Write(out); err != nil {
+		_ = tempFile.Close()
+		return humane.Wrap(err, "failed to write temp kubeconfig", "check disk space and permissions")
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return humane.Wrap(err, "failed to write temp kubeconfig", "check disk space and permissions")
+	}
+
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return humane.Wrap(err, "failed to replace kubeconfig", "check you have write permissions to "+path)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/pkg/models"
+	tkaApi "github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestRefreshAt(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		margin   time.Duration
+	}{
+		{name: "fifth of lifetime", lifetime: 5 * time.Minute, margin: time.Minute},
+		{name: "capped for long tokens", lifetime: time.Hour, margin: maxRefreshMargin},
+		{name: "at least the minimum margin", lifetime: time.Minute, margin: minRefreshMargin},
+		{name: "already expired", lifetime: -time.Minute, margin: minRefreshMargin},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expiresAt := now.Add(tc.lifetime)
+			require.Equal(t, expiresAt.Add(-tc.margin), refreshAt(expiresAt, now))
+		})
+	}
+}
+
+// useTestServer points the CLI's REST client at handler for the duration of the test.
+func useTestServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	viper.Set("tailscale.hostname", host)
+	viper.Set("tailscale.port", portNum)
+	viper.Set("tailscale.tailnet", "")
+	t.Cleanup(viper.Reset)
+}
+
+func TestRefreshKubeconfig(t *testing.T) {
+	var calls atomic.Int32
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, tkaApi.ApiRouteV1Alpha1+tkaApi.KubeconfigApiRoute, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+
+		// First call hands out a token that is about to expire, then the session ends
+		if calls.Add(1) == 1 {
+			w.Header().Set(tkaApi.TokenExpiresAtHeader, time.Now().Add(time.Second).Format(time.RFC3339))
+			_ = json.NewEncoder(w).Encode(api.Config{CurrentContext: "refreshed"})
+			return
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(models.NewErrorResponse("User not signed in", nil))
+	})
+
+	path := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	require.Nil(t, writeKubeconfig(path, &api.Config{CurrentContext: "initial"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require.Nil(t, refreshKubeconfig(ctx, path, time.Now()))
+	require.Equal(t, int32(2), calls.Load())
+
+	cfg, err := clientcmd.LoadFromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "refreshed", cfg.CurrentContext)
+}
+
+func TestRefreshKubeconfig_StopsWhenFileRemoved(t *testing.T) {
+	var calls atomic.Int32
+	useTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	path := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.Nil(t, refreshKubeconfig(context.Background(), path, time.Now()))
+	require.Zero(t, calls.Load())
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// Process creation flags, see https://learn.microsoft.com/windows/win32/procthread/process-creation-flags
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+// detachedProcAttr starts the refresher without a console so it survives the terminal closing.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: createNewProcessGroup | detachedProcess, HideWindow: true}
+}
@@ -70,19 +70,35 @@ tka generate kubeconfig`,
 	// Sign out
 	cmdRoot.AddCommand(cmdSignout)
 	cmdRoot.AddCommand(cmdReauth)
+	cmdRoot.AddCommand(cmdExtend)
+	cmdRoot.AddCommand(cmdDelegate)
 
 	// Cluster info
 	cmdRoot.AddCommand(cmdClusterInfo)
 	cmdGet.AddCommand(cmdClusterInfo)
 
+	// Admin
+	cmdRoot.AddCommand(cmdAdmin)
+	cmdAdmin.AddCommand(cmdAdminSuspend)
+	cmdAdmin.AddCommand(cmdAdminResume)
+
 	if err := cmdRoot.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func getServerAddr() string {
-	hostname := viper.GetString("tailscale.hostname")
+// getServerAddrs returns the address of the server followed by the addresses of its HA replicas, which
+// the CLI fails over to if the server can't be reached.
+func getServerAddrs() []string {
+	addrs := []string{getServerAddr(viper.GetString("tailscale.hostname"))}
+	for _, replica := range viper.GetStringSlice("tailscale.ha.replicas") {
+		addrs = append(addrs, getServerAddr(replica))
+	}
+	return addrs
+}
+
+func getServerAddr(hostname string) string {
 	tailnet := viper.GetString("tailscale.tailnet")
 	apiPort := viper.GetInt("tailscale.port")
 	prefix := ""
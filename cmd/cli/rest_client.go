@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/sierrasoftworks/humane-errors-go"
 	"github.com/spechtlabs/tka/pkg/models"
 	"github.com/spechtlabs/tka/pkg/service/api"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // httpClient is a custom HTTP client with timeout for CLI requests.
@@ -20,6 +24,23 @@ var httpClient = &http.Client{
 }
 
 func doRequestAndDecode[T any](ctx context.Context, method, uri string, body io.Reader, expectedStatus ...int) (*T, int, humane.Error) {
+	resp, err := doRequest[T](ctx, method, uri, body, expectedStatus...)
+	if resp == nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.StatusCode, err
+}
+
+// apiResponse is a decoded API response together with the HTTP metadata it was sent with.
+type apiResponse[T any] struct {
+	Body       *T
+	StatusCode int
+	Header     http.Header
+}
+
+// doRequest performs an API request and decodes the response body. The returned apiResponse
+// carries the status code and headers even if the request failed with an unexpected status.
+func doRequest[T any](ctx context.Context, method, uri string, body io.Reader, expectedStatus ...int) (*apiResponse[T], humane.Error) {
 	// Allow 200 OK by default if no status codes are passed in
 	okStatus := map[int]bool{}
 	if len(expectedStatus) == 0 {
@@ -30,41 +51,83 @@ func doRequestAndDecode[T any](ctx context.Context, method, uri string, body io.
 		}
 	}
 
-	// Assemble the request URL
-	serverAddr := getServerAddr()
-	url := fmt.Sprintf("%s%s%s", serverAddr, api.ApiRouteV1Alpha1, uri)
-
-	// Create the request with context
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, 0, humane.Wrap(err, "failed to create request", "this indicates a bug in the CLI; please report it")
-	}
-
 	// Do the request
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, 0, humane.Wrap(err, "failed to perform request", "ensure tailscale is running and the TKA server is reachable")
+	resp, herr := sendRequest(ctx, method, uri, body)
+	if herr != nil {
+		return nil, herr
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	result := &apiResponse[T]{StatusCode: resp.StatusCode, Header: resp.Header}
+
 	// Grab the response
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, humane.Wrap(err, "failed to read response body", "the server may have closed the connection unexpectedly")
+		return result, humane.Wrap(err, "failed to read response body", "the server may have closed the connection unexpectedly")
 	}
 
 	// based on the HTTP response, handle the API error
 	if !okStatus[resp.StatusCode] {
-		return nil, resp.StatusCode, handleAPIError(resp, respBytes)
+		return result, handleAPIError(resp, respBytes)
 	}
 
 	// attempt parsing the response body
-	var result T
-	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return nil, resp.StatusCode, humane.Wrap(err, "failed to decode response body", "the server returned an unexpected response format")
+	var decoded T
+	if err := json.Unmarshal(respBytes, &decoded); err != nil {
+		return result, humane.Wrap(err, "failed to decode response body", "the server returned an unexpected response format")
 	}
 
-	return &result, resp.StatusCode, nil
+	result.Body = &decoded
+	return result, nil
+}
+
+// sendRequest sends the request to the server. If the server can't be reached, it is sent to the
+// HA replicas in turn. Requests that reached a server are never repeated.
+func sendRequest(ctx context.Context, method, uri string, body io.Reader) (*http.Response, humane.Error) {
+	// Every attempt needs its own copy of the body
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, humane.Wrap(err, "failed to read request body", "this indicates a bug in the CLI; please report it")
+		}
+	}
+
+	var lastErr error
+	for _, serverAddr := range getServerAddrs() {
+		// Assemble the request URL
+		url := fmt.Sprintf("%s%s%s", serverAddr, api.ApiRouteV1Alpha1, uri)
+
+		// Create the request with context
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return nil, humane.Wrap(err, "failed to create request", "this indicates a bug in the CLI; please report it")
+		}
+
+		// Hand the command's trace to the server (see startTrace)
+		propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !isUnreachable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, humane.Wrap(lastErr, "failed to perform request", "ensure tailscale is running and the TKA server is reachable")
+}
+
+// isUnreachable reports whether err means the request never reached a server, so it is safe to send it to another replica.
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 func handleAPIError(resp *http.Response, body []byte) humane.Error {
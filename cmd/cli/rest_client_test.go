@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tkaApi "github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDoRequest_PropagatesTraceContext(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	var traceParent string
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		_ = json.NewEncoder(w).Encode(models.TkaClusterInfo{})
+	})
+
+	_, _, herr := doRequestAndDecode[models.TkaClusterInfo](ctx, http.MethodGet, tkaApi.ClusterInfoApiRoute, nil)
+	require.Nil(t, herr)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceParent)
+}
+
+func TestDoRequest_FailsOverToReplicas(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(models.TkaClusterInfo{})
+	})
+
+	// The test server only listens on IPv4, so nothing answers on the IPv6 loopback
+	replica := viper.GetString("tailscale.hostname")
+	viper.Set("tailscale.hostname", "[::1]")
+	viper.Set("tailscale.ha.replicas", []string{"[::1]", replica, replica})
+
+	_, _, herr := doRequestAndDecode[models.TkaClusterInfo](context.Background(), http.MethodPost, tkaApi.ClusterInfoApiRoute, strings.NewReader("payload"))
+	require.Nil(t, herr)
+	require.Equal(t, int32(1), calls.Load())
+	require.Equal(t, []string{"payload"}, bodies)
+
+	// A replica that answered with an error is not asked again
+	_, status, herr := doRequestAndDecode[models.TkaClusterInfo](context.Background(), http.MethodGet, tkaApi.ClusterInfoApiRoute+"?fail=1", nil)
+	require.NotNil(t, herr)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, int32(2), calls.Load())
+
+	// Without any reachable replica the request fails
+	viper.Set("tailscale.ha.replicas", []string{"[::1]"})
+	_, _, herr = doRequestAndDecode[models.TkaClusterInfo](context.Background(), http.MethodGet, tkaApi.ClusterInfoApiRoute, nil)
+	require.NotNil(t, herr)
+	require.Contains(t, herr.Error(), "failed to perform request")
+	require.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/audit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func init() {
+	auditCmd.AddCommand(auditCorrelateCmd)
+}
+
+var (
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Work with Kubernetes audit logs",
+		Long:  `Tools for tracing requests in the API server's audit logs back to the people and sessions behind them.`,
+	}
+
+	auditCorrelateCmd = &cobra.Command{
+		Use:   "correlate <audit.log>",
+		Short: "Enrich an audit log with the TKA sessions behind its requests",
+		Long: `Read an audit log written by the API server's log backend (JSON lines) and print every event.
+
+Events made with the credentials of a TKA session get a "tka" field with the username and, while the session
+is still active, its login name, device, session ID, reason and role. These are read from the annotations of
+the session's ServiceAccount and from the TkaSignin in operator.namespace.
+
+Sessions that already ended only report the username, since their ServiceAccount is gone. Pass - to read
+the audit log from standard input.`,
+		Example: `# Enrich an audit log
+tka-server audit correlate /var/log/kubernetes/audit.log
+
+# Show who ran kubectl exec in the last events
+tail -n 1000 audit.log | tka-server audit correlate - | jq 'select(.tka and .objectRef.subresource == "exec") | .tka'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := correlateAuditLog(cmd, args[0]); err != nil {
+				pretty_print.PrintError(err)
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+)
+
+// correlateAuditLog enriches the audit log in file with the sessions currently known to the cluster.
+func correlateAuditLog(cmd *cobra.Command, file string) humane.Error {
+	in := cmd.InOrStdin()
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return humane.Wrap(err, "Failed to open "+file, "check that the file exists and is readable")
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+
+	c, herr := newAuditClient()
+	if herr != nil {
+		return herr
+	}
+
+	namespace := viper.GetString("operator.namespace")
+	sessions, herr := k8s.ListSessions(cmd.Context(), c, namespace)
+	if herr != nil {
+		return herr
+	}
+
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	skipped, herr := audit.NewCorrelator(namespace, sessions).Enrich(in, out)
+	if err := out.Flush(); err != nil && herr == nil {
+		herr = humane.Wrap(err, "Failed to write enriched audit events", "check that the output is writable")
+	}
+	if herr != nil {
+		return herr
+	}
+
+	if skipped > 0 {
+		printSkipped(cmd.ErrOrStderr(), skipped)
+	}
+	return nil
+}
+
+// newAuditClient creates a client that reads ServiceAccounts and TkaSignins.
+func newAuditClient() (client.Reader, humane.Error) {
+	restCfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, humane.Wrap(err, "failed to get Kubernetes rest config", "set KUBECONFIG or pass --kubeconfig to point at the cluster")
+	}
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, humane.Wrap(err, "failed to add corev1 to scheme", "this is an internal error; please report it")
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, humane.Wrap(err, "failed to add v1alpha1 to scheme", "this is an internal error; please report it")
+	}
+
+	c, err := client.New(restCfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, humane.Wrap(err, "failed to create Kubernetes client", "check cluster connectivity and authentication")
+	}
+	return c, nil
+}
+
+func printSkipped(w io.Writer, skipped int) {
+	_, _ = fmt.Fprintf(w, "Skipped %d lines that are not audit events\n", skipped)
+}
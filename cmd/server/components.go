@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spf13/viper"
+)
+
+const (
+	// componentAPI serves the TKA API on the tailnet. It only creates and updates TkaSignins, which the
+	// operator provisions, so it can run with any number of replicas.
+	componentAPI = "api"
+	// componentOperator runs the leader-elected controller manager that provisions the TkaSignins.
+	componentOperator = "operator"
+)
+
+// components are the parts of TKA that run in this process. Every component serves the health port.
+type components struct {
+	api      bool
+	operator bool
+}
+
+// getComponents parses server.components. Both components run if it is empty.
+func getComponents() (components, humane.Error) {
+	var c components
+
+	for _, entry := range viper.GetStringSlice("server.components") {
+		// Environment variables arrive as a single comma separated value
+		for _, name := range strings.Split(entry, ",") {
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case componentAPI:
+				c.api = true
+			case componentOperator:
+				c.operator = true
+			case "":
+			default:
+				return components{}, humane.New(fmt.Sprintf("unknown component %q", name),
+					fmt.Sprintf("set server.components (flag --components) to %q, %q or both", componentAPI, componentOperator))
+			}
+		}
+	}
+
+	if !c.api && !c.operator {
+		return components{api: true, operator: true}, nil
+	}
+	return c, nil
+}
+
+// String lists the components for logs, e.g. "api,operator".
+func (c components) String() string {
+	var names []string
+	if c.api {
+		names = append(names, componentAPI)
+	}
+	if c.operator {
+		names = append(names, componentOperator)
+	}
+	return strings.Join(names, ",")
+}
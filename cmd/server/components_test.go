@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetComponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    components
+		wantErr string
+	}{
+		{name: "default runs both", value: nil, want: components{api: true, operator: true}},
+		{name: "api", value: []string{"api"}, want: components{api: true}},
+		{name: "operator", value: []string{"operator"}, want: components{operator: true}},
+		{name: "both", value: []string{"api", "operator"}, want: components{api: true, operator: true}},
+		{name: "environment variable", value: "Operator, api", want: components{api: true, operator: true}},
+		{name: "unknown", value: []string{"api", "webhook"}, wantErr: `unknown component "webhook"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("server.components", tt.value)
+			t.Cleanup(func() { viper.Set("server.components", nil) })
+
+			got, err := getComponents()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
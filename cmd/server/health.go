@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	koperator "github.com/spechtlabs/tka/pkg/operator"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	ts "github.com/spechtlabs/tka/pkg/tshttp"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// livenessChecks only verify that the process serves requests. Dependencies belong in the readiness checks,
+// so an unreachable API server or tailnet doesn't get the pod restarted.
+func livenessChecks() map[string]healthz.Checker {
+	return map[string]healthz.Checker{
+		"ping": healthz.Ping,
+	}
+}
+
+// readinessChecks combines the checks of the components that run in this process: the operator's if operator
+// is set, the tsnet server's if tsServer is set, and the Kubernetes API and cluster info, which both need.
+func readinessChecks(clientset kubernetes.Interface, operator *koperator.KubeOperator, tsServer ts.TailscaleServer, clusterInfo *models.ClusterInfoStore) map[string]healthz.Checker {
+	checks := livenessChecks()
+
+	if clientset != nil {
+		checks["kubernetes-api"] = healthzChecker(koperator.KubernetesAPICheck(clientset))
+	}
+
+	if operator != nil {
+		for name, check := range operator.ReadinessChecks() {
+			checks[name] = healthzChecker(check)
+		}
+	}
+
+	if tsServer != nil {
+		checks["tailscale"] = func(_ *http.Request) error {
+			if !tsServer.IsConnected() {
+				return fmt.Errorf("tailscale server is in %s state", tsServer.BackendState())
+			}
+			return nil
+		}
+	}
+
+	checks["cluster-info"] = func(_ *http.Request) error {
+		if info := clusterInfo.Load(); info == nil || info.ServerURL == "" {
+			return errors.New("cluster info is not loaded")
+		}
+		return nil
+	}
+
+	return checks
+}
+
+// failedCheck runs checks in the order of their names and returns the error of the first one that fails
+func failedCheck(r *http.Request, checks map[string]healthz.Checker) error {
+	for _, name := range slices.Sorted(maps.Keys(checks)) {
+		if err := checks[name](r); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// healthzChecker runs check with the context of the probe request
+func healthzChecker(check koperator.HealthCheck) healthz.Checker {
+	return func(r *http.Request) error { return check(r.Context()) }
+}
+
+// mountHealthz serves the aggregated checks on path and each check on path/<name>. The aggregate accepts
+// ?verbose to list every check and ?exclude=<name> to skip one; a single check reports why it failed.
+func mountHealthz(router *gin.Engine, path string, checks map[string]healthz.Checker) {
+	handler := gin.WrapH(http.StripPrefix(path, &healthz.Handler{Checks: checks}))
+	router.GET(path, handler)
+	router.GET(path+"/:check", handler)
+}
+
+// mountPprof serves the Go runtime profiles under /debug/pprof
+func mountPprof(router *gin.Engine) {
+	router.GET("/debug/pprof/*profile", func(c *gin.Context) {
+		switch strings.TrimPrefix(c.Param("profile"), "/") {
+		case "cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			// Index serves the named profiles (heap, goroutine, ...) and the overview page
+			pprof.Index(c.Writer, c.Request)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTailscaleServer struct {
+	state string
+}
+
+func (f fakeTailscaleServer) IsConnected() bool    { return f.state == "Running" }
+func (f fakeTailscaleServer) BackendState() string { return f.state }
+
+func serveHealth(t *testing.T, srv *http.Server, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHealthServer_Probes(t *testing.T) {
+	clusterInfo := &models.TkaClusterInfo{ServerURL: "https://api.example.com:6443"}
+
+	tests := []struct {
+		name        string
+		tsServer    fakeTailscaleServer
+		clusterInfo *models.TkaClusterInfo
+		path        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "liveness ignores dependencies",
+			tsServer:    fakeTailscaleServer{state: "NeedsLogin"},
+			clusterInfo: nil,
+			path:        "/livez",
+			wantStatus:  http.StatusOK,
+			wantBody:    "ok",
+		},
+		{
+			name:        "ready",
+			tsServer:    fakeTailscaleServer{state: "Running"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz",
+			wantStatus:  http.StatusOK,
+			wantBody:    "ok",
+		},
+		{
+			name:        "verbose lists every check",
+			tsServer:    fakeTailscaleServer{state: "Running"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz?verbose",
+			wantStatus:  http.StatusOK,
+			wantBody:    "[+]cluster-info ok\n[+]ping ok\n[+]tailscale ok\nhealthz check passed\n",
+		},
+		{
+			name:        "tailnet disconnected",
+			tsServer:    fakeTailscaleServer{state: "NeedsLogin"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz",
+			wantStatus:  http.StatusInternalServerError,
+			wantBody:    "[+]cluster-info ok\n[+]ping ok\n[-]tailscale failed: reason withheld\nhealthz check failed\n",
+		},
+		{
+			name:        "excluded check",
+			tsServer:    fakeTailscaleServer{state: "NeedsLogin"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz?exclude=tailscale",
+			wantStatus:  http.StatusOK,
+			wantBody:    "ok",
+		},
+		{
+			name:        "single check reports the reason",
+			tsServer:    fakeTailscaleServer{state: "NeedsLogin"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz/tailscale",
+			wantStatus:  http.StatusInternalServerError,
+			wantBody:    "internal server error: tailscale server is in NeedsLogin state\n",
+		},
+		{
+			name:        "missing cluster info",
+			tsServer:    fakeTailscaleServer{state: "Running"},
+			clusterInfo: nil,
+			path:        "/readyz/cluster-info",
+			wantStatus:  http.StatusInternalServerError,
+			wantBody:    "internal server error: cluster info is not loaded\n",
+		},
+		{
+			name:        "unknown check",
+			tsServer:    fakeTailscaleServer{state: "Running"},
+			clusterInfo: clusterInfo,
+			path:        "/readyz/nope",
+			wantStatus:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newHealthServer(tt.tsServer, nil, nil, readinessChecks(nil, nil, tt.tsServer, models.NewClusterInfoStore(tt.clusterInfo)))
+
+			rec := serveHealth(t, srv, tt.path)
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				require.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHealthServer_OperatorOnly(t *testing.T) {
+	clusterInfo := &models.TkaClusterInfo{ServerURL: "https://api.example.com:6443"}
+
+	// Without the API there is no tsnet server to wait for
+	srv := newHealthServer(nil, nil, nil, readinessChecks(nil, nil, nil, models.NewClusterInfoStore(clusterInfo)))
+
+	rec := serveHealth(t, srv, "/readyz?verbose")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "[+]cluster-info ok\n[+]ping ok\nhealthz check passed\n", rec.Body.String())
+	require.Equal(t, http.StatusNotFound, serveHealth(t, srv, "/readyz/tailscale").Code)
+
+	rec = serveHealth(t, srv, "/ready")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status": "ready", "reason": "all readiness checks passed"}`, rec.Body.String())
+}
+
+func TestHealthServer_Ready(t *testing.T) {
+	clusterInfo := &models.TkaClusterInfo{ServerURL: "https://api.example.com:6443"}
+
+	tests := []struct {
+		name        string
+		tsServer    fakeTailscaleServer
+		clusterInfo *models.TkaClusterInfo
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "ready",
+			tsServer:    fakeTailscaleServer{state: "Running"},
+			clusterInfo: clusterInfo,
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"status": "ready", "reason": "tailscale server is Running state"}`,
+		},
+		{
+			name:        "tailnet disconnected",
+			tsServer:    fakeTailscaleServer{state: "NeedsLogin"},
+			clusterInfo: clusterInfo,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    `{"status": "not ready", "reason": "tailscale: tailscale server is in NeedsLogin state"}`,
+		},
+		{
+			name:       "missing cluster info",
+			tsServer:   fakeTailscaleServer{state: "Running"},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status": "not ready", "reason": "cluster-info: cluster info is not loaded"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newHealthServer(tt.tsServer, nil, nil, readinessChecks(nil, nil, tt.tsServer, models.NewClusterInfoStore(tt.clusterInfo)))
+
+			rec := serveHealth(t, srv, "/ready")
+			require.Equal(t, tt.wantStatus, rec.Code)
+			require.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestHealthServer_Pprof(t *testing.T) {
+	tsServer := fakeTailscaleServer{state: "Running"}
+
+	srv := newHealthServer(tsServer, nil, nil, readinessChecks(nil, nil, tsServer, models.NewClusterInfoStore(nil)))
+	require.Equal(t, http.StatusNotFound, serveHealth(t, srv, "/debug/pprof/").Code)
+
+	viper.Set("health.pprof", true)
+	t.Cleanup(func() { viper.Set("health.pprof", false) })
+
+	srv = newHealthServer(tsServer, nil, nil, readinessChecks(nil, nil, tsServer, models.NewClusterInfoStore(nil)))
+	require.Equal(t, http.StatusOK, serveHealth(t, srv, "/debug/pprof/").Code)
+	require.Equal(t, http.StatusOK, serveHealth(t, srv, "/debug/pprof/goroutine?debug=1").Code)
+	require.Equal(t, http.StatusOK, serveHealth(t, srv, "/debug/pprof/cmdline").Code)
+}
+
+func TestGetAuditReceiver(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	receiver, err := getAuditReceiver(nil)
+	require.Nil(t, err)
+	require.Nil(t, receiver)
+
+	// The health port is reachable by anyone in the cluster, so events must be authenticated
+	viper.Set("audit.enabled", true)
+	receiver, err = getAuditReceiver(nil)
+	require.NotNil(t, err)
+	require.Nil(t, receiver)
+	require.Equal(t, "audit.enabled requires audit.token", err.Error())
+
+	viper.Set("audit.token", "secret")
+	receiver, err = getAuditReceiver(nil)
+	require.Nil(t, err)
+	require.NotNil(t, receiver)
+}
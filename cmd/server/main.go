@@ -11,6 +11,8 @@ func main() {
 	cmdRoot := cmd.NewServerRootCmd()
 
 	cmdRoot.AddCommand(serveCmd)
+	cmdRoot.AddCommand(templateCmd)
+	cmdRoot.AddCommand(auditCmd)
 
 	err := cmdRoot.Execute()
 	if err != nil {
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"tailscale.com/tailcfg"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+const (
+	reloadSourceFile      = "file"
+	reloadSourceConfigMap = "configmap"
+
+	reloadApplied   = "applied"
+	reloadUnchanged = "unchanged"
+	reloadRejected  = "rejected"
+)
+
+// liveSettingPrefixes are the settings that take effect while the server runs, as lower-case viper keys.
+// Changes to any other setting are only applied by a restart.
+var liveSettingPrefixes = []string{
+	"clusterinfo.",
+	"tailscale.capname",
+	"api.retryafterseconds",
+}
+
+// restartSettingPrefixes are the settings below liveSettingPrefixes that still need a restart, as lower-case viper keys.
+// The ConfigMap watched and the keys read from it are the ones configured at startup.
+var restartSettingPrefixes = []string{
+	"clusterinfo.configmapref.",
+}
+
+// configGenerationGauge reports the generation of the configuration the server runs with
+var configGenerationGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tka_config_generation",
+		Help: "Generation of the configuration the server runs with, starting at 1 and incremented by every applied reload",
+	},
+)
+
+// configReloads counts the reloads of the configuration by what triggered them and their result
+var configReloads = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tka_config_reloads_total",
+		Help: "Total number of configuration reloads by source (file, configmap) and result (applied, unchanged, rejected)",
+	},
+	[]string{
+		"source",
+		"result",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(configGenerationGauge)
+	prometheus.MustRegister(configReloads)
+}
+
+// configReloader applies changes to the config file and to the ConfigMap of clusterInfo.configMapRef while
+// the server runs. A reload is validated first and applied as a whole, or not at all. The cluster info,
+// tailscale.capName and api.retryAfterSeconds take effect at once, everything else after a restart.
+type configReloader struct {
+	clientset    kubernetes.Interface
+	configMapRef configMapRef
+	clusterInfo  *models.ClusterInfoStore
+
+	capName           atomic.Pointer[tailcfg.PeerCapability]
+	retryAfterSeconds atomic.Int64
+
+	// mu serializes reloads, which arrive from the file watcher and the informer
+	mu         sync.Mutex
+	generation uint64
+	live       liveSettings
+	settings   map[string]any
+}
+
+// liveSettings are the settings besides the cluster info that a reload applies.
+type liveSettings struct {
+	capName           tailcfg.PeerCapability
+	retryAfterSeconds int
+}
+
+// newConfigReloader starts at generation 1 with clusterInfo, loaded with ref, and the current settings.
+func newConfigReloader(clientset kubernetes.Interface, ref configMapRef, clusterInfo *models.TkaClusterInfo) (*configReloader, humane.Error) {
+	live, err := getLiveSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &configReloader{
+		clientset:    clientset,
+		configMapRef: ref,
+		clusterInfo:  models.NewClusterInfoStore(clusterInfo),
+		generation:   1,
+		live:         live,
+		settings:     currentSettings(),
+	}
+	r.storeLiveSettings(live)
+	configGenerationGauge.Set(float64(r.generation))
+
+	return r, nil
+}
+
+// getLiveSettings reads and validates the settings that a reload applies.
+func getLiveSettings() (liveSettings, humane.Error) {
+	live := liveSettings{
+		capName:           tailcfg.PeerCapability(viper.GetString("tailscale.capName")),
+		retryAfterSeconds: viper.GetInt("api.retryAfterSeconds"),
+	}
+
+	if live.capName == "" {
+		return liveSettings{}, humane.New("tailscale.capName must not be empty", "set the name of the capability TKA reads from the tailnet policy, e.g. specht-labs.de/cap/tka")
+	}
+	if live.retryAfterSeconds <= 0 {
+		return liveSettings{}, humane.New("api.retryAfterSeconds must be positive", "set how many seconds clients wait before polling again, e.g. 1")
+	}
+
+	return live, nil
+}
+
+func (r *configReloader) storeLiveSettings(live liveSettings) {
+	r.capName.Store(&live.capName)
+	r.retryAfterSeconds.Store(int64(live.retryAfterSeconds))
+}
+
+// ClusterInfo returns the store that always holds the current cluster info.
+func (r *configReloader) ClusterInfo() *models.ClusterInfoStore {
+	return r.clusterInfo
+}
+
+// CapName returns the current tailscale.capName.
+func (r *configReloader) CapName() tailcfg.PeerCapability {
+	return *r.capName.Load()
+}
+
+// RetryAfterSeconds returns the current api.retryAfterSeconds.
+func (r *configReloader) RetryAfterSeconds() int {
+	return int(r.retryAfterSeconds.Load())
+}
+
+// ClusterLabels returns the labels of the current cluster info.
+func (r *configReloader) ClusterLabels() map[string]string {
+	if info := r.clusterInfo.Load(); info != nil {
+		return info.Labels
+	}
+	return nil
+}
+
+// Generation returns the generation of the configuration the server runs with.
+func (r *configReloader) Generation() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// Watch reloads the configuration whenever the config file or the ConfigMap of clusterInfo.configMapRef
+// changes, until ctx is done. The ConfigMap is the one configured at startup.
+func (r *configReloader) Watch(ctx context.Context) humane.Error {
+	var sources []string
+
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(func(_ fsnotify.Event) { r.reloadFile(ctx) })
+		viper.WatchConfig()
+		sources = append(sources, viper.ConfigFileUsed())
+	}
+
+	if ref := r.configMapRef; ref.enabled {
+		if err := r.watchConfigMap(ctx, ref.namespace, ref.name); err != nil {
+			return err
+		}
+		sources = append(sources, "configmap/"+ref.namespace+"/"+ref.name)
+	}
+
+	otelzap.L().InfoContext(ctx, "Watching configuration for changes",
+		zap.Strings("sources", sources),
+		zap.Uint64("config_generation", r.Generation()),
+	)
+	return nil
+}
+
+// watchConfigMap reloads the cluster info whenever the ConfigMap namespace/name changes
+func (r *configReloader) watchConfigMap(ctx context.Context, namespace, name string) humane.Error {
+	factory := informers.NewSharedInformerFactoryWithOptions(r.clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	_, err := factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { r.onConfigMap(ctx, name, obj) },
+		UpdateFunc: func(_, obj any) { r.onConfigMap(ctx, name, obj) },
+		DeleteFunc: func(_ any) {
+			otelzap.L().WarnContext(ctx, "cluster info ConfigMap was deleted, keeping the current cluster info", //nolint:golint-sl // informer callback has no request to attach to
+				zap.String("namespace", namespace),
+				zap.String("name", name),
+				zap.Uint64("config_generation", r.Generation()),
+			)
+		},
+	})
+	if err != nil {
+		return humane.Wrap(err, "failed to watch the cluster info ConfigMap", "this is an internal error; please report it")
+	}
+
+	factory.Start(ctx.Done())
+	return nil
+}
+
+// onConfigMap reloads the cluster info from cm. It runs on the informer goroutine, where reading the
+// configuration would race with the file watcher, so the labels and settings are the ones the server runs with.
+func (r *configReloader) onConfigMap(ctx context.Context, name string, obj any) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.Name != name {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var labels map[string]string
+	if current := r.clusterInfo.Load(); current != nil {
+		labels = current.Labels
+	}
+
+	clusterInfo, err := clusterInfoFromConfigMap(cm, r.configMapRef, labels)
+	r.apply(ctx, reloadSourceConfigMap, clusterInfo, r.live, r.settings, err)
+}
+
+// reloadFile reloads the configuration after viper read the changed config file
+func (r *configReloader) reloadFile(ctx context.Context) {
+	clusterInfo, err := loadClusterInfo(ctx, r.clientset, r.configMapRef)
+	live, lerr := getLiveSettings()
+	if err == nil {
+		err = lerr
+	}
+	settings := currentSettings()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply(ctx, reloadSourceFile, clusterInfo, live, settings, err)
+}
+
+// apply validates the reloaded configuration and swaps it in if it changed. err is the error of loading
+// clusterInfo or live, which rejects the reload. The caller holds r.mu.
+func (r *configReloader) apply(ctx context.Context, source string, clusterInfo *models.TkaClusterInfo, live liveSettings, settings map[string]any, err humane.Error) {
+	if err != nil {
+		configReloads.WithLabelValues(source, reloadRejected).Inc()
+		otelzap.L().WithError(err).ErrorContext(ctx, "Rejected configuration reload, keeping the current configuration", //nolint:golint-sl // reloads happen outside of any request
+			zap.String("source", source),
+			zap.Uint64("config_generation", r.generation),
+		)
+		return
+	}
+
+	changed := changedSettings(r.settings, settings)
+	clusterInfoChanged := !reflect.DeepEqual(r.clusterInfo.Load(), clusterInfo)
+
+	if !clusterInfoChanged && len(changed) == 0 {
+		configReloads.WithLabelValues(source, reloadUnchanged).Inc()
+		return
+	}
+
+	r.clusterInfo.Store(clusterInfo)
+	r.storeLiveSettings(live)
+	r.live = live
+	r.settings = settings
+	r.generation++
+
+	configGenerationGauge.Set(float64(r.generation))
+	configReloads.WithLabelValues(source, reloadApplied).Inc()
+
+	otelzap.L().InfoContext(ctx, "Applied configuration reload", //nolint:golint-sl // reloads happen outside of any request
+		zap.String("source", source),
+		zap.Uint64("config_generation", r.generation),
+		zap.Bool("cluster_info_changed", clusterInfoChanged),
+		zap.String("api_endpoint", clusterInfo.ServerURL),
+		zap.Strings("changed_settings", changed),
+	)
+
+	if restart := restartRequired(changed); len(restart) > 0 {
+		otelzap.L().WarnContext(ctx, "Some configuration changes only take effect after a restart", //nolint:golint-sl // reloads happen outside of any request
+			zap.Strings("settings", restart),
+			zap.Uint64("config_generation", r.generation),
+		)
+	}
+}
+
+// currentSettings returns every setting viper knows about by its lower-case key
+func currentSettings() map[string]any {
+	settings := make(map[string]any)
+	for _, key := range viper.AllKeys() {
+		settings[key] = viper.Get(key)
+	}
+	return settings
+}
+
+// changedSettings returns the sorted keys whose values differ between previous and current
+func changedSettings(previous, current map[string]any) []string {
+	var changed []string
+	for key, value := range current {
+		if !reflect.DeepEqual(previous[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// restartRequired returns the keys that are not applied while the server runs
+func restartRequired(keys []string) []string {
+	var restart []string
+	for _, key := range keys {
+		hasPrefix := func(prefix string) bool { return strings.HasPrefix(key, prefix) }
+		live := slices.ContainsFunc(liveSettingPrefixes, hasPrefix) && !slices.ContainsFunc(restartSettingPrefixes, hasPrefix)
+		if !live {
+			restart = append(restart, key)
+		}
+	}
+	return restart
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testCAData returns the base64 encoded PEM certificate of a self-signed CA
+func testCAData(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func useReloadSettings(t *testing.T, settings map[string]any) {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("tailscale.capName", "specht-labs.de/cap/tka")
+	viper.Set("api.retryAfterSeconds", 1)
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+}
+
+func TestValidateClusterInfo(t *testing.T) {
+	caData := testCAData(t)
+
+	tests := []struct {
+		name    string
+		info    models.TkaClusterInfo
+		wantErr string
+	}{
+		{name: "endpoint", info: models.TkaClusterInfo{ServerURL: "https://api.example.com:6443"}},
+		{name: "endpoint without scheme", info: models.TkaClusterInfo{ServerURL: "192.168.1.100:6443"}},
+		{name: "ca", info: models.TkaClusterInfo{ServerURL: "https://api.example.com:6443", CAData: caData}},
+		{name: "unsupported scheme", info: models.TkaClusterInfo{ServerURL: "ftp://api.example.com"}, wantErr: "invalid cluster API endpoint"},
+		{name: "no host", info: models.TkaClusterInfo{ServerURL: "https://"}, wantErr: "invalid cluster API endpoint"},
+		{name: "ca not base64", info: models.TkaClusterInfo{ServerURL: "https://api.example.com", CAData: "not base64!"}, wantErr: "not valid base64"},
+		{name: "ca not pem", info: models.TkaClusterInfo{ServerURL: "https://api.example.com", CAData: base64.StdEncoding.EncodeToString([]byte("garbage"))}, wantErr: "no PEM certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateClusterInfo(&tt.info)
+			if tt.wantErr == "" {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfigReloader_File(t *testing.T) {
+	useReloadSettings(t, map[string]any{
+		"clusterInfo.apiEndpoint": "https://api.example.com:6443",
+		"clusterInfo.labels":      map[string]string{"env": "prod"},
+	})
+	ctx := context.Background()
+
+	clusterInfo, err := loadClusterInfo(ctx, nil, getConfigMapRef())
+	require.Nil(t, err)
+	r, err := newConfigReloader(nil, getConfigMapRef(), clusterInfo)
+	require.Nil(t, err)
+	store := r.ClusterInfo()
+	require.Equal(t, uint64(1), r.Generation())
+	require.Equal(t, 1, r.RetryAfterSeconds())
+
+	// Nothing changed
+	r.reloadFile(ctx)
+	require.Equal(t, uint64(1), r.Generation())
+
+	// The CA rotated and the live settings changed
+	caData := testCAData(t)
+	viper.Set("clusterInfo.caData", caData)
+	viper.Set("api.retryAfterSeconds", 5)
+	viper.Set("tailscale.capName", "example.com/cap/tka")
+	r.reloadFile(ctx)
+	require.Equal(t, uint64(2), r.Generation())
+	require.Equal(t, float64(2), testutil.ToFloat64(configGenerationGauge))
+	require.Same(t, store, r.ClusterInfo())
+	require.Equal(t, caData, store.Load().CAData)
+	require.Equal(t, map[string]string{"env": "prod"}, r.ClusterLabels())
+	require.Equal(t, 5, r.RetryAfterSeconds())
+	require.Equal(t, "example.com/cap/tka", string(r.CapName()))
+
+	// A broken CA rejects the whole reload
+	rejected := testutil.ToFloat64(configReloads.WithLabelValues(reloadSourceFile, reloadRejected))
+	viper.Set("clusterInfo.caData", "not base64!")
+	viper.Set("api.retryAfterSeconds", 10)
+	r.reloadFile(ctx)
+	require.Equal(t, uint64(2), r.Generation())
+	require.Equal(t, caData, store.Load().CAData)
+	require.Equal(t, 5, r.RetryAfterSeconds())
+	require.Equal(t, rejected+1, testutil.ToFloat64(configReloads.WithLabelValues(reloadSourceFile, reloadRejected)))
+
+	// So does an invalid live setting
+	viper.Set("clusterInfo.caData", caData)
+	viper.Set("api.retryAfterSeconds", 0)
+	r.reloadFile(ctx)
+	require.Equal(t, uint64(2), r.Generation())
+	require.Equal(t, 5, r.RetryAfterSeconds())
+}
+
+func TestConfigReloader_ConfigMap(t *testing.T) {
+	useReloadSettings(t, map[string]any{
+		"clusterInfo.configMapRef.enabled":          true,
+		"clusterInfo.configMapRef.name":             "cluster-info",
+		"clusterInfo.configMapRef.namespace":        "kube-public",
+		"clusterInfo.configMapRef.keys.apiEndpoint": "apiEndpoint",
+		"clusterInfo.configMapRef.keys.caData":      "caData",
+		"clusterInfo.labels":                        map[string]string{"env": "prod"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-info", Namespace: "kube-public"},
+		Data:       map[string]string{"apiEndpoint": "https://api.example.com:6443"},
+	}
+	clientset := fake.NewClientset(cm)
+
+	ref := getConfigMapRef()
+	clusterInfo, err := loadClusterInfo(ctx, clientset, ref)
+	require.Nil(t, err)
+	r, err := newConfigReloader(clientset, ref, clusterInfo)
+	require.Nil(t, err)
+	require.Nil(t, r.Watch(ctx))
+
+	// The keys read from the ConfigMap are the ones configured at startup
+	viper.Set("clusterInfo.configMapRef.keys.apiEndpoint", "server")
+
+	// The API endpoint moved
+	cm = cm.DeepCopy()
+	cm.Data["apiEndpoint"] = "https://api.new.example.com:6443"
+	_, uerr := clientset.CoreV1().ConfigMaps("kube-public").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, uerr)
+	require.Eventually(t, func() bool {
+		return r.ClusterInfo().Load().ServerURL == "https://api.new.example.com:6443"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, uint64(2), r.Generation())
+	require.Equal(t, map[string]string{"env": "prod"}, r.ClusterLabels())
+
+	// A broken CA is not applied
+	rejected := testutil.ToFloat64(configReloads.WithLabelValues(reloadSourceConfigMap, reloadRejected))
+	cm = cm.DeepCopy()
+	cm.Data["caData"] = "not base64!"
+	_, uerr = clientset.CoreV1().ConfigMaps("kube-public").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, uerr)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(configReloads.WithLabelValues(reloadSourceConfigMap, reloadRejected)) == rejected+1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "https://api.new.example.com:6443", r.ClusterInfo().Load().ServerURL)
+	require.Empty(t, r.ClusterInfo().Load().CAData)
+	require.Equal(t, uint64(2), r.Generation())
+}
+
+func TestRestartRequired(t *testing.T) {
+	changed := changedSettings(
+		map[string]any{"api.retryafterseconds": 1, "server.readtimeout": "10s", "tailscale.port": 443, "clusterinfo.configmapref.name": "cluster-info"},
+		map[string]any{"api.retryafterseconds": 2, "server.readtimeout": "20s", "clusterinfo.cadata": "abc", "tailscale.port": 443, "clusterinfo.configmapref.name": "other"},
+	)
+	require.Equal(t, []string{"api.retryafterseconds", "clusterinfo.cadata", "clusterinfo.configmapref.name", "server.readtimeout"}, changed)
+	require.Equal(t, []string{"clusterinfo.configmapref.name", "server.readtimeout"}, restartRequired(changed))
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
@@ -19,8 +23,11 @@ import (
 	authMw "github.com/spechtlabs/tka/pkg/middleware/auth"
 	koperator "github.com/spechtlabs/tka/pkg/operator"
 	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/audit"
 	"github.com/spechtlabs/tka/pkg/service/capability"
 	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
 	ts "github.com/spechtlabs/tka/pkg/tshttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,12 +36,13 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
-	"tailscale.com/tailcfg"
 )
 
 func init() {
@@ -44,6 +52,13 @@ func init() {
 	if err != nil {
 		panic(humane.Wrap(err, "fatal binding flag", "check that the flag name matches the viper key"))
 	}
+	viper.SetDefault("health.pprof", false)
+
+	serveCmd.PersistentFlags().StringSlice("components", []string{componentAPI, componentOperator}, "Components to run: api serves the TKA API on the tailnet, operator provisions sign-ins")
+	viper.SetDefault("server.components", []string{componentAPI, componentOperator})
+	if err := viper.BindPFlag("server.components", serveCmd.PersistentFlags().Lookup("components")); err != nil {
+		panic(humane.Wrap(err, "fatal binding flag", "check that the flag name matches the viper key"))
+	}
 
 	serveCmd.PersistentFlags().String("api-endpoint", "", "API endpoint for the Kubernetes cluster")
 	viper.SetDefault("clusterInfo.apiEndpoint", "")
@@ -66,7 +81,7 @@ func init() {
 
 var (
 	serveCmd = &cobra.Command{
-		Use:   "serve [--server|-s <string>] [--port|-p <int>] [--dir|-d <string>] [--cap-name|-n <string>] [--health-port <int>] [--api-endpoint <string>] [--ca-data <string>] [--insecure-skip-tls-verify] [--labels <key=value>]",
+		Use:   "serve [--server|-s <string>] [--port|-p <int>] [--dir|-d <string>] [--cap-name|-n <string>] [--health-port <int>] [--components <api,operator>] [--api-endpoint <string>] [--ca-data <string>] [--insecure-skip-tls-verify] [--labels <key=value>]",
 		Short: "Run the TKA API and Kubernetes operator services",
 		Long: `Start the Tailscale-embedded HTTP API and the Kubernetes operator.
 
@@ -77,6 +92,10 @@ This command:
 - Runs the Kubernetes operator to manage kubeconfigs and user resources
 - Starts a local HTTP server for metrics and health checks
 
+Use --components to run the API and the operator in separate deployments. The API
+then runs without leader election and can be scaled out, while the operator keeps
+a single leader that provisions the sign-ins the API creates.
+
 Configuration is provided via flags and environment variables (see --help).`,
 		Example: `# Start the server with defaults from config and environment
 tka serve
@@ -87,6 +106,9 @@ tka serve --cap-name specht-labs.de/cap/custom --health-port 9090
 # Start with custom cluster information
 tka serve --api-endpoint https://api.cluster.example.com:6443 --labels environment=prod,region=us-west-2
 
+# Run only the API, e.g. as a scaled-out deployment next to a separate operator
+tka serve --components api
+
 # Start with insecure TLS for development
 tka serve --api-endpoint https://localhost:6443 --insecure-skip-tls-verify --labels environment=dev`,
 		Args:      cobra.ExactArgs(0),
@@ -119,13 +141,119 @@ func configureGinMode(debug bool) {
 	gin.SetMode(gin.ReleaseMode)
 }
 
-func getClientOptions() k8s.ClientOptions {
+func getClientOptions(clientset kubernetes.Interface, serverVersion *utils.ServerVersion) k8s.ClientOptions {
 	return k8s.ClientOptions{
 		Namespace:     viper.GetString("operator.namespace"),
 		ClusterName:   viper.GetString("operator.clusterName"),
 		ContextPrefix: viper.GetString("operator.contextPrefix"),
 		UserPrefix:    viper.GetString("operator.userPrefix"),
+		Clientset:     clientset,
+		ServerVersion: serverVersion,
+		MaxTokenTTL:   viper.GetDuration("operator.maxTokenTTL"),
+		TokenCache: k8s.TokenCacheOptions{
+			Enabled:       viper.GetBool("operator.tokenCache.enabled"),
+			RotateBefore:  viper.GetDuration("operator.tokenCache.rotateBefore"),
+			EncryptionKey: viper.GetString("operator.tokenCache.encryptionKey"),
+		},
+	}
+}
+
+func getOperatorOptions() ([]koperator.Option, humane.Error) {
+	opts := []koperator.Option{
+		koperator.WithUsernameMetrics(viper.GetBool("metrics.usernameLabels")),
+		koperator.WithExpiringSoonWindow(viper.GetDuration("metrics.expiringSoonWindow")),
+	}
+
+	if viper.GetBool("operator.webhook.enabled") {
+		opts = append(opts, koperator.WithAdmissionWebhook(koperator.WebhookOptions{
+			Port:         viper.GetInt("operator.webhook.port"),
+			CertDir:      viper.GetString("operator.webhook.certDir"),
+			AllowedRoles: viper.GetStringSlice("operator.webhook.allowedRoles"),
+			AllowedUsers: viper.GetStringSlice("operator.webhook.allowedUsers"),
+			MaxValidity:  viper.GetDuration("operator.webhook.maxValidity"),
+		}))
+	}
+
+	if idle := viper.GetStringMapString("operator.idleTimeouts"); len(idle) > 0 {
+		timeouts := make(map[string]time.Duration, len(idle))
+		for role, raw := range idle {
+			timeout, err := time.ParseDuration(raw)
+			if err != nil {
+				return nil, humane.Wrap(err, "invalid operator.idleTimeouts."+role, "use a valid duration format like '15m' or '1h'")
+			}
+			timeouts[role] = timeout
+		}
+		opts = append(opts, koperator.WithIdleTimeouts(timeouts))
 	}
+
+	return opts, nil
+}
+
+// getReasonValidator builds the validator for the reasons users give when signing in.
+func getReasonValidator() (reason.Validator, humane.Error) {
+	var opts []reason.Option
+
+	if pattern := viper.GetString("api.reason.pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, humane.Wrap(err, "invalid api.reason.pattern", "use a valid Go regular expression, e.g. '^(INC|CHG)-[0-9]+'")
+		}
+		opts = append(opts, reason.WithPattern(re))
+	}
+
+	if url := viper.GetString("api.reason.callbackURL"); url != "" {
+		opts = append(opts, reason.WithCallback(url, viper.GetDuration("api.reason.callbackTimeout")))
+	}
+
+	return reason.NewValidator(opts...), nil
+}
+
+// getPreSigninAuthorizer builds the client for the pre-signin webhook, or returns nil if none is configured.
+func getPreSigninAuthorizer() (presignin.Authorizer, humane.Error) {
+	url := viper.GetString("api.preSignin.url")
+	if url == "" {
+		return nil, nil
+	}
+
+	tlsConfig, err := presignin.NewTLSConfig(
+		viper.GetString("api.preSignin.tls.certFile"),
+		viper.GetString("api.preSignin.tls.keyFile"),
+		viper.GetString("api.preSignin.tls.caFile"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []presignin.Option{
+		presignin.WithTimeout(viper.GetDuration("api.preSignin.timeout")),
+		presignin.WithFailOpen(viper.GetBool("api.preSignin.failOpen")),
+		presignin.WithTLSConfig(tlsConfig),
+	}
+	if secret := viper.GetString("api.preSignin.hmacSecret"); secret != "" {
+		opts = append(opts, presignin.WithHMACSecret([]byte(secret)))
+	}
+
+	return presignin.NewWebhookAuthorizer(url, opts...), nil
+}
+
+// newSharedClients creates the clientset and server version cache shared by the API and the operator.
+func newSharedClients() (kubernetes.Interface, *utils.ServerVersion, humane.Error) {
+	restCfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, nil, humane.Wrap(err, "failed to get Kubernetes rest config", "ensure the server is running inside a Kubernetes cluster or has valid kubeconfig")
+	}
+
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, nil, humane.Wrap(err, "failed to create Kubernetes clientset", "check cluster connectivity and authentication")
+	}
+
+	serverVersion, herr := utils.NewServerVersion(clientset.Discovery())
+	if herr != nil {
+		return nil, nil, herr
+	}
+
+	return clientset, serverVersion, nil
 }
 
 func parseBoolish(in string) bool {
@@ -137,71 +265,48 @@ func parseBoolish(in string) bool {
 	}
 }
 
-func loadClusterInfo(ctx context.Context) (*models.TkaClusterInfo, humane.Error) {
-	useCMRef := viper.GetBool("clusterInfo.configMapRef.enabled")
+// configMapRef is clusterInfo.configMapRef. It is read once at startup, so the ConfigMap and the keys read from
+// it only change with a restart.
+type configMapRef struct {
+	enabled   bool
+	name      string
+	namespace string
+
+	keyAPIEndpoint string
+	keyCAData      string
+	keyInsecure    string
+	keyKubeconfig  string
+}
+
+func getConfigMapRef() configMapRef {
+	return configMapRef{
+		enabled:        viper.GetBool("clusterInfo.configMapRef.enabled"),
+		name:           viper.GetString("clusterInfo.configMapRef.name"),
+		namespace:      viper.GetString("clusterInfo.configMapRef.namespace"),
+		keyAPIEndpoint: viper.GetString("clusterInfo.configMapRef.keys.apiEndpoint"),
+		keyCAData:      viper.GetString("clusterInfo.configMapRef.keys.caData"),
+		keyInsecure:    viper.GetString("clusterInfo.configMapRef.keys.insecure"),
+		keyKubeconfig:  viper.GetString("clusterInfo.configMapRef.keys.kubeconfig"),
+	}
+}
+
+func loadClusterInfo(ctx context.Context, clientset kubernetes.Interface, ref configMapRef) (*models.TkaClusterInfo, humane.Error) {
 	explicitEndpoint := viper.GetString("clusterInfo.apiEndpoint")
-	if useCMRef && explicitEndpoint != "" {
+	if ref.enabled && explicitEndpoint != "" {
 		return nil, humane.New("invalid configuration: both clusterInfo and configMapRef provided", "Use either explicit clusterInfo.* settings or enable configMapRef, not both")
 	}
 
-	if useCMRef {
-		name := viper.GetString("clusterInfo.configMapRef.name")
-		namespace := viper.GetString("clusterInfo.configMapRef.namespace")
-		if name == "" || namespace == "" {
+	if ref.enabled {
+		if ref.name == "" || ref.namespace == "" {
 			return nil, humane.New("configMapRef.name and configMapRef.namespace are required when configMapRef.enabled is true", "set CLUSTER_INFO_CONFIGMAP_REF_NAME and CLUSTER_INFO_CONFIGMAP_REF_NAMESPACE environment variables")
 		}
 
-		keyAPI := viper.GetString("clusterInfo.configMapRef.keys.apiEndpoint")       //nolint:golint-sl // config keys grouped for readability
-		keyCA := viper.GetString("clusterInfo.configMapRef.keys.caData")             //nolint:golint-sl // config keys grouped for readability
-		keyInsecure := viper.GetString("clusterInfo.configMapRef.keys.insecure")     //nolint:golint-sl // config keys grouped for readability
-		kubeconfigKey := viper.GetString("clusterInfo.configMapRef.keys.kubeconfig") //nolint:golint-sl // config keys grouped for readability
-
-		restCfg, err := ctrl.GetConfig()
-		if err != nil {
-			return nil, humane.Wrap(err, "failed to get Kubernetes rest config", "ensure the server is running inside a Kubernetes cluster")
-		}
-		clientset, err := kubernetes.NewForConfig(restCfg)
-		if err != nil {
-			return nil, humane.Wrap(err, "failed to create Kubernetes clientset", "check cluster connectivity and authentication")
-		}
-
-		cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		cm, err := clientset.CoreV1().ConfigMaps(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
 		if err != nil {
 			return nil, humane.Wrap(err, "failed to read configMapRef ConfigMap", "verify the ConfigMap exists and the server has read permissions")
 		}
 
-		serverURL := cm.Data[keyAPI]
-		caData := cm.Data[keyCA]
-		insecure := parseBoolish(cm.Data[keyInsecure]) //nolint:golint-sl // insecure used in struct below
-
-		// kubeadm cluster-info configmap supports embedding a kubeconfig in a single key
-		if serverURL == "" && caData == "" && kubeconfigKey != "" {
-			if yamlKubeconfig, ok := cm.Data[kubeconfigKey]; ok && yamlKubeconfig != "" {
-				cfg, err := clientcmd.Load([]byte(yamlKubeconfig))
-				if err != nil {
-					return nil, humane.Wrap(err, "failed to parse kubeconfig from ConfigMap", "verify the kubeconfig data in the ConfigMap is valid YAML")
-				}
-				// Use the first cluster entry
-				for _, cluster := range cfg.Clusters {
-					serverURL = cluster.Server
-					if len(cluster.CertificateAuthorityData) > 0 {
-						caData = base64.StdEncoding.EncodeToString(cluster.CertificateAuthorityData)
-					}
-					break
-				}
-			}
-		}
-
-		if serverURL == "" {
-			return nil, humane.New("configMapRef missing api endpoint", fmt.Sprintf("ConfigMap %s/%s missing key '%s'", namespace, name, keyAPI))
-		}
-
-		return &models.TkaClusterInfo{
-			ServerURL:             serverURL,
-			CAData:                caData,
-			InsecureSkipTLSVerify: insecure,
-			Labels:                viper.GetStringMapString("clusterInfo.labels"),
-		}, nil
+		return clusterInfoFromConfigMap(cm, ref, viper.GetStringMapString("clusterInfo.labels"))
 	}
 
 	clusterInfo := &models.TkaClusterInfo{
@@ -215,19 +320,102 @@ func loadClusterInfo(ctx context.Context) (*models.TkaClusterInfo, humane.Error)
 		return nil, humane.New("clusterInfo.apiEndpoint is required", "Please provide the API endpoint for the Kubernetes cluster in the config file or via the --api-endpoint flag")
 	}
 
+	if err := validateClusterInfo(clusterInfo); err != nil {
+		return nil, err
+	}
+
+	return clusterInfo, nil
+}
+
+// clusterInfoFromConfigMap reads the cluster info from the keys of ref in the ConfigMap cm and adds labels.
+// It does not read the configuration, so it is safe to call while the configuration reloads.
+func clusterInfoFromConfigMap(cm *corev1.ConfigMap, ref configMapRef, labels map[string]string) (*models.TkaClusterInfo, humane.Error) {
+	serverURL := cm.Data[ref.keyAPIEndpoint]
+	caData := cm.Data[ref.keyCAData]
+	insecure := parseBoolish(cm.Data[ref.keyInsecure]) //nolint:golint-sl // insecure used in struct below
+
+	// kubeadm cluster-info configmap supports embedding a kubeconfig in a single key
+	if serverURL == "" && caData == "" && ref.keyKubeconfig != "" {
+		if yamlKubeconfig, ok := cm.Data[ref.keyKubeconfig]; ok && yamlKubeconfig != "" {
+			cfg, err := clientcmd.Load([]byte(yamlKubeconfig))
+			if err != nil {
+				return nil, humane.Wrap(err, "failed to parse kubeconfig from ConfigMap", "verify the kubeconfig data in the ConfigMap is valid YAML")
+			}
+			// Use the first cluster entry
+			for _, cluster := range cfg.Clusters {
+				serverURL = cluster.Server
+				if len(cluster.CertificateAuthorityData) > 0 {
+					caData = base64.StdEncoding.EncodeToString(cluster.CertificateAuthorityData)
+				}
+				break
+			}
+		}
+	}
+
+	if serverURL == "" {
+		return nil, humane.New("configMapRef missing api endpoint", fmt.Sprintf("ConfigMap %s/%s missing key '%s'", cm.Namespace, cm.Name, ref.keyAPIEndpoint))
+	}
+
+	clusterInfo := &models.TkaClusterInfo{
+		ServerURL:             serverURL,
+		CAData:                caData,
+		InsecureSkipTLSVerify: insecure,
+		Labels:                labels,
+	}
+
+	if err := validateClusterInfo(clusterInfo); err != nil {
+		return nil, humane.Wrap(err, fmt.Sprintf("invalid cluster info in ConfigMap %s/%s", cm.Namespace, cm.Name), "fix the ConfigMap; the server keeps the cluster info it runs with")
+	}
+
 	return clusterInfo, nil
 }
 
-func newTailscaleServer(debug bool) *ts.Server {
-	return ts.NewServer(viper.GetString("tailscale.hostname"),
+// validateClusterInfo checks that kubeconfigs built from clusterInfo can be used, so a broken endpoint or CA
+// is rejected when it is loaded instead of when users sign in.
+func validateClusterInfo(clusterInfo *models.TkaClusterInfo) humane.Error {
+	endpoint := clusterInfo.ServerURL
+	if !strings.Contains(endpoint, "://") {
+		// kubectl assumes https for endpoints without a scheme
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return humane.New(fmt.Sprintf("invalid cluster API endpoint %q", clusterInfo.ServerURL), "set the URL of the Kubernetes API server, e.g. https://api.example.com:6443")
+	}
+
+	if clusterInfo.CAData == "" {
+		return nil
+	}
+
+	pemData, err := base64.StdEncoding.DecodeString(clusterInfo.CAData)
+	if err != nil {
+		return humane.Wrap(err, "cluster CA data is not valid base64", "set the base64 encoded PEM certificate of the cluster CA")
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(pemData) {
+		return humane.New("cluster CA data contains no PEM certificate", "set the base64 encoded PEM certificate of the cluster CA")
+	}
+
+	return nil
+}
+
+func newTailscaleServer(debug bool) (*ts.Server, humane.Error) {
+	id, err := getTailnetIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.NewServer(id.hostname,
 		ts.WithDebug(debug),
 		ts.WithPort(viper.GetInt("tailscale.port")),
 		ts.WithStateDir(viper.GetString("tailscale.stateDir")),
+		ts.WithStateSecret(id.stateSecret),
+		ts.WithService(id.service),
 		ts.WithReadTimeout(viper.GetDuration("server.readTimeout")),
 		ts.WithReadHeaderTimeout(viper.GetDuration("server.readHeaderTimeout")),
 		ts.WithWriteTimeout(viper.GetDuration("server.writeTimeout")),
 		ts.WithIdleTimeout(viper.GetDuration("server.idleTimeout")),
-	)
+	), nil
 }
 
 func getHealthPort() int {
@@ -238,76 +426,196 @@ func getHealthPort() int {
 	return localPort
 }
 
+// newAPIServer creates the TKA API that serves on the tsnet server srv and manages sign-ins through tkaClient.
+// It follows the cluster info and the settings the reloader applies.
+func newAPIServer(srv *ts.Server, tkaClient k8s.TkaClient, reloader *configReloader, prom *ginprometheus.Prometheus) (*api.TKAServer, humane.Error) {
+	reasonValidator, err := getReasonValidator()
+	if err != nil {
+		return nil, err
+	}
+
+	preSignin, err := getPreSigninAuthorizer()
+	if err != nil {
+		return nil, err
+	}
+
+	authMiddleware := authMw.NewGinAuthMiddleware(srv, reloader.CapName(), //nolint:golint-sl // part of init sequence
+		authMw.WithCapNameFunc[capability.Rule](reloader.CapName),
+		authMw.AllowTaggedNodes[capability.Rule](viper.GetBool("tailscale.allowTaggedNodes")),
+		authMw.WithClusterLabelsFunc[capability.Rule](reloader.ClusterLabels),
+		// Scheduled shifts grant access to callers without a capability rule
+		authMw.AllowWithoutRules[capability.Rule](http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute),
+		authMw.AllowWithoutRules[capability.Rule](http.MethodPost, api.ApiRouteV1Alpha1+api.ExtendApiRoute),
+	)
+
+	// Sign-ins are only rejected while disconnected on request, users may still be able to reach the server
+	var loginTailnet ts.TailscaleServer
+	if viper.GetBool("tailscale.rejectLoginsWhileDisconnected") {
+		loginTailnet = srv
+	}
+
+	tkaServer := api.NewTKAServer(
+		api.WithRetryAfterSecondsFunc(reloader.RetryAfterSeconds),
+		api.WithPrometheusMiddleware(prom),
+		api.WithClusterInfoStore(reloader.ClusterInfo()),
+		api.WithAuthMiddleware(authMiddleware),
+		api.WithReasonValidator(reasonValidator),
+		api.WithPreSigninAuthorizer(preSignin),
+		api.WithShiftLeadTime(viper.GetDuration("api.schedules.leadTime")),
+		api.WithMaxExtensionPerDay(viper.GetDuration("api.extend.maxPerDay")),
+		api.WithUsernameMetrics(viper.GetBool("metrics.usernameLabels")),
+		api.WithRejectLoginsWhileDisconnected(loginTailnet),
+	)
+
+	if err := tkaServer.LoadApiRoutes(tkaClient); err != nil {
+		return nil, err
+	}
+
+	return tkaServer, nil
+}
+
+// newTkaClient returns the client the API and the audit receiver use: the operator's, which reads from the
+// manager's cache, or a direct one if the operator runs elsewhere.
+func newTkaClient(k8sOperator *koperator.KubeOperator, clusterInfo *models.ClusterInfoStore, clientOpts k8s.ClientOptions) (k8s.TkaClient, humane.Error) {
+	if k8sOperator != nil {
+		return k8sOperator.GetClient(), nil
+	}
+
+	tkaClient, err := koperator.NewAPIClient(clusterInfo, clientOpts)
+	if err != nil {
+		return nil, humane.Wrap(err, "failed to initialize Kubernetes client", "check cluster connectivity and permissions")
+	}
+	return tkaClient, nil
+}
+
+// newOperator creates the operator if it is one of the components that run in this process
+func newOperator(comps components, clusterInfo *models.ClusterInfoStore, clientOpts k8s.ClientOptions) (*koperator.KubeOperator, humane.Error) {
+	if !comps.operator {
+		return nil, nil
+	}
+
+	operatorOpts, err := getOperatorOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	k8sOperator, err := koperator.NewK8sOperator(clusterInfo, clientOpts, operatorOpts...)
+	if err != nil {
+		return nil, humane.Wrap(err, "failed to initialize Kubernetes operator", "check cluster connectivity and permissions")
+	}
+	return k8sOperator, nil
+}
+
+// runE starts the components selected by server.components
+//
 //nolint:golint-sl // Server lifecycle: startup info, component-specific Fatal logs in goroutines, shutdown wide event
 func runE(cmd *cobra.Command, _ []string) humane.Error {
 	debug := viper.GetBool("debug")
 	configureGinMode(debug)
 
+	comps, err := getComponents()
+	if err != nil {
+		return err
+	}
+
 	ctx, cancelFn := context.WithCancelCause(cmd.Context())
 	utils.InterruptHandler(ctx, cancelFn)
 
-	clientOpts := getClientOptions()
+	otelzap.L().InfoContext(ctx, "Starting TKA server", zap.String("components", comps.String()))
 
-	clusterInfo, err := loadClusterInfo(ctx)
+	clientset, serverVersion, err := newSharedClients()
+	if err != nil {
+		cancelFn(err)
+		return err
+	}
+
+	clientOpts := getClientOptions(clientset, serverVersion)
+
+	cmRef := getConfigMapRef()
+	clusterInfo, err := loadClusterInfo(ctx, clientset, cmRef)
 	if err != nil {
 		herr := humane.Wrap(err, "failed to load cluster info", "ensure the server is running inside a Kubernetes cluster or has valid kubeconfig")
 		cancelFn(herr)
 		return herr
 	}
 
-	k8sOperator, err := koperator.NewK8sOperator(clusterInfo, clientOpts) //nolint:golint-sl // part of init sequence, used in LoadApiRoutes
+	// Changes to the config file and the cluster info ConfigMap are applied without a restart
+	reloader, err := newConfigReloader(clientset, cmRef, clusterInfo)
 	if err != nil {
-		herr := humane.Wrap(err, "failed to initialize Kubernetes operator", "check cluster connectivity and permissions")
-		cancelFn(herr)
-		return herr
+		cancelFn(err)
+		return err
+	}
+	if err := reloader.Watch(ctx); err != nil {
+		cancelFn(err)
+		return err
 	}
 
-	// Create Tailscale server
-	srv := newTailscaleServer(debug)
+	k8sOperator, err := newOperator(comps, reloader.ClusterInfo(), clientOpts)
+	if err != nil {
+		cancelFn(err)
+		return err
+	}
 
-	authMiddleware := authMw.NewGinAuthMiddleware[capability.Rule](srv, tailcfg.PeerCapability(viper.GetString("tailscale.capName"))) //nolint:golint-sl // part of init sequence
+	tkaClient, err := newTkaClient(k8sOperator, reloader.ClusterInfo(), clientOpts)
+	if err != nil {
+		cancelFn(err)
+		return err
+	}
 
-	// Start the Tailscale connection
-	if err := srv.Start(ctx); err != nil {
-		herr := humane.Wrap(err, "failed to connect to tailscale", "ensure your TS_AUTH_KEY is set and valid")
-		cancelFn(herr)
-		return herr
+	auditReceiver, err := getAuditReceiver(tkaClient)
+	if err != nil {
+		cancelFn(err)
+		return err
 	}
 
 	// Create shared Prometheus instance for all servers
 	sharedPrometheus := ginprometheus.NewPrometheus("tka")
 
-	tkaServer := api.NewTKAServer(
-		api.WithRetryAfterSeconds(viper.GetInt("api.retryAfterSeconds")),
-		api.WithPrometheusMiddleware(sharedPrometheus),
-		api.WithClusterInfo(clusterInfo),
-		api.WithAuthMiddleware(authMiddleware),
-	)
+	// Create Tailscale server, only the API is served on the tailnet
+	var srv *ts.Server
+	var tailnet ts.TailscaleServer
+	var tkaServer *api.TKAServer
+	if comps.api {
+		if srv, err = newTailscaleServer(debug); err != nil {
+			cancelFn(err)
+			return err
+		}
+		tailnet = srv
 
-	if err := tkaServer.LoadApiRoutes(k8sOperator.GetClient()); err != nil {
-		cancelFn(err)
-		return err
+		if tkaServer, err = newAPIServer(srv, tkaClient, reloader, sharedPrometheus); err != nil {
+			cancelFn(err)
+			return err
+		}
+
+		// Start the Tailscale connection
+		if err := srv.Start(ctx); err != nil {
+			herr := humane.Wrap(err, "failed to connect to tailscale", "ensure your TS_AUTH_KEY is set and valid")
+			cancelFn(herr)
+			return herr
+		}
 	}
 
 	// Create local metrics server
-	healthSrv := newHealthServer(srv, sharedPrometheus)
+	healthSrv := newHealthServer(tailnet, sharedPrometheus, auditReceiver, readinessChecks(clientset, k8sOperator, tailnet, reloader.ClusterInfo()))
 	healthSrv.Addr = fmt.Sprintf(":%d", getHealthPort())
 
 	// Start TKA server (Tailscale)
-	go func() {
-		network := "tcp"
-		if viper.GetInt("tailscale.port") == 443 {
-			network = "tls"
-		}
-		if err := srv.Serve(ctx, tkaServer.Engine(), network); err != nil {
-			if err.Cause() != nil {
-				cancelFn(err.Cause())
-			} else {
-				cancelFn(err)
+	if tkaServer != nil {
+		go func() {
+			network := "tcp"
+			if viper.GetInt("tailscale.port") == 443 {
+				network = "tls"
 			}
-			otelzap.L().WithError(err).FatalContext(ctx, "Failed to start TKA tailscale")
-		}
-	}()
+			if err := srv.Serve(ctx, tkaServer.Engine(), network); err != nil {
+				if err.Cause() != nil {
+					cancelFn(err.Cause())
+				} else {
+					cancelFn(err)
+				}
+				otelzap.L().WithError(err).FatalContext(ctx, "Failed to start TKA tailscale")
+			}
+		}()
+	}
 
 	// Start metrics server (Local)
 	go func() {
@@ -319,21 +627,31 @@ func runE(cmd *cobra.Command, _ []string) humane.Error {
 		}
 	}()
 
-	go func() {
-		if err := k8sOperator.Start(ctx); err != nil {
-			if err.Cause() != nil {
-				cancelFn(err.Cause())
-			} else {
-				cancelFn(err)
+	if k8sOperator != nil {
+		go func() {
+			if err := k8sOperator.Start(ctx); err != nil {
+				if err.Cause() != nil {
+					cancelFn(err.Cause())
+				} else {
+					cancelFn(err)
+				}
+				otelzap.L().WithError(err).FatalContext(ctx, "Failed to start k8s operator")
 			}
-			otelzap.L().WithError(err).FatalContext(ctx, "Failed to start k8s operator")
-		}
-	}()
+		}()
+	}
+
+	// Pick up control plane upgrades without a restart
+	go serverVersion.Run(ctx, viper.GetDuration("operator.versionRefreshInterval"))
 
 	// Wait for context done
 	<-ctx.Done()
 	// No more logging to ctx from here onwards
 
+	return shutdown(ctx, healthSrv, srv)
+}
+
+// shutdown gracefully stops the health server and, if the API ran, the tsnet server after ctx is done
+func shutdown(ctx context.Context, healthSrv *http.Server, srv *ts.Server) humane.Error {
 	// Graceful shutdown with timeout and trace context
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -351,7 +669,9 @@ func runE(cmd *cobra.Command, _ []string) humane.Error {
 	healthShutdownErr = healthSrv.Shutdown(shutdownCtx)
 
 	// Shutdown Tailscale server
-	tsShutdownErr = srv.Stop(shutdownCtx)
+	if srv != nil {
+		tsShutdownErr = srv.Stop(shutdownCtx)
+	}
 
 	// Set span attributes for shutdown wide event
 	span.SetAttributes(
@@ -368,7 +688,7 @@ func runE(cmd *cobra.Command, _ []string) humane.Error {
 	}
 
 	// Log summary
-	otelzap.L().InfoContext(shutdownCtx, "servers shutdown completed",
+	otelzap.L().InfoContext(shutdownCtx, "servers shutdown completed", //nolint:golint-sl // Shutdown wide event
 		zap.Bool("health_ok", healthShutdownErr == nil),
 		zap.Bool("tailscale_ok", tsShutdownErr == nil),
 	)
@@ -387,8 +707,27 @@ func runE(cmd *cobra.Command, _ []string) humane.Error {
 	return nil
 }
 
-// newHealthServer creates a local HTTP server for metrics and health checks
-func newHealthServer(tsServer ts.TailscaleServer, prom *ginprometheus.Prometheus) *http.Server {
+// getAuditReceiver builds the receiver for the API server's audit webhook, or returns nil if it is disabled.
+// The health port listens on all interfaces, so the receiver requires a token; anyone could fake activity otherwise.
+func getAuditReceiver(recorder audit.Recorder) (http.Handler, humane.Error) {
+	if !viper.GetBool("audit.enabled") {
+		return nil, nil
+	}
+
+	token := viper.GetString("audit.token")
+	if token == "" {
+		return nil, humane.New("audit.enabled requires audit.token",
+			"set audit.token to a random string and configure it as the token of the API server's audit webhook kubeconfig")
+	}
+
+	return audit.NewReceiver(recorder,
+		audit.WithBearerToken(token),
+		audit.WithMaxBodyBytes(viper.GetInt64("audit.maxBodyBytes")),
+	), nil
+}
+
+// newHealthServer creates a local HTTP server for metrics and health checks, and audit events if auditReceiver is set
+func newHealthServer(tsServer ts.TailscaleServer, prom *ginprometheus.Prometheus, auditReceiver http.Handler, readiness map[string]healthz.Checker) *http.Server {
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(ginzap.GinzapWithConfig(otelzap.L(), &ginzap.Config{
@@ -403,20 +742,37 @@ func newHealthServer(tsServer ts.TailscaleServer, prom *ginprometheus.Prometheus
 	// Controller metrics endpoint - expose controller-runtime metrics for backwards compatibility
 	router.GET("/metrics/controller", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
 
-	// Ready endpoint - checks if Tailscale server is connected
+	// Audit endpoint - receives events from the API server's audit webhook backend to track session activity
+	if auditReceiver != nil {
+		router.POST(audit.DefaultPath, gin.WrapH(auditReceiver))
+	}
+
+	// Liveness and readiness endpoints for the kubelet probes, with one sub-path per check
+	mountHealthz(router, "/livez", livenessChecks())
+	mountHealthz(router, "/readyz", readiness)
+
+	// Profiling endpoints - only on request, as profiles expose internals and cost CPU while they run
+	if viper.GetBool("health.pprof") {
+		mountPprof(router)
+	}
+
+	// Ready endpoint - runs the readiness checks of the components in this process, so an operator without
+	// the API is ready without a tsnet server. Superseded by /readyz, kept for existing probes
 	router.GET("/ready", func(c *gin.Context) {
-		status := "not ready"
-		httpStatus := http.StatusServiceUnavailable
-		reason := "tailscale server not initialized"
-		if tsServer != nil && tsServer.IsConnected() {
-			status = "ready"
-			httpStatus = http.StatusOK
-			reason = fmt.Sprintf("tailscale server is %s state", tsServer.BackendState())
+		if err := failedCheck(c.Request, readiness); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "not ready",
+				"reason": err.Error(),
+			})
+			return
 		}
 
-		// Check if Tailscale server is started
-		c.JSON(httpStatus, gin.H{
-			"status": status,
+		reason := "all readiness checks passed"
+		if tsServer != nil {
+			reason = fmt.Sprintf("tailscale server is %s state", tsServer.BackendState())
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ready",
 			"reason": reason,
 		})
 	})
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spf13/viper"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;patch
+
+// tailnetIdentity is how this replica appears on the tailnet and where it keeps its node state.
+type tailnetIdentity struct {
+	hostname    string
+	stateSecret string
+	// service is the Tailscale Service all replicas host in HA mode, empty otherwise
+	service string
+}
+
+// getTailnetIdentity reads the tailscale settings. In HA mode every replica joins as its own node,
+// <hostname>-<replica> with the state in <stateSecret>-<replica>, and hosts the Tailscale Service
+// that clients reach by the plain hostname.
+func getTailnetIdentity() (tailnetIdentity, humane.Error) {
+	id := tailnetIdentity{
+		hostname:    viper.GetString("tailscale.hostname"),
+		stateSecret: viper.GetString("tailscale.stateSecret"),
+	}
+
+	if !viper.GetBool("tailscale.ha.enabled") {
+		return id, nil
+	}
+
+	replica := viper.GetString("tailscale.ha.replica")
+	if replica == "" {
+		podName, _ := os.Hostname()
+		if replica = statefulSetOrdinal(podName); replica == "" {
+			return tailnetIdentity{}, humane.New(fmt.Sprintf("cannot tell which replica %q is", podName),
+				"run the server in a StatefulSet or set tailscale.ha.replica (TKA_TAILSCALE_HA_REPLICA)")
+		}
+	}
+
+	id.service = viper.GetString("tailscale.ha.service")
+	if id.service == "" {
+		id.service = id.hostname
+	}
+	if !strings.HasPrefix(id.service, "svc:") {
+		id.service = "svc:" + id.service
+	}
+
+	id.hostname = fmt.Sprintf("%s-%s", id.hostname, replica)
+	if id.stateSecret != "" {
+		id.stateSecret = fmt.Sprintf("%s-%s", id.stateSecret, replica)
+	}
+
+	return id, nil
+}
+
+// statefulSetOrdinal returns the ordinal a StatefulSet appends to its pod names, e.g. "1" for tka-1,
+// or "" if podName doesn't end in one.
+func statefulSetOrdinal(podName string) string {
+	idx := strings.LastIndex(podName, "-")
+	if idx < 0 {
+		return ""
+	}
+
+	ordinal := podName[idx+1:]
+	if _, err := strconv.ParseUint(ordinal, 10, 32); err != nil {
+		return ""
+	}
+	return ordinal
+}
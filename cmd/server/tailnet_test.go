@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTailnetIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     tailnetIdentity
+	}{
+		{
+			name:     "single node",
+			settings: map[string]any{},
+			want:     tailnetIdentity{hostname: "tka"},
+		},
+		{
+			name:     "state secret",
+			settings: map[string]any{"tailscale.stateSecret": "tka-state"},
+			want:     tailnetIdentity{hostname: "tka", stateSecret: "tka-state"},
+		},
+		{
+			name: "ha replica",
+			settings: map[string]any{
+				"tailscale.stateSecret": "tka-state",
+				"tailscale.ha.enabled":  true,
+				"tailscale.ha.replica":  "1",
+				"tailscale.ha.service":  "",
+			},
+			want: tailnetIdentity{hostname: "tka-1", stateSecret: "tka-state-1", service: "svc:tka"},
+		},
+		{
+			name: "ha with a named service and a state directory",
+			settings: map[string]any{
+				"tailscale.ha.enabled": true,
+				"tailscale.ha.replica": "0",
+				"tailscale.ha.service": "kubernetes",
+			},
+			want: tailnetIdentity{hostname: "tka-0", service: "svc:kubernetes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set("tailscale.hostname", "tka")
+			for key, value := range tt.settings {
+				viper.Set(key, value)
+			}
+
+			got, err := getTailnetIdentity()
+			require.Nil(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStatefulSetOrdinal(t *testing.T) {
+	tests := []struct {
+		podName string
+		want    string
+	}{
+		{podName: "tka-0", want: "0"},
+		{podName: "tka-api-12", want: "12"},
+		{podName: "tka-api-7d9f8b6c5d-x2k4q", want: ""},
+		{podName: "tka", want: ""},
+		{podName: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.podName, func(t *testing.T) {
+			require.Equal(t, tt.want, statefulSetOrdinal(tt.podName))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	koperator "github.com/spechtlabs/tka/pkg/operator"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	templateRenderCmd.Flags().String("user", "alice", "TKA username (local part of the login name) to render the template for")
+	templateRenderCmd.Flags().String("role", "", "Role of the sign-in (defaults to the template's role)")
+	templateRenderCmd.Flags().Duration("period", time.Hour, "Validity period of the sign-in")
+	templateCmd.AddCommand(templateRenderCmd)
+}
+
+var (
+	templateCmd = &cobra.Command{
+		Use:   "template",
+		Short: "Work with TkaRoleTemplates",
+		Long:  `Tools for authors of TkaRoleTemplates, the resources provisioned with every sign-in to a role.`,
+	}
+
+	templateRenderCmd = &cobra.Command{
+		Use:   "render <file> [--user <string>] [--role <string>] [--period <duration>]",
+		Short: "Render a TkaRoleTemplate without applying it",
+		Long: `Render the manifests of a TkaRoleTemplate for an example sign-in and print the resulting objects.
+
+Nothing is sent to the cluster. The cluster variables are taken from the server configuration
+(operator.clusterName, clusterInfo.apiEndpoint and clusterInfo.labels).`,
+		Example: `# Render a template for the default example user
+tka template render scratch-namespace.yaml
+
+# Render a template for a specific sign-in
+tka template render scratch-namespace.yaml --user bob@example.com --period 30m`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := renderTemplate(cmd, args[0]); err != nil {
+				pretty_print.PrintError(err)
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+)
+
+// renderTemplate renders the TkaRoleTemplate in file for the sign-in described by the command's flags.
+func renderTemplate(cmd *cobra.Command, file string) humane.Error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return humane.Wrap(err, "Failed to read "+file, "check that the file exists and is readable")
+	}
+
+	var tmpl v1alpha1.TkaRoleTemplate
+	if err := yaml.UnmarshalStrict(data, &tmpl); err != nil {
+		return humane.Wrap(err, "Invalid TkaRoleTemplate in "+file, "the file must contain a single TkaRoleTemplate")
+	}
+
+	user, _ := cmd.Flags().GetString("user")
+	role, _ := cmd.Flags().GetString("role")
+	period, _ := cmd.Flags().GetDuration("period")
+	if role == "" {
+		role = tmpl.Spec.Role
+	}
+
+	signIn := k8s.NewSignin(user, role, period, viper.GetString("operator.namespace"))
+	signIn.Status.ValidUntil = time.Now().Add(period).Format(time.RFC3339)
+
+	clusterInfo := &models.TkaClusterInfo{
+		ServerURL: viper.GetString("clusterInfo.apiEndpoint"),
+		Labels:    viper.GetStringMapString("clusterInfo.labels"),
+	}
+
+	objects, herr := koperator.RenderRoleTemplate(&tmpl, koperator.NewTemplateData(signIn, viper.GetString("operator.clusterName"), clusterInfo))
+	if herr != nil {
+		return herr
+	}
+
+	return printObjects(cmd.OutOrStdout(), objects)
+}
+
+// printObjects writes objects as a multi-document YAML stream.
+func printObjects(out io.Writer, objects []*unstructured.Unstructured) humane.Error {
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return humane.Wrap(err, "Failed to print rendered object", "this is a bug in TKA; please report it")
+		}
+		_, _ = fmt.Fprintf(out, "---\n%s", data)
+	}
+	return nil
+}
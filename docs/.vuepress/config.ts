@@ -112,6 +112,11 @@ export default defineUserConfig({
               link: "configure-acl",
               items: [
                 { text: "Configure ACLs", link: "configure-acl", icon: "mdi:shield-lock" },
+                { text: "Role Templates", link: "role-templates", icon: "mdi:file-document-multiple" },
+                { text: "Scheduled Access", link: "scheduled-access", icon: "mdi:calendar-clock" },
+                { text: "Delegated Access", link: "delegated-access", icon: "mdi:account-arrow-right" },
+                { text: "Idle Sessions", link: "idle-sessions", icon: "mdi:timer-sand" },
+                { text: "Audit Correlation", link: "audit-correlation", icon: "mdi:file-search" },
               ]
             },
             {
@@ -20,6 +20,11 @@ export const navbar = defineNavbarConfig([
     icon: "mdi:compass",
     items: [
       { text: "Configure ACLs", link: "/guides/configure-acl", icon: "mdi:shield-lock" },
+      { text: "Role Templates", link: "/guides/role-templates", icon: "mdi:file-document-multiple" },
+      { text: "Scheduled Access", link: "/guides/scheduled-access", icon: "mdi:calendar-clock" },
+      { text: "Delegated Access", link: "/guides/delegated-access", icon: "mdi:account-arrow-right" },
+      { text: "Idle Sessions", link: "/guides/idle-sessions", icon: "mdi:timer-sand" },
+      { text: "Audit Correlation", link: "/guides/audit-correlation", icon: "mdi:file-search" },
       { text: "Shell Integration", link: "/guides/shell-integration", icon: "mdi:console" },
       { text: "Use Subshell", link: "/guides/use-subshell", icon: "mdi:layers" },
       { text: "CLI Autocompletion", link: "/guides/autocompletion", icon: "mdi:keyboard" },
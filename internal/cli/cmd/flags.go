@@ -5,7 +5,13 @@ import (
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
 	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spechtlabs/tka/internal/utils"
 	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/operator"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/audit"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -64,6 +70,41 @@ func addServerFlags(cmd *cobra.Command) {
 	viper.SetDefault("server.readHeaderTimeout", 5*time.Second)
 	viper.SetDefault("server.writeTimeout", 20*time.Second)
 	viper.SetDefault("server.idleTimeout", 120*time.Second)
+	viper.SetDefault("tailscale.allowTaggedNodes", false)
+	viper.SetDefault("tailscale.rejectLoginsWhileDisconnected", false)
+	viper.SetDefault("tailscale.stateSecret", "")
+	viper.SetDefault("tailscale.ha.enabled", false)
+	viper.SetDefault("tailscale.ha.replica", "")
+	viper.SetDefault("tailscale.ha.service", "")
+	viper.SetDefault("operator.versionRefreshInterval", utils.DefaultServerVersionRefreshInterval)
+	viper.SetDefault("operator.tokenCache.enabled", true)
+	viper.SetDefault("operator.tokenCache.rotateBefore", k8s.DefaultTokenCacheRotateBefore)
+	viper.SetDefault("operator.tokenCache.encryptionKey", "")
+	viper.SetDefault("operator.maxTokenTTL", time.Duration(0))
+	viper.SetDefault("operator.webhook.enabled", false)
+	viper.SetDefault("operator.webhook.port", operator.DefaultWebhookPort)
+	viper.SetDefault("operator.webhook.certDir", "")
+	viper.SetDefault("operator.webhook.allowedRoles", []string{})
+	viper.SetDefault("operator.webhook.allowedUsers", []string{operator.DefaultWebhookAllowedUser})
+	viper.SetDefault("operator.webhook.maxValidity", operator.DefaultWebhookMaxValidity)
+	viper.SetDefault("operator.idleTimeouts", map[string]string{})
+	viper.SetDefault("api.reason.pattern", "")
+	viper.SetDefault("api.reason.callbackURL", "")
+	viper.SetDefault("api.reason.callbackTimeout", reason.DefaultCallbackTimeout)
+	viper.SetDefault("api.preSignin.url", "")
+	viper.SetDefault("api.preSignin.timeout", presignin.DefaultTimeout)
+	viper.SetDefault("api.preSignin.failOpen", false)
+	viper.SetDefault("api.preSignin.hmacSecret", "")
+	viper.SetDefault("api.preSignin.tls.certFile", "")
+	viper.SetDefault("api.preSignin.tls.keyFile", "")
+	viper.SetDefault("api.preSignin.tls.caFile", "")
+	viper.SetDefault("api.schedules.leadTime", k8s.DefaultShiftLeadTime)
+	viper.SetDefault("api.extend.maxPerDay", api.DefaultMaxExtensionPerDay)
+	viper.SetDefault("audit.enabled", false)
+	viper.SetDefault("audit.token", "")
+	viper.SetDefault("audit.maxBodyBytes", audit.DefaultMaxBodyBytes)
+	viper.SetDefault("metrics.usernameLabels", true)
+	viper.SetDefault("metrics.expiringSoonWindow", operator.DefaultExpiringSoonWindow)
 
 	cmd.PersistentFlags().StringP("dir", "d", "", "tsnet state directory; a default one will be created if not provided")
 	viper.SetDefault("tailscale.stateDir", "")
@@ -94,4 +135,12 @@ func addClientFlags(cmd *cobra.Command) {
 	})
 
 	cmd.PersistentFlags().BoolP("no-eval", "e", false, "Do not evaluate the command")
+
+	viper.SetDefault("tailscale.ha.replicas", []string{})
+
+	cmd.PersistentFlags().Bool("trace", false, "Print the trace ID of the command's requests, e.g. to hand to support")
+	viper.SetDefault("output.trace", false)
+	if err := viper.BindPFlag("output.trace", cmd.PersistentFlags().Lookup("trace")); err != nil {
+		panic(humane.Wrap(err, "fatal binding flag", "check that the flag name matches the viper key")) //nolint:nopanic // flag binding errors are programming errors
+	}
 }
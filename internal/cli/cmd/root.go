@@ -55,7 +55,7 @@ func NewRootCmd() *cobra.Command {
 func NewCliRootCmd() *cobra.Command {
 	cmdRoot := NewRootCmd()
 	addClientFlags(cmdRoot)
-	cmdRoot.Use = "tka [--config|-c <string>] [--debug] [--server|-s <string>] [--port|-p <int>] [--long|-l] [--theme|-t <string>] [--no-eval|-e]"
+	cmdRoot.Use = "tka [--config|-c <string>] [--debug] [--server|-s <string>] [--port|-p <int>] [--long|-l] [--theme|-t <string>] [--no-eval|-e] [--trace]"
 
 	cmdRoot.Long = `tka is the client for Tailscale Kubernetes Auth. It lets you authenticate to clusters over Tailscale, manage kubeconfig entries, and inspect status with readable, themed output.
 
@@ -81,6 +81,9 @@ $ TKA_OUTPUT_THEME=light tka kubeconfig
 
 # no theme (useful in non-interactive contexts)
 $ tka --theme notty login
+
+# print the trace ID to hand to support
+$ tka --trace login
 `
 
 	cmdRoot.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
@@ -92,6 +95,8 @@ $ tka --theme notty login
 			viper.Set("output.theme", pretty_print.TokyoNightStyle)
 			return humane.New("invalid theme: "+theme, "use one of the supported themes: "+fmt.Sprintf("%v", pretty_print.AllThemeNames()))
 		}
+
+		startTrace(cmd)
 		return nil
 	}
 
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/spechtlabs/tka/internal/cli/pretty_print"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTrace starts the trace the API requests of a command belong to. The CLI does not record spans
+// itself; it only sends the trace context along with its requests, so the spans of the server and the
+// operator become part of one trace per command.
+func startTrace(cmd *cobra.Command) {
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	_, _ = rand.Read(traceID[:])
+	_, _ = rand.Read(spanID[:])
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd.SetContext(trace.ContextWithSpanContext(ctx, spanContext))
+
+	if viper.GetBool("output.trace") {
+		_, _ = fmt.Fprint(cmd.ErrOrStderr(), pretty_print.FormatInfo("Trace ID: "+traceID.String(), "share it with your administrator to look up this command in the server's traces"))
+	}
+}
@@ -3,6 +3,8 @@ package pretty_print
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -27,6 +29,11 @@ func PrintLoginInformation(respBody *models.UserLoginResponse) {
 		boldStyle(options.Theme).Render("Until:"), normalStyle(options.Theme).Render(formattedUntil),
 	)
 
+	// Access requested ahead of a scheduled shift starts later
+	if fromTime, err := time.Parse(time.RFC3339, respBody.NotBefore); err == nil {
+		content += fmt.Sprintf("\n%s %s", boldStyle(options.Theme).Render("From: "), normalStyle(options.Theme).Render(fromTime.Format(time.RFC1123)))
+	}
+
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(okColor(options.Theme)).
@@ -61,6 +68,13 @@ func PrintLoginInfoWithProvisioning(respBody *models.UserLoginResponse, httpCode
 		boldStyle(options.Theme).Render("Provisioned:"), normalStyle(options.Theme).Render(formattedProvisioned),
 	)
 
+	if len(respBody.Permissions) > 0 {
+		content += "\n" + boldStyle(options.Theme).Render("Permissions:")
+		for _, rule := range respBody.Permissions {
+			content += "\n  " + normalStyle(options.Theme).Render(FormatPermissionRule(rule))
+		}
+	}
+
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color("10")).
@@ -71,3 +85,27 @@ func PrintLoginInfoWithProvisioning(respBody *models.UserLoginResponse, httpCode
 
 	_, _ = fmt.Fprintln(os.Stdout, boxStyle.Render(content))
 }
+
+// FormatPermissionRule renders a permission rule as "<verbs> <targets>", e.g.
+// "get, list, watch pods, deployments.apps" or "get /healthz".
+func FormatPermissionRule(rule models.PermissionRule) string {
+	targets := slices.Clone(rule.NonResourceURLs)
+	for _, resource := range rule.Resources {
+		if len(rule.APIGroups) == 0 {
+			targets = append(targets, resource)
+		}
+		for _, group := range rule.APIGroups {
+			if group == "" {
+				targets = append(targets, resource)
+			} else {
+				targets = append(targets, resource+"."+group)
+			}
+		}
+	}
+
+	summary := strings.Join(rule.Verbs, ", ") + " " + strings.Join(targets, ", ")
+	if len(rule.ResourceNames) > 0 {
+		summary += " (" + strings.Join(rule.ResourceNames, ", ") + ")"
+	}
+	return summary
+}
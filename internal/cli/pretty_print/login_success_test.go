@@ -0,0 +1,39 @@
+package pretty_print
+
+import (
+	"testing"
+
+	"github.com/spechtlabs/tka/pkg/service/models"
+)
+
+func TestFormatPermissionRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.PermissionRule
+		want string
+	}{
+		{
+			name: "core_and_named_groups",
+			rule: models.PermissionRule{Verbs: []string{"get", "list"}, APIGroups: []string{"", "apps"}, Resources: []string{"deployments"}},
+			want: "get, list deployments, deployments.apps",
+		},
+		{
+			name: "resource_names",
+			rule: models.PermissionRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"cluster-info"}},
+			want: "get configmaps (cluster-info)",
+		},
+		{
+			name: "non_resource_urls",
+			rule: models.PermissionRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz", "/version"}},
+			want: "get /healthz, /version",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FormatPermissionRule(tc.rule); got != tc.want {
+				t.Fatalf("unexpected output.\nwant: %q\n got: %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,41 +1,113 @@
 package utils
 
 import (
+	"context"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/discovery"
-	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-// IsK8sVerAtLeast checks if the cluster's Kubernetes version is at least the specified
-// major.minor version. It queries the Kubernetes API server to determine the current version.
-func IsK8sVerAtLeast(majorVersion, minorVersion int) (bool, humane.Error) {
-	config, err := ctrl.GetConfig()
-	if err != nil {
-		return false, humane.Wrap(err, "Failed to get Kubernetes config", "ensure you're running inside a Kubernetes cluster or have valid kubeconfig")
+// DefaultServerVersionRefreshInterval is how often ServerVersion re-queries the API server by default.
+const DefaultServerVersionRefreshInterval = 10 * time.Minute
+
+// ServerVersion caches the Kubernetes API server version. It is shared by the operator and the API,
+// so checking which cluster versions are supported does not cost a discovery round-trip each time.
+// Run refreshes the cached value to pick up control plane upgrades.
+type ServerVersion struct {
+	discovery discovery.ServerVersionInterface
+
+	mu   sync.RWMutex
+	info *version.Info
+}
+
+// NewServerVersion fetches the server version from d and returns a cache for it.
+func NewServerVersion(d discovery.ServerVersionInterface) (*ServerVersion, humane.Error) {
+	v := &ServerVersion{discovery: d}
+	if err := v.Refresh(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Run refreshes the cached server version every interval until ctx is done.
+// Failed refreshes keep the previous value.
+func (v *ServerVersion) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultServerVersionRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.Refresh(); err != nil {
+				otelzap.L().WithError(err).WarnContext(ctx, "Failed to refresh Kubernetes server version", zap.String("cached_version", v.Info().String()))
+			}
+		}
 	}
+}
 
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+// Refresh queries the API server and replaces the cached version.
+func (v *ServerVersion) Refresh() humane.Error {
+	info, err := v.discovery.ServerVersion()
 	if err != nil {
-		return false, humane.Wrap(err, "Failed to create discovery client", "check cluster connectivity and authentication")
+		return humane.Wrap(err, "Failed to get Kubernetes version", "check cluster connectivity and API server availability")
+	}
+
+	if _, _, herr := parseVersion(info); herr != nil {
+		return herr
 	}
 
-	versionInfo, err := discoveryClient.ServerVersion()
+	v.mu.Lock()
+	v.info = info
+	v.mu.Unlock()
+	return nil
+}
+
+// Info returns the cached version information.
+func (v *ServerVersion) Info() *version.Info {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.info
+}
+
+// AtLeast reports whether the cached server version is at least majorVersion.minorVersion.
+func (v *ServerVersion) AtLeast(majorVersion, minorVersion int) bool {
+	currentMajor, currentMinor, err := parseVersion(v.Info())
 	if err != nil {
-		return false, humane.Wrap(err, "Failed to get Kubernetes version", "check cluster connectivity and API server availability")
+		return false
+	}
+
+	return (currentMajor > majorVersion) || (currentMajor == majorVersion && currentMinor >= minorVersion)
+}
+
+// parseVersion extracts the numeric major and minor version. Managed distributions
+// report versions like "29+", so any non-numeric suffix is ignored.
+func parseVersion(info *version.Info) (int, int, humane.Error) {
+	if info == nil {
+		return 0, 0, humane.New("Kubernetes version unknown", "check cluster connectivity and API server availability")
 	}
 
-	currentMajor, err := strconv.Atoi(versionInfo.Major)
+	currentMajor, err := strconv.Atoi(strings.TrimRight(info.Major, "+"))
 	if err != nil {
-		return false, humane.Wrap(err, "Failed to parse Kubernetes major version", "this indicates an unexpected version format from the API server")
+		return 0, 0, humane.Wrap(err, "Failed to parse Kubernetes major version", "this indicates an unexpected version format from the API server")
 	}
 
-	currentMinor, err := strconv.Atoi(versionInfo.Minor)
+	currentMinor, err := strconv.Atoi(strings.TrimRight(info.Minor, "+"))
 	if err != nil {
-		return false, humane.Wrap(err, "Failed to parse Kubernetes minor version", "this indicates an unexpected version format from the API server")
+		return 0, 0, humane.Wrap(err, "Failed to parse Kubernetes minor version", "this indicates an unexpected version format from the API server")
 	}
 
-	// Check if current version is at least the required version
-	return (currentMajor > majorVersion) || (currentMajor == majorVersion && currentMinor >= minorVersion), nil
+	return currentMajor, currentMinor, nil
 }
@@ -0,0 +1,141 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/internal/utils"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeDiscovery(major, minor string) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{
+		Fake:               &k8stesting.Fake{},
+		FakedServerVersion: &version.Info{Major: major, Minor: minor},
+	}
+}
+
+func TestServerVersion_AtLeast(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    string
+		minor    string
+		atLeast  [2]int
+		expected bool
+	}{
+		{name: "same version", major: "1", minor: "24", atLeast: [2]int{1, 24}, expected: true},
+		{name: "newer minor", major: "1", minor: "30", atLeast: [2]int{1, 24}, expected: true},
+		{name: "older minor", major: "1", minor: "23", atLeast: [2]int{1, 24}, expected: false},
+		{name: "newer major", major: "2", minor: "0", atLeast: [2]int{1, 24}, expected: true},
+		{name: "older major", major: "0", minor: "99", atLeast: [2]int{1, 24}, expected: false},
+		{name: "managed distribution suffix", major: "1", minor: "29+", atLeast: [2]int{1, 24}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := utils.NewServerVersion(newFakeDiscovery(tt.major, tt.minor))
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, v.AtLeast(tt.atLeast[0], tt.atLeast[1]))
+		})
+	}
+}
+
+func TestServerVersion_Cached(t *testing.T) {
+	d := newFakeDiscovery("1", "30")
+
+	v, err := utils.NewServerVersion(d)
+	require.Nil(t, err)
+	require.Len(t, d.Actions(), 1)
+
+	for range 5 {
+		require.True(t, v.AtLeast(1, 24))
+		require.Equal(t, "30", v.Info().Minor)
+	}
+	require.Len(t, d.Actions(), 1)
+}
+
+func TestServerVersion_Refresh(t *testing.T) {
+	d := newFakeDiscovery("1", "23")
+
+	v, err := utils.NewServerVersion(d)
+	require.Nil(t, err)
+	require.False(t, v.AtLeast(1, 24))
+
+	// The control plane was upgraded
+	d.FakedServerVersion = &version.Info{Major: "1", Minor: "30"}
+	require.Nil(t, v.Refresh())
+	require.True(t, v.AtLeast(1, 24))
+
+	// A failed refresh keeps the cached version
+	d.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	require.NotNil(t, v.Refresh())
+	require.Equal(t, "30", v.Info().Minor)
+}
+
+func TestServerVersion_Run(t *testing.T) {
+	d := newFakeDiscovery("1", "23")
+
+	v, err := utils.NewServerVersion(d)
+	require.Nil(t, err)
+
+	// Set before Run starts, the fake discovery client is not safe for concurrent use
+	d.FakedServerVersion = &version.Info{Major: "1", Minor: "30"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		v.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return v.AtLeast(1, 24) }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond, "Run must return once the context is done")
+}
+
+func TestNewServerVersion_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       *fakediscovery.FakeDiscovery
+		wantErr string
+	}{
+		{name: "unparsable major", d: newFakeDiscovery("v1", "30"), wantErr: "major version"},
+		{name: "unparsable minor", d: newFakeDiscovery("1", "thirty"), wantErr: "minor version"},
+		{
+			name: "discovery fails",
+			d: func() *fakediscovery.FakeDiscovery {
+				d := newFakeDiscovery("1", "30")
+				d.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("connection refused")
+				})
+				return d
+			}(),
+			wantErr: "Failed to get Kubernetes version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := utils.NewServerVersion(tt.d)
+			require.Nil(t, v)
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/spechtlabs/go-otel-utils/otelprovider"
 	"github.com/spechtlabs/go-otel-utils/otelzap"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +38,9 @@ func InitObservability() func() {
 	logProvider := otelprovider.NewLogger(loggerOptions...)
 	traceProvider := otelprovider.NewTracer(tracerOptions...)
 
+	// Propagate W3C trace context, so CLI requests, the API server and the operator share one trace
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
 	// Initialize Logging
 	debug := viper.GetBool("debug")
 	var zapLogger *zap.Logger
@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ActivityResolution is how much newer activity must be than the recorded one before the sign-in is
+// updated again. It keeps busy sessions from writing to the API server on every request.
+const ActivityResolution = time.Minute
+
+// RecordActivity notes that the ServiceAccount made a request to the API server at the given time.
+// Requests of ServiceAccounts that don't belong to a provisioned sign-in are ignored.
+func (t *tkaClient) RecordActivity(ctx context.Context, namespace, serviceAccount string, at time.Time) humane.Error {
+	ctx, span := t.tracer.Start(ctx, "TkaClient.RecordActivity")
+	defer span.End()
+	span.SetAttributes(attribute.String("activity.service_account", serviceAccount))
+
+	// Sign-ins share the name of their ServiceAccount
+	if namespace != t.opts.Namespace || !strings.HasPrefix(serviceAccount, DefaultUserEntryPrefix) {
+		return nil
+	}
+
+	var signIn v1alpha1.TkaSignin
+	if err := t.client.Get(ctx, client.ObjectKey{Name: serviceAccount, Namespace: namespace}, &signIn); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return humane.Wrap(err, "Failed to load sign-in request", "check Kubernetes connectivity and read permissions")
+	}
+
+	if !signIn.Status.Provisioned {
+		return nil
+	}
+
+	if last, err := time.Parse(time.RFC3339, signIn.Status.LastActivity); err == nil && at.Before(last.Add(ActivityResolution)) {
+		return nil
+	}
+
+	signIn.Status.LastActivity = at.UTC().Format(time.RFC3339)
+	if err := t.client.Status().Update(ctx, &signIn); err != nil {
+		return humane.Wrap(err, "Failed to record activity of sign-in "+signIn.Name, "check Kubernetes permissions for updating TkaSignin status")
+	}
+
+	return nil
+}
+
+// IdleDeadline returns when a sign-in that goes unused is idle for longer than timeout. Its credentials count as used
+// when they last made a request and when the user last signed in. It returns false if the sign-in was never provisioned.
+func IdleDeadline(signIn *v1alpha1.TkaSignin, timeout time.Duration) (time.Time, bool) {
+	if !signIn.Status.Provisioned {
+		return time.Time{}, false
+	}
+
+	var lastActive time.Time
+	for _, ts := range []string{signIn.Status.SignedInAt, signIn.Annotations[LastAttemptedSignIn], signIn.Status.LastActivity} {
+		if at, err := time.Parse(time.RFC3339, ts); err == nil && at.After(lastActive) {
+			lastActive = at
+		}
+	}
+
+	if lastActive.IsZero() {
+		return time.Time{}, false
+	}
+
+	return lastActive.Add(timeout), true
+}
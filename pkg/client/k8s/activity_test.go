@@ -0,0 +1,164 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordActivity(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	signedIn := now.Add(-10 * time.Minute)
+
+	tests := []struct {
+		name           string
+		lastActivity   string
+		provisioned    bool
+		namespace      string
+		serviceAccount string
+		at             time.Time
+		expected       string
+	}{
+		{
+			name:           "records first activity",
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-alice",
+			at:             now,
+			expected:       now.Format(time.RFC3339),
+		},
+		{
+			name:           "records newer activity",
+			lastActivity:   now.Add(-5 * time.Minute).Format(time.RFC3339),
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-alice",
+			at:             now,
+			expected:       now.Format(time.RFC3339),
+		},
+		{
+			name:           "skips activity within the resolution",
+			lastActivity:   now.Add(-30 * time.Second).Format(time.RFC3339),
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-alice",
+			at:             now,
+			expected:       now.Add(-30 * time.Second).Format(time.RFC3339),
+		},
+		{
+			name:           "ignores older activity",
+			lastActivity:   now.Format(time.RFC3339),
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-alice",
+			at:             now.Add(-5 * time.Minute),
+			expected:       now.Format(time.RFC3339),
+		},
+		{
+			name:           "ignores sign-ins that are not provisioned",
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-alice",
+			at:             now,
+		},
+		{
+			name:           "ignores other namespaces",
+			provisioned:    true,
+			namespace:      "kube-system",
+			serviceAccount: "tka-user-alice",
+			at:             now,
+		},
+		{
+			name:           "ignores other service accounts",
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "default",
+			at:             now,
+		},
+		{
+			name:           "ignores users that are not signed in",
+			provisioned:    true,
+			namespace:      testNamespace,
+			serviceAccount: "tka-user-bob",
+			at:             now,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signIn := newExtendTestSignin(signedIn, time.Hour, "")
+			signIn.Status.Provisioned = tc.provisioned
+			signIn.Status.LastActivity = tc.lastActivity
+			tkaClient := newRoleTestClient(t, signIn, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}})
+			ctx := context.Background()
+
+			require.Nil(t, tkaClient.RecordActivity(ctx, tc.namespace, tc.serviceAccount, tc.at))
+
+			info, err := tkaClient.GetStatus(ctx, "alice")
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, info.LastActivity)
+		})
+	}
+}
+
+func TestIdleDeadline(t *testing.T) {
+	signedIn := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		signIn       func() *v1alpha1.TkaSignin
+		lastActivity string
+		idleTimeout  time.Duration
+		expected     time.Time
+		expectedOK   bool
+	}{
+		{
+			name:        "counts from the sign-in without activity",
+			signIn:      func() *v1alpha1.TkaSignin { return newExtendTestSignin(signedIn, time.Hour, "") },
+			idleTimeout: 15 * time.Minute,
+			expected:    signedIn.Add(15 * time.Minute),
+			expectedOK:  true,
+		},
+		{
+			name:         "counts from the last activity",
+			signIn:       func() *v1alpha1.TkaSignin { return newExtendTestSignin(signedIn, time.Hour, "") },
+			lastActivity: "2026-01-01T10:20:00Z",
+			idleTimeout:  15 * time.Minute,
+			expected:     signedIn.Add(35 * time.Minute),
+			expectedOK:   true,
+		},
+		{
+			name: "counts from a repeated sign-in",
+			signIn: func() *v1alpha1.TkaSignin {
+				s := newExtendTestSignin(signedIn, time.Hour, "")
+				s.Annotations[k8s.LastAttemptedSignIn] = "2026-01-01T10:30:00Z"
+				return s
+			},
+			lastActivity: "2026-01-01T10:20:00Z",
+			idleTimeout:  15 * time.Minute,
+			expected:     signedIn.Add(45 * time.Minute),
+			expectedOK:   true,
+		},
+		{
+			name:        "not provisioned",
+			signIn:      func() *v1alpha1.TkaSignin { return k8s.NewSignin("alice", "view", time.Hour, testNamespace) },
+			idleTimeout: 15 * time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signIn := tc.signIn()
+			signIn.Status.LastActivity = tc.lastActivity
+
+			deadline, ok := k8s.IdleDeadline(signIn, tc.idleTimeout)
+			require.Equal(t, tc.expectedOK, ok)
+			require.True(t, tc.expected.Equal(deadline), "expected %s, got %s", tc.expected, deadline)
+		})
+	}
+}
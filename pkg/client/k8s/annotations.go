@@ -6,4 +6,42 @@ const (
 	LastAttemptedSignIn = "tka.specht-labs.de/last-attempted-sign-in"
 	// SignInValidUntil stores the expiration timestamp of the current sign-in.
 	SignInValidUntil = "tka.specht-labs.de/sign-in-valid-until"
+	// SignInReason stores the justification the user gave for signing in.
+	SignInReason = "tka.specht-labs.de/sign-in-reason"
+	// SignInLoginName stores the Tailscale login name of the person who signed in, including the domain.
+	SignInLoginName = "tka.specht-labs.de/login-name"
+	// SignInDevice stores the name of the Tailscale device the user signed in from.
+	SignInDevice = "tka.specht-labs.de/device"
+	// SignInDeviceID stores the stable node ID of the Tailscale device the user signed in from.
+	SignInDeviceID = "tka.specht-labs.de/device-id"
+	// SignInSessionID stores the UID of the TkaSignin a ServiceAccount was created for. It stays the same
+	// while the session is extended and changes with every new sign-in after signing out.
+	SignInSessionID = "tka.specht-labs.de/session-id"
+	// SignInOwner stores the namespace/name of the sign-in an object was created from by a TkaRoleTemplate.
+	SignInOwner = "tka.specht-labs.de/signin"
+	// TraceParent stores the W3C traceparent of the request that created or last changed a TkaSignin, so the
+	// operator can link its reconciliation to it.
+	TraceParent = "tka.specht-labs.de/traceparent"
+	// TraceState stores the W3C tracestate that accompanies TraceParent, if any.
+	TraceState = "tka.specht-labs.de/tracestate"
+	// TokenCacheSessionHash stores the session fingerprint a cached token was issued for.
+	TokenCacheSessionHash = "tka.specht-labs.de/session-hash"
+)
+
+// SigninFinalizer keeps a TkaSignin until the operator removed everything it created for it, including
+// objects a sign-in cannot own, such as cluster-scoped ones and bindings in other namespaces.
+const SigninFinalizer = "tka.specht-labs.de/deprovision"
+
+// Label keys used on TKA resources to find related objects.
+const (
+	// DelegatedFromLabel stores the name of the TkaSignin a delegated sign-in was derived from.
+	DelegatedFromLabel = "tka.specht-labs.de/delegated-from"
+	// SignInLabel stores the name of the TkaSignin a binding outside the sign-in's namespace was created for,
+	// so it is found and removed without relying on the sign-in's spec.
+	SignInLabel = "tka.specht-labs.de/signin"
+	// TokenCacheLabel marks the Secrets caching issued tokens, the only Secrets the operator's cache holds.
+	TokenCacheLabel = "tka.specht-labs.de/token-cache"
+	// ExtensionUsageLabel marks the ConfigMaps recording how far users extended their sessions today, the only
+	// ConfigMaps the operator's cache holds.
+	ExtensionUsageLabel = "tka.specht-labs.de/extension-usage"
 )
@@ -11,15 +11,11 @@ import (
 	"github.com/sierrasoftworks/humane-errors-go"
 	"github.com/spechtlabs/go-otel-utils/otelzap"
 	"github.com/spechtlabs/tka/api/v1alpha1"
-	"github.com/spechtlabs/tka/internal/utils"
 	"github.com/spechtlabs/tka/pkg/service/models"
 	"go.uber.org/zap"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd/api"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -27,12 +23,12 @@ type tkaClient struct {
 	client      client.Client
 	tracer      trace.Tracer
 	opts        ClientOptions
-	clusterInfo *models.TkaClusterInfo
+	clusterInfo *models.ClusterInfoStore
 }
 
 // NewTkaClient creates a new TkaClient instance with the provided Kubernetes client,
-// cluster information, and configuration options.
-func NewTkaClient(client client.Client, clusterInfo *models.TkaClusterInfo, opts ClientOptions) TkaClient {
+// cluster information, and configuration options. Kubeconfigs always use the current cluster information.
+func NewTkaClient(client client.Client, clusterInfo *models.ClusterInfoStore, opts ClientOptions) TkaClient {
 	return &tkaClient{
 		client:      client,
 		clusterInfo: clusterInfo,
@@ -42,7 +38,7 @@ func NewTkaClient(client client.Client, clusterInfo *models.TkaClusterInfo, opts
 }
 
 // NewSignIn creates necessary Kubernetes resources to grant a user temporary access with a specific role
-func (t *tkaClient) NewSignIn(ctx context.Context, userName, role string, validPeriod time.Duration) humane.Error {
+func (t *tkaClient) NewSignIn(ctx context.Context, userName, role string, validPeriod time.Duration, opts ...SignInOption) humane.Error {
 	ctx, span := t.tracer.Start(ctx, "TkaClient.NewUser")
 	defer span.End()
 
@@ -53,6 +49,20 @@ func (t *tkaClient) NewSignIn(ctx context.Context, userName, role string, validP
 	}
 
 	signin := NewSignin(userName, role, validPeriod, t.opts.Namespace)
+	for _, opt := range opts {
+		opt(signin)
+	}
+	SetTraceParent(ctx, &signin.ObjectMeta)
+
+	if err := validateTokenTTL(signin); err != nil {
+		return err
+	}
+
+	// Refuse to sign in to a role that grants nothing rather than reporting a successful login
+	if _, err := GetClusterRole(ctx, t.client, role); err != nil {
+		return err
+	}
+
 	if err := t.client.Create(ctx, signin); err != nil && k8serrors.IsAlreadyExists(err) {
 		otelzap.L().DebugContext(ctx, "User already signed in",
 			zap.String("user", userName),
@@ -64,8 +74,19 @@ func (t *tkaClient) NewSignIn(ctx context.Context, userName, role string, validP
 			return humane.Wrap(err, "Failed to load existing sign-in request", "check Kubernetes connectivity and permissions")
 		}
 
+		// The operator is still removing the previous session, and would remove this one with it
+		if !existing.DeletionTimestamp.IsZero() {
+			return humane.New("Previous session of "+userName+" is still being signed out", "try signing in again in a few seconds")
+		}
+
 		existing.Spec.ValidityPeriod = signin.Spec.ValidityPeriod
 		existing.Spec.Role = signin.Spec.Role
+		existing.Spec.TokenTTL = signin.Spec.TokenTTL
+		existing.Spec.Device = signin.Spec.Device
+		existing.Spec.LoginName = signin.Spec.LoginName
+		existing.Spec.Reason = signin.Spec.Reason
+		existing.Spec.NotBefore = signin.Spec.NotBefore
+		existing.Spec.NotAfter = signin.Spec.NotAfter
 		existing.Annotations = signin.Annotations
 		if err := t.client.Update(ctx, existing); err != nil {
 			return humane.Wrap(err, "Failed to update existing sign-in request", "check Kubernetes permissions for updating TkaSignin resources")
@@ -102,7 +123,7 @@ func (t *tkaClient) GetSignIn(ctx context.Context, userName string) (*v1alpha1.T
 	return &signIn, nil
 }
 
-func (t *tkaClient) GetKubeconfig(ctx context.Context, userName string) (*api.Config, humane.Error) {
+func (t *tkaClient) GetKubeconfig(ctx context.Context, userName string) (*api.Config, time.Time, humane.Error) {
 	ctx, span := t.tracer.Start(ctx, "TkaClient.GetKubeconfig")
 	defer span.End()
 
@@ -114,19 +135,35 @@ func (t *tkaClient) GetKubeconfig(ctx context.Context, userName string) (*api.Co
 	var signIn v1alpha1.TkaSignin
 	if err := t.client.Get(ctx, resName, &signIn); err != nil {
 		if k8serrors.IsNotFound(err) {
-			return nil, humane.Wrap(err, "User not signed in", "run 'tka login' to sign in first")
+			return nil, time.Time{}, humane.Wrap(err, "User not signed in", "run 'tka login' to sign in first")
 		}
-		return nil, humane.Wrap(err, "Failed to load sign-in request", "check Kubernetes connectivity and read permissions")
+		return nil, time.Time{}, humane.Wrap(err, "Failed to load sign-in request", "check Kubernetes connectivity and read permissions")
+	}
+
+	if !RoleAvailable(&signIn) {
+		return nil, time.Time{}, NewRoleNotFoundError(signIn.Spec.Role)
+	}
+
+	if signIn.Spec.Suspended {
+		return nil, time.Time{}, NewSessionSuspendedError(userName)
 	}
 
 	if !signIn.Status.Provisioned {
-		return nil, NotReadyYetError
+		return nil, time.Time{}, NotReadyYetError
 	}
 
 	// Generate token for ServiceAccount
-	token, err := t.generateToken(ctx, &signIn)
-	if err != nil {
-		return nil, humane.Wrap(err, "Failed to generate token", "check that the service account exists and Kubernetes has token generation enabled")
+	token, shortLived, err := t.generateToken(ctx, &signIn)
+	if err == NotReadyYetError {
+		return nil, time.Time{}, NotReadyYetError
+	} else if err != nil {
+		return nil, time.Time{}, humane.Wrap(err, "Failed to generate token", "check that the service account exists and Kubernetes has token generation enabled")
+	}
+
+	// Clients only need to fetch a new token if it expires before the session does
+	var expiresAt time.Time
+	if shortLived {
+		expiresAt = token.ExpiresAt
 	}
 
 	clusterName := t.opts.ClusterName
@@ -136,11 +173,11 @@ func (t *tkaClient) GetKubeconfig(ctx context.Context, userName string) (*api.Co
 	// Use discovered external cluster information for clients
 	return NewKubeconfig(
 		contextName,
-		t.clusterInfo,
-		token,
+		t.clusterInfo.Load(),
+		token.Token,
 		clusterName,
 		userEntry,
-	), nil
+	), expiresAt, nil
 }
 
 func (t *tkaClient) DeleteSignIn(ctx context.Context, userName string) humane.Error {
@@ -157,6 +194,11 @@ func (t *tkaClient) DeleteSignIn(ctx context.Context, userName string) humane.Er
 		return humane.Wrap(err, "Failed to load sign-in request", "check Kubernetes connectivity and read permissions")
 	}
 
+	// Signing out and in again would start a new session without the suspension
+	if signIn.Spec.Suspended {
+		return NewSessionSuspendedError(userName)
+	}
+
 	if err := t.client.Delete(ctx, &signIn); err != nil {
 		return humane.Wrap(err, "Failed to remove sign-in request", "check Kubernetes permissions for deleting TkaSignin resources")
 	}
@@ -170,57 +212,96 @@ func (t *tkaClient) GetStatus(ctx context.Context, username string) (*SignInInfo
 		return nil, err
 	}
 
-	return &SignInInfo{
+	if !RoleAvailable(signIn) {
+		return nil, NewRoleNotFoundError(signIn.Spec.Role)
+	}
+
+	info := &SignInInfo{
 		Username:       signIn.Spec.Username,
 		Role:           signIn.Spec.Role,
 		ValidityPeriod: signIn.Spec.ValidityPeriod,
 		ValidUntil:     signIn.Status.ValidUntil,
 		Provisioned:    signIn.Status.Provisioned,
-	}, nil
-}
-
-// generateToken creates a token for the service account in Kubernetes versions >= 1.30 do no longer
-// automatically include a token for new ServiceAccounts, thus we have to manually create one,
-// so we can use it when assembling the kubeconfig for the user
-func (t *tkaClient) generateToken(ctx context.Context, signIn *v1alpha1.TkaSignin) (string, humane.Error) {
-	// Check if Kubernetes version is at least 1.30
-	isSupported, herr := utils.IsK8sVerAtLeast(1, 30) //nolint:golint-sl // isSupported is used after this if block
-	if herr != nil {
-		return "", herr
+		Suspended:      signIn.Spec.Suspended,
+		NotBefore:      signIn.Spec.NotBefore,
+		NotAfter:       signIn.Spec.NotAfter,
+		Parent:         signIn.Spec.Parent,
+		Namespace:      signIn.Spec.Namespace,
+		LastActivity:   signIn.Status.LastActivity,
+		Reason:         signIn.Spec.Reason,
 	}
 
-	if !isSupported {
-		// Token generation not supported in this Kubernetes version
-		return "", nil
+	if signIn.Status.Provisioned {
+		clusterRole, err := GetClusterRole(ctx, t.client, signIn.Spec.Role)
+		if err != nil {
+			return nil, err
+		}
+		info.Permissions = SummarizePermissions(clusterRole)
 	}
 
-	config, err := ctrl.GetConfig()
-	if err != nil {
-		return "", humane.Wrap(err, "Failed to get Kubernetes config", "ensure the operator is running in a Kubernetes cluster or has valid kubeconfig")
+	return info, nil
+}
+
+// generateToken issues a token for the sign-in's ServiceAccount using the token strategy
+// selected at startup. See tokenTTL for how the requested lifetime is chosen and when the token is short-lived.
+func (t *tkaClient) generateToken(ctx context.Context, signIn *v1alpha1.TkaSignin) (*IssuedToken, bool, humane.Error) {
+	if t.opts.TokenIssuer == nil {
+		return nil, false, humane.New("No token issuer configured", "this indicates a bug in the server setup; please report it")
 	}
 
-	// For Kubernetes >= 1.30, we need to create a token request
-	clientset, err := kubernetes.NewForConfig(config) //nolint:golint-sl // used in CreateToken call below
+	ttl, shortLived, err := t.tokenTTL(signIn)
 	if err != nil {
-		return "", humane.Wrap(err, "Failed to create Kubernetes clientset", "check cluster connectivity and authentication")
+		return nil, false, err
 	}
 
-	// Create a token request with expiration time
+	token, err := t.opts.TokenIssuer.IssueToken(ctx, signIn, ttl)
+	return token, shortLived, err
+}
+
+// tokenTTL returns the lifetime to request for a new token: the remaining validity of the sign-in,
+// capped by the sign-in's own token TTL and the server-wide MaxTokenTTL. It reports whether one of
+// the caps applied, i.e. the token is short-lived and expires before the sign-in.
+func (t *tkaClient) tokenTTL(signIn *v1alpha1.TkaSignin) (time.Duration, bool, humane.Error) {
 	validUntil, err := time.Parse(time.RFC3339, signIn.Status.ValidUntil)
 	if err != nil {
-		return "", humane.Wrap(err, "Failed to parse validUntil", "this indicates a bug in the sign-in status; try signing out and back in")
+		return 0, false, humane.Wrap(err, "Failed to parse validUntil", "this indicates a bug in the sign-in status; try signing out and back in")
+	}
+
+	ttl := time.Until(validUntil)
+	shortLived := false
+	if t.opts.MaxTokenTTL > 0 && t.opts.MaxTokenTTL < ttl {
+		ttl, shortLived = t.opts.MaxTokenTTL, true
 	}
 
-	expirationSeconds := int64(time.Until(validUntil).Seconds())
-	if expirationSeconds < int64(MinSigninValidity.Seconds()) {
-		expirationSeconds = int64(MinSigninValidity.Seconds())
+	if signIn.Spec.TokenTTL != "" {
+		signInTTL, err := time.ParseDuration(signIn.Spec.TokenTTL)
+		if err != nil {
+			return 0, false, humane.Wrap(err, "Failed to parse token TTL of sign-in", "try signing out and back in")
+		}
+		if signInTTL < ttl {
+			ttl, shortLived = signInTTL, true
+		}
 	}
-	tokenRequest := NewTokenRequest(expirationSeconds)
 
-	tokenResponse, err := clientset.CoreV1().ServiceAccounts(signIn.Namespace).CreateToken(ctx, FormatSigninObjectName(signIn.Spec.Username), tokenRequest, metav1.CreateOptions{})
+	return ttl, shortLived, nil
+}
+
+// validateTokenTTL rejects token lifetimes below what the TokenRequest API accepts.
+func validateTokenTTL(signIn *v1alpha1.TkaSignin) humane.Error {
+	if signIn.Spec.TokenTTL == "" {
+		return nil
+	}
+
+	ttl, err := time.ParseDuration(signIn.Spec.TokenTTL)
 	if err != nil {
-		return "", humane.Wrap(err, "Failed to create token for service account", "check that the service account exists and the operator has token creation permissions")
+		return humane.Wrap(err, "Invalid token TTL", "specify `tokenTTL` as a Go duration, e.g. 15m, in your api ACL")
 	}
 
-	return tokenResponse.Status.Token, nil
+	if ttl < MinSigninValidity {
+		return humane.New("`tokenTTL` may not specify a duration less than 10 minutes",
+			fmt.Sprintf("Specify a tokenTTL of at least 10 minutes in your api ACL for user %s", signIn.Spec.Username),
+		)
+	}
+
+	return nil
 }
@@ -0,0 +1,28 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+)
+
+// BenchmarkGetKubeconfig measures concurrent kubeconfig requests against a shared
+// clientset, the hot path hit by every `tka login` and `tka kubeconfig`.
+func BenchmarkGetKubeconfig(b *testing.B) {
+	signIn := newTestSignIn()
+	signIn.Status.ValidUntil = time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	tkaClient, _ := newKubeconfigTestClient(b, signIn, k8s.DefaultClientOptions())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := tkaClient.GetKubeconfig(context.Background(), "alice"); err != nil {
+				b.Fatal(err.Display())
+			}
+		}
+	})
+}
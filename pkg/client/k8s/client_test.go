@@ -0,0 +1,266 @@
+package k8s_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newKubeconfigTestClient returns a TkaClient backed by fake clients holding a provisioned
+// sign-in, and a pointer to the token lifetime (in seconds) that was last requested.
+func newKubeconfigTestClient(tb testing.TB, signIn *v1alpha1.TkaSignin, opts k8s.ClientOptions) (k8s.TkaClient, *atomic.Int64) {
+	tb.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(tb, v1alpha1.AddToScheme(scheme))
+
+	signIn.Status.Provisioned = true
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(signIn).WithStatusSubresource(signIn).Build()
+
+	var requestedSeconds atomic.Int64
+	clientset := newFakeClientset(tb, "29", true, newTestServiceAccount())
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		req := create.GetObject().(*authenticationv1.TokenRequest)
+		requestedSeconds.Store(*req.Spec.ExpirationSeconds)
+		expiry := time.Now().Add(time.Duration(*req.Spec.ExpirationSeconds) * time.Second)
+		req.Status = authenticationv1.TokenRequestStatus{Token: "bound-token", ExpirationTimestamp: metav1.NewTime(expiry)}
+		return true, req, nil
+	})
+
+	opts.Namespace = testNamespace
+	opts.Clientset = clientset
+	opts.TokenIssuer = k8s.NewTokenRequestIssuer(clientset)
+
+	return k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{ServerURL: "https://127.0.0.1:6443"}), opts), &requestedSeconds
+}
+
+func TestGetKubeconfig_TokenTTL(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxTokenTTL  time.Duration
+		signInTTL    time.Duration
+		expectedTTL  time.Duration
+		expectExpiry bool
+	}{
+		{name: "defaults to remaining session", expectedTTL: 8 * time.Hour},
+		{name: "server maximum", maxTokenTTL: 15 * time.Minute, expectedTTL: 15 * time.Minute, expectExpiry: true},
+		{name: "rule below server maximum", maxTokenTTL: time.Hour, signInTTL: 20 * time.Minute, expectedTTL: 20 * time.Minute, expectExpiry: true},
+		{name: "server maximum below rule", maxTokenTTL: 15 * time.Minute, signInTTL: time.Hour, expectedTTL: 15 * time.Minute, expectExpiry: true},
+		{name: "rule without server maximum", signInTTL: 30 * time.Minute, expectedTTL: 30 * time.Minute, expectExpiry: true},
+		{name: "server maximum beyond the session", maxTokenTTL: 24 * time.Hour, expectedTTL: 8 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signIn := k8s.NewSignin("alice", "view", 8*time.Hour, testNamespace)
+			k8s.WithTokenTTL(tc.signInTTL)(signIn)
+			signIn.Status.ValidUntil = time.Now().Add(8 * time.Hour).Format(time.RFC3339)
+
+			opts := k8s.DefaultClientOptions()
+			opts.MaxTokenTTL = tc.maxTokenTTL
+			tkaClient, requestedSeconds := newKubeconfigTestClient(t, signIn, opts)
+
+			cfg, expiresAt, err := tkaClient.GetKubeconfig(context.Background(), "alice")
+			require.Nil(t, err)
+			require.NotNil(t, cfg)
+			require.InDelta(t, tc.expectedTTL.Seconds(), float64(requestedSeconds.Load()), 2)
+
+			// Tokens that last as long as the session need no refresh, so their expiry is not reported
+			if !tc.expectExpiry {
+				require.True(t, expiresAt.IsZero(), "expected no expiry, got %s", expiresAt)
+				return
+			}
+			// ValidUntil is stored with second precision, so allow for truncation on top of test runtime
+			require.WithinDuration(t, time.Now().Add(tc.expectedTTL), expiresAt, 5*time.Second)
+		})
+	}
+}
+
+func TestNewSignIn_RejectsShortTokenTTL(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	tkaClient := k8s.NewTkaClient(fake.NewClientBuilder().WithScheme(scheme).Build(), models.NewClusterInfoStore(&models.TkaClusterInfo{}), k8s.DefaultClientOptions())
+
+	err := tkaClient.NewSignIn(context.Background(), "alice", "view", time.Hour, k8s.WithTokenTTL(time.Minute))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "tokenTTL")
+}
+
+// newRoleTestClient returns a TkaClient backed by a fake client holding the given objects.
+func newRoleTestClient(t *testing.T, objs ...client.Object) k8s.TkaClient {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, rbacv1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithStatusSubresource(&v1alpha1.TkaSignin{}).Build()
+	opts := k8s.DefaultClientOptions()
+	opts.Namespace = testNamespace
+	return k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{}), opts)
+}
+
+func TestNewSignIn_RejectsMissingRole(t *testing.T) {
+	tkaClient := newRoleTestClient(t)
+
+	err := tkaClient.NewSignIn(context.Background(), "alice", "does-not-exist", time.Hour)
+	require.NotNil(t, err)
+	require.ErrorIs(t, err, k8s.ErrRoleNotFound)
+}
+
+func TestNewSignIn_RejectsTerminatingSignin(t *testing.T) {
+	signingOut := k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+	signingOut.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	tkaClient := newRoleTestClient(t, signingOut, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}})
+
+	err := tkaClient.NewSignIn(context.Background(), "alice", "view", time.Hour)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "still being signed out")
+}
+
+func TestNewSignIn_RecordsDevice(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, rbacv1.AddToScheme(scheme))
+
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}).
+		WithStatusSubresource(&v1alpha1.TkaSignin{}).Build()
+	opts := k8s.DefaultClientOptions()
+	opts.Namespace = testNamespace
+	tkaClient := k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{}), opts)
+
+	keyExpiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	who := &tshttp.WhoIsInfo{
+		NodeName:       "laptop",
+		NodeID:         "nABC123CNTRL",
+		OS:             "macOS",
+		ClientVersion:  "1.82.0",
+		KeyExpiry:      keyExpiry,
+		NodeAttributes: []string{"tka:managed"},
+	}
+
+	require.Nil(t, tkaClient.NewSignIn(context.Background(), "alice", "view", time.Hour, k8s.WithDevice(who)))
+
+	var signIn v1alpha1.TkaSignin
+	require.NoError(t, ctrlClient.Get(context.Background(), client.ObjectKey{Namespace: testNamespace, Name: k8s.FormatSigninObjectName("alice")}, &signIn))
+	require.Equal(t, &v1alpha1.TkaSigninDevice{
+		NodeName:       "laptop",
+		NodeID:         "nABC123CNTRL",
+		OS:             "macOS",
+		ClientVersion:  "1.82.0",
+		KeyExpiry:      "2030-01-02T03:04:05Z",
+		NodeAttributes: []string{"tka:managed"},
+	}, signIn.Spec.Device)
+}
+
+func TestGetStatus_Roles(t *testing.T) {
+	viewRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "view"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		provisioned bool
+		roleMissing bool
+		permissions int
+	}{
+		{name: "provisioned sign-in reports permissions", provisioned: true, permissions: 2},
+		{name: "pending sign-in omits permissions", provisioned: false},
+		{name: "missing role is an error", provisioned: true, roleMissing: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signIn := k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+			signIn.Status.Provisioned = tc.provisioned
+			if tc.roleMissing {
+				meta.SetStatusCondition(&signIn.Status.Conditions, metav1.Condition{
+					Type:   v1alpha1.ConditionRoleAvailable,
+					Status: metav1.ConditionFalse,
+					Reason: v1alpha1.ReasonRoleNotFound,
+				})
+			}
+
+			info, err := newRoleTestClient(t, signIn, viewRole).GetStatus(context.Background(), "alice")
+			if tc.roleMissing {
+				require.NotNil(t, err)
+				require.ErrorIs(t, err, k8s.ErrRoleNotFound)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Len(t, info.Permissions, tc.permissions)
+			if tc.permissions > 0 {
+				require.Equal(t, []string{"get", "list"}, info.Permissions[0].Verbs)
+				require.Equal(t, []string{"/healthz"}, info.Permissions[1].NonResourceURLs)
+			}
+		})
+	}
+}
+
+func TestSetSuspended(t *testing.T) {
+	signIn := k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+	signIn.Status.Provisioned = true
+	tkaClient := newRoleTestClient(t, signIn, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}})
+	ctx := context.Background()
+
+	require.Nil(t, tkaClient.SetSuspended(ctx, "alice", true))
+
+	info, err := tkaClient.GetStatus(ctx, "alice")
+	require.Nil(t, err)
+	require.True(t, info.Suspended)
+
+	_, _, err = tkaClient.GetKubeconfig(ctx, "alice")
+	require.NotNil(t, err)
+	require.ErrorIs(t, err, k8s.ErrSessionSuspended)
+
+	// Signing in again must not lift the suspension
+	require.Nil(t, tkaClient.NewSignIn(ctx, "alice", "view", time.Hour))
+	info, err = tkaClient.GetStatus(ctx, "alice")
+	require.Nil(t, err)
+	require.True(t, info.Suspended)
+
+	// Neither may signing out and in again
+	err = tkaClient.DeleteSignIn(ctx, "alice")
+	require.NotNil(t, err)
+	require.ErrorIs(t, err, k8s.ErrSessionSuspended)
+	info, err = tkaClient.GetStatus(ctx, "alice")
+	require.Nil(t, err)
+	require.True(t, info.Suspended)
+
+	require.Nil(t, tkaClient.SetSuspended(ctx, "alice", false))
+	info, err = tkaClient.GetStatus(ctx, "alice")
+	require.Nil(t, err)
+	require.False(t, info.Suspended)
+
+	err = tkaClient.SetSuspended(ctx, "bob", true)
+	require.NotNil(t, err)
+}
@@ -15,6 +15,9 @@ const (
 
 	// MinSigninValidity is the minimum validity period for a token in Kubernetes. This minimum period is enforced by the Kubernetes API.
 	MinSigninValidity = 10 * time.Minute
+
+	// DefaultShiftLeadTime is how long before a scheduled shift starts its users may sign in for it.
+	DefaultShiftLeadTime = time.Hour
 )
 
 // NotReadyYetError is returned when a sign-in request exists but has not yet been provisioned.
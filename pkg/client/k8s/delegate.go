@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrRoleNotDelegable is the cause of errors returned when a delegated role would grant more than the session it is derived from.
+var ErrRoleNotDelegable = errors.New("role not delegable")
+
+// DelegateOptions describe the credential Delegate derives from a user's session.
+type DelegateOptions struct {
+	// Role is the ClusterRole of the delegated credential. Empty uses the role of the session.
+	Role string
+	// Namespace restricts the delegated credential to a single namespace. Empty grants the role cluster-wide.
+	Namespace string
+	// Period is how long the delegated credential is valid. It never outlives the session.
+	Period time.Duration
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// Delegate creates a sign-in for a separate credential derived from the user's provisioned session. The
+// credential is restricted to opts and is revoked together with the session.
+func (t *tkaClient) Delegate(ctx context.Context, userName string, opts DelegateOptions) (*SignInInfo, humane.Error) {
+	ctx, span := t.tracer.Start(ctx, "TkaClient.Delegate")
+	defer span.End()
+
+	parent, err := t.GetSignIn(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent.Spec.Suspended {
+		return nil, NewSessionSuspendedError(userName)
+	}
+
+	if !parent.Status.Provisioned {
+		return nil, NotReadyYetError
+	}
+
+	opts.Role = cmp.Or(opts.Role, parent.Spec.Role)
+	if err := t.checkDelegable(ctx, parent.Spec.Role, opts); err != nil {
+		return nil, err
+	}
+
+	signIn := NewDelegatedSignin(parent, FormatDelegateUsername(userName, utilrand.String(5)), opts)
+	SetTraceParent(ctx, &signIn.ObjectMeta)
+	if err := t.client.Create(ctx, signIn); err != nil {
+		return nil, humane.Wrap(err, "Failed to create delegated sign-in", "check Kubernetes permissions for creating TkaSignin resources")
+	}
+
+	_, until := AccessWindow(time.Now(), opts.Period, "", signIn.Spec.NotAfter)
+	return &SignInInfo{
+		Username:       signIn.Spec.Username,
+		Role:           signIn.Spec.Role,
+		ValidityPeriod: signIn.Spec.ValidityPeriod,
+		ValidUntil:     until.Format(time.RFC3339),
+		NotAfter:       signIn.Spec.NotAfter,
+		Parent:         parent.Name,
+		Namespace:      signIn.Spec.Namespace,
+	}, nil
+}
+
+// checkDelegable ensures the delegated role grants nothing the session's role does not and that its namespace exists.
+func (t *tkaClient) checkDelegable(ctx context.Context, sessionRole string, opts DelegateOptions) humane.Error {
+	if opts.Role != sessionRole {
+		covered, err := roleCovers(ctx, t.client, sessionRole, opts.Role)
+		if err != nil {
+			return err
+		}
+
+		if !covered {
+			return humane.Wrap(fmt.Errorf("%w: %s grants more than %s", ErrRoleNotDelegable, opts.Role, sessionRole),
+				fmt.Sprintf("ClusterRole %q grants permissions your session does not have", opts.Role),
+				fmt.Sprintf("delegate %q or a role whose permissions it includes", sessionRole))
+		}
+	}
+
+	if opts.Namespace == "" {
+		return nil
+	}
+
+	if err := t.client.Get(ctx, client.ObjectKey{Name: opts.Namespace}, &corev1.Namespace{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return humane.Wrap(err, fmt.Sprintf("Namespace %q does not exist", opts.Namespace), "check the namespace for typos")
+		}
+		return humane.Wrap(err, "Failed to load namespace "+opts.Namespace, "check that the TKA server may read namespaces")
+	}
+
+	return nil
+}
+
+// ListDelegations returns the sign-ins delegated from the given sign-in.
+func ListDelegations(ctx context.Context, c client.Reader, parent *v1alpha1.TkaSignin) ([]v1alpha1.TkaSignin, humane.Error) {
+	var signIns v1alpha1.TkaSigninList
+	if err := c.List(ctx, &signIns, client.InNamespace(parent.Namespace), client.MatchingLabels{DelegatedFromLabel: parent.Name}); err != nil {
+		return nil, humane.Wrap(err, "Failed to list delegated sign-ins of "+parent.Name, "check Kubernetes permissions for listing TkaSignin resources")
+	}
+
+	return signIns.Items, nil
+}
@@ -0,0 +1,200 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestClusterRole(name string, rules ...rbacv1.PolicyRule) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}, Rules: rules}
+}
+
+func TestRulesCover(t *testing.T) {
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+
+	tests := []struct {
+		name      string
+		owner     []rbacv1.PolicyRule
+		requested []rbacv1.PolicyRule
+		expected  bool
+	}{
+		{name: "same rules", owner: []rbacv1.PolicyRule{readPods}, requested: []rbacv1.PolicyRule{readPods}, expected: true},
+		{name: "no rules", owner: []rbacv1.PolicyRule{readPods}, expected: true},
+		{
+			name:      "wildcards cover everything",
+			owner:     []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}, {Verbs: []string{"*"}, NonResourceURLs: []string{"*"}}},
+			requested: []rbacv1.PolicyRule{readPods, {Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz"}}},
+			expected:  true,
+		},
+		{
+			name:      "covered by several rules together",
+			owner:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}, {Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+			requested: []rbacv1.PolicyRule{readPods},
+			expected:  true,
+		},
+		{
+			name:      "additional verb",
+			owner:     []rbacv1.PolicyRule{readPods},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		},
+		{
+			name:      "wildcard verb is not covered by explicit verbs",
+			owner:     []rbacv1.PolicyRule{readPods},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		},
+		{
+			name:      "additional resource",
+			owner:     []rbacv1.PolicyRule{readPods},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+		},
+		{
+			name:      "restricted to resource names",
+			owner:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a", "b"}}},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a"}}},
+			expected:  true,
+		},
+		{
+			name:      "unrestricted names are not covered by restricted ones",
+			owner:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a"}}},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}}},
+		},
+		{
+			name:      "non-resource url prefix",
+			owner:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics*"}}},
+			requested: []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics/cadvisor", "/healthz"}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, k8s.RulesCover(tc.owner, tc.requested))
+		})
+	}
+}
+
+func newDelegateTestObjects(modify func(*v1alpha1.TkaSignin)) []client.Object {
+	signIn := newExtendTestSignin(time.Now().Add(-10*time.Minute), time.Hour, "")
+	signIn.Spec.Role = "edit"
+	if modify != nil {
+		modify(signIn)
+	}
+
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	return []client.Object{
+		signIn,
+		newTestClusterRole("edit", rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods", "configmaps"}}),
+		newTestClusterRole("view", readPods),
+		newTestClusterRole("admin", rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+	}
+}
+
+func TestDelegate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*v1alpha1.TkaSignin)
+		opts        k8s.DelegateOptions
+		expectedErr error
+		notFound    bool
+	}{
+		{name: "role of the session", opts: k8s.DelegateOptions{Period: 15 * time.Minute}},
+		{name: "narrower role in a namespace", opts: k8s.DelegateOptions{Role: "view", Namespace: "foo", Period: 15 * time.Minute}},
+		{name: "broader role", opts: k8s.DelegateOptions{Role: "admin", Period: 15 * time.Minute}, expectedErr: k8s.ErrRoleNotDelegable},
+		{name: "role does not exist", opts: k8s.DelegateOptions{Role: "nope", Period: 15 * time.Minute}, expectedErr: k8s.ErrRoleNotFound},
+		{name: "namespace does not exist", opts: k8s.DelegateOptions{Role: "view", Namespace: "bar", Period: 15 * time.Minute}, notFound: true},
+		{name: "session suspended", modify: func(s *v1alpha1.TkaSignin) { s.Spec.Suspended = true }, opts: k8s.DelegateOptions{Period: 15 * time.Minute}, expectedErr: k8s.ErrSessionSuspended},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tkaClient := newRoleTestClient(t, newDelegateTestObjects(tc.modify)...)
+			ctx := context.Background()
+
+			info, err := tkaClient.Delegate(ctx, "alice", tc.opts)
+			switch {
+			case tc.expectedErr != nil:
+				require.NotNil(t, err)
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			case tc.notFound:
+				require.NotNil(t, err)
+				require.True(t, k8serrors.IsNotFound(err.Cause()), err.Error())
+				return
+			}
+
+			require.Nil(t, err)
+			require.Contains(t, info.Username, "alice-delegate-")
+
+			parent, err := tkaClient.GetStatus(ctx, "alice")
+			require.Nil(t, err)
+
+			child, err := tkaClient.GetStatus(ctx, info.Username)
+			require.Nil(t, err)
+			require.Equal(t, k8s.FormatSigninObjectName("alice"), child.Parent)
+			require.Equal(t, tc.opts.Namespace, child.Namespace)
+			require.Equal(t, "15m0s", child.ValidityPeriod)
+			require.Equal(t, parent.ValidUntil, child.NotAfter, "the delegation must not outlive the session")
+			require.False(t, child.Provisioned)
+		})
+	}
+}
+
+func TestCheckRoleCovered(t *testing.T) {
+	tests := []struct {
+		name        string
+		granted     string
+		role        string
+		expectedErr error
+	}{
+		{name: "same role", granted: "edit", role: "edit"},
+		{name: "narrower role", granted: "edit", role: "view"},
+		{name: "broader role", granted: "view", role: "admin", expectedErr: k8s.ErrRoleNotCovered},
+		{name: "role does not exist", granted: "edit", role: "nope", expectedErr: k8s.ErrRoleNotFound},
+	}
+
+	tkaClient := newRoleTestClient(t, newDelegateTestObjects(nil)...)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tkaClient.CheckRoleCovered(context.Background(), tc.granted, tc.role)
+			if tc.expectedErr == nil {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestDelegate_NotProvisioned(t *testing.T) {
+	tkaClient := newRoleTestClient(t, newDelegateTestObjects(func(s *v1alpha1.TkaSignin) { s.Status.Provisioned = false })...)
+
+	_, err := tkaClient.Delegate(context.Background(), "alice", k8s.DelegateOptions{Period: 15 * time.Minute})
+	require.Equal(t, k8s.NotReadyYetError, err)
+}
+
+func TestSetSuspended_Delegations(t *testing.T) {
+	tkaClient := newRoleTestClient(t, newDelegateTestObjects(nil)...)
+	ctx := context.Background()
+
+	info, err := tkaClient.Delegate(ctx, "alice", k8s.DelegateOptions{Role: "view", Period: 15 * time.Minute})
+	require.Nil(t, err)
+
+	for _, suspended := range []bool{true, false} {
+		require.Nil(t, tkaClient.SetSuspended(ctx, "alice", suspended))
+
+		child, err := tkaClient.GetStatus(ctx, info.Username)
+		require.Nil(t, err)
+		require.Equal(t, suspended, child.Suspended)
+	}
+}
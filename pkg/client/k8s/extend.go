@@ -0,0 +1,183 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+
+// Keys of the extension usage ConfigMap's data.
+const (
+	extensionUsageDayKey      = "day"
+	extensionUsageExtendedKey = "extended"
+)
+
+// ErrExtensionLimit is the cause of errors returned when a sign-in cannot be extended any further.
+var ErrExtensionLimit = errors.New("session extension limit reached")
+
+// ErrGrantChanged is the cause of errors returned when a sign-in no longer matches the user's grant.
+var ErrGrantChanged = errors.New("grant changed")
+
+// ExtendOptions bounds how far ExtendSignIn pushes the end of a sign-in.
+type ExtendOptions struct {
+	// Role is the role the user's grant currently allows. Only sign-ins whose role it covers are extended,
+	// e.g. sessions the pre-signin webhook signed in with a lower role than the grant's.
+	Role string
+	// By is how much to add to the remaining session. Zero extends as far as the limits allow.
+	By time.Duration
+	// MaxPeriod is the period of the user's grant. The session never ends later than MaxPeriod from now.
+	MaxPeriod time.Duration
+	// MaxPerDay caps how much time the user's extensions add per day (UTC), across all of their sessions.
+	// Zero means no cap.
+	MaxPerDay time.Duration
+}
+
+// ExtendSignIn pushes the end of a provisioned sign-in forward, keeping its credentials. It returns when the
+// sign-in now ends. The operator picks up the new validity period like a repeated sign-in.
+func (t *tkaClient) ExtendSignIn(ctx context.Context, userName string, opts ExtendOptions) (time.Time, humane.Error) {
+	ctx, span := t.tracer.Start(ctx, "TkaClient.ExtendSignIn")
+	defer span.End()
+
+	signIn, err := t.GetSignIn(ctx, userName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !signIn.Status.Provisioned {
+		return time.Time{}, NotReadyYetError
+	}
+
+	if err := t.CheckRoleCovered(ctx, opts.Role, signIn.Spec.Role); err != nil {
+		if !errors.Is(err.Cause(), ErrRoleNotCovered) {
+			return time.Time{}, err
+		}
+		return time.Time{}, humane.Wrap(fmt.Errorf("%w: signed in as %s, granted %s", ErrGrantChanged, signIn.Spec.Role, opts.Role),
+			"Your grant no longer covers the role of this session",
+			"run 'tka login' to sign in with your current grant")
+	}
+
+	start, validUntil, herr := sessionBounds(signIn)
+	if herr != nil {
+		return time.Time{}, herr
+	}
+
+	now := time.Now()
+	until := extendedUntil(signIn, now, start, validUntil, opts)
+	if !until.After(validUntil) {
+		return time.Time{}, humane.Wrap(fmt.Errorf("%w: session ends at %s", ErrExtensionLimit, validUntil.Format(time.RFC3339)),
+			"Your session cannot be extended any further",
+			"run 'tka login' once it has ended to start a new session")
+	}
+
+	if opts.MaxPerDay > 0 {
+		if until, herr = t.recordExtension(ctx, signIn, now, validUntil, until, opts.MaxPerDay); herr != nil {
+			return time.Time{}, herr
+		}
+	}
+
+	signIn.Spec.ValidityPeriod = until.Sub(start).String()
+	if signIn.Annotations == nil {
+		signIn.Annotations = map[string]string{}
+	}
+	signIn.Annotations[SignInValidUntil] = until.Format(time.RFC3339)
+	SetTraceParent(ctx, &signIn.ObjectMeta)
+	if err := t.client.Update(ctx, signIn); err != nil {
+		return time.Time{}, humane.Wrap(err, "Failed to update sign-in request", "check Kubernetes permissions for updating TkaSignin resources")
+	}
+
+	return until, nil
+}
+
+// sessionBounds returns when the access of a provisioned sign-in started and when it currently ends.
+func sessionBounds(signIn *v1alpha1.TkaSignin) (time.Time, time.Time, humane.Error) {
+	signedInAt := signIn.Status.SignedInAt
+	if attempted, ok := signIn.Annotations[LastAttemptedSignIn]; ok {
+		signedInAt = attempted
+	}
+
+	signedIn, err := time.Parse(time.RFC3339, signedInAt)
+	if err != nil {
+		return time.Time{}, time.Time{}, humane.Wrap(err, "Failed to parse signedInAt", "ensure the timestamp is in RFC3339 format")
+	}
+
+	validUntil, err := time.Parse(time.RFC3339, signIn.Status.ValidUntil)
+	if err != nil {
+		return time.Time{}, time.Time{}, humane.Wrap(err, "Failed to parse validUntil", "ensure the timestamp is in RFC3339 format")
+	}
+
+	start, _ := AccessWindow(signedIn, 0, signIn.Spec.NotBefore, "")
+	return start, validUntil, nil
+}
+
+// extendedUntil applies the grant's period, the requested duration and the sign-in's NotAfter to the extension.
+func extendedUntil(signIn *v1alpha1.TkaSignin, now, start, validUntil time.Time, opts ExtendOptions) time.Time {
+	until := now.Add(opts.MaxPeriod)
+	if opts.By > 0 && validUntil.Add(opts.By).Before(until) {
+		until = validUntil.Add(opts.By)
+	}
+
+	_, until = AccessWindow(start, until.Sub(start), "", signIn.Spec.NotAfter)
+	return until.Truncate(time.Second)
+}
+
+// recordExtension caps the extension of the sign-in from validUntil to until at what is left of the user's
+// maxPerDay today, and adds it to the extension usage ConfigMap before the sign-in is changed. It returns the
+// capped end of the sign-in. Concurrent extensions of the same user fail with a conflict rather than both
+// counting against the same remainder.
+func (t *tkaClient) recordExtension(ctx context.Context, signIn *v1alpha1.TkaSignin, now, validUntil, until time.Time, maxPerDay time.Duration) (time.Time, humane.Error) {
+	day := now.UTC().Format(time.DateOnly)
+
+	usage := &corev1.ConfigMap{}
+	key := client.ObjectKey{Name: FormatExtensionUsageName(signIn.Spec.Username), Namespace: signIn.Namespace} //nolint:golint-sl // used in Get call
+	if err := t.client.Get(ctx, key, usage); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return time.Time{}, humane.Wrap(err, "Failed to load today's session extensions", "check Kubernetes permissions for reading ConfigMaps in namespace "+signIn.Namespace)
+		}
+		usage = nil
+	}
+
+	var used time.Duration
+	if usage != nil && usage.Data[extensionUsageDayKey] == day {
+		var err error
+		if used, err = time.ParseDuration(usage.Data[extensionUsageExtendedKey]); err != nil {
+			// An unreadable record counts as used up rather than lifting the cap
+			used = maxPerDay
+		}
+	}
+
+	if remaining := maxPerDay - used; validUntil.Add(remaining).Before(until) {
+		until = validUntil.Add(remaining)
+	}
+	if !until.After(validUntil) {
+		return time.Time{}, humane.Wrap(fmt.Errorf("%w: extended by %s today", ErrExtensionLimit, used),
+			fmt.Sprintf("You have extended your sessions by %s today, the most allowed per day", maxPerDay),
+			"run 'tka login' once your session has ended to start a new one")
+	}
+
+	record := NewExtensionUsage(signIn.Spec.Username, signIn.Namespace, day, used+until.Sub(validUntil))
+	var err error
+	if usage == nil {
+		err = t.client.Create(ctx, record)
+	} else {
+		usage.Labels = record.Labels
+		usage.Data = record.Data
+		err = t.client.Update(ctx, usage)
+	}
+	if err != nil {
+		if k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) {
+			return time.Time{}, humane.Wrap(err, "Your session is being extended concurrently", "try extending it again")
+		}
+		return time.Time{}, humane.Wrap(err, "Failed to record today's session extensions", "check Kubernetes permissions for creating and updating ConfigMaps in namespace "+signIn.Namespace)
+	}
+
+	return until, nil
+}
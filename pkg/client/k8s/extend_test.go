@@ -0,0 +1,205 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newExtendTestSignin(signedIn time.Time, period time.Duration, notAfter string) *v1alpha1.TkaSignin {
+	signIn := k8s.NewSignin("alice", "view", period, testNamespace)
+	signIn.Annotations[k8s.LastAttemptedSignIn] = signedIn.Format(time.RFC3339)
+	signIn.Spec.NotAfter = notAfter
+	signIn.Status = v1alpha1.TkaSigninStatus{
+		Provisioned: true,
+		SignedInAt:  signedIn.Format(time.RFC3339),
+		ValidUntil:  signedIn.Add(period).Format(time.RFC3339),
+	}
+	return signIn
+}
+
+// newExtendTestRoles returns the ClusterRoles view and edit, which covers view.
+func newExtendTestRoles() []client.Object {
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	return []client.Object{
+		newTestClusterRole("view", readPods),
+		newTestClusterRole("edit", rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods", "configmaps"}}),
+	}
+}
+
+func TestExtendSignIn(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	signedIn := now.Add(-30 * time.Minute)
+
+	today := now.UTC().Format(time.DateOnly)
+	yesterday := now.UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	editSignin := newExtendTestSignin(signedIn, time.Hour, "")
+	editSignin.Spec.Role = "edit"
+
+	tests := []struct {
+		name          string
+		signIn        *v1alpha1.TkaSignin
+		usage         *corev1.ConfigMap
+		opts          k8s.ExtendOptions
+		expectedUntil time.Time
+		expectedErr   error
+	}{
+		{
+			name:          "extends to the grant period from now",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			opts:          k8s.ExtendOptions{Role: "view", MaxPeriod: time.Hour},
+			expectedUntil: now.Add(time.Hour),
+		},
+		{
+			name:          "extends by the requested duration",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			opts:          k8s.ExtendOptions{Role: "view", By: 10 * time.Minute, MaxPeriod: time.Hour},
+			expectedUntil: signedIn.Add(70 * time.Minute),
+		},
+		{
+			name:          "requested duration is capped at the grant period",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			opts:          k8s.ExtendOptions{Role: "view", By: 5 * time.Hour, MaxPeriod: time.Hour},
+			expectedUntil: now.Add(time.Hour),
+		},
+		{
+			name:          "capped at the daily limit",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			opts:          k8s.ExtendOptions{Role: "view", MaxPeriod: 2 * time.Hour, MaxPerDay: 30 * time.Minute},
+			expectedUntil: signedIn.Add(90 * time.Minute),
+		},
+		{
+			name:          "capped at what is left of the daily limit",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			usage:         k8s.NewExtensionUsage("alice", testNamespace, today, 20*time.Minute),
+			opts:          k8s.ExtendOptions{Role: "view", MaxPeriod: 2 * time.Hour, MaxPerDay: 30 * time.Minute},
+			expectedUntil: signedIn.Add(70 * time.Minute),
+		},
+		{
+			name:          "extensions of earlier days do not count",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			usage:         k8s.NewExtensionUsage("alice", testNamespace, yesterday, 30*time.Minute),
+			opts:          k8s.ExtendOptions{Role: "view", MaxPeriod: 2 * time.Hour, MaxPerDay: 30 * time.Minute},
+			expectedUntil: signedIn.Add(90 * time.Minute),
+		},
+		{
+			name:          "session with a role the grant covers",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, ""),
+			opts:          k8s.ExtendOptions{Role: "edit", MaxPeriod: time.Hour},
+			expectedUntil: now.Add(time.Hour),
+		},
+		{
+			name:          "capped at not_after",
+			signIn:        newExtendTestSignin(signedIn, time.Hour, now.Add(45*time.Minute).Format(time.RFC3339)),
+			opts:          k8s.ExtendOptions{Role: "view", MaxPeriod: 2 * time.Hour},
+			expectedUntil: now.Add(45 * time.Minute),
+		},
+		{
+			name:        "daily limit already used",
+			signIn:      newExtendTestSignin(signedIn, time.Hour, ""),
+			usage:       k8s.NewExtensionUsage("alice", testNamespace, today, 30*time.Minute),
+			opts:        k8s.ExtendOptions{Role: "view", MaxPeriod: time.Hour, MaxPerDay: 30 * time.Minute},
+			expectedErr: k8s.ErrExtensionLimit,
+		},
+		{
+			name:        "role no longer granted",
+			signIn:      editSignin,
+			opts:        k8s.ExtendOptions{Role: "view", MaxPeriod: time.Hour},
+			expectedErr: k8s.ErrGrantChanged,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newExtendTestRoles()
+			objs = append(objs, tc.signIn)
+			if tc.usage != nil {
+				objs = append(objs, tc.usage)
+			}
+			tkaClient := newRoleTestClient(t, objs...)
+			ctx := context.Background()
+
+			until, err := tkaClient.ExtendSignIn(ctx, "alice", tc.opts)
+			if tc.expectedErr != nil {
+				require.NotNil(t, err)
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			require.Nil(t, err)
+			require.WithinDuration(t, tc.expectedUntil, until, time.Second)
+
+			// The operator derives the new end from the sign-in time and the validity period
+			info, err := tkaClient.GetStatus(ctx, "alice")
+			require.Nil(t, err)
+			validity, perr := time.ParseDuration(info.ValidityPeriod)
+			require.NoError(t, perr)
+			_, end := k8s.AccessWindow(signedIn, validity, "", tc.signIn.Spec.NotAfter)
+			require.True(t, end.Equal(until), "expected %s, got %s", until, end)
+		})
+	}
+}
+
+func TestExtendSignIn_NotProvisioned(t *testing.T) {
+	signIn := k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+	tkaClient := newRoleTestClient(t, signIn)
+
+	_, err := tkaClient.ExtendSignIn(context.Background(), "alice", k8s.ExtendOptions{Role: "view", MaxPeriod: time.Hour})
+	require.Equal(t, k8s.NotReadyYetError, err)
+
+	_, err = tkaClient.ExtendSignIn(context.Background(), "bob", k8s.ExtendOptions{Role: "view", MaxPeriod: time.Hour})
+	require.NotNil(t, err)
+}
+
+func TestExtendSignIn_DailyLimitAcrossSessions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	opts := k8s.ExtendOptions{Role: "view", By: 30 * time.Minute, MaxPeriod: 2 * time.Hour, MaxPerDay: 45 * time.Minute}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, rbacv1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	first := newExtendTestSignin(now.Add(-30*time.Minute), time.Hour, "")
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(newExtendTestRoles(), first)...).WithStatusSubresource(&v1alpha1.TkaSignin{}).Build()
+	clientOpts := k8s.DefaultClientOptions()
+	clientOpts.Namespace = testNamespace
+	tkaClient := k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{}), clientOpts)
+
+	until, err := tkaClient.ExtendSignIn(ctx, "alice", opts)
+	require.Nil(t, err)
+	require.WithinDuration(t, now.Add(time.Hour), until, time.Second)
+
+	// Sign out, the operator releases the sign-in, and sign in again
+	require.NoError(t, ctrlClient.Delete(ctx, first))
+	var signingOut v1alpha1.TkaSignin
+	require.NoError(t, ctrlClient.Get(ctx, client.ObjectKeyFromObject(first), &signingOut))
+	signingOut.Finalizers = nil
+	require.NoError(t, ctrlClient.Update(ctx, &signingOut))
+
+	second := newExtendTestSignin(now, time.Hour, "")
+	status := second.Status
+	require.NoError(t, ctrlClient.Create(ctx, second))
+	second.Status = status
+	require.NoError(t, ctrlClient.Status().Update(ctx, second))
+
+	// Only the 15 minutes left of today's limit are added to the new session
+	until, err = tkaClient.ExtendSignIn(ctx, "alice", opts)
+	require.Nil(t, err)
+	require.WithinDuration(t, now.Add(75*time.Minute), until, time.Second)
+
+	_, err = tkaClient.ExtendSignIn(ctx, "alice", opts)
+	require.NotNil(t, err)
+	require.ErrorIs(t, err, k8s.ErrExtensionLimit)
+}
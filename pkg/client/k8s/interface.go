@@ -10,6 +10,8 @@ import (
 	"time"
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/service/models"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
@@ -27,6 +29,22 @@ type SignInInfo struct {
 	ValidUntil string
 	// Provisioned indicates whether credentials are ready for use
 	Provisioned bool
+	// Permissions summarizes the rules of the user's ClusterRole once credentials are provisioned
+	Permissions []models.PermissionRule
+	// Suspended indicates that an administrator temporarily withdrew the user's access
+	Suspended bool
+	// NotBefore is the RFC3339 timestamp the access starts at if it was requested ahead of time, e.g. for a shift
+	NotBefore string
+	// NotAfter is the RFC3339 timestamp the access is revoked at regardless of the validity period, e.g. a shift's end
+	NotAfter string
+	// Parent is the name of the sign-in a delegated credential was derived from. Empty for users' own sign-ins.
+	Parent string
+	// Namespace is the only namespace a delegated credential has access to. Empty if the role is granted cluster-wide.
+	Namespace string
+	// LastActivity is the RFC3339 timestamp of the last request made with the credentials. Empty if none was seen yet.
+	LastActivity string
+	// Reason is the reason the user gave when signing in. Empty if none was given.
+	Reason string
 }
 
 // TkaClient defines the core business logic operations for user authentication and credential management.
@@ -41,17 +59,65 @@ type SignInInfo struct {
 type TkaClient interface {
 	// SignIn initiates the credential provisioning process for a user.
 	// This is an asynchronous operation that may take time to complete.
-	NewSignIn(ctx context.Context, username string, role string, period time.Duration) humane.Error
+	NewSignIn(ctx context.Context, username string, role string, period time.Duration, opts ...SignInOption) humane.Error
 
 	// Status retrieves the current authentication status for a user.
 	// Use this to check if credentials are ready after calling SignIn.
 	GetStatus(ctx context.Context, username string) (*SignInInfo, humane.Error)
 
-	// Kubeconfig retrieves the kubeconfig for an authenticated user.
+	// Kubeconfig retrieves the kubeconfig for an authenticated user together with the time the
+	// embedded token expires. A zero time means the token does not expire before the session,
+	// because no token TTL shorter than the session is configured.
 	// This only succeeds if the user has successfully signed in and credentials are provisioned.
-	GetKubeconfig(ctx context.Context, username string) (*clientcmdapi.Config, humane.Error)
+	GetKubeconfig(ctx context.Context, username string) (*clientcmdapi.Config, time.Time, humane.Error)
 
 	// Logout revokes credentials and removes authentication state for a user.
-	// This is typically used when users explicitly log out or when cleaning up expired sessions.
+	// This is typically used when users explicitly log out. Suspended sign-ins cannot be removed.
 	DeleteSignIn(ctx context.Context, username string) humane.Error
+
+	// SetSuspended suspends or resumes a user's sign-in. While suspended the user keeps the
+	// sign-in but has no access and cannot fetch a kubeconfig.
+	SetSuspended(ctx context.Context, username string, suspended bool) humane.Error
+
+	// GetShift returns the scheduled shift of the user with the given Tailscale login name, e.g. alice@example.com,
+	// that is active at the given time or starts within lead. It returns nil if the user is not on shift.
+	GetShift(ctx context.Context, loginName string, at time.Time, lead time.Duration) (*Shift, humane.Error)
+
+	// ExtendSignIn pushes the end of a provisioned sign-in forward within the given limits and returns
+	// its new end. The credentials of the sign-in stay the same. Extensions count against the user's
+	// daily limit across sessions, so signing in again does not reset it.
+	ExtendSignIn(ctx context.Context, username string, opts ExtendOptions) (time.Time, humane.Error)
+
+	// Delegate derives a separate credential from the user's provisioned sign-in and returns the sign-in
+	// backing it. The credential is restricted to the given options and is revoked together with the user's sign-in.
+	Delegate(ctx context.Context, username string, opts DelegateOptions) (*SignInInfo, humane.Error)
+
+	// CheckRoleCovered ensures role grants nothing the granted role does not. It returns an error
+	// caused by ErrRoleNotCovered otherwise.
+	CheckRoleCovered(ctx context.Context, granted, role string) humane.Error
+
+	// RecordActivity notes that the given ServiceAccount made a request to the API server at the given time,
+	// e.g. as reported by an audit event. ServiceAccounts that don't back a sign-in are ignored.
+	RecordActivity(ctx context.Context, namespace, serviceAccount string, at time.Time) humane.Error
+}
+
+// TokenIssuer mints bearer tokens for the ServiceAccount that backs a TkaSignin.
+// Which implementation is used depends on the APIs offered by the cluster and is
+// decided once at startup (see NewTokenIssuer).
+//
+// Implementations should:
+// Return NotReadyYetError while token material is still being created by Kubernetes
+// Remove every object they created for a sign-in in RevokeToken
+// Be safe for concurrent use
+type TokenIssuer interface {
+	// Name identifies the token strategy, e.g. for logs and span attributes.
+	Name() string
+
+	// IssueToken returns a token for the ServiceAccount of the given sign-in.
+	// The ttl is a hint; strategies that cannot bound the lifetime of a token ignore it.
+	IssueToken(ctx context.Context, signIn *v1alpha1.TkaSignin, ttl time.Duration) (*IssuedToken, humane.Error)
+
+	// RevokeToken removes any token material the issuer created for the sign-in.
+	// It must succeed if there is nothing to remove.
+	RevokeToken(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error
 }
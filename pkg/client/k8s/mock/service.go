@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
 	"github.com/spechtlabs/tka/pkg/client/k8s"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
@@ -32,12 +33,26 @@ var _ k8s.TkaClient = &MockTkaClient{}
 type MockTkaClient struct {
 	// SignInFn defines custom behavior for SignIn method calls
 	SignInFn func(username, role string, period time.Duration) humane.Error
+	// SignInSpecFn receives the TkaSignin that SignIn would create, with all SignInOptions applied
+	SignInSpecFn func(signIn *v1alpha1.TkaSignin)
 	// StatusFn defines custom behavior for Status method calls
 	StatusFn func(username string) (*k8s.SignInInfo, humane.Error)
 	// KubeconfigFn defines custom behavior for Kubeconfig method calls
-	KubeconfigFn func(username string) (*api.Config, humane.Error)
+	KubeconfigFn func(username string) (*api.Config, time.Time, humane.Error)
 	// LogoutFn defines custom behavior for Logout method calls
 	LogoutFn func(username string) humane.Error
+	// SuspendFn defines custom behavior for SetSuspended method calls
+	SuspendFn func(username string, suspended bool) humane.Error
+	// ShiftFn defines custom behavior for GetShift method calls
+	ShiftFn func(loginName string, at time.Time) (*k8s.Shift, humane.Error)
+	// ExtendFn defines custom behavior for ExtendSignIn method calls
+	ExtendFn func(username string, opts k8s.ExtendOptions) (time.Time, humane.Error)
+	// DelegateFn defines custom behavior for Delegate method calls
+	DelegateFn func(username string, opts k8s.DelegateOptions) (*k8s.SignInInfo, humane.Error)
+	// ActivityFn defines custom behavior for RecordActivity method calls
+	ActivityFn func(namespace, serviceAccount string, at time.Time) humane.Error
+	// RoleCoveredFn defines custom behavior for CheckRoleCovered method calls
+	RoleCoveredFn func(granted, role string) humane.Error
 }
 
 // NewMockTkaClient creates a new mock client with default (success) behavior.
@@ -46,7 +61,14 @@ func NewMockTkaClient() k8s.TkaClient {
 	return &MockTkaClient{}
 }
 
-func (m *MockTkaClient) NewSignIn(_ context.Context, username string, role string, period time.Duration) humane.Error {
+func (m *MockTkaClient) NewSignIn(_ context.Context, username string, role string, period time.Duration, opts ...k8s.SignInOption) humane.Error {
+	if m.SignInSpecFn != nil {
+		signIn := k8s.NewSignin(username, role, period, k8s.DefaultNamespace)
+		for _, opt := range opts {
+			opt(signIn)
+		}
+		m.SignInSpecFn(signIn)
+	}
 	if m.SignInFn != nil {
 		return m.SignInFn(username, role, period)
 	}
@@ -60,11 +82,11 @@ func (m *MockTkaClient) GetStatus(_ context.Context, username string) (*k8s.Sign
 	return nil, nil
 }
 
-func (m *MockTkaClient) GetKubeconfig(_ context.Context, username string) (*api.Config, humane.Error) {
+func (m *MockTkaClient) GetKubeconfig(_ context.Context, username string) (*api.Config, time.Time, humane.Error) {
 	if m.KubeconfigFn != nil {
 		return m.KubeconfigFn(username)
 	}
-	return nil, nil
+	return nil, time.Time{}, nil
 }
 
 func (m *MockTkaClient) DeleteSignIn(_ context.Context, username string) humane.Error {
@@ -73,3 +95,52 @@ func (m *MockTkaClient) DeleteSignIn(_ context.Context, username string) humane.
 	}
 	return nil
 }
+
+func (m *MockTkaClient) SetSuspended(_ context.Context, username string, suspended bool) humane.Error {
+	if m.SuspendFn != nil {
+		return m.SuspendFn(username, suspended)
+	}
+	return nil
+}
+
+func (m *MockTkaClient) GetShift(_ context.Context, loginName string, at time.Time, _ time.Duration) (*k8s.Shift, humane.Error) {
+	if m.ShiftFn != nil {
+		return m.ShiftFn(loginName, at)
+	}
+	return nil, nil
+}
+
+func (m *MockTkaClient) ExtendSignIn(_ context.Context, username string, opts k8s.ExtendOptions) (time.Time, humane.Error) {
+	if m.ExtendFn != nil {
+		return m.ExtendFn(username, opts)
+	}
+	return time.Now().Add(opts.MaxPeriod), nil
+}
+
+func (m *MockTkaClient) Delegate(_ context.Context, username string, opts k8s.DelegateOptions) (*k8s.SignInInfo, humane.Error) {
+	if m.DelegateFn != nil {
+		return m.DelegateFn(username, opts)
+	}
+	return &k8s.SignInInfo{
+		Username:       k8s.FormatDelegateUsername(username, "mock1"),
+		Role:           opts.Role,
+		ValidityPeriod: opts.Period.String(),
+		ValidUntil:     time.Now().Add(opts.Period).Format(time.RFC3339),
+		Parent:         k8s.FormatSigninObjectName(username),
+		Namespace:      opts.Namespace,
+	}, nil
+}
+
+func (m *MockTkaClient) CheckRoleCovered(_ context.Context, granted, role string) humane.Error {
+	if m.RoleCoveredFn != nil {
+		return m.RoleCoveredFn(granted, role)
+	}
+	return nil
+}
+
+func (m *MockTkaClient) RecordActivity(_ context.Context, namespace, serviceAccount string, at time.Time) humane.Error {
+	if m.ActivityFn != nil {
+		return m.ActivityFn(namespace, serviceAccount, at)
+	}
+	return nil
+}
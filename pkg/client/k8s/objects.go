@@ -26,8 +26,9 @@ func NewSignin(userName, role string, validPeriod time.Duration, namespace strin
 	now := time.Now()
 	return &v1alpha1.TkaSignin{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      FormatSigninObjectName(userName),
-			Namespace: namespace,
+			Name:       FormatSigninObjectName(userName),
+			Namespace:  namespace,
+			Finalizers: []string{SigninFinalizer},
 			Annotations: map[string]string{
 				LastAttemptedSignIn: now.Format(time.RFC3339),
 				SignInValidUntil:    now.Add(validPeriod).Format(time.RFC3339),
@@ -47,13 +48,134 @@ func NewSignin(userName, role string, validPeriod time.Duration, namespace strin
 }
 
 // NewServiceAccount creates a new Kubernetes ServiceAccount for the given TkaSignin resource.
+// The reason given for the sign-in is recorded as an annotation so it shows up next to the
+// ServiceAccount's activity.
 func NewServiceAccount(signIn *v1alpha1.TkaSignin) *corev1.ServiceAccount {
-	return &corev1.ServiceAccount{
+	serviceAccount := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      FormatSigninObjectName(signIn.Spec.Username),
 			Namespace: signIn.Namespace,
 		},
 	}
+	SetSessionAnnotations(&serviceAccount.ObjectMeta, signIn)
+
+	return serviceAccount
+}
+
+// SetSessionAnnotations records who signed in, from which device, for which session and why on obj,
+// so requests made with its credentials can be traced back to the person. Stale values are removed.
+func SetSessionAnnotations(obj *metav1.ObjectMeta, signIn *v1alpha1.TkaSignin) {
+	var device, deviceID string
+	if signIn.Spec.Device != nil {
+		device, deviceID = signIn.Spec.Device.NodeName, signIn.Spec.Device.NodeID
+	}
+
+	setAnnotation(obj, SignInLoginName, signIn.Spec.LoginName)
+	setAnnotation(obj, SignInDevice, device)
+	setAnnotation(obj, SignInDeviceID, deviceID)
+	setAnnotation(obj, SignInSessionID, string(signIn.UID))
+	setAnnotation(obj, SignInReason, signIn.Spec.Reason)
+}
+
+// setAnnotation sets the annotation on obj, or removes it if value is empty.
+func setAnnotation(obj *metav1.ObjectMeta, key, value string) {
+	if value == "" {
+		delete(obj.Annotations, key)
+		return
+	}
+
+	metav1.SetMetaDataAnnotation(obj, key, value)
+}
+
+// FormatTokenSecretName generates the name of the Secret holding a user's long-lived ServiceAccount token.
+func FormatTokenSecretName(userName string) string {
+	return fmt.Sprintf("%s-token", FormatSigninObjectName(userName))
+}
+
+// NewServiceAccountTokenSecret creates a `kubernetes.io/service-account-token` Secret for the given ServiceAccount.
+// The Secret is owned by the ServiceAccount so it is garbage collected together with it.
+func NewServiceAccountTokenSecret(serviceAccount *corev1.ServiceAccount) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-token", serviceAccount.Name),
+			Namespace: serviceAccount.Namespace,
+			Annotations: map[string]string{
+				corev1.ServiceAccountNameKey: serviceAccount.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "ServiceAccount",
+					Name:       serviceAccount.Name,
+					UID:        serviceAccount.UID,
+				},
+			},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+}
+
+// FormatTokenCacheSecretName generates the name of the Secret caching a user's issued token.
+func FormatTokenCacheSecretName(userName string) string {
+	return fmt.Sprintf("%s-token-cache", FormatSigninObjectName(userName))
+}
+
+// NewTokenCacheSecret creates the Secret caching the encrypted token sealedToken, which expires at expiresAt,
+// for the session identified by sessionHash. The Secret is owned by the TkaSignin so it is garbage collected
+// when the user signs out.
+func NewTokenCacheSecret(signIn *v1alpha1.TkaSignin, sessionHash string, sealedToken []byte, expiresAt time.Time) *corev1.Secret {
+	data := map[string][]byte{
+		tokenCacheTokenKey: sealedToken,
+	}
+	if !expiresAt.IsZero() {
+		data[tokenCacheExpiresAtKey] = []byte(expiresAt.Format(time.RFC3339))
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FormatTokenCacheSecretName(signIn.Spec.Username),
+			Namespace: signIn.Namespace,
+			Labels: map[string]string{
+				TokenCacheLabel: "true",
+			},
+			Annotations: map[string]string{
+				TokenCacheSessionHash: sessionHash,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: v1alpha1.GroupVersion.String(),
+					Kind:       "TkaSignin",
+					Name:       signIn.Name,
+					UID:        signIn.UID,
+				},
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// FormatExtensionUsageName generates the name of the ConfigMap recording how far a user extended their sessions today.
+func FormatExtensionUsageName(userName string) string {
+	return fmt.Sprintf("%s-extensions", FormatSigninObjectName(userName))
+}
+
+// NewExtensionUsage creates the ConfigMap recording that the user extended their sessions by extended on day
+// (YYYY-MM-DD, UTC). It is not owned by a sign-in, so the record outlives signing out and in again.
+func NewExtensionUsage(userName, namespace, day string, extended time.Duration) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FormatExtensionUsageName(userName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				ExtensionUsageLabel: "true",
+			},
+		},
+		Data: map[string]string{
+			extensionUsageDayKey:      day,
+			extensionUsageExtendedKey: extended.String(),
+		},
+	}
 }
 
 // NewKubeconfig creates a kubeconfig for accessing the cluster with the given credentials.
@@ -145,3 +267,54 @@ func NewClusterRoleBinding(signIn *v1alpha1.TkaSignin) *rbacv1.ClusterRoleBindin
 		},
 	}
 }
+
+// NewRoleBinding creates a RoleBinding that grants the user the specified role in the namespace the sign-in is restricted to.
+// It is labelled with the sign-in's name, so it is found again without the sign-in's spec.
+func NewRoleBinding(signIn *v1alpha1.TkaSignin) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetClusterRoleBindingName(signIn),
+			Namespace: signIn.Spec.Namespace,
+			Labels:    map[string]string{SignInLabel: signIn.Name},
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      FormatSigninObjectName(signIn.Spec.Username),
+				Namespace: signIn.Namespace,
+			},
+		},
+		RoleRef: NewRoleRef(signIn),
+	}
+}
+
+// FormatDelegateUsername generates the username of a credential delegated from a user's session.
+// The suffix keeps several delegations of the same user apart.
+func FormatDelegateUsername(userName, suffix string) string {
+	return fmt.Sprintf("%s-delegate-%s", userName, suffix)
+}
+
+// NewDelegatedSignin creates a TkaSignin for a credential derived from the parent sign-in. It ends with the
+// parent at the latest and is owned by it, so deleting the parent deletes the delegation as well. Like every
+// sign-in it carries SigninFinalizer, so the operator removes its bindings before it is gone.
+func NewDelegatedSignin(parent *v1alpha1.TkaSignin, userName string, opts DelegateOptions) *v1alpha1.TkaSignin {
+	signIn := NewSignin(userName, opts.Role, opts.Period, parent.Namespace)
+	signIn.Labels = map[string]string{DelegatedFromLabel: parent.Name}
+	signIn.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       "TkaSignin",
+			Name:       parent.Name,
+			UID:        parent.UID,
+		},
+	}
+
+	signIn.Spec.Parent = parent.Name
+	signIn.Spec.Namespace = opts.Namespace
+	signIn.Spec.Reason = parent.Spec.Reason
+	signIn.Spec.LoginName = parent.Spec.LoginName
+	signIn.Spec.Device = parent.Spec.Device
+	signIn.Spec.NotAfter = parent.Status.ValidUntil
+
+	return signIn
+}
@@ -1,11 +1,49 @@
 package k8s
 
+import (
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/internal/utils"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"k8s.io/client-go/kubernetes"
+)
+
 // ClientOptions holds configuration for the Kubernetes operator behavior and naming.
 type ClientOptions struct {
 	Namespace     string
 	ClusterName   string
 	ContextPrefix string
 	UserPrefix    string
+
+	// Clientset is the shared typed Kubernetes client used for token issuance and discovery.
+	// It is created once at startup so requests don't pay for building a new client.
+	Clientset kubernetes.Interface
+
+	// ServerVersion is the cached, periodically refreshed version of the API server.
+	ServerVersion *utils.ServerVersion
+
+	// TokenIssuer mints the ServiceAccount tokens handed out in kubeconfigs.
+	// See NewTokenIssuer for selecting the right strategy for a cluster.
+	TokenIssuer TokenIssuer
+
+	// TokenCache controls reuse of issued tokens within a sign-in session.
+	TokenCache TokenCacheOptions
+
+	// MaxTokenTTL caps the lifetime of every issued token, independent of how long the
+	// sign-in is valid. Zero means tokens live as long as the remaining sign-in.
+	MaxTokenTTL time.Duration
+}
+
+// TokenCacheOptions configures the per-session token cache (see NewCachingTokenIssuer).
+type TokenCacheOptions struct {
+	// Enabled turns on token reuse. When disabled every kubeconfig request mints a new token.
+	Enabled bool
+	// RotateBefore is the remaining lifetime below which a cached token is replaced.
+	RotateBefore time.Duration
+	// EncryptionKey is the base64 encoded 32 byte key that encrypts cached tokens. Replicas share cached
+	// tokens only if they use the same key. When empty, each process uses a random key.
+	EncryptionKey string
 }
 
 // DefaultClientOptions returns ClientOptions with sensible default values for development.
@@ -15,5 +53,65 @@ func DefaultClientOptions() ClientOptions {
 		ClusterName:   DefaultClusterName,
 		ContextPrefix: DefaultContextPrefix,
 		UserPrefix:    DefaultUserEntryPrefix,
+		TokenCache: TokenCacheOptions{
+			Enabled:      true,
+			RotateBefore: DefaultTokenCacheRotateBefore,
+		},
+	}
+}
+
+// SignInOption customizes a TkaSignin before it is created or updated by NewSignIn.
+type SignInOption func(*v1alpha1.TkaSignin)
+
+// WithTokenTTL caps the lifetime of tokens issued for the sign-in.
+// The server-wide MaxTokenTTL still applies if it is shorter.
+func WithTokenTTL(ttl time.Duration) SignInOption {
+	return func(signIn *v1alpha1.TkaSignin) {
+		if ttl > 0 {
+			signIn.Spec.TokenTTL = ttl.String()
+		}
+	}
+}
+
+// WithReason records the justification the user gave for signing in.
+func WithReason(reason string) SignInOption {
+	return func(signIn *v1alpha1.TkaSignin) {
+		signIn.Spec.Reason = reason
+	}
+}
+
+// WithShift limits the sign-in to a scheduled shift: access starts no earlier than the shift and
+// is revoked when the shift ends, even if the validity period lasts longer.
+func WithShift(shift *Shift) SignInOption {
+	return func(signIn *v1alpha1.TkaSignin) {
+		signIn.Spec.NotBefore, signIn.Spec.NotAfter = shift.Bounds()
+	}
+}
+
+// WithLoginName records the full Tailscale login name of the person signing in.
+func WithLoginName(loginName string) SignInOption {
+	return func(signIn *v1alpha1.TkaSignin) {
+		signIn.Spec.LoginName = loginName
+	}
+}
+
+// WithDevice records the Tailscale device the sign-in was requested from.
+func WithDevice(who *tshttp.WhoIsInfo) SignInOption {
+	return func(signIn *v1alpha1.TkaSignin) {
+		if who == nil {
+			return
+		}
+
+		device := &v1alpha1.TkaSigninDevice{
+			NodeName:       who.NodeName,
+			NodeID:         who.NodeID,
+			OS:             who.OS,
+			ClientVersion:  who.ClientVersion,
+			NodeAttributes: who.NodeAttributes,
+		}
+		if !who.KeyExpiry.IsZero() {
+			device.KeyExpiry = who.KeyExpiry.UTC().Format(time.RFC3339)
+		}
+		signIn.Spec.Device = device
 	}
 }
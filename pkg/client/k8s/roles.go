@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrRoleNotFound is the cause of errors returned when a sign-in references a ClusterRole that does not exist.
+var ErrRoleNotFound = errors.New("cluster role not found")
+
+// NewRoleNotFoundError explains that the ClusterRole granted to a user does not exist.
+func NewRoleNotFoundError(role string) humane.Error {
+	return humane.Wrap(fmt.Errorf("%w: %s", ErrRoleNotFound, role),
+		fmt.Sprintf("ClusterRole %q does not exist", role),
+		"check the role in your Tailscale ACL grant for typos",
+		"ask your cluster administrator to create the ClusterRole")
+}
+
+// ErrRoleNotCovered is the cause of errors returned when a role replacing the granted one would grant more than it.
+var ErrRoleNotCovered = errors.New("role not covered by the granted role")
+
+// RoleAvailable reports whether the sign-in's ClusterRole is known to exist. Sign-ins the operator
+// has not checked yet are treated as available.
+func RoleAvailable(signIn *v1alpha1.TkaSignin) bool {
+	return !meta.IsStatusConditionFalse(signIn.Status.Conditions, v1alpha1.ConditionRoleAvailable)
+}
+
+// GetClusterRole loads the ClusterRole with the given name, returning NewRoleNotFoundError if it does not exist.
+func GetClusterRole(ctx context.Context, c client.Reader, role string) (*rbacv1.ClusterRole, humane.Error) {
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := c.Get(ctx, client.ObjectKey{Name: role}, clusterRole); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, NewRoleNotFoundError(role)
+		}
+		return nil, humane.Wrap(err, "Failed to load ClusterRole "+role, "check that the TKA server may read ClusterRoles")
+	}
+
+	return clusterRole, nil
+}
+
+// SummarizePermissions lists the rules granted by a ClusterRole, including rules aggregated into it.
+func SummarizePermissions(clusterRole *rbacv1.ClusterRole) []models.PermissionRule {
+	rules := make([]models.PermissionRule, 0, len(clusterRole.Rules))
+	for _, rule := range clusterRole.Rules {
+		rules = append(rules, models.PermissionRule{
+			Verbs:           rule.Verbs,
+			APIGroups:       rule.APIGroups,
+			Resources:       rule.Resources,
+			ResourceNames:   rule.ResourceNames,
+			NonResourceURLs: rule.NonResourceURLs,
+		})
+	}
+
+	return rules
+}
+
+// CheckRoleCovered ensures role grants nothing the granted role does not, e.g. before a sign-in goes
+// ahead with a role other than the one the user's grant names.
+func (t *tkaClient) CheckRoleCovered(ctx context.Context, granted, role string) humane.Error {
+	if role == granted {
+		return nil
+	}
+
+	covered, err := roleCovers(ctx, t.client, granted, role)
+	if err != nil {
+		return err
+	}
+	if !covered {
+		return humane.Wrap(fmt.Errorf("%w: %s grants more than %s", ErrRoleNotCovered, role, granted),
+			fmt.Sprintf("ClusterRole %q grants permissions that %q does not", role, granted),
+			fmt.Sprintf("only %q or a role whose permissions it includes can be granted", granted))
+	}
+
+	return nil
+}
+
+// roleCovers reports whether the owner ClusterRole grants everything the requested ClusterRole grants.
+func roleCovers(ctx context.Context, c client.Reader, owner, requested string) (bool, humane.Error) {
+	ownerRole, err := GetClusterRole(ctx, c, owner)
+	if err != nil {
+		return false, err
+	}
+
+	requestedRole, err := GetClusterRole(ctx, c, requested)
+	if err != nil {
+		return false, err
+	}
+
+	return RulesCover(ownerRole.Rules, requestedRole.Rules), nil
+}
+
+// RulesCover reports whether the owner rules grant everything the requested rules grant. Like the
+// Kubernetes escalation check, requested rules are split into single verb and resource combinations,
+// so a requested rule may be covered by several owner rules together.
+func RulesCover(owner, requested []rbacv1.PolicyRule) bool {
+	for _, rule := range requested {
+		for _, verb := range rule.Verbs {
+			if !nonResourceURLsCovered(owner, verb, rule.NonResourceURLs) || !resourcesCovered(owner, verb, rule) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func resourcesCovered(owner []rbacv1.PolicyRule, verb string, rule rbacv1.PolicyRule) bool {
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			covered := slices.ContainsFunc(owner, func(o rbacv1.PolicyRule) bool {
+				return matchesRuleValue(o.Verbs, verb) &&
+					matchesRuleValue(o.APIGroups, group) &&
+					matchesRuleValue(o.Resources, resource) &&
+					resourceNamesCovered(o.ResourceNames, rule.ResourceNames)
+			})
+			if !covered {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func nonResourceURLsCovered(owner []rbacv1.PolicyRule, verb string, urls []string) bool {
+	for _, url := range urls {
+		covered := slices.ContainsFunc(owner, func(o rbacv1.PolicyRule) bool {
+			return matchesRuleValue(o.Verbs, verb) && slices.ContainsFunc(o.NonResourceURLs, func(pattern string) bool {
+				prefix, wildcard := strings.CutSuffix(pattern, "*")
+				return pattern == url || (wildcard && strings.HasPrefix(url, prefix))
+			})
+		})
+		if !covered {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesRuleValue(values []string, value string) bool {
+	return slices.Contains(values, rbacv1.VerbAll) || slices.Contains(values, value)
+}
+
+// resourceNamesCovered reports whether a rule restricted to names may be granted by one restricted to owner names.
+// An empty list allows every name.
+func resourceNamesCovered(owner, names []string) bool {
+	if len(owner) == 0 {
+		return true
+	}
+
+	return len(names) > 0 && !slices.ContainsFunc(names, func(name string) bool { return !slices.Contains(owner, name) })
+}
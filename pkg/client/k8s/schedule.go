@@ -0,0 +1,113 @@
+package k8s
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// Shift is a time range in which a user may sign in with the role of a TkaSchedule.
+type Shift struct {
+	// Schedule is the name of the TkaSchedule the shift belongs to.
+	Schedule string
+	// Role is the ClusterRole granted during the shift.
+	Role  string
+	Start time.Time
+	End   time.Time
+}
+
+// Bounds formats the start and end of the shift as RFC3339 timestamps, the way TkaSignin stores them
+// in NotBefore and NotAfter. Both are empty for a nil shift.
+func (s *Shift) Bounds() (string, string) {
+	if s == nil {
+		return "", ""
+	}
+	return s.Start.UTC().Format(time.RFC3339), s.End.UTC().Format(time.RFC3339)
+}
+
+// Active reports whether the shift has begun at the given time.
+func (s *Shift) Active(at time.Time) bool {
+	return !at.Before(s.Start)
+}
+
+// +kubebuilder:rbac:groups=tka.specht-labs.de,resources=tkaschedules,verbs=get;list;watch
+
+// GetShift returns the shift of the user with the given Tailscale login name that is active at the given time or,
+// failing that, the earliest one starting within lead. It returns nil if the user is not on shift or TkaSchedules
+// are not installed.
+func (t *tkaClient) GetShift(ctx context.Context, loginName string, at time.Time, lead time.Duration) (*Shift, humane.Error) {
+	ctx, span := t.tracer.Start(ctx, "TkaClient.GetShift")
+	defer span.End()
+
+	var schedules v1alpha1.TkaScheduleList
+	if err := t.client.List(ctx, &schedules); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, humane.Wrap(err, "Failed to load schedules", "check that the TKA server may list TkaSchedules")
+	}
+
+	return FindShift(schedules.Items, loginName, at, lead), nil
+}
+
+// FindShift picks the shift of the user with the given login name from the given schedules: an active shift if
+// there is one, preferring the one that lasts longest, otherwise the earliest shift starting within lead. Shifts
+// with invalid times are ignored. An empty login name, e.g. of a tagged node, is on no shift.
+func FindShift(schedules []v1alpha1.TkaSchedule, loginName string, at time.Time, lead time.Duration) *Shift {
+	if loginName == "" {
+		return nil
+	}
+
+	var found *Shift
+	for _, schedule := range schedules {
+		for _, s := range schedule.Spec.Shifts {
+			if !slices.Contains(s.Users, loginName) {
+				continue
+			}
+
+			start, startErr := time.Parse(time.RFC3339, s.Start)
+			end, endErr := time.Parse(time.RFC3339, s.End)
+			if startErr != nil || endErr != nil || !end.After(at) || !end.After(start) || start.After(at.Add(lead)) {
+				continue
+			}
+
+			shift := &Shift{Schedule: schedule.Name, Role: schedule.Spec.Role, Start: start, End: end}
+			if found == nil || shift.preferredOver(found, at) {
+				found = shift
+			}
+		}
+	}
+
+	return found
+}
+
+func (s *Shift) preferredOver(other *Shift, at time.Time) bool {
+	if s.Active(at) != other.Active(at) {
+		return s.Active(at)
+	}
+	if s.Active(at) {
+		return s.End.After(other.End)
+	}
+	return s.Start.Before(other.Start)
+}
+
+// AccessWindow returns when the access granted by a sign-in starts and ends if the user signed in at the given time.
+// Access starts at notBefore if that is later and ends at notAfter if that comes before the validity period is over.
+// Both are RFC3339 timestamps as stored in TkaSignin; empty values are ignored.
+func AccessWindow(signedInAt time.Time, period time.Duration, notBefore, notAfter string) (time.Time, time.Time) {
+	start := signedInAt
+	if notBefore, err := time.Parse(time.RFC3339, notBefore); err == nil && notBefore.After(start) {
+		start = notBefore
+	}
+
+	end := start.Add(period)
+	if notAfter, err := time.Parse(time.RFC3339, notAfter); err == nil && notAfter.Before(end) {
+		end = notAfter
+	}
+
+	return start, end
+}
@@ -0,0 +1,155 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestSchedule(name, role string, shifts ...v1alpha1.TkaScheduleShift) v1alpha1.TkaSchedule {
+	return v1alpha1.TkaSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.TkaScheduleSpec{Role: role, Shifts: shifts},
+	}
+}
+
+func newTestShift(start, end time.Time, users ...string) v1alpha1.TkaScheduleShift {
+	return v1alpha1.TkaScheduleShift{Users: users, Start: start.Format(time.RFC3339), End: end.Format(time.RFC3339)}
+}
+
+func TestFindShift(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	lead := time.Hour
+
+	tests := []struct {
+		name          string
+		schedules     []v1alpha1.TkaSchedule
+		expectedRole  string
+		expectedStart time.Time
+	}{
+		{
+			name: "not on shift",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(-time.Hour), now.Add(time.Hour), "bob@example.com")),
+			},
+		},
+		{
+			name: "active shift",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(-time.Hour), now.Add(time.Hour), "bob@example.com", "alice@example.com")),
+			},
+			expectedRole:  "cluster-admin",
+			expectedStart: now.Add(-time.Hour),
+		},
+		{
+			name: "ended shift",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(-2*time.Hour), now, "alice@example.com")),
+			},
+		},
+		{
+			name: "shift starting within the lead time",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(30*time.Minute), now.Add(8*time.Hour), "alice@example.com")),
+			},
+			expectedRole:  "cluster-admin",
+			expectedStart: now.Add(30 * time.Minute),
+		},
+		{
+			name: "shift starting after the lead time",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(2*time.Hour), now.Add(8*time.Hour), "alice@example.com")),
+			},
+		},
+		{
+			name: "active shift is preferred over an upcoming one",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("upcoming", "cluster-admin", newTestShift(now.Add(10*time.Minute), now.Add(8*time.Hour), "alice@example.com")),
+				newTestSchedule("active", "edit", newTestShift(now.Add(-time.Hour), now.Add(time.Hour), "alice@example.com")),
+			},
+			expectedRole:  "edit",
+			expectedStart: now.Add(-time.Hour),
+		},
+		{
+			name: "invalid shifts are ignored",
+			schedules: []v1alpha1.TkaSchedule{
+				newTestSchedule("oncall", "cluster-admin",
+					v1alpha1.TkaScheduleShift{Users: []string{"alice@example.com"}, Start: "tomorrow", End: now.Add(time.Hour).Format(time.RFC3339)},
+					newTestShift(now.Add(time.Hour), now.Add(-time.Hour), "alice@example.com"),
+				),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shift := k8s.FindShift(tc.schedules, "alice@example.com", now, lead)
+			if tc.expectedRole == "" {
+				require.Nil(t, shift)
+				return
+			}
+
+			require.NotNil(t, shift)
+			require.Equal(t, tc.expectedRole, shift.Role)
+			require.True(t, tc.expectedStart.Equal(shift.Start))
+		})
+	}
+}
+
+func TestGetShift(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	schedule := newTestSchedule("oncall", "cluster-admin", newTestShift(now.Add(-time.Hour), now.Add(time.Hour), "alice@example.com"))
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&schedule).Build()
+	tkaClient := k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{}), k8s.DefaultClientOptions())
+
+	shift, err := tkaClient.GetShift(context.Background(), "alice@example.com", now, k8s.DefaultShiftLeadTime)
+	require.Nil(t, err)
+	require.NotNil(t, shift)
+	require.Equal(t, "oncall", shift.Schedule)
+
+	// Schedules list login names, the TKA username alone does not match
+	shift, err = tkaClient.GetShift(context.Background(), "alice", now, k8s.DefaultShiftLeadTime)
+	require.Nil(t, err)
+	require.Nil(t, shift)
+
+	shift, err = tkaClient.GetShift(context.Background(), "bob@example.com", now, k8s.DefaultShiftLeadTime)
+	require.Nil(t, err)
+	require.Nil(t, shift)
+}
+
+func TestAccessWindow(t *testing.T) {
+	signedIn := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		notBefore     string
+		notAfter      string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{name: "unrestricted", expectedStart: signedIn, expectedEnd: signedIn.Add(8 * time.Hour)},
+		{name: "starts later", notBefore: "2030-01-01T14:00:00Z", expectedStart: signedIn.Add(2 * time.Hour), expectedEnd: signedIn.Add(10 * time.Hour)},
+		{name: "not before in the past", notBefore: "2030-01-01T10:00:00Z", expectedStart: signedIn, expectedEnd: signedIn.Add(8 * time.Hour)},
+		{name: "revoked early", notAfter: "2030-01-01T16:00:00Z", expectedStart: signedIn, expectedEnd: signedIn.Add(4 * time.Hour)},
+		{name: "not after beyond the period", notAfter: "2030-01-02T00:00:00Z", expectedStart: signedIn, expectedEnd: signedIn.Add(8 * time.Hour)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := k8s.AccessWindow(signedIn, 8*time.Hour, tc.notBefore, tc.notAfter)
+			require.True(t, tc.expectedStart.Equal(start), start)
+			require.True(t, tc.expectedEnd.Equal(end), end)
+		})
+	}
+}
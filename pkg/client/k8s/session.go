@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Session describes the person and sign-in behind a ServiceAccount managed by TKA.
+type Session struct {
+	// ServiceAccount is the name of the ServiceAccount the session's credentials authenticate as.
+	ServiceAccount string `json:"serviceAccount"`
+	// UID of the ServiceAccount. Every sign-in after signing out gets a new ServiceAccount, so requests
+	// of an earlier session of the same user carry a different UID.
+	UID types.UID `json:"-"`
+
+	// The following are recorded in the ServiceAccount's annotations (see SetSessionAnnotations)
+	Username  string `json:"username"`
+	LoginName string `json:"loginName,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	Device    string `json:"device,omitempty"`
+	DeviceID  string `json:"deviceId,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+
+	// The following are taken from the live TkaSignin and are empty if it is gone
+	Role       string `json:"role,omitempty"`
+	SignedInAt string `json:"signedInAt,omitempty"`
+	ValidUntil string `json:"validUntil,omitempty"`
+	Parent     string `json:"parent,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// NewSession combines the annotations of a ServiceAccount with the sign-in it belongs to. signIn may be nil.
+func NewSession(sa *corev1.ServiceAccount, signIn *v1alpha1.TkaSignin) Session {
+	session := Session{
+		ServiceAccount: sa.Name,
+		UID:            sa.UID,
+		Username:       strings.TrimPrefix(sa.Name, DefaultUserEntryPrefix),
+		LoginName:      sa.Annotations[SignInLoginName],
+		SessionID:      sa.Annotations[SignInSessionID],
+		Device:         sa.Annotations[SignInDevice],
+		DeviceID:       sa.Annotations[SignInDeviceID],
+		Reason:         sa.Annotations[SignInReason],
+	}
+
+	if signIn != nil {
+		session.Username = signIn.Spec.Username
+		session.Role = signIn.Spec.Role
+		session.SignedInAt = signIn.Status.SignedInAt
+		session.ValidUntil = signIn.Status.ValidUntil
+		session.Parent = signIn.Spec.Parent
+		session.Namespace = signIn.Spec.Namespace
+	}
+
+	return session
+}
+
+// ListSessions returns the sessions of all ServiceAccounts TKA manages in the namespace, keyed by ServiceAccount name.
+func ListSessions(ctx context.Context, c client.Reader, namespace string) (map[string]Session, humane.Error) {
+	var serviceAccounts corev1.ServiceAccountList
+	if err := c.List(ctx, &serviceAccounts, client.InNamespace(namespace)); err != nil {
+		return nil, humane.Wrap(err, "Failed to list service accounts", "check Kubernetes permissions for listing service accounts in namespace "+namespace)
+	}
+
+	var signIns v1alpha1.TkaSigninList
+	if err := c.List(ctx, &signIns, client.InNamespace(namespace)); err != nil {
+		return nil, humane.Wrap(err, "Failed to list sign-in requests", "check Kubernetes permissions for listing TkaSignin resources in namespace "+namespace)
+	}
+
+	byName := make(map[string]*v1alpha1.TkaSignin, len(signIns.Items))
+	for i := range signIns.Items {
+		byName[signIns.Items[i].Name] = &signIns.Items[i]
+	}
+
+	sessions := make(map[string]Session)
+	for i := range serviceAccounts.Items {
+		sa := &serviceAccounts.Items[i]
+		if !strings.HasPrefix(sa.Name, DefaultUserEntryPrefix) {
+			continue
+		}
+		// Sign-ins share the name of their ServiceAccount
+		sessions[sa.Name] = NewSession(sa, byName[sa.Name])
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,98 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newAnnotatedSignin() *v1alpha1.TkaSignin {
+	signIn := k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+	signIn.UID = "0f8e2c6a-signin"
+	k8s.WithLoginName("alice@example.com")(signIn)
+	k8s.WithDevice(&tshttp.WhoIsInfo{NodeName: "laptop", NodeID: "nXXXX"})(signIn)
+	k8s.WithReason("INC-1234")(signIn)
+	signIn.Status.SignedInAt = "2026-01-01T10:00:00Z"
+	signIn.Status.ValidUntil = "2026-01-01T11:00:00Z"
+	return signIn
+}
+
+func TestNewServiceAccount_SessionAnnotations(t *testing.T) {
+	signIn := newAnnotatedSignin()
+	sa := k8s.NewServiceAccount(signIn)
+
+	require.Equal(t, map[string]string{
+		k8s.SignInLoginName: "alice@example.com",
+		k8s.SignInDevice:    "laptop",
+		k8s.SignInDeviceID:  "nXXXX",
+		k8s.SignInSessionID: "0f8e2c6a-signin",
+		k8s.SignInReason:    "INC-1234",
+	}, sa.Annotations)
+
+	// A later sign-in without a reason or device must not keep the stale values
+	signIn.Spec.Reason = ""
+	signIn.Spec.Device = nil
+	k8s.SetSessionAnnotations(&sa.ObjectMeta, signIn)
+	require.Equal(t, map[string]string{
+		k8s.SignInLoginName: "alice@example.com",
+		k8s.SignInSessionID: "0f8e2c6a-signin",
+	}, sa.Annotations)
+}
+
+func TestListSessions(t *testing.T) {
+	signIn := newAnnotatedSignin()
+	aliceSA := k8s.NewServiceAccount(signIn)
+	aliceSA.UID = "sa-uid-alice"
+
+	// bob's sign-in is already gone, only the annotations of his ServiceAccount are left
+	bob := newAnnotatedSignin()
+	bob.Spec.Username = "bob"
+	bob.UID = "7a1d93be-signin"
+	k8s.WithLoginName("bob@example.com")(bob)
+	bobSA := k8s.NewServiceAccount(bob)
+	bobSA.UID = "sa-uid-bob"
+
+	other := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: testNamespace}}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(signIn, aliceSA, bobSA, other).Build()
+
+	sessions, err := k8s.ListSessions(context.Background(), c, testNamespace)
+	require.Nil(t, err)
+	require.Equal(t, map[string]k8s.Session{
+		"tka-user-alice": {
+			ServiceAccount: "tka-user-alice",
+			UID:            "sa-uid-alice",
+			Username:       "alice",
+			LoginName:      "alice@example.com",
+			SessionID:      "0f8e2c6a-signin",
+			Device:         "laptop",
+			DeviceID:       "nXXXX",
+			Reason:         "INC-1234",
+			Role:           "view",
+			SignedInAt:     "2026-01-01T10:00:00Z",
+			ValidUntil:     "2026-01-01T11:00:00Z",
+		},
+		"tka-user-bob": {
+			ServiceAccount: "tka-user-bob",
+			UID:            "sa-uid-bob",
+			Username:       "bob",
+			LoginName:      "bob@example.com",
+			SessionID:      "7a1d93be-signin",
+			Device:         "laptop",
+			DeviceID:       "nXXXX",
+			Reason:         "INC-1234",
+		},
+	}, sessions)
+}
@@ -0,0 +1,51 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+)
+
+// ErrSessionSuspended is the cause of errors returned for sign-ins an administrator suspended.
+var ErrSessionSuspended = errors.New("session suspended")
+
+// NewSessionSuspendedError explains that the user's access is suspended.
+func NewSessionSuspendedError(userName string) humane.Error {
+	return humane.Wrap(fmt.Errorf("%w: %s", ErrSessionSuspended, userName),
+		"Session suspended",
+		"an administrator temporarily suspended your access; it is restored when they resume your session",
+		"your session still expires at its usual time")
+}
+
+// SetSuspended suspends or resumes a user's sign-in together with the credentials delegated from it.
+// The operator removes or restores the bindings.
+func (t *tkaClient) SetSuspended(ctx context.Context, userName string, suspended bool) humane.Error {
+	ctx, span := t.tracer.Start(ctx, "TkaClient.SetSuspended")
+	defer span.End()
+
+	signIn, err := t.GetSignIn(ctx, userName)
+	if err != nil {
+		return err
+	}
+
+	delegations, err := ListDelegations(ctx, t.client, signIn)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range append(delegations, *signIn) {
+		if s.Spec.Suspended == suspended {
+			continue
+		}
+
+		s.Spec.Suspended = suspended
+		SetTraceParent(ctx, &s.ObjectMeta)
+		if err := t.client.Update(ctx, &s); err != nil {
+			return humane.Wrap(err, "Failed to update sign-in request "+s.Name, "check Kubernetes permissions for updating TkaSignin resources")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Names of the available token strategies, as reported by TokenIssuer.Name.
+const (
+	TokenStrategyTokenRequest = "token-request"
+	TokenStrategySecret       = "service-account-token-secret"
+)
+
+// serviceAccountTokenSubresource is the core/v1 subresource backing the TokenRequest API.
+const serviceAccountTokenSubresource = "serviceaccounts/token"
+
+// IssuedToken is a bearer token minted by a TokenIssuer.
+type IssuedToken struct {
+	// Token is the bearer token to put into the kubeconfig.
+	Token string
+	// ExpiresAt is when the token stops being valid. A zero value means the token
+	// does not expire on its own and lives until it is revoked.
+	ExpiresAt time.Time
+}
+
+// NewTokenIssuer selects the token strategy supported by the cluster behind clientset.
+// Clusters serving the TokenRequest API get short-lived, bound tokens. Clusters without it
+// fall back to a managed `kubernetes.io/service-account-token` Secret per sign-in, which
+// is deleted again when the user signs out.
+func NewTokenIssuer(clientset kubernetes.Interface) (TokenIssuer, humane.Error) {
+	supported, err := supportsTokenRequest(clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	if supported {
+		return NewTokenRequestIssuer(clientset), nil
+	}
+	return NewSecretTokenIssuer(clientset), nil
+}
+
+func supportsTokenRequest(clientset kubernetes.Interface) (bool, humane.Error) {
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersion(corev1.SchemeGroupVersion.String())
+	if err != nil {
+		return false, humane.Wrap(err, "Failed to discover core/v1 API resources", "check cluster connectivity and API server availability")
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == serviceAccountTokenSubresource {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tokenRequestClient issues bound ServiceAccount tokens through the TokenRequest API.
+type tokenRequestClient struct {
+	clientset kubernetes.Interface
+}
+
+// NewTokenRequestIssuer returns a TokenIssuer backed by the TokenRequest API.
+func NewTokenRequestIssuer(clientset kubernetes.Interface) TokenIssuer {
+	return &tokenRequestClient{clientset: clientset}
+}
+
+func (t *tokenRequestClient) Name() string { return TokenStrategyTokenRequest }
+
+func (t *tokenRequestClient) IssueToken(ctx context.Context, signIn *v1alpha1.TkaSignin, ttl time.Duration) (*IssuedToken, humane.Error) {
+	if ttl < MinSigninValidity {
+		ttl = MinSigninValidity
+	}
+
+	tokenRequest := NewTokenRequest(int64(ttl.Seconds()))
+	resp, err := t.clientset.CoreV1().ServiceAccounts(signIn.Namespace).CreateToken(ctx, FormatSigninObjectName(signIn.Spec.Username), tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return nil, humane.Wrap(err, "Failed to create token for service account", "check that the service account exists and the operator has token creation permissions")
+	}
+
+	return &IssuedToken{
+		Token:     resp.Status.Token,
+		ExpiresAt: resp.Status.ExpirationTimestamp.Time,
+	}, nil
+}
+
+// RevokeToken is a no-op: bound tokens become invalid as soon as their ServiceAccount is deleted.
+func (t *tokenRequestClient) RevokeToken(_ context.Context, _ *v1alpha1.TkaSignin) humane.Error {
+	return nil
+}
+
+// secretTokenClient issues long-lived tokens by creating a `kubernetes.io/service-account-token`
+// Secret that the token controller fills in. These tokens do not expire on their own, so the
+// Secret must be removed explicitly via RevokeToken.
+type secretTokenClient struct {
+	clientset kubernetes.Interface
+}
+
+// NewSecretTokenIssuer returns a TokenIssuer backed by managed service-account-token Secrets.
+func NewSecretTokenIssuer(clientset kubernetes.Interface) TokenIssuer {
+	return &secretTokenClient{clientset: clientset}
+}
+
+func (s *secretTokenClient) Name() string { return TokenStrategySecret }
+
+func (s *secretTokenClient) IssueToken(ctx context.Context, signIn *v1alpha1.TkaSignin, _ time.Duration) (*IssuedToken, humane.Error) {
+	secrets := s.clientset.CoreV1().Secrets(signIn.Namespace)
+
+	secret, err := secrets.Get(ctx, FormatTokenSecretName(signIn.Spec.Username), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if herr := s.createTokenSecret(ctx, signIn); herr != nil {
+			return nil, herr
+		}
+		// The token controller populates the Secret asynchronously
+		return nil, NotReadyYetError
+	} else if err != nil {
+		return nil, humane.Wrap(err, "Failed to load service account token secret", "check Kubernetes connectivity and read permissions for secrets in namespace "+signIn.Namespace)
+	}
+
+	token, ok := secret.Data[corev1.ServiceAccountTokenKey]
+	if !ok || len(token) == 0 {
+		return nil, NotReadyYetError
+	}
+
+	return &IssuedToken{Token: string(token)}, nil
+}
+
+func (s *secretTokenClient) createTokenSecret(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	serviceAccount, err := s.clientset.CoreV1().ServiceAccounts(signIn.Namespace).Get(ctx, FormatSigninObjectName(signIn.Spec.Username), metav1.GetOptions{})
+	if err != nil {
+		return humane.Wrap(err, "Failed to load service account", "check that the sign-in has been provisioned by the operator")
+	}
+
+	if _, err := s.clientset.CoreV1().Secrets(signIn.Namespace).Create(ctx, NewServiceAccountTokenSecret(serviceAccount), metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return humane.Wrap(err, "Failed to create service account token secret", "check Kubernetes permissions for creating secrets in namespace "+signIn.Namespace)
+	}
+
+	return nil
+}
+
+func (s *secretTokenClient) RevokeToken(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	err := s.clientset.CoreV1().Secrets(signIn.Namespace).Delete(ctx, FormatTokenSecretName(signIn.Spec.Username), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return humane.Wrap(err, fmt.Sprintf("Failed to delete service account token secret for user %s", signIn.Spec.Username), "check Kubernetes permissions for deleting secrets in namespace "+signIn.Namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package k8s
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Keys of the token cache Secret's data.
+const (
+	tokenCacheTokenKey     = "token"
+	tokenCacheExpiresAtKey = "expiresAt"
+)
+
+// DefaultTokenCacheRotateBefore is how much remaining lifetime a cached token must have to be reused.
+const DefaultTokenCacheRotateBefore = 5 * time.Minute
+
+// tokenCacheKeySize is the size of the AES-256 key that encrypts cached tokens.
+const tokenCacheKeySize = 32
+
+// cachingTokenClient wraps a TokenIssuer and reuses the issued token for the lifetime of a session.
+// The token is stored encrypted in a Secret per user so every API replica hands out the same token.
+// The Secret is annotated with a hash of the session (sign-in UID, role and validity), so signing
+// in again, extending the sign-in or changing the role all invalidate the cached token.
+type cachingTokenClient struct {
+	issuer       TokenIssuer
+	client       client.Client
+	aead         cipher.AEAD
+	rotateBefore time.Duration
+}
+
+// NewCachingTokenIssuer returns a TokenIssuer that reuses tokens minted by issuer while they have
+// more than opts.RotateBefore lifetime left. The cache Secrets are read and written with c, so pass
+// the manager's client to read them from its cache. Tokens are encrypted with opts.EncryptionKey, a
+// base64 encoded 32 byte key. Without one a random key is used and only this process can reuse the
+// tokens it cached.
+func NewCachingTokenIssuer(issuer TokenIssuer, c client.Client, opts TokenCacheOptions) (TokenIssuer, humane.Error) {
+	rotateBefore := opts.RotateBefore
+	if rotateBefore <= 0 {
+		rotateBefore = DefaultTokenCacheRotateBefore
+	}
+
+	key, err := tokenCacheKey(opts.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	block, cerr := aes.NewCipher(key)
+	if cerr != nil {
+		return nil, humane.Wrap(cerr, "failed to set up token cache encryption", "this is an internal error; please report it")
+	}
+	aead, cerr := cipher.NewGCM(block)
+	if cerr != nil {
+		return nil, humane.Wrap(cerr, "failed to set up token cache encryption", "this is an internal error; please report it")
+	}
+
+	return &cachingTokenClient{
+		issuer:       issuer,
+		client:       c,
+		aead:         aead,
+		rotateBefore: rotateBefore,
+	}, nil
+}
+
+// tokenCacheKey decodes encoded, or returns a random key if it is empty.
+func tokenCacheKey(encoded string) ([]byte, humane.Error) {
+	if encoded == "" {
+		key := make([]byte, tokenCacheKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, humane.Wrap(err, "failed to generate token cache key", "set operator.tokenCache.encryptionKey")
+		}
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, humane.Wrap(err, "operator.tokenCache.encryptionKey is not valid base64", "generate a key with: openssl rand -base64 32")
+	}
+	if len(key) != tokenCacheKeySize {
+		return nil, humane.New(fmt.Sprintf("operator.tokenCache.encryptionKey must be %d bytes, got %d", tokenCacheKeySize, len(key)), "generate a key with: openssl rand -base64 32")
+	}
+	return key, nil
+}
+
+func (c *cachingTokenClient) Name() string { return c.issuer.Name() }
+
+func (c *cachingTokenClient) IssueToken(ctx context.Context, signIn *v1alpha1.TkaSignin, ttl time.Duration) (*IssuedToken, humane.Error) {
+	sessionHash := SessionHash(signIn)
+
+	cached := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: signIn.Namespace, Name: FormatTokenCacheSecretName(signIn.Spec.Username)}
+	if err := c.client.Get(ctx, key, cached); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			otelzap.L().WithError(err).WarnContext(ctx, "Failed to read token cache, issuing a new token", zap.String("user", signIn.Spec.Username))
+		}
+		cached = nil
+	}
+
+	if token, ok := c.reusableToken(cached, sessionHash); ok {
+		return token, nil
+	}
+
+	token, herr := c.issuer.IssueToken(ctx, signIn, ttl)
+	if herr != nil {
+		return nil, herr
+	}
+
+	sealed, serr := c.seal(FormatTokenCacheSecretName(signIn.Spec.Username), sessionHash, token.Token)
+	if serr != nil {
+		otelzap.L().WithError(serr).WarnContext(ctx, "Failed to encrypt issued token, not caching it", zap.String("user", signIn.Spec.Username))
+		return token, nil
+	}
+
+	c.store(ctx, signIn, cached, NewTokenCacheSecret(signIn, sessionHash, sealed, token.ExpiresAt))
+	return token, nil
+}
+
+// seal encrypts token for the cache Secret name. The Secret name and session hash are authenticated
+// with it, so a cached token cannot be moved to another user or session.
+func (c *cachingTokenClient) seal(name, sessionHash, token string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, []byte(token), []byte(name+"\x00"+sessionHash)), nil
+}
+
+// open decrypts a token sealed by seal.
+func (c *cachingTokenClient) open(name, sessionHash string, sealed []byte) ([]byte, bool) {
+	size := c.aead.NonceSize()
+	if len(sealed) <= size {
+		return nil, false
+	}
+	token, err := c.aead.Open(nil, sealed[:size], sealed[size:], []byte(name+"\x00"+sessionHash))
+	return token, err == nil
+}
+
+// reusableToken returns the token held in the cache Secret if it belongs to the current
+// session, can be decrypted and is not about to expire.
+func (c *cachingTokenClient) reusableToken(cached *corev1.Secret, sessionHash string) (*IssuedToken, bool) {
+	if cached == nil || cached.Annotations[TokenCacheSessionHash] != sessionHash {
+		return nil, false
+	}
+
+	token, ok := c.open(cached.Name, sessionHash, cached.Data[tokenCacheTokenKey])
+	if !ok || len(token) == 0 {
+		return nil, false
+	}
+
+	issued := &IssuedToken{Token: string(token)}
+	if raw := cached.Data[tokenCacheExpiresAtKey]; len(raw) > 0 {
+		expiresAt, err := time.Parse(time.RFC3339, string(raw))
+		if err != nil || time.Until(expiresAt) < c.rotateBefore {
+			return nil, false
+		}
+		issued.ExpiresAt = expiresAt
+	}
+
+	return issued, true
+}
+
+// store writes the freshly issued token to the cache. Losing a race against another replica, or
+// reading a cache that lags behind, is fine: the token we issued is still valid and a later request
+// picks up the stored token.
+func (c *cachingTokenClient) store(ctx context.Context, signIn *v1alpha1.TkaSignin, cached, secret *corev1.Secret) {
+	var err error
+	if cached == nil {
+		err = c.client.Create(ctx, secret)
+	} else {
+		secret.ResourceVersion = cached.ResourceVersion
+		err = c.client.Update(ctx, secret)
+	}
+
+	if err != nil && !k8serrors.IsAlreadyExists(err) && !k8serrors.IsConflict(err) {
+		otelzap.L().WithError(err).WarnContext(ctx, "Failed to cache issued token", zap.String("user", signIn.Spec.Username))
+	}
+}
+
+func (c *cachingTokenClient) RevokeToken(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	secret := &corev1.Secret{}
+	secret.Namespace = signIn.Namespace
+	secret.Name = FormatTokenCacheSecretName(signIn.Spec.Username)
+	if err := c.client.Delete(ctx, secret); err != nil && !k8serrors.IsNotFound(err) {
+		return humane.Wrap(err, fmt.Sprintf("Failed to delete token cache for user %s", signIn.Spec.Username), "check Kubernetes permissions for deleting secrets in namespace "+signIn.Namespace)
+	}
+
+	return c.issuer.RevokeToken(ctx, signIn)
+}
+
+// SessionHash fingerprints the parts of a sign-in that a cached token is bound to.
+// It changes whenever the user signs in again, is granted a different role or token lifetime,
+// or has their sign-in extended.
+func SessionHash(signIn *v1alpha1.TkaSignin) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s", signIn.UID, signIn.Spec.Role, signIn.Spec.TokenTTL, signIn.Status.ValidUntil)))
+	return hex.EncodeToString(sum[:])
+}
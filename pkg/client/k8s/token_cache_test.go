@@ -0,0 +1,195 @@
+package k8s_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testTokenCacheKey is a base64 encoded 32 byte key for the token cache.
+const testTokenCacheKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+// countingIssuer hands out a new token on every call and remembers how often it was asked.
+type countingIssuer struct {
+	issued   int
+	revoked  int
+	lifetime time.Duration
+}
+
+func (c *countingIssuer) Name() string { return k8s.TokenStrategyTokenRequest }
+
+func (c *countingIssuer) IssueToken(_ context.Context, _ *v1alpha1.TkaSignin, _ time.Duration) (*k8s.IssuedToken, humane.Error) {
+	c.issued++
+	return &k8s.IssuedToken{Token: fmt.Sprintf("token-%d", c.issued), ExpiresAt: time.Now().Add(c.lifetime)}, nil
+}
+
+func (c *countingIssuer) RevokeToken(_ context.Context, _ *v1alpha1.TkaSignin) humane.Error {
+	c.revoked++
+	return nil
+}
+
+func newCachingTokenIssuer(t *testing.T, inner k8s.TokenIssuer, c client.Client, rotateBefore time.Duration) k8s.TokenIssuer {
+	t.Helper()
+
+	issuer, err := k8s.NewCachingTokenIssuer(inner, c, k8s.TokenCacheOptions{RotateBefore: rotateBefore, EncryptionKey: testTokenCacheKey})
+	require.Nil(t, err)
+	return issuer
+}
+
+func newCachedSignIn() *v1alpha1.TkaSignin {
+	signIn := newTestSignIn()
+	signIn.UID = types.UID("signin-uid")
+	signIn.Status.ValidUntil = time.Now().Add(time.Hour).Format(time.RFC3339)
+	return signIn
+}
+
+func TestCachingTokenIssuer_ReusesToken(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingIssuer{lifetime: time.Hour}
+	issuer := newCachingTokenIssuer(t, inner, fake.NewClientBuilder().Build(), 5*time.Minute)
+	signIn := newCachedSignIn()
+
+	first, err := issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+
+	for range 5 {
+		token, err := issuer.IssueToken(ctx, signIn, time.Hour)
+		require.Nil(t, err)
+		require.Equal(t, first.Token, token.Token)
+	}
+	require.Equal(t, 1, inner.issued)
+	require.Equal(t, k8s.TokenStrategyTokenRequest, issuer.Name())
+}
+
+func TestCachingTokenIssuer_Invalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(signIn *v1alpha1.TkaSignin)
+	}{
+		{name: "role change", mutate: func(s *v1alpha1.TkaSignin) { s.Spec.Role = "edit" }},
+		{name: "extension", mutate: func(s *v1alpha1.TkaSignin) {
+			s.Status.ValidUntil = time.Now().Add(2 * time.Hour).Format(time.RFC3339)
+		}},
+		{name: "new sign-in", mutate: func(s *v1alpha1.TkaSignin) { s.UID = "other-signin-uid" }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := &countingIssuer{lifetime: time.Hour}
+			issuer := newCachingTokenIssuer(t, inner, fake.NewClientBuilder().Build(), 5*time.Minute)
+			signIn := newCachedSignIn()
+
+			first, err := issuer.IssueToken(ctx, signIn, time.Hour)
+			require.Nil(t, err)
+
+			tc.mutate(signIn)
+			second, err := issuer.IssueToken(ctx, signIn, time.Hour)
+			require.Nil(t, err)
+			require.NotEqual(t, first.Token, second.Token)
+			require.Equal(t, 2, inner.issued)
+		})
+	}
+}
+
+func TestCachingTokenIssuer_RotatesNearExpiry(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingIssuer{lifetime: 4 * time.Minute}
+	issuer := newCachingTokenIssuer(t, inner, fake.NewClientBuilder().Build(), 5*time.Minute)
+	signIn := newCachedSignIn()
+
+	first, err := issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+
+	second, err := issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+	require.NotEqual(t, first.Token, second.Token)
+}
+
+func TestCachingTokenIssuer_SharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	signIn := newCachedSignIn()
+
+	replicaA := newCachingTokenIssuer(t, &countingIssuer{lifetime: time.Hour}, c, 5*time.Minute)
+	replicaB := newCachingTokenIssuer(t, &countingIssuer{lifetime: time.Hour}, c, 5*time.Minute)
+
+	first, err := replicaA.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+
+	second, err := replicaB.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, first.Token, second.Token)
+
+	// A replica with another key cannot read the cached token and issues its own
+	innerC := &countingIssuer{lifetime: time.Hour}
+	replicaC, herr := k8s.NewCachingTokenIssuer(innerC, c, k8s.TokenCacheOptions{})
+	require.Nil(t, herr)
+	_, err = replicaC.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 1, innerC.issued)
+}
+
+func TestCachingTokenIssuer_EncryptionKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{name: "key", key: testTokenCacheKey},
+		{name: "random key"},
+		{name: "not base64", key: "not base64!", wantErr: "not valid base64"},
+		{name: "too short", key: "c2hvcnQ=", wantErr: "must be 32 bytes"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := k8s.NewCachingTokenIssuer(&countingIssuer{}, fake.NewClientBuilder().Build(), k8s.TokenCacheOptions{EncryptionKey: tc.key})
+			if tc.wantErr == "" {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestCachingTokenIssuer_RevokeToken(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	inner := &countingIssuer{lifetime: time.Hour}
+	issuer := newCachingTokenIssuer(t, inner, c, 5*time.Minute)
+	signIn := newCachedSignIn()
+
+	token, err := issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+
+	key := types.NamespacedName{Namespace: testNamespace, Name: k8s.FormatTokenCacheSecretName("alice")}
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(ctx, key, secret))
+	require.Equal(t, k8s.SessionHash(signIn), secret.Annotations[k8s.TokenCacheSessionHash])
+	require.Equal(t, "true", secret.Labels[k8s.TokenCacheLabel])
+	require.Len(t, secret.OwnerReferences, 1)
+	require.Equal(t, signIn.UID, secret.OwnerReferences[0].UID)
+	for _, value := range secret.Data {
+		require.NotContains(t, string(value), token.Token)
+	}
+
+	require.Nil(t, issuer.RevokeToken(ctx, signIn))
+	require.Equal(t, 1, inner.revoked)
+
+	require.True(t, k8serrors.IsNotFound(c.Get(ctx, key, &corev1.Secret{})))
+	require.Nil(t, issuer.RevokeToken(ctx, signIn))
+}
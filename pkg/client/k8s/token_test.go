@@ -0,0 +1,167 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "tka-test"
+
+func newTestSignIn() *v1alpha1.TkaSignin {
+	return k8s.NewSignin("alice", "view", time.Hour, testNamespace)
+}
+
+func newTestServiceAccount() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8s.FormatSigninObjectName("alice"),
+			Namespace: testNamespace,
+			UID:       "sa-uid",
+		},
+	}
+}
+
+// newFakeClientset returns a fake clientset reporting the given server version and,
+// if withTokenRequest is set, advertising the serviceaccounts/token subresource.
+func newFakeClientset(t testing.TB, minor string, withTokenRequest bool, objects ...runtime.Object) *fake.Clientset {
+	t.Helper()
+
+	clientset := fake.NewClientset(objects...)
+	discovery, ok := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	require.True(t, ok)
+
+	discovery.FakedServerVersion = &version.Info{Major: "1", Minor: minor}
+
+	resources := []metav1.APIResource{{Name: "serviceaccounts", Namespaced: true, Kind: "ServiceAccount"}}
+	if withTokenRequest {
+		resources = append(resources, metav1.APIResource{Name: "serviceaccounts/token", Namespaced: true, Kind: "TokenRequest"})
+	}
+	discovery.Resources = []*metav1.APIResourceList{{GroupVersion: "v1", APIResources: resources}}
+
+	return clientset
+}
+
+func TestNewTokenIssuer(t *testing.T) {
+	tests := []struct {
+		name             string
+		minor            string
+		withTokenRequest bool
+		expectedStrategy string
+	}{
+		{name: "1.24 with TokenRequest", minor: "24", withTokenRequest: true, expectedStrategy: k8s.TokenStrategyTokenRequest},
+		{name: "1.29 with TokenRequest", minor: "29", withTokenRequest: true, expectedStrategy: k8s.TokenStrategyTokenRequest},
+		{name: "1.30 with TokenRequest", minor: "30", withTokenRequest: true, expectedStrategy: k8s.TokenStrategyTokenRequest},
+		{name: "1.24 without TokenRequest", minor: "24", withTokenRequest: false, expectedStrategy: k8s.TokenStrategySecret},
+		{name: "1.29 without TokenRequest", minor: "29", withTokenRequest: false, expectedStrategy: k8s.TokenStrategySecret},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			issuer, err := k8s.NewTokenIssuer(newFakeClientset(t, tc.minor, tc.withTokenRequest))
+			require.Nil(t, err)
+			require.Equal(t, tc.expectedStrategy, issuer.Name())
+		})
+	}
+}
+
+func TestNewTokenIssuer_DiscoveryError(t *testing.T) {
+	clientset := newFakeClientset(t, "29", true)
+	clientset.PrependReactor("get", "resource", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewServiceUnavailable("apiserver down")
+	})
+
+	issuer, err := k8s.NewTokenIssuer(clientset)
+	require.NotNil(t, err)
+	require.Nil(t, issuer)
+}
+
+func TestTokenRequestIssuer(t *testing.T) {
+	clientset := newFakeClientset(t, "29", true, newTestServiceAccount())
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	var requestedSeconds int64
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		req := create.GetObject().(*authenticationv1.TokenRequest)
+		requestedSeconds = *req.Spec.ExpirationSeconds
+		req.Status = authenticationv1.TokenRequestStatus{Token: "bound-token", ExpirationTimestamp: metav1.NewTime(expiry)}
+		return true, req, nil
+	})
+
+	issuer := k8s.NewTokenRequestIssuer(clientset)
+
+	token, err := issuer.IssueToken(context.Background(), newTestSignIn(), time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, "bound-token", token.Token)
+	require.True(t, expiry.Equal(token.ExpiresAt))
+	require.Equal(t, int64(k8s.MinSigninValidity.Seconds()), requestedSeconds, "ttl must be raised to the Kubernetes minimum")
+
+	require.Nil(t, issuer.RevokeToken(context.Background(), newTestSignIn()))
+}
+
+func TestSecretTokenIssuer(t *testing.T) {
+	ctx := context.Background()
+	signIn := newTestSignIn()
+	clientset := newFakeClientset(t, "24", false, newTestServiceAccount())
+	issuer := k8s.NewSecretTokenIssuer(clientset)
+
+	// First call creates the Secret and waits for the token controller
+	token, err := issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, token)
+	require.Equal(t, k8s.NotReadyYetError, err)
+
+	secret, getErr := clientset.CoreV1().Secrets(testNamespace).Get(ctx, k8s.FormatTokenSecretName("alice"), metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Equal(t, corev1.SecretTypeServiceAccountToken, secret.Type)
+	require.Equal(t, k8s.FormatSigninObjectName("alice"), secret.Annotations[corev1.ServiceAccountNameKey])
+	require.Len(t, secret.OwnerReferences, 1)
+	require.Equal(t, "sa-uid", string(secret.OwnerReferences[0].UID))
+
+	// Still not populated
+	token, err = issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, token)
+	require.Equal(t, k8s.NotReadyYetError, err)
+
+	// Simulate the token controller
+	secret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("legacy-token")}
+	_, updateErr := clientset.CoreV1().Secrets(testNamespace).Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, updateErr)
+
+	token, err = issuer.IssueToken(ctx, signIn, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, "legacy-token", token.Token)
+	require.True(t, token.ExpiresAt.IsZero())
+
+	// Revocation removes the Secret and is idempotent
+	require.Nil(t, issuer.RevokeToken(ctx, signIn))
+	_, getErr = clientset.CoreV1().Secrets(testNamespace).Get(ctx, k8s.FormatTokenSecretName("alice"), metav1.GetOptions{})
+	require.True(t, k8serrors.IsNotFound(getErr))
+	require.Nil(t, issuer.RevokeToken(ctx, signIn))
+}
+
+func TestSecretTokenIssuer_MissingServiceAccount(t *testing.T) {
+	issuer := k8s.NewSecretTokenIssuer(newFakeClientset(t, "24", false))
+
+	token, err := issuer.IssueToken(context.Background(), newTestSignIn(), time.Hour)
+	require.Nil(t, token)
+	require.NotNil(t, err)
+	require.NotEqual(t, k8s.NotReadyYetError, err)
+}
@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// traceContext always writes the W3C format, independent of the globally configured propagator,
+// so annotations written by one component can be read by any other.
+var traceContext = propagation.TraceContext{}
+
+// SetTraceParent records the span in ctx as the request that created or last changed obj.
+// The annotation is removed if ctx carries no valid span.
+func SetTraceParent(ctx context.Context, obj *metav1.ObjectMeta) {
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+
+	setAnnotation(obj, TraceParent, carrier.Get("traceparent"))
+	setAnnotation(obj, TraceState, carrier.Get("tracestate"))
+}
+
+// OriginLink returns a link to the span recorded by SetTraceParent, or false if obj has none.
+func OriginLink(obj metav1.Object) (trace.Link, bool) {
+	annotations := obj.GetAnnotations()
+	carrier := propagation.MapCarrier{
+		"traceparent": annotations[TraceParent],
+		"tracestate":  annotations[TraceState],
+	}
+
+	spanContext := trace.SpanContextFromContext(traceContext.Extract(context.Background(), carrier))
+	if !spanContext.IsValid() {
+		return trace.Link{}, false
+	}
+
+	return trace.Link{
+		SpanContext: spanContext,
+		Attributes:  []attribute.KeyValue{attribute.String("link.type", "origin")},
+	}, true
+}
@@ -0,0 +1,80 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestSpanContext(t *testing.T) trace.SpanContext {
+	t.Helper()
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+}
+
+func TestSetTraceParent(t *testing.T) {
+	spanContext := newTestSpanContext(t)
+	obj := &metav1.ObjectMeta{}
+
+	k8s.SetTraceParent(trace.ContextWithSpanContext(context.Background(), spanContext), obj)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", obj.Annotations[k8s.TraceParent])
+
+	link, ok := k8s.OriginLink(obj)
+	require.True(t, ok)
+	require.Equal(t, spanContext.TraceID(), link.SpanContext.TraceID())
+	require.Equal(t, spanContext.SpanID(), link.SpanContext.SpanID())
+	require.True(t, link.SpanContext.IsRemote())
+
+	// A later change without a trace must not keep linking to the old request
+	k8s.SetTraceParent(context.Background(), obj)
+	require.NotContains(t, obj.Annotations, k8s.TraceParent)
+
+	_, ok = k8s.OriginLink(obj)
+	require.False(t, ok)
+}
+
+func TestOriginLink_Invalid(t *testing.T) {
+	obj := &metav1.ObjectMeta{Annotations: map[string]string{k8s.TraceParent: "not-a-traceparent"}}
+
+	_, ok := k8s.OriginLink(obj)
+	require.False(t, ok)
+}
+
+func TestNewSignIn_RecordsTraceParent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, rbacv1.AddToScheme(scheme))
+
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}).
+		WithStatusSubresource(&v1alpha1.TkaSignin{}).Build()
+	opts := k8s.DefaultClientOptions()
+	opts.Namespace = testNamespace
+	tkaClient := k8s.NewTkaClient(ctrlClient, models.NewClusterInfoStore(&models.TkaClusterInfo{}), opts)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), newTestSpanContext(t))
+	require.Nil(t, tkaClient.NewSignIn(ctx, "alice", "view", time.Hour))
+
+	var signIn v1alpha1.TkaSignin
+	require.NoError(t, ctrlClient.Get(context.Background(), client.ObjectKey{Namespace: testNamespace, Name: k8s.FormatSigninObjectName("alice")}, &signIn))
+
+	link, ok := k8s.OriginLink(&signIn)
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", link.SpanContext.TraceID().String())
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+)
+
+// conditionVariablesAdvice lists what capability rule conditions can refer to.
+const conditionVariablesAdvice = "Conditions can use the variables user, tags, role, device (name, id, os, clientVersion, keyExpiry, attributes), now and cluster (labels)."
+
+// conditionEnv declares the variables available to capability rule conditions.
+var conditionEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("user", cel.StringType),
+		cel.Variable("tags", cel.ListType(cel.StringType)),
+		cel.Variable("role", cel.StringType),
+		cel.Variable("device", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("cluster", cel.MapType(cel.StringType, cel.DynType)),
+	)
+})
+
+// conditionEvaluator compiles capability rule conditions and caches the programs by expression,
+// so every distinct condition in the ACL is only compiled once.
+type conditionEvaluator struct {
+	mu       sync.RWMutex
+	programs map[string]cel.Program
+}
+
+func newConditionEvaluator() *conditionEvaluator {
+	return &conditionEvaluator{programs: make(map[string]cel.Program)}
+}
+
+// evaluate reports whether the condition holds for the given variables.
+func (e *conditionEvaluator) evaluate(expr string, vars map[string]any) (bool, humane.Error) {
+	prg, herr := e.program(expr)
+	if herr != nil {
+		return false, herr
+	}
+
+	out, _, err := prg.Eval(vars)
+	if err != nil {
+		return false, humane.Wrap(err, "Error evaluating condition `"+expr+"` of capability rule",
+			"Guard optional values with has(), e.g. has(cluster.labels.env).", conditionVariablesAdvice)
+	}
+
+	result, ok := out.Value().(bool)
+	return ok && result, nil
+}
+
+// program returns the compiled program for expr, compiling and caching it on first use.
+func (e *conditionEvaluator) program(expr string) (cel.Program, humane.Error) {
+	e.mu.RLock()
+	prg, ok := e.programs[expr]
+	e.mu.RUnlock()
+	if ok {
+		return prg, nil
+	}
+
+	env, err := conditionEnv()
+	if err != nil {
+		return nil, humane.Wrap(err, "Error setting up the condition environment", "This is a bug in TKA; please report it.")
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, humane.Wrap(issues.Err(), "Invalid condition `"+expr+"` in capability rule",
+			"Check the CEL syntax of the condition in your Tailscale ACL.", conditionVariablesAdvice)
+	}
+
+	if ast.OutputType() != cel.BoolType {
+		return nil, humane.New("Condition `"+expr+"` in capability rule does not evaluate to a bool",
+			"Conditions must be boolean expressions, e.g. role != \"cluster-admin\".")
+	}
+
+	prg, err = env.Program(ast)
+	if err != nil {
+		return nil, humane.Wrap(err, "Invalid condition `"+expr+"` in capability rule", conditionVariablesAdvice)
+	}
+
+	e.mu.Lock()
+	e.programs[expr] = prg
+	e.mu.Unlock()
+
+	return prg, nil
+}
+
+// conditionVariables collects the facts about a request that rule conditions are evaluated against.
+// The role variable is set per rule.
+func conditionVariables(userName string, who *tshttp.WhoIsInfo, clusterLabels map[string]string, now time.Time) map[string]any {
+	tags := who.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	attributes := who.NodeAttributes
+	if attributes == nil {
+		attributes = []string{}
+	}
+
+	device := map[string]any{
+		"name":          who.NodeName,
+		"id":            who.NodeID,
+		"os":            who.OS,
+		"clientVersion": who.ClientVersion,
+		"attributes":    attributes,
+	}
+	if !who.KeyExpiry.IsZero() {
+		device["keyExpiry"] = who.KeyExpiry
+	}
+
+	labels := clusterLabels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	return map[string]any{
+		"user":    userName,
+		"tags":    tags,
+		"device":  device,
+		"now":     now,
+		"cluster": map[string]any{"labels": labels},
+	}
+}
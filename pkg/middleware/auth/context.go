@@ -1,10 +1,14 @@
 package auth
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+)
 
 const (
 	contextKeyUser    = "auth_username"
 	contextKeyCapRule = "auth_cap_rule"
+	contextKeyWhoIs   = "auth_whois"
 )
 
 // SetUsername stores the authenticated username in the Gin context.
@@ -45,3 +49,19 @@ func GetCapability[T any](c *gin.Context) *T {
 	}
 	return nil
 }
+
+// SetWhoIs stores the caller's Tailscale identity and device details in the Gin context.
+func SetWhoIs(c *gin.Context, who *tshttp.WhoIsInfo) {
+	c.Set(contextKeyWhoIs, who)
+}
+
+// GetWhoIs retrieves the caller's Tailscale identity and device details from the Gin context.
+// It returns nil if the request was not authenticated.
+func GetWhoIs(c *gin.Context) *tshttp.WhoIsInfo {
+	if v, ok := c.Get(contextKeyWhoIs); ok {
+		if who, ok := v.(*tshttp.WhoIsInfo); ok {
+			return who
+		}
+	}
+	return nil
+}
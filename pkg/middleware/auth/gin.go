@@ -5,9 +5,13 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	humane "github.com/sierrasoftworks/humane-errors-go"
@@ -29,14 +33,18 @@ import (
 // The middleware performs these authentication steps:
 //  1. Rejects requests from Tailscale Funnel (external access)
 //  2. Performs WhoIs lookup on the client's IP address
-//  3. Rejects tagged nodes (service accounts)
-//  4. Extracts and validates capability rules from Tailscale ACLs
-//  5. Stores username and capability in Gin context for handlers
+//  3. Rejects tagged nodes (service accounts) unless allowed, and names them after their tags and node
+//  4. Extracts and validates capability rules from Tailscale ACLs, dropping rules scoped to other
+//     callers, requiring a device posture the caller does not meet or whose CEL condition does not hold
+//  5. Stores username, capability and device details in Gin context for handlers
 type ginAuthMiddleware[capRule tshttp.TailscaleCapability] struct {
-	capName     tailcfg.PeerCapability
-	resolver    tshttp.WhoIsResolver
-	allowTagged bool
-	allowFunnel bool
+	capName       func() tailcfg.PeerCapability
+	resolver      tshttp.WhoIsResolver
+	allowTagged   bool
+	allowFunnel   bool
+	clusterLabels func() map[string]string
+	conditions    *conditionEvaluator
+	withoutRules  map[string]bool
 }
 
 // NewGinAuthMiddleware creates a new Tailscale authentication middleware for Gin.
@@ -45,10 +53,13 @@ type ginAuthMiddleware[capRule tshttp.TailscaleCapability] struct {
 // and rejects unauthorized users with appropriate HTTP status codes.
 func NewGinAuthMiddleware[capRule tshttp.TailscaleCapability](resolver tshttp.WhoIsResolver, capName tailcfg.PeerCapability, opts ...Option[capRule]) mw.Middleware {
 	mw := &ginAuthMiddleware[capRule]{
-		capName:     capName,
-		resolver:    resolver,
-		allowTagged: false,
-		allowFunnel: false,
+		capName:       func() tailcfg.PeerCapability { return capName },
+		resolver:      resolver,
+		allowTagged:   false,
+		allowFunnel:   false,
+		clusterLabels: func() map[string]string { return nil },
+		conditions:    newConditionEvaluator(),
+		withoutRules:  map[string]bool{},
 	}
 
 	for _, opt := range opts {
@@ -137,12 +148,17 @@ func (m *ginAuthMiddleware[capRule]) handler(tracer trace.Tracer) gin.HandlerFun
 
 		if who.IsTagged() && !m.allowTagged {
 			success, rejectReason, statusCode = false, "tagged_node_not_allowed", http.StatusBadRequest
-			ct.JSON(http.StatusBadRequest, models.NewErrorResponse("tagged nodes not (yet) supported"))
+			ct.JSON(http.StatusBadRequest, models.NewErrorResponse("tagged nodes not allowed"))
 			ct.Abort()
 			return
 		}
 
-		rules, err := tailcfg.UnmarshalCapJSON[capRule](who.CapMap, m.capName)
+		// Tagged devices share a login name, so they are told apart by node
+		if who.IsTagged() {
+			userName = TaggedUsername(who.Tags, who.NodeName)
+		}
+
+		rules, err := tailcfg.UnmarshalCapJSON[capRule](who.CapMap, m.capName())
 		if err != nil {
 			success, rejectReason, statusCode = false, "capability_unmarshal_failed", http.StatusBadRequest
 			ct.JSON(http.StatusBadRequest, models.FromHumaneError(humane.Wrap(err, "Error unmarshaling api capability map", "Check the syntax of your api ACL for user "+userName+".")))
@@ -150,6 +166,51 @@ func (m *ginAuthMiddleware[capRule]) handler(tracer trace.Tracer) gin.HandlerFun
 			return
 		}
 
+		now := time.Now()
+		rules, deviceErr := applicableRules(rules, who, now)
+		if len(rules) == 0 && deviceErr != nil {
+			success, rejectReason, statusCode = false, "device_requirements_not_met", http.StatusForbidden
+			ct.JSON(http.StatusForbidden, models.FromHumaneError(humane.Wrap(deviceErr, "Device does not meet the requirements of any capability rule",
+				"Update the device or its Tailscale client, or ask your administrator which device requirements apply.")))
+			ct.Abort()
+			return
+		}
+
+		rules, conditionErr, invalid := m.conditionalRules(rules, conditionVariables(userName, who, m.clusterLabels(), now))
+		if len(invalid) > 0 {
+			invalidErrs := make([]error, 0, len(invalid))
+			for _, herr := range invalid {
+				invalidErrs = append(invalidErrs, herr)
+			}
+			otelzap.L().WithError(errors.Join(invalidErrs...)).WarnContext(ctx, "Skipped capability rules whose condition cannot be evaluated",
+				zap.String("username", userName), zap.Int("rules", len(invalid)))
+		}
+
+		// Nothing the caller did can fix a broken ACL, so this is on the server side
+		if len(rules) == 0 && len(invalid) > 0 {
+			success, rejectReason, statusCode = false, "invalid_condition", http.StatusInternalServerError
+			ct.JSON(http.StatusInternalServerError, models.FromHumaneError(humane.Wrap(invalid[0], "The conditions of your capability rules cannot be evaluated",
+				"Ask your administrator to fix the conditions of the capability rules in the Tailscale ACL.")))
+			ct.Abort()
+			return
+		}
+
+		if len(rules) == 0 && conditionErr != nil {
+			success, rejectReason, statusCode = false, "condition_not_met", http.StatusForbidden
+			ct.JSON(http.StatusForbidden, models.FromHumaneError(humane.Wrap(conditionErr, "The conditions of your capability rules are not met",
+				"Ask your administrator when and from where the role may be used.")))
+			ct.Abort()
+			return
+		}
+
+		// Some routes grant access on other grounds, e.g. a scheduled shift, and check for themselves
+		if len(rules) == 0 && m.withoutRules[routeKey(req.Method, ct.FullPath())] {
+			SetUsername(ct, userName)
+			SetWhoIs(ct, who)
+			ct.Next()
+			return
+		}
+
 		if len(rules) == 0 {
 			success, rejectReason, statusCode = false, "no_capability_rules", http.StatusForbidden
 			ct.JSON(http.StatusForbidden, models.NewErrorResponse("User not authorized"))
@@ -182,7 +243,66 @@ func (m *ginAuthMiddleware[capRule]) handler(tracer trace.Tracer) gin.HandlerFun
 
 		SetUsername(ct, userName)
 		SetCapability(ct, rules[0])
+		SetWhoIs(ct, who)
 
 		ct.Next()
 	}
 }
+
+// applicableRules drops the rules that are scoped to other callers or whose device requirements
+// the caller does not meet. The returned error explains the device requirements that failed.
+func applicableRules[capRule tshttp.TailscaleCapability](rules []capRule, who *tshttp.WhoIsInfo, now time.Time) ([]capRule, error) {
+	var deviceErrs []error
+	rules = slices.DeleteFunc(rules, func(rule capRule) bool {
+		if scoped, ok := any(rule).(tshttp.ScopedCapability); ok && !scoped.AppliesTo(who.Tags) {
+			return true
+		}
+
+		if checked, ok := any(rule).(tshttp.DeviceCapability); ok {
+			if err := checked.CheckDevice(who, now); err != nil {
+				deviceErrs = append(deviceErrs, err)
+				return true
+			}
+		}
+
+		return false
+	})
+
+	return rules, errors.Join(deviceErrs...)
+}
+
+// conditionalRules drops the rules whose condition does not hold for the request. The returned error
+// lists the conditions that were not met. Rules whose condition cannot be evaluated are dropped as well,
+// and reported separately, so that a broken rule does not keep the others from applying.
+func (m *ginAuthMiddleware[capRule]) conditionalRules(rules []capRule, vars map[string]any) ([]capRule, error, []humane.Error) {
+	var unmet []error
+	var invalid []humane.Error
+	kept := make([]capRule, 0, len(rules))
+	for _, rule := range rules {
+		conditional, ok := any(rule).(tshttp.ConditionalCapability)
+		if !ok || conditional.ConditionExpression() == "" {
+			kept = append(kept, rule)
+			continue
+		}
+
+		vars["role"] = conditional.GrantedRole()
+		holds, herr := m.conditions.evaluate(conditional.ConditionExpression(), vars)
+		if herr != nil {
+			invalid = append(invalid, herr)
+			continue
+		}
+
+		if !holds {
+			unmet = append(unmet, fmt.Errorf("condition of the %q rule is not met: %s", conditional.GrantedRole(), conditional.ConditionExpression()))
+			continue
+		}
+		kept = append(kept, rule)
+	}
+
+	return kept, errors.Join(unmet...), invalid
+}
+
+// routeKey identifies a route by its method and path pattern.
+func routeKey(method, path string) string {
+	return method + " " + path
+}
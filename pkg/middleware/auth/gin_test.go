@@ -66,9 +66,28 @@ func TestGinAuthMiddleware(t *testing.T) {
 	admin := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200}
 	admin2 := capability.Rule{Role: "admin", Period: "10m", RulePriority: 100}
 
+	ciRule := capability.Rule{Role: "ci", Period: "10m", RulePriority: 100, Tags: []string{"tag:test"}}
+	prodRule := capability.Rule{Role: "prod", Period: "10m", RulePriority: 300, Tags: []string{"tag:prod"}}
+
+	macOnly := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Device: &capability.DeviceRequirements{OS: []string{"macOS"}}}
+	recentClient := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Device: &capability.DeviceRequirements{MinClientVersion: "1.80.0"}}
+
+	managedAlice := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Condition: `user == "alice" && "tka:managed" in device.attributes`}
+	devOnly := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Condition: `cluster.labels.env == "dev"`}
+	invalidCondition := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Condition: `role ==`}
+	nonBoolCondition := capability.Rule{Role: "admin", Period: "10m", RulePriority: 200, Condition: `role`}
+
 	viewerB, _ := json.Marshal(viewer)
 	adminB, _ := json.Marshal(admin)
 	admin2B, _ := json.Marshal(admin2)
+	ciB, _ := json.Marshal(ciRule)
+	prodB, _ := json.Marshal(prodRule)
+	macOnlyB, _ := json.Marshal(macOnly)
+	recentClientB, _ := json.Marshal(recentClient)
+	managedAliceB, _ := json.Marshal(managedAlice)
+	devOnlyB, _ := json.Marshal(devOnly)
+	invalidConditionB, _ := json.Marshal(invalidCondition)
+	nonBoolConditionB, _ := json.Marshal(nonBoolCondition)
 
 	cases := []struct {
 		name          string
@@ -76,6 +95,7 @@ func TestGinAuthMiddleware(t *testing.T) {
 		headers       map[string]string
 		allowTagged   bool
 		allowFunnel   bool
+		clusterLabels map[string]string
 		wantStatus    int
 		wantUser      string
 		wantRole      string
@@ -151,21 +171,197 @@ func TestGinAuthMiddleware(t *testing.T) {
 			allowFunnel: false,
 			allowTagged: false,
 			wantStatus:  http.StatusBadRequest,
-			wantError:   "tagged nodes not (yet) supported",
+			wantError:   "tagged nodes not allowed",
 		},
 		{
 			name: "tagged nodes are allowed, only if allowed",
 			whoisResponse: whoisResponse{
 				WhoIsInfo: ts.WhoIsInfo{
-					LoginName: "alice@example.com",
+					LoginName: "tagged-devices",
 					Tags:      []string{"tag:test"},
-					CapMap:    buildCap(t, capName, viewer),
+					NodeName:  "runner-1",
+					CapMap:    buildCap(t, capName, ciRule),
 				},
 			},
 			allowTagged: true,
 			allowFunnel: false,
 			wantStatus:  http.StatusOK,
-			wantRole:    "viewer",
+			wantUser:    "tag-test.runner-1",
+			wantRole:    "ci",
+		},
+		{
+			name: "rules without tags don't apply to tagged nodes",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "tagged-devices",
+					Tags:      []string{"tag:test"},
+					NodeName:  "runner-1",
+					CapMap:    buildCap(t, capName, viewer),
+				},
+			},
+			allowTagged: true,
+			wantStatus:  http.StatusForbidden,
+			wantError:   "User not authorized",
+		},
+		{
+			name: "rules scoped to tags don't apply to users",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					Tags:      []string{},
+					CapMap:    buildCap(t, capName, ciRule),
+				},
+			},
+			allowTagged: true,
+			wantStatus:  http.StatusForbidden,
+			wantError:   "User not authorized",
+		},
+		{
+			name: "tagged nodes only get rules for their tags",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "tagged-devices",
+					Tags:      []string{"tag:test"},
+					NodeName:  "runner-1",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(ciB), tailcfg.RawMessage(prodB)}},
+				},
+			},
+			allowTagged: true,
+			wantStatus:  http.StatusOK,
+			wantUser:    "tag-test.runner-1",
+			wantRole:    "ci",
+		},
+		{
+			name: "device requirements not met -> 403",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					OS:        "windows",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(macOnlyB)}},
+				},
+			},
+			wantStatus: http.StatusForbidden,
+			wantError:  "Device does not meet the requirements of any capability rule",
+		},
+		{
+			name: "device requirements not met -> fall back to lower priority rule",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName:     "alice@example.com",
+					ClientVersion: "1.76.6-t3b4a1c2d0-g1234567",
+					CapMap:        tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(recentClientB), tailcfg.RawMessage(viewerB)}},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+			wantRole:   "viewer",
+		},
+		{
+			name: "device requirements met -> 200",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName:     "alice@example.com",
+					ClientVersion: "1.82.0-t3b4a1c2d0-g1234567",
+					CapMap:        tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(recentClientB), tailcfg.RawMessage(viewerB)}},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+			wantRole:   "admin",
+		},
+		{
+			name: "condition holds -> 200",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName:      "alice@example.com",
+					NodeAttributes: []string{"tka:managed"},
+					CapMap:         tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(managedAliceB)}},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+			wantRole:   "admin",
+		},
+		{
+			name: "condition does not hold -> 403",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(managedAliceB)}},
+				},
+			},
+			wantStatus: http.StatusForbidden,
+			wantError:  "The conditions of your capability rules are not met",
+		},
+		{
+			name: "condition on cluster labels does not hold -> fall back to lower priority rule",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(devOnlyB), tailcfg.RawMessage(viewerB)}},
+				},
+			},
+			clusterLabels: map[string]string{"env": "prod"},
+			wantStatus:    http.StatusOK,
+			wantUser:      "alice",
+			wantRole:      "viewer",
+		},
+		{
+			name: "condition referencing a missing label -> 500",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(devOnlyB)}},
+				},
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "The conditions of your capability rules cannot be evaluated",
+		},
+		{
+			name: "condition that cannot be evaluated -> fall back to lower priority rule",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(devOnlyB), tailcfg.RawMessage(viewerB)}},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+			wantRole:   "viewer",
+		},
+		{
+			name: "invalid condition -> fall back to lower priority rule",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(invalidConditionB), tailcfg.RawMessage(viewerB)}},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+			wantRole:   "viewer",
+		},
+		{
+			name: "invalid condition -> 500",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(invalidConditionB)}},
+				},
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "The conditions of your capability rules cannot be evaluated",
+		},
+		{
+			name: "non-boolean condition -> 500",
+			whoisResponse: whoisResponse{
+				WhoIsInfo: ts.WhoIsInfo{
+					LoginName: "alice@example.com",
+					CapMap:    tailcfg.PeerCapMap{capName: []tailcfg.RawMessage{tailcfg.RawMessage(nonBoolConditionB)}},
+				},
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "The conditions of your capability rules cannot be evaluated",
 		},
 		{
 			name: "no rule found -> 403",
@@ -244,6 +440,7 @@ func TestGinAuthMiddleware(t *testing.T) {
 			authMiddleware := mwauth.NewGinAuthMiddleware(whoIsResolver, capName,
 				mwauth.AllowFunnelRequest[capability.Rule](tc.allowFunnel),
 				mwauth.AllowTaggedNodes[capability.Rule](tc.allowTagged),
+				mwauth.WithClusterLabels[capability.Rule](tc.clusterLabels),
 			)
 
 			// 4. Setup router using our auth middleware
@@ -276,3 +473,102 @@ func TestGinAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestTaggedUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		nodeName string
+		want     string
+	}{
+		{name: "single tag", tags: []string{"tag:ci"}, nodeName: "runner-1", want: "tag-ci.runner-1"},
+		{name: "tags are sorted", tags: []string{"tag:deploy", "tag:ci"}, nodeName: "runner-1", want: "tag-ci-deploy.runner-1"},
+		{name: "names are sanitized", tags: []string{"tag:CI_Runner"}, nodeName: "GitHub Runner", want: "tag-ci-runner--39882f43.github-runner"},
+		{name: "dashed tags are hashed", tags: []string{"tag:ci-deploy"}, nodeName: "runner-1", want: "tag-ci-deploy--3df6b6ad.runner-1"},
+		{name: "missing node name", tags: []string{"tag:ci"}, want: "tag-ci"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, mwauth.TaggedUsername(tc.tags, tc.nodeName))
+		})
+	}
+}
+
+func TestServiceIdentityName_NoCollisions(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+	}{
+		{name: "dash inside a tag", a: []string{"tag:a-b"}, b: []string{"tag:a", "tag:b"}},
+		{name: "dash split differently", a: []string{"tag:a-b", "tag:c"}, b: []string{"tag:a", "tag:b-c"}},
+		{name: "sanitized characters", a: []string{"tag:a.b"}, b: []string{"tag:a-b"}},
+		{name: "case", a: []string{"tag:CI"}, b: []string{"tag:ci"}},
+		{name: "hash-like tag", a: []string{"tag:a-b"}, b: []string{"tag:a", "tag:b", "tag:d44362d6"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NotEqual(t, mwauth.ServiceIdentityName(tc.a), mwauth.ServiceIdentityName(tc.b))
+		})
+	}
+}
+
+func TestServiceIdentityName_OrderIndependent(t *testing.T) {
+	require.Equal(t,
+		mwauth.ServiceIdentityName([]string{"tag:ci-deploy", "tag:Prod"}),
+		mwauth.ServiceIdentityName([]string{"tag:Prod", "tag:ci-deploy"}),
+	)
+}
+
+func TestGinAuthMiddleware_AllowWithoutRules(t *testing.T) {
+	capName := tailcfg.PeerCapability("specht-labs.de/cap/tka")
+	macOnly := capability.Rule{Role: "admin", Period: "10m", Device: &capability.DeviceRequirements{OS: []string{"macOS"}}}
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		capMap     tailcfg.PeerCapMap
+		wantStatus int
+	}{
+		{name: "allowed route without rules", method: http.MethodPost, path: "/login", wantStatus: http.StatusOK},
+		{name: "other route without rules", method: http.MethodGet, path: "/test", wantStatus: http.StatusForbidden},
+		{name: "other method without rules", method: http.MethodGet, path: "/login", wantStatus: http.StatusForbidden},
+		{name: "device requirements not met", method: http.MethodPost, path: "/login", capMap: buildCap(t, capName, macOnly), wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			whoIsResolver := mock.NewMockWhoIsResolver(
+				mock.WithWhoIsResponse(req.RemoteAddr, &ts.WhoIsInfo{LoginName: "alice@example.com", OS: "linux", CapMap: tc.capMap}),
+			)
+
+			authMiddleware := mwauth.NewGinAuthMiddleware(whoIsResolver, capName,
+				mwauth.AllowWithoutRules[capability.Rule](http.MethodPost, "/login"),
+			)
+			r, w := setupRouter(t, authMiddleware)
+
+			handler := func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{
+					"user":       mwauth.GetUsername(c),
+					"capability": mwauth.GetCapability[capability.Rule](c) != nil,
+				})
+			}
+			r.POST("/login", handler)
+			r.GET("/login", handler)
+
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+
+			if tc.wantStatus == http.StatusOK {
+				var resp map[string]any
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				require.Equal(t, "alice", resp["user"])
+				require.Equal(t, false, resp["capability"])
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// ServiceIdentityName maps the tags of a Tailscale device to a stable identity name,
+// e.g. ["tag:ci", "tag:deploy"] becomes "tag-ci-deploy". The order of the tags does not matter.
+//
+// Tags that do not survive sanitizing unchanged, such as "tag:CI" or "tag:ci-deploy", would make
+// the joined name ambiguous, so the name then ends in "--" and a short hash of the raw tags, e.g.
+// ["tag:ci-deploy"] becomes "tag-ci-deploy--<hash>". Plain names never contain "--".
+func ServiceIdentityName(tags []string) string {
+	raw := make([]string, 0, len(tags))
+	names := make([]string, 0, len(tags))
+	ambiguous := false
+	for _, tag := range tags {
+		name := strings.TrimPrefix(tag, "tag:")
+		sanitized := sanitizeName(name)
+		if sanitized != name || strings.Contains(name, "-") {
+			ambiguous = true
+		}
+
+		raw = append(raw, name)
+		names = append(names, sanitized)
+	}
+	slices.Sort(names)
+
+	identity := "tag-" + strings.Join(names, "-")
+	if !ambiguous {
+		return identity
+	}
+
+	slices.Sort(raw)
+	sum := sha256.Sum256([]byte(strings.Join(raw, "\x00")))
+	return identity + "--" + hex.EncodeToString(sum[:4])
+}
+
+// TaggedUsername is the username a tagged device signs in as: its service identity qualified by
+// the node name, so every device gets its own sign-in, e.g. "tag-ci.runner-1".
+func TaggedUsername(tags []string, nodeName string) string {
+	identity := ServiceIdentityName(tags)
+	if node := sanitizeName(nodeName); node != "" {
+		return identity + "." + node
+	}
+	return identity
+}
+
+// sanitizeName lowercases name and replaces everything but letters, digits and dashes so
+// the result can be used in Kubernetes object names.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, name)
+
+	return strings.Trim(name, "-")
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "github.com/spechtlabs/tka/pkg/tshttp"
+import (
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"tailscale.com/tailcfg"
+)
 
 // Option is a functional option for configuring the Gin authentication middleware.
 type Option[capRule tshttp.TailscaleCapability] func(*ginAuthMiddleware[capRule])
@@ -20,3 +23,41 @@ func AllowTaggedNodes[capRule tshttp.TailscaleCapability](allowed bool) Option[c
 		m.allowTagged = allowed
 	}
 }
+
+// WithClusterLabels returns an Option that makes the labels of the cluster available
+// to capability rule conditions as cluster.labels.
+func WithClusterLabels[capRule tshttp.TailscaleCapability](labels map[string]string) Option[capRule] {
+	return func(m *ginAuthMiddleware[capRule]) {
+		m.clusterLabels = func() map[string]string { return labels }
+	}
+}
+
+// WithClusterLabelsFunc is like WithClusterLabels, but asks labels for every request,
+// so the labels can change while the server runs.
+func WithClusterLabelsFunc[capRule tshttp.TailscaleCapability](labels func() map[string]string) Option[capRule] {
+	return func(m *ginAuthMiddleware[capRule]) {
+		if labels != nil {
+			m.clusterLabels = labels
+		}
+	}
+}
+
+// WithCapNameFunc asks capName for the name of the capability to read from the ACL on every request,
+// instead of using the name the middleware was created with, so it can change while the server runs.
+func WithCapNameFunc[capRule tshttp.TailscaleCapability](capName func() tailcfg.PeerCapability) Option[capRule] {
+	return func(m *ginAuthMiddleware[capRule]) {
+		if capName != nil {
+			m.capName = capName
+		}
+	}
+}
+
+// AllowWithoutRules returns an Option that lets callers without any capability rule through to the route with
+// the given method and full path, e.g. a login route that also grants access on a scheduled shift.
+// Handlers of the route find no capability in the context and must authorize the caller themselves.
+// Callers whose rules are all dropped for their device or conditions are still rejected.
+func AllowWithoutRules[capRule tshttp.TailscaleCapability](method, path string) Option[capRule] {
+	return func(m *ginAuthMiddleware[capRule]) {
+		m.withoutRules[routeKey(method, path)] = true
+	}
+}
@@ -0,0 +1,29 @@
+package operator
+
+import (
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewAPIClient creates the TkaClient for an API server that runs without the operator. It talks to the API
+// server directly instead of through the manager's cache, so it needs neither a controller nor leader election
+// and any number of API replicas can use it. The operator provisions the sign-ins it creates.
+func NewAPIClient(clusterInfo *models.ClusterInfoStore, clientOpts k8s.ClientOptions) (k8s.TkaClient, humane.Error) {
+	if err := addToScheme(); err != nil {
+		return nil, err
+	}
+
+	config := getConfigOrDie()
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, humane.Wrap(err, "failed to create Kubernetes client", "check cluster connectivity and authentication")
+	}
+
+	if err := completeClientOptions(config, c, &clientOpts); err != nil {
+		return nil, err
+	}
+
+	return k8s.NewTkaClient(c, clusterInfo, clientOpts), nil
+}
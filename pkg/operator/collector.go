@@ -0,0 +1,102 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// collectTimeout bounds how long a scrape waits for the sign-ins to be listed.
+const collectTimeout = 5 * time.Second
+
+var (
+	activeSessionsDesc = prometheus.NewDesc(
+		"tka_active_user_sessions",
+		"Current number of active user sessions by cluster role",
+		[]string{"cluster_role"}, nil,
+	)
+	expiringSessionsDesc = prometheus.NewDesc(
+		"tka_user_sessions_expiring_soon",
+		"Number of active user sessions by cluster role that end within the expiring soon window",
+		[]string{"cluster_role"}, nil,
+	)
+	pendingSessionsDesc = prometheus.NewDesc(
+		"tka_pending_user_sessions",
+		"Number of sign-ins by cluster role whose credentials are not provisioned yet",
+		[]string{"cluster_role"}, nil,
+	)
+)
+
+// sessionCollector reports the sessions in the cluster. It counts the TkaSignins on every scrape instead of
+// keeping counters in memory, so its values survive restarts and are the same on every replica.
+type sessionCollector struct {
+	reader       client.Reader
+	namespace    string
+	expiringSoon time.Duration
+}
+
+// NewSessionCollector creates a collector that counts the TkaSignins in namespace. reader is typically the
+// manager's cache, so scrapes don't reach the API server. Sessions ending within expiringSoon are also
+// reported as expiring soon.
+func NewSessionCollector(reader client.Reader, namespace string, expiringSoon time.Duration) prometheus.Collector {
+	return &sessionCollector{reader: reader, namespace: namespace, expiringSoon: expiringSoon}
+}
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSessionsDesc
+	ch <- expiringSessionsDesc
+	ch <- pendingSessionsDesc
+}
+
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	var signIns v1alpha1.TkaSigninList
+	if err := c.reader.List(ctx, &signIns, client.InNamespace(c.namespace)); err != nil {
+		ch <- prometheus.NewInvalidMetric(activeSessionsDesc, err)
+		return
+	}
+
+	active := map[string]int{}
+	expiring := map[string]int{}
+	pending := map[string]int{}
+
+	now := time.Now()
+	for i := range signIns.Items {
+		signIn := &signIns.Items[i]
+		role := signIn.Spec.Role
+
+		// Signed out sessions wait for the reconciler to deprovision them
+		if !signIn.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if !signIn.Status.Provisioned {
+			pending[role]++
+			continue
+		}
+
+		// Expired sessions wait for the reconciler to revoke them
+		validUntil, err := time.Parse(time.RFC3339, signIn.Status.ValidUntil)
+		if err != nil || !now.Before(validUntil) {
+			continue
+		}
+
+		active[role]++
+		if validUntil.Sub(now) <= c.expiringSoon {
+			expiring[role]++
+		}
+	}
+
+	for role, n := range active {
+		ch <- prometheus.MustNewConstMetric(activeSessionsDesc, prometheus.GaugeValue, float64(n), role)
+		ch <- prometheus.MustNewConstMetric(expiringSessionsDesc, prometheus.GaugeValue, float64(expiring[role]), role)
+	}
+	for role, n := range pending {
+		ch <- prometheus.MustNewConstMetric(pendingSessionsDesc, prometheus.GaugeValue, float64(n), role)
+	}
+}
@@ -0,0 +1,70 @@
+package operator_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/operator"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newCollectorTestSignin(userName, role string, provisioned bool, validFor time.Duration) client.Object {
+	signIn := k8s.NewSignin(userName, role, time.Hour, "tka-dev")
+	signIn.Status.Provisioned = provisioned
+	if provisioned {
+		signIn.Status.ValidUntil = time.Now().Add(validFor).Format(time.RFC3339)
+	}
+	return signIn
+}
+
+func TestSessionCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	other := k8s.NewSignin("mallory", "view", time.Hour, "default")
+	other.Status.Provisioned = true
+	other.Status.ValidUntil = time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newCollectorTestSignin("alice", "view", true, time.Hour),
+		newCollectorTestSignin("bob", "view", true, 5*time.Minute),
+		newCollectorTestSignin("carol", "edit", true, 10*time.Minute),
+		newCollectorTestSignin("dave", "edit", false, 0),
+		newCollectorTestSignin("erin", "edit", true, -time.Minute), // expired, not yet revoked
+		other,
+	).Build()
+
+	collector := operator.NewSessionCollector(c, "tka-dev", 15*time.Minute)
+
+	expected := `
+# HELP tka_active_user_sessions Current number of active user sessions by cluster role
+# TYPE tka_active_user_sessions gauge
+tka_active_user_sessions{cluster_role="edit"} 1
+tka_active_user_sessions{cluster_role="view"} 2
+# HELP tka_pending_user_sessions Number of sign-ins by cluster role whose credentials are not provisioned yet
+# TYPE tka_pending_user_sessions gauge
+tka_pending_user_sessions{cluster_role="edit"} 1
+# HELP tka_user_sessions_expiring_soon Number of active user sessions by cluster role that end within the expiring soon window
+# TYPE tka_user_sessions_expiring_soon gauge
+tka_user_sessions_expiring_soon{cluster_role="edit"} 1
+tka_user_sessions_expiring_soon{cluster_role="view"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+
+	// The values are recomputed from the cluster on every scrape
+	require.NoError(t, c.Delete(t.Context(), newCollectorTestSignin("alice", "view", true, time.Hour)))
+	expected = `
+# HELP tka_active_user_sessions Current number of active user sessions by cluster role
+# TYPE tka_active_user_sessions gauge
+tka_active_user_sessions{cluster_role="edit"} 1
+tka_active_user_sessions{cluster_role="view"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "tka_active_user_sessions"))
+}
@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;delete
+
+// createOrUpdateBinding grants the role of the sign-in, either cluster-wide or in the namespace it is restricted to.
+func (t *KubeOperator) createOrUpdateBinding(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	if signIn.Spec.Namespace == "" {
+		return t.createOrUpdateClusterRoleBinding(ctx, signIn)
+	}
+
+	return t.createRoleBinding(ctx, signIn)
+}
+
+// deleteBinding removes the binding created by createOrUpdateBinding. It does not rely on the sign-in's
+// spec, which is empty when the sign-in is already gone, and treats bindings that do not exist as removed.
+func (t *KubeOperator) deleteBinding(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	if err := t.deleteClusterRoleBinding(ctx, signIn); err != nil {
+		return err
+	}
+
+	return t.deleteRoleBindings(ctx, signIn)
+}
+
+// createRoleBinding binds the role of a sign-in restricted to a namespace. The RoleBinding lives in
+// that namespace and thus cannot be owned by the sign-in; it is deleted when the user signs out.
+func (t *KubeOperator) createRoleBinding(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	roleBinding := k8s.NewRoleBinding(signIn)
+
+	// The role of a sign-in never changes, so an existing binding is already up to date
+	if err := t.mgr.GetClient().Create(ctx, roleBinding); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return humane.Wrap(err, fmt.Sprintf("Failed to create role binding for user %s", signIn.Spec.Username), "check Kubernetes RBAC permissions for creating role bindings in namespace "+signIn.Spec.Namespace)
+	}
+
+	return nil
+}
+
+// deleteRoleBindings removes the RoleBindings labelled with the sign-in's name, in whichever namespace they are.
+func (t *KubeOperator) deleteRoleBindings(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	c := t.mgr.GetClient()
+
+	var roleBindings rbacv1.RoleBindingList
+	if err := c.List(ctx, &roleBindings, client.MatchingLabels{k8s.SignInLabel: signIn.Name}); err != nil {
+		return humane.Wrap(err, "Failed to list role bindings of sign-in "+signIn.Name, "check Kubernetes connectivity and RBAC list permissions for role bindings")
+	}
+
+	// Bindings created before they were labelled are only known by the sign-in's spec
+	if signIn.Spec.Namespace != "" {
+		roleBindings.Items = append(roleBindings.Items, *k8s.NewRoleBinding(signIn))
+	}
+
+	for i := range roleBindings.Items {
+		if err := c.Delete(ctx, &roleBindings.Items[i]); client.IgnoreNotFound(err) != nil {
+			return humane.Wrap(err, "Failed to remove role binding", "check Kubernetes permissions for deleting role bindings")
+		}
+	}
+
+	return nil
+}
+
+// revokeDelegations signs out the credentials delegated from the sign-in so they do not outlive it.
+func (t *KubeOperator) revokeDelegations(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	delegations, err := k8s.ListDelegations(ctx, t.mgr.GetClient(), signIn)
+	if err != nil {
+		return err
+	}
+
+	for i := range delegations {
+		if err := t.signOutUser(ctx, &delegations[i]); err != nil {
+			return humane.Wrap(err, "failed to revoke delegated sign-in "+delegations[i].Name, "the delegation is retried with the next reconciliation of "+signIn.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package operator
+
+import (
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"go.opentelemetry.io/otel"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// testManager hands out the client and scheme of a test. Calling any other Manager method panics.
+type testManager struct {
+	manager.Manager
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func (m *testManager) GetClient() client.Client   { return m.client }
+func (m *testManager) GetScheme() *runtime.Scheme { return m.scheme }
+
+// NewTestOperator returns a KubeOperator that reconciles with c instead of a manager's client.
+func NewTestOperator(c client.Client, scheme *runtime.Scheme) *KubeOperator {
+	clusterInfo := models.NewClusterInfoStore(&models.TkaClusterInfo{ServerURL: "https://api.example.com:6443"})
+	opts := k8s.DefaultClientOptions()
+	opts.Namespace = "tka-dev"
+
+	return &KubeOperator{
+		mgr:         &testManager{client: c, scheme: scheme},
+		tracer:      otel.Tracer("tka_controller"),
+		client:      k8s.NewTkaClient(c, clusterInfo, opts),
+		clusterName: "tka-cluster",
+		clusterInfo: clusterInfo,
+	}
+}
@@ -0,0 +1,85 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get
+
+// healthCheckTimeout bounds how long a single health check may take.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck probes one dependency. It returns nil if the dependency is healthy.
+type HealthCheck func(ctx context.Context) error
+
+// ReadinessChecks returns the named checks that must pass before the operator can provision sign-ins.
+// Combine them with KubernetesAPICheck, which the API needs as well.
+func (t *KubeOperator) ReadinessChecks() map[string]HealthCheck {
+	return map[string]HealthCheck{
+		"informer-sync":   t.checkCacheSync,
+		"leader-election": t.checkLeaderElection,
+	}
+}
+
+// KubernetesAPICheck asks the API server whether it is ready to serve requests.
+func KubernetesAPICheck(clientset kubernetes.Interface) HealthCheck {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+
+		if err := clientset.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error(); err != nil {
+			return fmt.Errorf("kubernetes API server is not reachable: %w", err)
+		}
+		return nil
+	}
+}
+
+// checkCacheSync reports whether the informer caches the reconciler reads from are populated.
+func (t *KubeOperator) checkCacheSync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if !t.mgr.GetCache().WaitForCacheSync(ctx) {
+		return errors.New("informer caches have not synced yet")
+	}
+	return nil
+}
+
+// checkLeaderElection reports whether some replica leads the operator. Standby replicas are ready as long
+// as the leader keeps renewing its lease, since they still serve the API.
+func (t *KubeOperator) checkLeaderElection(ctx context.Context) error {
+	if !t.election.enabled {
+		return nil
+	}
+
+	select {
+	case <-t.mgr.Elected():
+		return nil
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	lease, err := t.clientset.CoordinationV1().Leases(t.election.namespace).Get(ctx, leaderElectionID, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read the leader lease: %w", err)
+	}
+
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return errors.New("no replica holds the leader lease")
+	}
+
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	if time.Now().After(expiry) {
+		return fmt.Errorf("leader %s stopped renewing its lease at %s", *spec.HolderIdentity, spec.RenewTime.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package operator
+
+import (
+	"strings"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// idleTimeout returns how long sessions of the role may go unused. Zero never revokes them for being idle.
+func (t *KubeOperator) idleTimeout(role string) time.Duration {
+	if timeout, ok := t.idleTimeouts[strings.ToLower(role)]; ok {
+		return timeout
+	}
+	return t.idleTimeouts[IdleTimeoutAllRoles]
+}
+
+// idleAction deprovisions a sign-in whose credentials went unused for longer than timeout. Otherwise it returns
+// when the sign-in runs idle, so the reconciler checks it again in time. Suspended sign-ins can't be used and
+// are left alone.
+func idleAction(signIn *v1alpha1.TkaSignin, timeout time.Duration, span trace.Span) (SignInOperation, time.Duration) {
+	if timeout <= 0 || signIn.Spec.Suspended {
+		return SignInOperationNOP, time.Duration(0)
+	}
+
+	deadline, ok := k8s.IdleDeadline(signIn, timeout)
+	if !ok {
+		return SignInOperationNOP, time.Duration(0)
+	}
+
+	if time.Now().After(deadline) {
+		span.AddEvent("signin_idle")
+		return SignInOperationDeprovision, time.Duration(0)
+	}
+
+	return SignInOperationNOP, time.Until(deadline)
+}
@@ -1,7 +1,11 @@
 package operator
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -25,15 +29,39 @@ var userSignInsTotal = prometheus.NewCounterVec(
 	},
 	[]string{
 		"cluster_role",
-		"username",
+		"username", // empty unless username metrics are enabled, see WithUsernameMetrics
+	},
+)
+
+// provisionDuration tracks how long it takes from a sign-in request until its credentials are provisioned
+var provisionDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "tka_signin_provision_duration_seconds",
+		Help:    "Time from a sign-in request until its credentials are provisioned by cluster role",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12), // 50ms to ~100s
+	},
+	[]string{
+		"cluster_role",
 	},
 )
 
-// activeUserSessions tracks the current number of active user sessions per cluster role
-var activeUserSessions = prometheus.NewGaugeVec(
-	prometheus.GaugeOpts{
-		Name: "tka_active_user_sessions",
-		Help: "Current number of active user sessions by cluster role",
+// sessionDuration tracks how long sessions lasted from sign-in until they were revoked
+var sessionDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name: "tka_session_duration_seconds",
+		Help: "How long sessions lasted from sign-in until they were revoked by cluster role",
+		Buckets: []float64{
+			(time.Minute).Seconds(),
+			(5 * time.Minute).Seconds(),
+			(15 * time.Minute).Seconds(),
+			(30 * time.Minute).Seconds(),
+			(time.Hour).Seconds(),
+			(2 * time.Hour).Seconds(),
+			(4 * time.Hour).Seconds(),
+			(8 * time.Hour).Seconds(),
+			(12 * time.Hour).Seconds(),
+			(24 * time.Hour).Seconds(),
+		},
 	},
 	[]string{
 		"cluster_role",
@@ -43,5 +71,41 @@ var activeUserSessions = prometheus.NewGaugeVec(
 func init() {
 	metrics.Registry.MustRegister(reconcilerDuration)
 	metrics.Registry.MustRegister(userSignInsTotal)
-	metrics.Registry.MustRegister(activeUserSessions)
+	metrics.Registry.MustRegister(provisionDuration)
+	metrics.Registry.MustRegister(sessionDuration)
+}
+
+// metricsUsername returns the username label value for userName, see WithUsernameMetrics.
+func (t *KubeOperator) metricsUsername(userName string) string {
+	if t.metrics.hideUsernames {
+		return ""
+	}
+	return userName
+}
+
+// observeProvisionDuration records how long the sign-in waited for its credentials. Sign-ins for a scheduled
+// shift are counted from the start of the shift, not from when they were requested.
+func observeProvisionDuration(signIn *v1alpha1.TkaSignin, now time.Time) {
+	requested := signIn.CreationTimestamp.Time
+	if attempted, err := time.Parse(time.RFC3339, signIn.Annotations[k8s.LastAttemptedSignIn]); err == nil {
+		requested = attempted
+	}
+	if notBefore, err := time.Parse(time.RFC3339, signIn.Spec.NotBefore); err == nil && notBefore.After(requested) {
+		requested = notBefore
+	}
+
+	if requested.IsZero() || now.Before(requested) {
+		return
+	}
+	provisionDuration.WithLabelValues(signIn.Spec.Role).Observe(now.Sub(requested).Seconds())
+}
+
+// observeSessionDuration records how long a revoked sign-in was active. Sign-ins that were never
+// provisioned, or are already gone, have no session to record.
+func observeSessionDuration(signIn *v1alpha1.TkaSignin, now time.Time) {
+	signedIn, err := time.Parse(time.RFC3339, signIn.Status.SignedInAt)
+	if !signIn.Status.Provisioned || err != nil || now.Before(signedIn) {
+		return
+	}
+	sessionDuration.WithLabelValues(signIn.Spec.Role).Observe(now.Sub(signedIn).Seconds())
 }
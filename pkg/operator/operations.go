@@ -14,18 +14,24 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 func (t *KubeOperator) signInUser(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	// Extensions provision the sign-in again, they are no new sign-ins
+	firstProvision := !signIn.Status.Provisioned
+
 	// 1. Create Service Account
 	_, err := t.createOrUpdateServiceAccount(ctx, signIn)
 	if err != nil {
 		return err
 	}
 
-	// 2. Create ClusterRoleBinding
-	if err := t.createOrUpdateClusterRoleBinding(ctx, signIn); err != nil {
-		return err
+	// 2. Create the (Cluster)RoleBinding, unless the session is suspended (see syncSuspension)
+	if !signIn.Spec.Suspended {
+		if err := t.createOrUpdateBinding(ctx, signIn); err != nil {
+			return err
+		}
 	}
 
 	c := t.mgr.GetClient()
@@ -55,40 +61,144 @@ func (t *KubeOperator) signInUser(ctx context.Context, signIn *v1alpha1.TkaSigni
 		return humane.Wrap(e, "Failed to parse validityPeriod", "use a valid duration format like '1h', '30m', or '24h'")
 	}
 
-	validUntil := signedIn.Add(duration)
+	_, validUntil := k8s.AccessWindow(signedIn, duration, signIn.Spec.NotBefore, signIn.Spec.NotAfter)
 	signIn.Status.ValidUntil = validUntil.Format(time.RFC3339)
 
+	// 3. Create the resources of the role's templates
+	resources, err := t.applyRoleTemplates(ctx, signIn)
+	if err != nil {
+		return err
+	}
+	signIn.Status.Resources = resources
+
 	signIn.Status.Provisioned = true
 	if err := c.Status().Update(ctx, signIn); err != nil {
 		return humane.Wrap(err, "Error updating signin status", "check Kubernetes API connectivity and RBAC permissions")
 	}
 
-	// Update Prometheus metrics for user sign-in
-	userSignInsTotal.WithLabelValues(signIn.Spec.Role, signIn.Spec.Username).Inc()
-	activeUserSessions.WithLabelValues(signIn.Spec.Role).Inc()
+	if firstProvision {
+		userSignInsTotal.WithLabelValues(signIn.Spec.Role, t.metricsUsername(signIn.Spec.Username)).Inc()
+		observeProvisionDuration(signIn, time.Now())
+	}
 
 	return nil
 }
 
+// signOutUser removes everything the sign-in grants and then the sign-in itself.
 func (t *KubeOperator) signOutUser(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
-	if err := t.deleteClusterRoleBinding(ctx, signIn); err != nil {
-		return humane.Wrap(err, "failed to delete cluster role binding", "check Kubernetes RBAC permissions and cluster connectivity")
+	if err := t.deprovision(ctx, signIn); err != nil {
+		return err
+	}
+
+	if err := t.deleteSignIn(ctx, signIn); err != nil {
+		return humane.Wrap(err, "failed to delete user", "verify the user exists and the operator has delete permissions")
+	}
+
+	observeSessionDuration(signIn, time.Now())
+
+	return nil
+}
+
+// finalizeSignIn deprovisions a sign-in that is being deleted, e.g. because the user signed out, and then
+// releases it by removing SigninFinalizer.
+func (t *KubeOperator) finalizeSignIn(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	if !controllerutil.ContainsFinalizer(signIn, k8s.SigninFinalizer) {
+		return nil
+	}
+
+	if err := t.deprovision(ctx, signIn); err != nil {
+		return err
+	}
+
+	if err := t.removeFinalizer(ctx, signIn); err != nil {
+		return err
+	}
+
+	observeSessionDuration(signIn, time.Now())
+
+	return nil
+}
+
+// deprovision removes everything created for the sign-in. Objects that are already gone count as removed,
+// so it can run again after a partial failure.
+func (t *KubeOperator) deprovision(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	// Credentials delegated from the session go first, so they never outlive it
+	if err := t.revokeDelegations(ctx, signIn); err != nil {
+		return err
+	}
+
+	if err := t.deleteTemplateResources(ctx, signIn); err != nil {
+		return err
+	}
+
+	if err := t.deleteBinding(ctx, signIn); err != nil {
+		return humane.Wrap(err, "failed to delete role binding", "check Kubernetes RBAC permissions and cluster connectivity")
+	}
+
+	if t.tokenIssuer != nil {
+		if err := t.tokenIssuer.RevokeToken(ctx, signIn); err != nil {
+			return humane.Wrap(err, "failed to revoke service account token", "check Kubernetes permissions for deleting secrets")
+		}
 	}
 
 	if err := t.deleteServiceAccount(ctx, signIn); err != nil {
-		return humane.Wrap(err, "failed to delete service account", "check Kubernetes permissions and that the service account exists")
+		return humane.Wrap(err, "failed to delete service account", "check Kubernetes permissions for deleting service accounts")
 	}
 
-	if err := t.client.DeleteSignIn(ctx, signIn.Spec.Username); err != nil {
-		return humane.Wrap(err, "failed to delete user", "verify the user exists and the operator has delete permissions")
+	return nil
+}
+
+// ensureFinalizer adds SigninFinalizer to sign-ins created before the TKA server set it.
+func (t *KubeOperator) ensureFinalizer(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	if !controllerutil.AddFinalizer(signIn, k8s.SigninFinalizer) {
+		return nil
 	}
 
-	// Update Prometheus metrics for user sign-out
-	activeUserSessions.WithLabelValues(signIn.Spec.Role).Dec()
+	if err := t.mgr.GetClient().Update(ctx, signIn); err != nil {
+		return humane.Wrap(err, "Failed to add finalizer to sign-in "+signIn.Name, "check that the operator's ServiceAccount is allowed by operator.webhook.allowedUsers")
+	}
 
 	return nil
 }
 
+// removeFinalizer releases a deprovisioned sign-in, so Kubernetes can delete it.
+func (t *KubeOperator) removeFinalizer(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	if !controllerutil.RemoveFinalizer(signIn, k8s.SigninFinalizer) {
+		return nil
+	}
+
+	if err := t.mgr.GetClient().Update(ctx, signIn); client.IgnoreNotFound(err) != nil {
+		return humane.Wrap(err, "Failed to remove finalizer from sign-in "+signIn.Name, "check Kubernetes permissions for updating TkaSignin resources")
+	}
+
+	return nil
+}
+
+// deleteSignIn removes the sign-in itself once it is deprovisioned. Unlike TkaClient.DeleteSignIn, which users
+// sign out with, it also removes suspended sign-ins, e.g. once they expire. As everything is removed already,
+// it releases the sign-in right away instead of waiting for the finalizer to run.
+func (t *KubeOperator) deleteSignIn(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	c := t.mgr.GetClient()
+
+	if err := c.Delete(ctx, signIn); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return humane.Wrap(err, "Failed to remove sign-in request", "check Kubernetes permissions for deleting TkaSignin resources")
+	}
+
+	// Only the finalizer may change once the sign-in is being deleted, so this passes the admission webhook
+	deleting := &v1alpha1.TkaSignin{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(signIn), deleting); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return humane.Wrap(err, "Failed to load deleted sign-in request", "check Kubernetes connectivity and permissions")
+	}
+
+	return t.removeFinalizer(ctx, deleting)
+}
+
 // createOrUpdateServiceAccount creates a new service account or updates an existing one with the given parameters
 func (t *KubeOperator) createOrUpdateServiceAccount(ctx context.Context, signIn *v1alpha1.TkaSignin) (*corev1.ServiceAccount, humane.Error) {
 	c := t.mgr.GetClient()
@@ -112,6 +222,7 @@ func (t *KubeOperator) createOrUpdateServiceAccount(ctx context.Context, signIn
 		if err := c.Get(ctx, saName, serviceAccount); err != nil {
 			return nil, humane.Wrap(err, fmt.Sprintf("Failed to get existing service account for user %s", signIn.Spec.Username), "verify the service account exists and you have read permissions")
 		}
+		k8s.SetSessionAnnotations(&serviceAccount.ObjectMeta, signIn)
 
 		if err := c.Update(ctx, serviceAccount); err != nil {
 			return nil, humane.Wrap(err, fmt.Sprintf("Failed to update service account for user %s", signIn.Spec.Username), "check Kubernetes permissions for updating service accounts")
@@ -156,40 +267,22 @@ func (t *KubeOperator) createOrUpdateClusterRoleBinding(ctx context.Context, sig
 	return nil
 }
 
+// deleteClusterRoleBinding removes the sign-in's ClusterRoleBinding, if there is one.
 func (t *KubeOperator) deleteClusterRoleBinding(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
-	c := t.mgr.GetClient()
-
-	var crb rbacv1.ClusterRoleBinding
-
-	crbName := types.NamespacedName{Name: k8s.GetClusterRoleBindingName(signIn), Namespace: signIn.Namespace} //nolint:golint-sl // used in Get call
-	if err := c.Get(ctx, crbName, &crb); err != nil {
-		if k8serrors.IsNotFound(err) {
-			return humane.New("Cluster role binding not found", "the cluster role binding may have been already deleted")
-		}
-		return humane.Wrap(err, "Failed to load cluster role binding", "check Kubernetes connectivity and RBAC read permissions")
-	}
-
-	if err := c.Delete(ctx, &crb); err != nil {
+	if err := t.mgr.GetClient().Delete(ctx, k8s.NewClusterRoleBinding(signIn)); client.IgnoreNotFound(err) != nil {
 		return humane.Wrap(err, "Failed to remove cluster role binding", "check Kubernetes permissions for deleting cluster role bindings")
 	}
 
 	return nil
 }
 
+// deleteServiceAccount removes the sign-in's ServiceAccount, if there is one.
 func (t *KubeOperator) deleteServiceAccount(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
-	c := t.mgr.GetClient()
-
-	var sa corev1.ServiceAccount
-
-	saName := types.NamespacedName{Name: k8s.FormatSigninObjectName(signIn.Spec.Username), Namespace: signIn.Namespace} //nolint:golint-sl // used in Get call
-	if err := c.Get(ctx, saName, &sa); err != nil {
-		if k8serrors.IsNotFound(err) {
-			return humane.New("Service account not found", "the service account may have been already deleted")
-		}
-		return humane.Wrap(err, "Failed to load service account", "check Kubernetes connectivity and read permissions in namespace "+signIn.Namespace)
-	}
+	sa := &corev1.ServiceAccount{}
+	sa.Name = k8s.FormatSigninObjectName(signIn.Spec.Username)
+	sa.Namespace = signIn.Namespace
 
-	if err := c.Delete(ctx, &sa); err != nil {
+	if err := t.mgr.GetClient().Delete(ctx, sa); client.IgnoreNotFound(err) != nil {
 		return humane.Wrap(err, "Failed to remove service account", "check Kubernetes permissions for deleting service accounts")
 	}
 
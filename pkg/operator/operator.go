@@ -6,6 +6,7 @@ package operator
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/zapr"
 	"github.com/sierrasoftworks/humane-errors-go"
@@ -19,10 +20,21 @@ import (
 	"github.com/spechtlabs/go-otel-utils/otelzap"
 	"github.com/spechtlabs/tka/internal/utils"
 
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var scheme = runtime.NewScheme()
@@ -30,9 +42,23 @@ var scheme = runtime.NewScheme()
 // KubeOperator is the Kubernetes controller that manages TkaSignin custom resources.
 // It handles provisioning and deprovisioning of user credentials based on sign-in requests.
 type KubeOperator struct {
-	mgr    ctrl.Manager
-	tracer trace.Tracer
-	client k8s.TkaClient
+	mgr         ctrl.Manager
+	tracer      trace.Tracer
+	client      k8s.TkaClient
+	tokenIssuer k8s.TokenIssuer
+
+	// Used by the health checks, see ReadinessChecks
+	clientset kubernetes.Interface
+	election  leaderElection
+
+	// Variables for rendering TkaRoleTemplates
+	clusterName string
+	clusterInfo *models.ClusterInfoStore
+
+	// idleTimeouts revoke sessions that were not used for too long, see WithIdleTimeouts
+	idleTimeouts map[string]time.Duration
+
+	metrics metricsOptions
 }
 
 //nolint:golint-sl // Startup validation: Fatal terminates on config error, no context available
@@ -50,25 +76,42 @@ func getConfigOrDie() *rest.Config {
 	return config
 }
 
-func newControllerManagedBy() (ctrl.Manager, humane.Error) {
-	// If we run in-cluster then we also do leader election.
-	// But for local debugging, that's not needed
-	inCluster := isInCluster()
-	leaderElectionNamespace := ""
-	if !inCluster {
-		leaderElectionNamespace = "default"
+func newControllerManagedBy(options operatorOptions, election leaderElection) (ctrl.Manager, humane.Error) {
+	// Selects the bindings created for sign-ins, see k8s.NewRoleBinding
+	signInBinding, err := labels.NewRequirement(k8s.SignInLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, humane.Wrap(err, "invalid sign-in label selector", "this is an internal error; please report it")
 	}
 
-	mgr, err := ctrl.NewManager(getConfigOrDie(), ctrl.Options{
-		Scheme:                  scheme,
+	mgrOpts := ctrl.Options{
+		Scheme: scheme,
+		// Health checks are served by the server's health port, see ReadinessChecks
 		HealthProbeBindAddress:  "0",
-		LeaderElection:          inCluster,
-		LeaderElectionNamespace: leaderElectionNamespace,
-		LeaderElectionID:        "controller.tka.specht-labs.de",
+		LeaderElection:          election.enabled,
+		LeaderElectionNamespace: election.namespace,
+		LeaderElectionID:        leaderElectionID,
 		Metrics: server.Options{
 			BindAddress: "0",
 		},
-	})
+		// The token cache and the extension usage are the only readers of Secrets and ConfigMaps through the
+		// manager's client, and only the bindings of sign-ins are looked up among the RoleBindings
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}:      {Label: labels.SelectorFromSet(labels.Set{k8s.TokenCacheLabel: "true"})},
+				&corev1.ConfigMap{}:   {Label: labels.SelectorFromSet(labels.Set{k8s.ExtensionUsageLabel: "true"})},
+				&rbacv1.RoleBinding{}: {Label: labels.NewSelector().Add(*signInBinding)},
+			},
+		},
+	}
+
+	if options.webhook != nil {
+		mgrOpts.WebhookServer = webhook.NewServer(webhook.Options{
+			Port:    options.webhook.Port,
+			CertDir: options.webhook.CertDir,
+		})
+	}
+
+	mgr, err := ctrl.NewManager(getConfigOrDie(), mgrOpts)
 	if err != nil {
 		return nil, humane.Wrap(err, "failed to create manager", "check Kubernetes cluster connectivity and RBAC permissions")
 	}
@@ -76,14 +119,34 @@ func newControllerManagedBy() (ctrl.Manager, humane.Error) {
 	return mgr, nil
 }
 
-func newKubeOperator(mgr ctrl.Manager, clusterInfo *models.TkaClusterInfo, clientOpts k8s.ClientOptions) (*KubeOperator, humane.Error) {
+func newKubeOperator(mgr ctrl.Manager, clusterInfo *models.ClusterInfoStore, clientOpts k8s.ClientOptions, options operatorOptions) (*KubeOperator, humane.Error) {
 	op := &KubeOperator{
-		mgr:    mgr,
-		tracer: otel.Tracer("tka_controller"),
-		client: k8s.NewTkaClient(mgr.GetClient(), clusterInfo, clientOpts),
+		mgr:          mgr,
+		tracer:       otel.Tracer("tka_controller"),
+		client:       k8s.NewTkaClient(mgr.GetClient(), clusterInfo, clientOpts),
+		tokenIssuer:  clientOpts.TokenIssuer,
+		clusterName:  clientOpts.ClusterName,
+		clusterInfo:  clusterInfo,
+		idleTimeouts: options.idleTimeouts,
+		metrics:      options.metrics,
+	}
+
+	// Sessions are counted from the cache on every scrape, see sessionCollector
+	collector := NewSessionCollector(mgr.GetCache(), clientOpts.Namespace, options.metrics.expiringSoon)
+	if err := metrics.Registry.Register(collector); err != nil {
+		return nil, humane.Wrap(err, "failed to register session metrics", "this is an internal error; please report it")
 	}
 
-	if err := ctrl.NewControllerManagedBy(mgr).For(&v1alpha1.TkaSignin{}).Named("TkaSignin").Complete(op); err != nil {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.TkaSignin{}, signinRoleIndex, indexSigninRole); err != nil {
+		return nil, humane.Wrap(err, "failed to index sign-ins by role", "this is an internal error; please report it")
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.TkaSignin{}).
+		Watches(&rbacv1.ClusterRole{}, handler.EnqueueRequestsFromMapFunc(op.signinsForClusterRole)).
+		Named("TkaSignin").
+		Complete(op)
+	if err != nil {
 		return nil, humane.Wrap(err, "failed to register controller manager", "check that the TkaSignin CRD is installed in the cluster")
 	}
 
@@ -91,38 +154,146 @@ func newKubeOperator(mgr ctrl.Manager, clusterInfo *models.TkaClusterInfo, clien
 }
 
 // NewK8sOperator creates and initializes a new KubeOperator with the provided
-// cluster information and client configuration options.
-func NewK8sOperator(clusterInfo *models.TkaClusterInfo, clientOpts k8s.ClientOptions) (*KubeOperator, humane.Error) {
-	// Register the schemes
-	if err := clientgoscheme.AddToScheme(scheme); err != nil {
-		return nil, humane.Wrap(err, "failed to add clientgoscheme to scheme", "this is an internal error; please report it")
+// cluster information and client configuration options. Sign-ins are provisioned
+// with the cluster information current at the time.
+func NewK8sOperator(clusterInfo *models.ClusterInfoStore, clientOpts k8s.ClientOptions, opts ...Option) (*KubeOperator, humane.Error) {
+	options := operatorOptions{metrics: metricsOptions{expiringSoon: DefaultExpiringSoonWindow}}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	if err := v1alpha1.AddToScheme(scheme); err != nil {
-		return nil, humane.Wrap(err, "failed to add v1alpha1 to scheme", "this is an internal error; please report it")
+	if err := addToScheme(); err != nil {
+		return nil, err
 	}
 
 	ctrl.SetLogger(zapr.NewLogger(otelzap.L().Logger))
 
-	mgr, err := newControllerManagedBy()
+	election, err := getLeaderElection()
+	if err != nil {
+		return nil, err
+	}
+
+	mgr, err := newControllerManagedBy(options, election)
 	if err != nil {
 		return nil, err
 	}
 
-	if ok, err := utils.IsK8sVerAtLeast(1, 24); err != nil {
+	if err := completeClientOptions(mgr.GetConfig(), mgr.GetClient(), &clientOpts); err != nil {
 		return nil, err
-	} else if !ok {
-		return nil, humane.New("k8s version must be at least 1.24", "upgrade your Kubernetes cluster to version 1.24 or later")
 	}
 
-	op, err := newKubeOperator(mgr, clusterInfo, clientOpts)
+	op, err := newKubeOperator(mgr, clusterInfo, clientOpts, options)
 	if err != nil {
 		return nil, err
 	}
+	op.clientset = clientOpts.Clientset
+	op.election = election
+
+	if err := registerAdmissionWebhook(mgr, clientOpts.Clientset, options.webhook); err != nil {
+		return nil, err
+	}
 
 	return op, nil
 }
 
+// addToScheme registers the types the operator and the API work with.
+func addToScheme() humane.Error {
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return humane.Wrap(err, "failed to add clientgoscheme to scheme", "this is an internal error; please report it")
+	}
+
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return humane.Wrap(err, "failed to add v1alpha1 to scheme", "this is an internal error; please report it")
+	}
+
+	return nil
+}
+
+// completeClientOptions fills in what the caller left out of clientOpts and checks the cluster is supported.
+// The token cache reads and writes its Secrets with c.
+func completeClientOptions(config *rest.Config, c client.Client, clientOpts *k8s.ClientOptions) humane.Error {
+	if err := withSharedClients(config, clientOpts); err != nil {
+		return err
+	}
+
+	if !clientOpts.ServerVersion.AtLeast(1, 24) {
+		return humane.New("k8s version must be at least 1.24", "upgrade your Kubernetes cluster to version 1.24 or later")
+	}
+
+	return withTokenIssuer(c, clientOpts)
+}
+
+// registerAdmissionWebhook serves the TkaSignin validating webhook if it is enabled.
+func registerAdmissionWebhook(mgr ctrl.Manager, clientset kubernetes.Interface, opts *WebhookOptions) humane.Error {
+	if opts == nil {
+		return nil
+	}
+
+	if len(opts.AllowedUsers) == 0 {
+		return humane.New("admission webhook requires at least one allowed user",
+			"set operator.webhook.allowedUsers to the TKA server's ServiceAccount, e.g. system:serviceaccount:tka-dev:tka-controller")
+	}
+
+	validator := NewSigninValidationHandler(clientset, *opts)
+	if err := ctrl.NewWebhookManagedBy(mgr, &v1alpha1.TkaSignin{}).WithValidator(validator).Complete(); err != nil {
+		return humane.Wrap(err, "failed to register admission webhook", "check the webhook server configuration")
+	}
+
+	otelzap.L().Info("registered TkaSignin admission webhook",
+		zap.Int("port", opts.Port),
+		zap.Strings("allowed_roles", opts.AllowedRoles),
+		zap.Strings("allowed_users", opts.AllowedUsers))
+	return nil
+}
+
+// withTokenIssuer selects the token strategy if the caller did not provide one. The strategy is
+// fixed for the lifetime of the process. Short-lived TokenRequest tokens are cached per session
+// so repeated kubeconfig requests don't mint a new token each time.
+func withTokenIssuer(c client.Client, clientOpts *k8s.ClientOptions) humane.Error {
+	if clientOpts.TokenIssuer == nil {
+		issuer, err := k8s.NewTokenIssuer(clientOpts.Clientset)
+		if err != nil {
+			return humane.Wrap(err, "failed to select token strategy", "check that the operator may use the discovery API")
+		}
+
+		if clientOpts.TokenCache.Enabled && issuer.Name() == k8s.TokenStrategyTokenRequest {
+			if issuer, err = k8s.NewCachingTokenIssuer(issuer, c, clientOpts.TokenCache); err != nil {
+				return err
+			}
+			if clientOpts.TokenCache.EncryptionKey == "" {
+				otelzap.L().Warn("operator.tokenCache.encryptionKey is not set, cached tokens are only reused by the replica that issued them")
+			}
+		}
+		clientOpts.TokenIssuer = issuer
+	}
+
+	otelzap.L().Info("selected service account token strategy",
+		zap.String("strategy", clientOpts.TokenIssuer.Name()),
+		zap.Bool("token_cache", clientOpts.TokenCache.Enabled))
+	return nil
+}
+
+// withSharedClients fills in the shared clientset and server version cache if the caller did not provide them.
+func withSharedClients(config *rest.Config, clientOpts *k8s.ClientOptions) humane.Error {
+	if clientOpts.Clientset == nil {
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return humane.Wrap(err, "failed to create Kubernetes clientset", "check cluster connectivity and authentication")
+		}
+		clientOpts.Clientset = clientset
+	}
+
+	if clientOpts.ServerVersion == nil {
+		serverVersion, err := utils.NewServerVersion(clientOpts.Clientset.Discovery())
+		if err != nil {
+			return err
+		}
+		clientOpts.ServerVersion = serverVersion
+	}
+
+	return nil
+}
+
 func (t *KubeOperator) Start(ctx context.Context) humane.Error {
 	if err := t.mgr.Start(ctx); err != nil {
 		return humane.Wrap(err, "failed to start manager", "check Kubernetes connectivity and operator permissions")
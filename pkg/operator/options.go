@@ -0,0 +1,93 @@
+package operator
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultWebhookPort is the port the admission webhook server listens on by default.
+const DefaultWebhookPort = 9443
+
+// DefaultWebhookMaxValidity is the longest validity period the admission webhook accepts by default.
+const DefaultWebhookMaxValidity = 24 * time.Hour
+
+// DefaultWebhookAllowedUser is the ServiceAccount of the operator in the default deployment (config/rbac). The
+// operator updates sign-ins itself, e.g. to suspend delegations or add their finalizer, so it must always be allowed.
+const DefaultWebhookAllowedUser = "system:serviceaccount:tka-dev:tka-controller"
+
+// Option configures optional features of the KubeOperator.
+type Option func(*operatorOptions)
+
+type operatorOptions struct {
+	webhook      *WebhookOptions
+	idleTimeouts map[string]time.Duration
+	metrics      metricsOptions
+}
+
+// DefaultExpiringSoonWindow is how long before they end sessions count as expiring soon by default.
+const DefaultExpiringSoonWindow = 15 * time.Minute
+
+type metricsOptions struct {
+	expiringSoon  time.Duration
+	hideUsernames bool
+}
+
+// IdleTimeoutAllRoles is the key of WithIdleTimeouts that applies to every role without a timeout of its own.
+const IdleTimeoutAllRoles = "*"
+
+// WebhookOptions configures the validating admission webhook for TkaSignin resources.
+type WebhookOptions struct {
+	// Port is the port the webhook server listens on.
+	Port int
+	// CertDir is the directory holding tls.crt and tls.key for the webhook server.
+	CertDir string
+	// AllowedRoles is the allow-list of ClusterRoles a sign-in may reference.
+	// An empty list allows every ClusterRole that exists in the cluster.
+	AllowedRoles []string
+	// AllowedUsers are the Kubernetes usernames permitted to create or modify sign-ins, typically the
+	// ServiceAccounts of the operator and the API (system:serviceaccount:<namespace>:<name>). The operator's
+	// must be among them, as it updates sign-ins too.
+	AllowedUsers []string
+	// MaxValidity is the longest validity period a sign-in may request. Zero disables the upper bound.
+	MaxValidity time.Duration
+}
+
+// WithAdmissionWebhook enables the validating admission webhook for TkaSignin resources.
+func WithAdmissionWebhook(opts WebhookOptions) Option {
+	return func(o *operatorOptions) {
+		if opts.Port == 0 {
+			opts.Port = DefaultWebhookPort
+		}
+		o.webhook = &opts
+	}
+}
+
+// WithIdleTimeouts revokes sessions whose credentials were not used for longer than the timeout of their role.
+// The key IdleTimeoutAllRoles sets the timeout for all other roles. Roles are matched case-insensitively.
+// Activity is reported by the audit webhook (see package audit); without it every session with a timeout
+// is revoked once it runs out.
+func WithIdleTimeouts(timeouts map[string]time.Duration) Option {
+	return func(o *operatorOptions) {
+		o.idleTimeouts = make(map[string]time.Duration, len(timeouts))
+		for role, timeout := range timeouts {
+			o.idleTimeouts[strings.ToLower(role)] = timeout
+		}
+	}
+}
+
+// WithExpiringSoonWindow sets how long before they end sessions are reported as expiring soon.
+func WithExpiringSoonWindow(window time.Duration) Option {
+	return func(o *operatorOptions) {
+		if window > 0 {
+			o.metrics.expiringSoon = window
+		}
+	}
+}
+
+// WithUsernameMetrics controls whether metrics carry a username label. On by default; turning it off
+// leaves the label empty, which keeps the number of series independent of the number of users.
+func WithUsernameMetrics(enabled bool) Option {
+	return func(o *operatorOptions) {
+		o.metrics.hideUsernames = !enabled
+	}
+}
@@ -36,6 +36,8 @@ type reconcileEvent struct {
 	name       string
 	namespace  string
 	username   string
+	reason     string
+	suspended  bool
 	operation  string
 	success    bool
 	err        error
@@ -46,6 +48,8 @@ type reconcileEvent struct {
 // +kubebuilder:rbac:groups=tka.specht-labs.de,resources=TkaSignin,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tka.specht-labs.de,resources=TkaSignin/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=tka.specht-labs.de,resources=TkaSignin/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
 
 func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
 	startTime := time.Now()
@@ -69,6 +73,8 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 			attribute.String("reconcile.name", event.name),
 			attribute.String("reconcile.namespace", event.namespace),
 			attribute.String("reconcile.username", event.username),
+			attribute.String("reconcile.reason", event.reason),
+			attribute.Bool("reconcile.suspended", event.suspended),
 			attribute.String("reconcile.operation", event.operation),
 			attribute.Bool("reconcile.success", event.success),
 			attribute.Int64("reconcile.duration_ms", event.durationMs),
@@ -92,6 +98,8 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 	// Grab and process the signin object first
 	signIn := &v1alpha1.TkaSignin{}
 	if err := c.Get(ctx, req.NamespacedName, signIn); err != nil {
+		// Sign-ins are deprovisioned before their finalizer is removed. This cleans up after sign-ins that
+		// were deleted without one, as far as their name tells what was created for them.
 		if k8serrors.IsNotFound(err) {
 			signIn = &v1alpha1.TkaSignin{
 				ObjectMeta: metav1.ObjectMeta{
@@ -105,7 +113,7 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 			event.username = signIn.Spec.Username
 			event.operation = "deprovision_not_found"
 
-			if err := t.signOutUser(ctx, signIn); err != nil {
+			if err := t.deprovision(ctx, signIn); err != nil {
 				event.success = false
 				event.err = err
 				return reconcile.Result{}, fmt.Errorf("failed to deprovision deleted signin %s: %w", req.Name, err) //nolint:golint-sl // controller-runtime expects standard error
@@ -120,10 +128,61 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 	}
 
 	event.username = signIn.Spec.Username
+	event.reason = signIn.Spec.Reason
+	if link, ok := k8s.OriginLink(signIn); ok {
+		span.AddLink(link)
+	}
+	event.suspended = signIn.Spec.Suspended
+
+	// The user signed out, or the sign-in was deleted otherwise
+	if !signIn.DeletionTimestamp.IsZero() {
+		event.operation = "finalize"
+		if err := t.finalizeSignIn(ctx, signIn); err != nil {
+			event.success = false
+			event.err = err
+			return reconcile.Result{}, fmt.Errorf("failed to deprovision deleted signin %s: %w", signIn.Name, err) //nolint:golint-sl // controller-runtime expects standard error
+		}
+		return reconcile.Result{}, nil
+	}
 
-	op, validDuration := getAction(signIn, span)
+	op, validDuration := getAction(signIn, t.idleTimeout(signIn.Spec.Role), span)
 	event.requeueIn = validDuration
 
+	// Don't grant a role that doesn't exist; the ClusterRole watch brings us back once it does
+	if op != SignInOperationDeprovision {
+		if available, err := t.syncRoleCondition(ctx, signIn); err != nil {
+			event.success = false
+			event.err = err
+			return reconcile.Result{}, fmt.Errorf("failed to check role of signin %s: %w", signIn.Name, err) //nolint:golint-sl // controller-runtime expects standard error
+		} else if !available {
+			span.AddEvent("role_not_found")
+			event.operation = "role_not_found"
+
+			// The sign-in still expires if the role never shows up. Provisioned sign-ins that expired take the
+			// deprovision path instead, so this one never got any resources to remove.
+			expires, ok := expiresIn(signIn, time.Now())
+			if ok && expires <= 0 {
+				event.operation = "expired_role_not_found"
+				if err := t.deleteSignIn(ctx, signIn); err != nil {
+					event.success = false
+					event.err = err
+					return reconcile.Result{}, fmt.Errorf("failed to remove expired signin %s: %w", signIn.Name, err) //nolint:golint-sl // controller-runtime expects standard error
+				}
+				return reconcile.Result{}, nil
+			}
+			event.requeueIn = expires
+			return reconcile.Result{RequeueAfter: expires}, nil
+		}
+	}
+
+	if op != SignInOperationDeprovision {
+		if err := t.ensureFinalizer(ctx, signIn); err != nil {
+			event.success = false
+			event.err = err
+			return reconcile.Result{}, fmt.Errorf("failed to add finalizer to signin %s: %w", signIn.Name, err) //nolint:golint-sl // controller-runtime expects standard error
+		}
+	}
+
 	switch op {
 	case SignInOperationProvision:
 		event.operation = "provision"
@@ -143,6 +202,11 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 
 	case SignInOperationNOP:
 		event.operation = "nop"
+		if err := t.syncSuspension(ctx, signIn); err != nil {
+			event.success = false
+			event.err = err
+			return reconcile.Result{}, fmt.Errorf("failed to sync suspension of signin %s: %w", signIn.Name, err) //nolint:golint-sl // controller-runtime expects standard error
+		}
 
 	default:
 		event.operation = "unknown"
@@ -151,17 +215,46 @@ func (t *KubeOperator) Reconcile(ctx context.Context, req ctrl.Request) (reconci
 	return reconcile.Result{RequeueAfter: validDuration}, nil
 }
 
-func getAction(signIn *v1alpha1.TkaSignin, span trace.Span) (SignInOperation, time.Duration) {
+// expiresIn returns how long the sign-in lasts from now: until its ValidUntil once provisioned, and counted
+// from the last sign-in attempt before. It reports false if the sign-in carries neither.
+func expiresIn(signIn *v1alpha1.TkaSignin, now time.Time) (time.Duration, bool) {
+	if validUntil, err := time.Parse(time.RFC3339, signIn.Status.ValidUntil); err == nil {
+		return validUntil.Sub(now), true
+	}
+
+	signedInAt, err := time.Parse(time.RFC3339, signIn.Annotations[k8s.LastAttemptedSignIn])
+	if err != nil {
+		return 0, false
+	}
+
+	validity, err := time.ParseDuration(signIn.Spec.ValidityPeriod)
+	if err != nil {
+		return 0, false
+	}
+
+	_, end := k8s.AccessWindow(signedInAt, validity, signIn.Spec.NotBefore, signIn.Spec.NotAfter)
+	return end.Sub(now), true
+}
+
+func getAction(signIn *v1alpha1.TkaSignin, idleTimeout time.Duration, span trace.Span) (SignInOperation, time.Duration) {
 	validity, err := time.ParseDuration(signIn.Spec.ValidityPeriod)
 	if err != nil {
 		span.AddEvent("parse_validity_period_failed")
 		return SignInOperationNOP, time.Duration(0)
 	}
 
-	// If a new signin is not yet provisioned - use the reconciler loop to deploy the SA and CRB
+	// If a new signin is not yet provisioned - use the reconciler loop to deploy the SA and CRB,
+	// unless its access starts later, e.g. with a scheduled shift
 	if !signIn.Status.Provisioned {
+		now := time.Now()
+		start, end := k8s.AccessWindow(now, validity, signIn.Spec.NotBefore, signIn.Spec.NotAfter)
+		if start.After(now) {
+			span.AddEvent("not_before")
+			return SignInOperationNOP, start.Sub(now)
+		}
+
 		span.AddEvent("not_provisioned")
-		return SignInOperationProvision, validity
+		return SignInOperationProvision, end.Sub(now)
 	}
 
 	// If SignIn is expired
@@ -176,6 +269,12 @@ func getAction(signIn *v1alpha1.TkaSignin, span trace.Span) (SignInOperation, ti
 		return SignInOperationDeprovision, time.Duration(0)
 	}
 
+	// If the credentials went unused for too long
+	idleOp, idleIn := idleAction(signIn, idleTimeout, span)
+	if idleOp == SignInOperationDeprovision {
+		return idleOp, time.Duration(0)
+	}
+
 	// If user extended the login
 	var signedInAtStr string
 	if signedIn, ok := signIn.Annotations[k8s.LastAttemptedSignIn]; ok {
@@ -196,9 +295,14 @@ func getAction(signIn *v1alpha1.TkaSignin, span trace.Span) (SignInOperation, ti
 		return SignInOperationNOP, time.Duration(0)
 	}
 
-	if statusValidUntil := signedInAt.Add(signedInUntilDuration); !statusValidUntil.Equal(validUntil) {
+	if _, statusValidUntil := k8s.AccessWindow(signedInAt, signedInUntilDuration, signIn.Spec.NotBefore, signIn.Spec.NotAfter); !statusValidUntil.Equal(validUntil) {
 		span.AddEvent("login_extended")
-		return SignInOperationProvision, time.Duration(0)
+		return SignInOperationProvision, time.Until(statusValidUntil)
+	}
+
+	// Checking for idleness again must not postpone the expiry
+	if idleIn > 0 {
+		return SignInOperationNOP, min(idleIn, time.Until(validUntil))
 	}
 
 	return SignInOperationNOP, time.Duration(0)
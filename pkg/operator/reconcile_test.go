@@ -0,0 +1,281 @@
+package operator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/operator"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const reconcileTestNamespace = "tka-dev"
+
+// newReconcileTestSignin returns a sign-in of alice that was provisioned at signedIn.
+func newReconcileTestSignin(signedIn time.Time, period time.Duration) *v1alpha1.TkaSignin {
+	signIn := k8s.NewSignin("alice", "edit", period, reconcileTestNamespace)
+	signIn.Annotations[k8s.LastAttemptedSignIn] = signedIn.Format(time.RFC3339)
+	signIn.Status = v1alpha1.TkaSigninStatus{
+		Provisioned: true,
+		SignedInAt:  signedIn.Format(time.RFC3339),
+		ValidUntil:  signedIn.Add(period).Format(time.RFC3339),
+	}
+	return signIn
+}
+
+func newReconcileTestOperator(t *testing.T, objs ...client.Object) (*operator.KubeOperator, client.Client) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme)).WithObjects(objs...).WithStatusSubresource(&v1alpha1.TkaSignin{}).Build()
+	return operator.NewTestOperator(c, scheme), c
+}
+
+func reconcileSignin(t *testing.T, op *operator.KubeOperator, name string) ctrl.Result {
+	t.Helper()
+
+	result, err := op.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: reconcileTestNamespace, Name: name}})
+	require.NoError(t, err)
+	return result
+}
+
+func TestReconcile_Suspension(t *testing.T) {
+	ctx := context.Background()
+	signIn := newReconcileTestSignin(time.Now().Add(-10*time.Minute).Truncate(time.Second), time.Hour)
+	signIn.UID = "alice-uid"
+	signIn.Spec.Suspended = true
+
+	delegation := k8s.NewDelegatedSignin(signIn, k8s.FormatDelegateUsername("alice", "abc"), k8s.DelegateOptions{Role: "edit", Period: 15 * time.Minute})
+
+	template := &v1alpha1.TkaRoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "scratch"},
+		Spec: v1alpha1.TkaRoleTemplateSpec{Role: "edit", Manifests: []string{`apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: scratch-{{ .User.ServiceAccount }}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: edit
+subjects:
+  - kind: ServiceAccount
+    name: {{ .User.ServiceAccount }}
+    namespace: {{ .Session.Namespace }}
+`}},
+	}
+	templateBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "scratch-" + k8s.FormatSigninObjectName("alice"), Namespace: reconcileTestNamespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+	}
+
+	binding := k8s.NewClusterRoleBinding(signIn)
+
+	op, c := newReconcileTestOperator(t,
+		signIn, delegation, template, templateBinding, binding.DeepCopy(),
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+	)
+
+	// Suspending removes every binding and suspends the delegation
+	reconcileSignin(t, op, signIn.Name)
+
+	err := c.Get(ctx, client.ObjectKeyFromObject(binding), &rbacv1.ClusterRoleBinding{})
+	require.True(t, k8serrors.IsNotFound(err), "the session's ClusterRoleBinding must be removed")
+	err = c.Get(ctx, client.ObjectKeyFromObject(templateBinding), &rbacv1.RoleBinding{})
+	require.True(t, k8serrors.IsNotFound(err), "bindings created from role templates must be removed")
+
+	var got v1alpha1.TkaSignin
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(delegation), &got))
+	require.True(t, got.Spec.Suspended, "delegations must be suspended with the session")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(signIn), &got))
+	require.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ConditionSuspended))
+	require.Len(t, got.Status.Resources, 1)
+	require.Equal(t, templateBinding.Name, got.Status.Resources[0].Name)
+	require.Equal(t, reconcileTestNamespace, got.Status.Resources[0].Namespace)
+
+	// Resuming restores them
+	got.Spec.Suspended = false
+	require.NoError(t, c.Update(ctx, &got))
+	reconcileSignin(t, op, signIn.Name)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(binding), &rbacv1.ClusterRoleBinding{}))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(templateBinding), &rbacv1.RoleBinding{}))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(delegation), &got))
+	require.False(t, got.Spec.Suspended)
+}
+
+func TestReconcile_DeprovisionsSuspendedSignin(t *testing.T) {
+	signIn := newReconcileTestSignin(time.Now().Add(-2*time.Hour).Truncate(time.Second), time.Hour)
+	signIn.Spec.Suspended = true
+
+	op, c := newReconcileTestOperator(t, signIn,
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: k8s.FormatSigninObjectName("alice"), Namespace: reconcileTestNamespace}},
+	)
+
+	reconcileSignin(t, op, signIn.Name)
+
+	err := c.Get(context.Background(), client.ObjectKeyFromObject(signIn), &v1alpha1.TkaSignin{})
+	require.True(t, k8serrors.IsNotFound(err), "expired sign-ins must be removed even while suspended")
+}
+
+func TestReconcile_RoleNotFound(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		signIn      func() *v1alpha1.TkaSignin
+		wantRequeue time.Duration
+		wantDeleted bool
+	}{
+		{
+			name:        "provisioned",
+			signIn:      func() *v1alpha1.TkaSignin { return newReconcileTestSignin(now.Add(-10*time.Minute), time.Hour) },
+			wantRequeue: 50 * time.Minute,
+		},
+		{
+			name: "not provisioned",
+			signIn: func() *v1alpha1.TkaSignin {
+				signIn := newReconcileTestSignin(now.Add(-10*time.Minute), time.Hour)
+				signIn.Status = v1alpha1.TkaSigninStatus{}
+				return signIn
+			},
+			wantRequeue: 50 * time.Minute,
+		},
+		{
+			name: "expired before it was provisioned",
+			signIn: func() *v1alpha1.TkaSignin {
+				signIn := newReconcileTestSignin(now.Add(-2*time.Hour), time.Hour)
+				signIn.Status = v1alpha1.TkaSigninStatus{}
+				return signIn
+			},
+			wantDeleted: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signIn := tc.signIn()
+
+			// No ClusterRole "edit"
+			op, c := newReconcileTestOperator(t, signIn)
+			result := reconcileSignin(t, op, signIn.Name)
+			require.InDelta(t, tc.wantRequeue.Seconds(), result.RequeueAfter.Seconds(), 5, "the sign-in must still expire without its role")
+
+			var got v1alpha1.TkaSignin
+			err := c.Get(ctx, client.ObjectKeyFromObject(signIn), &got)
+			if tc.wantDeleted {
+				require.True(t, k8serrors.IsNotFound(err), "expired sign-ins must be removed")
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.ConditionRoleAvailable))
+		})
+	}
+}
+
+func TestReconcile_SignOutDeprovisions(t *testing.T) {
+	ctx := context.Background()
+	signIn := newReconcileTestSignin(time.Now().Truncate(time.Second), time.Hour)
+	signIn.Status = v1alpha1.TkaSigninStatus{}
+
+	template := &v1alpha1.TkaRoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "scratch"},
+		Spec: v1alpha1.TkaRoleTemplateSpec{Role: "edit", Manifests: []string{`apiVersion: v1
+kind: Namespace
+metadata:
+  name: scratch-{{ dnsLabel .User.Name }}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: session-{{ dnsLabel .User.Name }}
+  namespace: team-a
+`}},
+	}
+	namespace := client.ObjectKey{Name: "scratch-alice"}
+	configMap := client.ObjectKey{Name: "session-alice", Namespace: "team-a"}
+
+	op, c := newReconcileTestOperator(t, signIn, template, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}})
+
+	reconcileSignin(t, op, signIn.Name)
+
+	var got v1alpha1.TkaSignin
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(signIn), &got))
+	require.True(t, got.Status.Provisioned)
+	require.Contains(t, got.Finalizers, k8s.SigninFinalizer)
+	require.NoError(t, c.Get(ctx, namespace, &corev1.Namespace{}))
+	require.NoError(t, c.Get(ctx, configMap, &corev1.ConfigMap{}))
+
+	// Signing out deletes the sign-in, which waits for the operator to remove what it granted
+	require.NoError(t, c.Delete(ctx, &got))
+	reconcileSignin(t, op, signIn.Name)
+
+	err := c.Get(ctx, namespace, &corev1.Namespace{})
+	require.True(t, k8serrors.IsNotFound(err), "cluster-scoped template objects must be removed")
+	err = c.Get(ctx, configMap, &corev1.ConfigMap{})
+	require.True(t, k8serrors.IsNotFound(err), "template objects in other namespaces must be removed")
+	err = c.Get(ctx, client.ObjectKeyFromObject(k8s.NewClusterRoleBinding(signIn)), &rbacv1.ClusterRoleBinding{})
+	require.True(t, k8serrors.IsNotFound(err), "the session's ClusterRoleBinding must be removed")
+	err = c.Get(ctx, client.ObjectKeyFromObject(signIn), &v1alpha1.TkaSignin{})
+	require.True(t, k8serrors.IsNotFound(err), "the sign-in must be released once deprovisioned")
+}
+
+func TestReconcile_RemovesDelegatedRoleBinding(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("parent signs out", func(t *testing.T) {
+		parent := newReconcileTestSignin(time.Now().Add(-10*time.Minute).Truncate(time.Second), time.Hour)
+		parent.UID = "alice-uid"
+		delegation := k8s.NewDelegatedSignin(parent, k8s.FormatDelegateUsername("alice", "abc"), k8s.DelegateOptions{Role: "edit", Period: 15 * time.Minute, Namespace: "team-a"})
+
+		op, c := newReconcileTestOperator(t, parent, delegation, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}})
+
+		reconcileSignin(t, op, delegation.Name)
+		binding := client.ObjectKeyFromObject(k8s.NewRoleBinding(delegation))
+		require.NoError(t, c.Get(ctx, binding, &rbacv1.RoleBinding{}))
+
+		require.NoError(t, c.Delete(ctx, parent))
+		reconcileSignin(t, op, parent.Name)
+
+		err := c.Get(ctx, binding, &rbacv1.RoleBinding{})
+		require.True(t, k8serrors.IsNotFound(err), "the delegation's RoleBinding must be removed")
+		err = c.Get(ctx, client.ObjectKeyFromObject(delegation), &v1alpha1.TkaSignin{})
+		require.True(t, k8serrors.IsNotFound(err), "the delegation must be removed with its parent")
+	})
+
+	t.Run("sign-in already gone", func(t *testing.T) {
+		name := k8s.FormatSigninObjectName(k8s.FormatDelegateUsername("alice", "abc"))
+		binding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-binding",
+			Namespace: "team-a",
+			Labels:    map[string]string{k8s.SignInLabel: name},
+		}}
+
+		op, c := newReconcileTestOperator(t, binding)
+
+		// Reconciling twice shows that bindings which are gone already count as removed
+		reconcileSignin(t, op, name)
+		reconcileSignin(t, op, name)
+
+		err := c.Get(ctx, client.ObjectKeyFromObject(binding), &rbacv1.RoleBinding{})
+		require.True(t, k8serrors.IsNotFound(err), "bindings are found by their label without the sign-in's spec")
+	})
+}
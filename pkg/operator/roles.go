@@ -0,0 +1,73 @@
+package operator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// signinRoleIndex indexes TkaSignins by the ClusterRole they reference.
+const signinRoleIndex = ".spec.role"
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;watch
+
+func indexSigninRole(obj client.Object) []string {
+	signIn, ok := obj.(*v1alpha1.TkaSignin)
+	if !ok || signIn.Spec.Role == "" {
+		return nil
+	}
+	return []string{signIn.Spec.Role}
+}
+
+// signinsForClusterRole maps a created, changed or deleted ClusterRole to the sign-ins referencing it,
+// so that their RoleAvailable condition follows the role.
+func (t *KubeOperator) signinsForClusterRole(ctx context.Context, obj client.Object) []reconcile.Request {
+	var signIns v1alpha1.TkaSigninList
+	if err := t.mgr.GetClient().List(ctx, &signIns, client.MatchingFields{signinRoleIndex: obj.GetName()}); err != nil {
+		otelzap.L().WithError(err).ErrorContext(ctx, "Failed to list sign-ins for ClusterRole", zap.String("role", obj.GetName()))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(signIns.Items))
+	for _, signIn := range signIns.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&signIn)})
+	}
+	return requests
+}
+
+// syncRoleCondition checks that the sign-in's ClusterRole exists and records the result in the
+// RoleAvailable condition. It reports whether the role is available.
+func (t *KubeOperator) syncRoleCondition(ctx context.Context, signIn *v1alpha1.TkaSignin) (bool, humane.Error) {
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionRoleAvailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonRoleFound,
+		Message:            "ClusterRole " + signIn.Spec.Role + " exists",
+		ObservedGeneration: signIn.Generation,
+	}
+
+	if _, err := k8s.GetClusterRole(ctx, t.mgr.GetClient(), signIn.Spec.Role); errors.Is(err, k8s.ErrRoleNotFound) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonRoleNotFound
+		condition.Message = "ClusterRole " + signIn.Spec.Role + " does not exist"
+	} else if err != nil {
+		return false, err
+	}
+
+	if meta.SetStatusCondition(&signIn.Status.Conditions, condition) {
+		if err := t.mgr.GetClient().Status().Update(ctx, signIn); err != nil {
+			return false, humane.Wrap(err, "Error updating signin status", "check Kubernetes API connectivity and RBAC permissions")
+		}
+	}
+
+	return condition.Status == metav1.ConditionTrue, nil
+}
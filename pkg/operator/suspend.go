@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"context"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncSuspension removes everything that grants a suspended sign-in access and restores it once the
+// sign-in is resumed: its (Cluster)RoleBinding, the bindings created from role templates and the
+// credentials delegated from it. The Suspended condition records which state the bindings are in,
+// so nothing is changed while the spec and the condition agree.
+func (t *KubeOperator) syncSuspension(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	bindingsRemoved := meta.IsStatusConditionTrue(signIn.Status.Conditions, v1alpha1.ConditionSuspended)
+	if signIn.Spec.Suspended == bindingsRemoved {
+		return nil
+	}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonSuspended,
+		Message:            "The session is suspended; its bindings are removed",
+		ObservedGeneration: signIn.Generation,
+	}
+
+	if err := t.suspendDelegations(ctx, signIn); err != nil {
+		return err
+	}
+
+	if signIn.Spec.Suspended {
+		if err := t.deleteBinding(ctx, signIn); err != nil {
+			return err
+		}
+	} else {
+		if err := t.createOrUpdateBinding(ctx, signIn); err != nil {
+			return err
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonResumed
+		condition.Message = "The session was resumed; its bindings are restored"
+	}
+
+	// Removes or restores the bindings among the objects of the role templates
+	resources, err := t.applyRoleTemplates(ctx, signIn)
+	if err != nil {
+		return err
+	}
+	signIn.Status.Resources = resources
+
+	meta.SetStatusCondition(&signIn.Status.Conditions, condition)
+	if err := t.mgr.GetClient().Status().Update(ctx, signIn); err != nil {
+		return humane.Wrap(err, "Error updating signin status", "check Kubernetes API connectivity and RBAC permissions")
+	}
+
+	return nil
+}
+
+// suspendDelegations carries the suspension of a sign-in over to the credentials delegated from it, also
+// when the sign-in was suspended by editing it rather than through TkaClient.SetSuspended.
+func (t *KubeOperator) suspendDelegations(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	delegations, err := k8s.ListDelegations(ctx, t.mgr.GetClient(), signIn)
+	if err != nil {
+		return err
+	}
+
+	for i := range delegations {
+		delegation := &delegations[i]
+		if delegation.Spec.Suspended == signIn.Spec.Suspended {
+			continue
+		}
+
+		delegation.Spec.Suspended = signIn.Spec.Suspended
+		if err := t.mgr.GetClient().Update(ctx, delegation); err != nil {
+			return humane.Wrap(err, "Failed to update delegated sign-in "+delegation.Name, "check Kubernetes permissions for updating TkaSignin resources")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// templateFieldOwner is the field manager the operator applies TkaRoleTemplate objects with.
+const templateFieldOwner = "tka-operator"
+
+// +kubebuilder:rbac:groups=tka.specht-labs.de,resources=tkaroletemplates,verbs=get;list;watch
+
+// TemplateData holds the variables available to TkaRoleTemplate manifests. The rendered YAML is applied
+// with the operator's permissions, so it must only hold values users cannot choose freely: a sign-in
+// reason containing "---" would add arbitrary objects.
+type TemplateData struct {
+	User    TemplateUser
+	Role    string
+	Session TemplateSession
+	Cluster TemplateCluster
+}
+
+// TemplateUser describes the user who signed in.
+type TemplateUser struct {
+	// Name is the TKA username of the sign-in, i.e. the local part of the
+	// Tailscale login name, e.g. alice for alice@example.com.
+	Name string
+	// ServiceAccount is the name of the ServiceAccount the user acts as.
+	ServiceAccount string
+}
+
+// TemplateSession describes the sign-in the objects are created for.
+type TemplateSession struct {
+	// Name and Namespace identify the TkaSignin.
+	Name      string
+	Namespace string
+	// Period is the validity period of the sign-in, e.g. 2h0m0s.
+	Period string
+	// ValidUntil is when the sign-in expires (RFC3339).
+	ValidUntil string
+}
+
+// TemplateCluster describes the cluster TKA runs in.
+type TemplateCluster struct {
+	Name      string
+	ServerURL string
+	Labels    map[string]string
+}
+
+// templateFuncs are the functions available to TkaRoleTemplate manifests in addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"dnsLabel": dnsLabel,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"quote":    strconv.Quote,
+	"trunc": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n])
+		}
+		return s
+	},
+}
+
+// NewTemplateData collects the variables a TkaRoleTemplate is rendered with for a sign-in.
+func NewTemplateData(signIn *v1alpha1.TkaSignin, clusterName string, clusterInfo *models.TkaClusterInfo) TemplateData {
+	data := TemplateData{
+		User: TemplateUser{
+			Name:           signIn.Spec.Username,
+			ServiceAccount: k8s.FormatSigninObjectName(signIn.Spec.Username),
+		},
+		Role: signIn.Spec.Role,
+		Session: TemplateSession{
+			Name:       signIn.Name,
+			Namespace:  signIn.Namespace,
+			Period:     signIn.Spec.ValidityPeriod,
+			ValidUntil: signIn.Status.ValidUntil,
+		},
+		Cluster: TemplateCluster{Name: clusterName, Labels: map[string]string{}},
+	}
+
+	if clusterInfo != nil {
+		data.Cluster.ServerURL = clusterInfo.ServerURL
+		if clusterInfo.Labels != nil {
+			data.Cluster.Labels = clusterInfo.Labels
+		}
+	}
+
+	return data
+}
+
+// RenderRoleTemplate renders the manifests of tmpl into the objects to create for a sign-in.
+func RenderRoleTemplate(tmpl *v1alpha1.TkaRoleTemplate, data TemplateData) ([]*unstructured.Unstructured, humane.Error) {
+	var objects []*unstructured.Unstructured
+
+	for i, manifest := range tmpl.Spec.Manifests {
+		t, err := template.New(fmt.Sprintf("%s[%d]", tmpl.Name, i)).Funcs(templateFuncs).Option("missingkey=error").Parse(manifest)
+		if err != nil {
+			return nil, humane.Wrap(err, fmt.Sprintf("Invalid manifest %d in TkaRoleTemplate %s", i, tmpl.Name),
+				"check the Go template syntax of the manifest")
+		}
+
+		var out bytes.Buffer
+		if err := t.Execute(&out, data); err != nil {
+			return nil, humane.Wrap(err, fmt.Sprintf("Failed to render manifest %d of TkaRoleTemplate %s", i, tmpl.Name),
+				"templates can use .User.Name, .User.ServiceAccount, .Role, .Session.Name, .Session.Namespace, .Session.Period, .Session.ValidUntil, .Cluster.Name, .Cluster.ServerURL and .Cluster.Labels")
+		}
+
+		decoded, herr := decodeManifests(&out)
+		if herr != nil {
+			return nil, humane.Wrap(herr, fmt.Sprintf("Manifest %d of TkaRoleTemplate %s does not render to valid Kubernetes objects", i, tmpl.Name),
+				"render the template with 'tka template render' to inspect the output")
+		}
+		objects = append(objects, decoded...)
+	}
+
+	return objects, nil
+}
+
+// decodeManifests splits rendered YAML into its documents, skipping empty ones.
+func decodeManifests(r io.Reader) ([]*unstructured.Unstructured, humane.Error) {
+	var objects []*unstructured.Unstructured
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var obj map[string]any
+		if err := decoder.Decode(&obj); errors.Is(err, io.EOF) {
+			return objects, nil
+		} else if err != nil {
+			return nil, humane.Wrap(err, "Invalid YAML", "check the indentation of the rendered manifest")
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: obj}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" || u.GetName() == "" {
+			return nil, humane.New("Object without apiVersion, kind or metadata.name", "every document must be a complete Kubernetes object")
+		}
+		objects = append(objects, u)
+	}
+}
+
+// dnsLabel turns s into a valid DNS label (RFC 1123), e.g. for namespace names derived from user names.
+func dnsLabel(s string) string {
+	label := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(s))
+
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return strings.Trim(label, "-")
+}
+
+// applyRoleTemplates creates or updates the objects of every TkaRoleTemplate for the sign-in's role and
+// removes objects of an earlier sign-in that the templates no longer produce. It returns the objects to
+// record in the sign-in's status.
+func (t *KubeOperator) applyRoleTemplates(ctx context.Context, signIn *v1alpha1.TkaSignin) ([]v1alpha1.TkaSigninResource, humane.Error) {
+	var templates v1alpha1.TkaRoleTemplateList
+	if err := t.mgr.GetClient().List(ctx, &templates); err != nil {
+		return nil, humane.Wrap(err, "Failed to list role templates", "check that the TkaRoleTemplate CRD is installed and the operator may list it")
+	}
+
+	data := NewTemplateData(signIn, t.clusterName, t.clusterInfo.Load())
+
+	var resources []v1alpha1.TkaSigninResource
+	for i := range templates.Items {
+		tmpl := &templates.Items[i]
+		if tmpl.Spec.Role != signIn.Spec.Role {
+			continue
+		}
+
+		objects, err := RenderRoleTemplate(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range objects {
+			// Bindings grant access, so a suspended sign-in keeps none of them (see syncSuspension)
+			if signIn.Spec.Suspended && isBinding(obj) {
+				if obj.GetKind() == "RoleBinding" && obj.GetNamespace() == "" {
+					obj.SetNamespace(signIn.Namespace)
+				}
+				res := templateResource(tmpl.Name, obj)
+				if err := t.deleteTemplateResource(ctx, res); err != nil {
+					return nil, err
+				}
+				resources = append(resources, res)
+				continue
+			}
+
+			if err := t.applyTemplateObject(ctx, signIn, obj); err != nil {
+				return nil, err
+			}
+			resources = append(resources, templateResource(tmpl.Name, obj))
+		}
+	}
+
+	for _, res := range signIn.Status.Resources {
+		if !slices.Contains(resources, res) {
+			if err := t.deleteTemplateResource(ctx, res); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// applyTemplateObject applies a rendered object with server-side apply. Objects in the sign-in's
+// namespace are owned by the sign-in, so Kubernetes garbage collects them with it.
+func (t *KubeOperator) applyTemplateObject(ctx context.Context, signIn *v1alpha1.TkaSignin, obj *unstructured.Unstructured) humane.Error {
+	c := t.mgr.GetClient()
+
+	namespaced, err := c.IsObjectNamespaced(obj)
+	if err != nil {
+		return humane.Wrap(err, fmt.Sprintf("Unknown kind %s in role template", obj.GroupVersionKind()), "check the apiVersion and kind of the manifest and that its CRD is installed")
+	}
+	if namespaced && obj.GetNamespace() == "" {
+		obj.SetNamespace(signIn.Namespace)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[k8s.SignInOwner] = signIn.Namespace + "/" + signIn.Name
+	obj.SetAnnotations(annotations)
+
+	if namespaced && obj.GetNamespace() == signIn.Namespace {
+		_ = ctrl.SetControllerReference(signIn, obj, t.mgr.GetScheme())
+	}
+
+	if err := c.Apply(ctx, client.ApplyConfigurationFromUnstructured(obj), client.FieldOwner(templateFieldOwner), client.ForceOwnership); err != nil {
+		return humane.Wrap(err, fmt.Sprintf("Failed to apply %s %s for user %s", obj.GetKind(), obj.GetName(), signIn.Spec.Username),
+			"check that the operator has permissions to manage "+obj.GetKind()+" objects")
+	}
+
+	return nil
+}
+
+// deleteTemplateResources deletes the objects created from role templates for the sign-in.
+func (t *KubeOperator) deleteTemplateResources(ctx context.Context, signIn *v1alpha1.TkaSignin) humane.Error {
+	for _, res := range signIn.Status.Resources {
+		if err := t.deleteTemplateResource(ctx, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *KubeOperator) deleteTemplateResource(ctx context.Context, res v1alpha1.TkaSigninResource) humane.Error {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(res.APIVersion)
+	obj.SetKind(res.Kind)
+	obj.SetNamespace(res.Namespace)
+	obj.SetName(res.Name)
+
+	if err := t.mgr.GetClient().Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+		return humane.Wrap(err, fmt.Sprintf("Failed to delete %s %s created from role template %s", res.Kind, res.Name, res.Template),
+			"check that the operator has permissions to delete "+res.Kind+" objects")
+	}
+	return nil
+}
+
+// isBinding reports whether obj is a RoleBinding or ClusterRoleBinding.
+func isBinding(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == rbacv1.GroupName && (gvk.Kind == "RoleBinding" || gvk.Kind == "ClusterRoleBinding")
+}
+
+func templateResource(templateName string, obj *unstructured.Unstructured) v1alpha1.TkaSigninResource {
+	return v1alpha1.TkaSigninResource{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Template:   templateName,
+	}
+}
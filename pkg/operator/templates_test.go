@@ -0,0 +1,129 @@
+package operator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/operator"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTemplateData() operator.TemplateData {
+	signIn := k8s.NewSignin("Alice@Example.com", "edit", time.Hour, "tka-dev")
+	k8s.WithReason("INC-1234")(signIn)
+	signIn.Status.ValidUntil = "2026-01-01T10:00:00Z"
+
+	return operator.NewTemplateData(signIn, "prod", &models.TkaClusterInfo{
+		ServerURL: "https://api.example.com:6443",
+		Labels:    map[string]string{"environment": "prod"},
+	})
+}
+
+func TestRenderRoleTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests []string
+		want      []map[string]string
+		wantErr   string
+	}{
+		{
+			name: "variables and functions",
+			manifests: []string{`apiVersion: v1
+kind: Namespace
+metadata:
+  name: scratch-{{ dnsLabel .User.Name }}
+  annotations:
+    short: {{ trunc 4 "Zürich" }}
+    until: {{ quote .Session.ValidUntil }}
+    env: {{ .Cluster.Labels.environment }}-{{ .Cluster.Name }}
+    sa: {{ .User.ServiceAccount }}
+    role: {{ upper .Role }}`},
+			want: []map[string]string{{
+				"kind":  "Namespace",
+				"name":  "scratch-alice-example-com",
+				"short": "Züri",
+				"until": "2026-01-01T10:00:00Z",
+				"env":   "prod-prod",
+				"sa":    "tka-user-Alice@Example.com",
+				"role":  "EDIT",
+			}},
+		},
+		{
+			name: "multiple documents and manifests",
+			manifests: []string{
+				"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: a\n---\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+				"{{ if eq .Role \"edit\" }}apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n{{ end }}",
+			},
+			want: []map[string]string{
+				{"kind": "Namespace", "name": "a"},
+				{"kind": "ConfigMap", "name": "b"},
+				{"kind": "ConfigMap", "name": "c"},
+			},
+		},
+		{
+			name:      "unknown variable",
+			manifests: []string{"name: {{ .Session.Ticket }}"},
+			wantErr:   "Failed to render manifest 0 of TkaRoleTemplate scratch",
+		},
+		{
+			name:      "invalid template",
+			manifests: []string{"name: {{ .User.Name"},
+			wantErr:   "Invalid manifest 0 in TkaRoleTemplate scratch",
+		},
+		{
+			name:      "incomplete object",
+			manifests: []string{"apiVersion: v1\nkind: ConfigMap\n"},
+			wantErr:   "Manifest 0 of TkaRoleTemplate scratch does not render to valid Kubernetes objects",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := &v1alpha1.TkaRoleTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "scratch"},
+				Spec:       v1alpha1.TkaRoleTemplateSpec{Role: "edit", Manifests: tc.manifests},
+			}
+
+			objects, err := operator.RenderRoleTemplate(tmpl, newTemplateData())
+			if tc.wantErr != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tc.wantErr, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Len(t, objects, len(tc.want))
+
+			for i, want := range tc.want {
+				require.Equal(t, want["kind"], objects[i].GetKind())
+				require.Equal(t, want["name"], objects[i].GetName())
+				for key, value := range want {
+					if key != "kind" && key != "name" {
+						require.Equal(t, value, objects[i].GetAnnotations()[key], key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRoleTemplate_ReasonInjection(t *testing.T) {
+	signIn := k8s.NewSignin("alice@example.com", "edit", time.Hour, "tka-dev")
+	k8s.WithReason("INC-1234\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRoleBinding\nmetadata:\n  name: pwned\nroleRef:\n  apiGroup: rbac.authorization.k8s.io\n  kind: ClusterRole\n  name: cluster-admin\n")(signIn)
+	data := operator.NewTemplateData(signIn, "prod", nil)
+
+	tmpl := &v1alpha1.TkaRoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "scratch"},
+		Spec: v1alpha1.TkaRoleTemplateSpec{Role: "edit", Manifests: []string{
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: notes\ndata:\n  reason: {{ .Session.Reason }}\n",
+		}},
+	}
+
+	objects, err := operator.RenderRoleTemplate(tmpl, data)
+	require.NotNil(t, err)
+	require.Equal(t, "Failed to render manifest 0 of TkaRoleTemplate scratch", err.Error())
+	require.Empty(t, objects)
+}
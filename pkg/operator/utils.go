@@ -1,10 +1,41 @@
 package operator
 
 import (
+	"os"
+	"strings"
+
+	"github.com/sierrasoftworks/humane-errors-go"
 	"k8s.io/client-go/rest"
 )
 
+// leaderElectionID names the Lease the operator replicas compete for.
+const leaderElectionID = "controller.tka.specht-labs.de"
+
+// inClusterNamespaceFile holds the namespace of the pod's ServiceAccount.
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// leaderElection describes where the operator replicas elect their leader.
+type leaderElection struct {
+	enabled   bool
+	namespace string
+}
+
 func isInCluster() bool {
 	_, err := rest.InClusterConfig()
 	return err == nil
 }
+
+// getLeaderElection enables leader election in the pod's namespace if we run in-cluster.
+// For local debugging, that's not needed.
+func getLeaderElection() (leaderElection, humane.Error) {
+	if !isInCluster() {
+		return leaderElection{namespace: "default"}, nil
+	}
+
+	namespace, err := os.ReadFile(inClusterNamespaceFile)
+	if err != nil {
+		return leaderElection{}, humane.Wrap(err, "failed to read the namespace of the pod", "ensure the ServiceAccount token is mounted at "+inClusterNamespaceFile)
+	}
+
+	return leaderElection{enabled: true, namespace: strings.TrimSpace(string(namespace))}, nil
+}
@@ -0,0 +1,192 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate-tka-specht-labs-de-v1alpha1-tkasignin,mutating=false,failurePolicy=fail,sideEffects=None,groups=tka.specht-labs.de,resources=tkasignins,verbs=create;update,versions=v1alpha1,name=vtkasignin.tka.specht-labs.de,admissionReviewVersions=v1
+
+// SigninValidationHandler rejects TkaSignin resources that the operator must not act on:
+// malformed or out-of-bounds durations, ClusterRoles that don't exist or aren't allowed,
+// names that don't match the username, and writes by anyone but the TKA server.
+type SigninValidationHandler struct {
+	clientset kubernetes.Interface
+	opts      WebhookOptions
+}
+
+var _ admission.Validator[*v1alpha1.TkaSignin] = &SigninValidationHandler{}
+
+// NewSigninValidationHandler creates a validator that looks up ClusterRoles through clientset.
+func NewSigninValidationHandler(clientset kubernetes.Interface, opts WebhookOptions) *SigninValidationHandler {
+	return &SigninValidationHandler{clientset: clientset, opts: opts}
+}
+
+func (v *SigninValidationHandler) ValidateCreate(ctx context.Context, signIn *v1alpha1.TkaSignin) (admission.Warnings, error) {
+	if err := v.validateRequester(ctx, signIn); err != nil {
+		return nil, err
+	}
+
+	return nil, v.validateSpec(ctx, signIn)
+}
+
+func (v *SigninValidationHandler) ValidateUpdate(ctx context.Context, oldSignIn, signIn *v1alpha1.TkaSignin) (admission.Warnings, error) {
+	// Finalizer cleanup by Kubernetes itself must not block deletion, other changes are still the server's alone
+	if signIn.DeletionTimestamp != nil && finalizersOnly(oldSignIn, signIn) {
+		return nil, nil
+	}
+
+	if err := v.validateRequester(ctx, signIn); err != nil {
+		return nil, err
+	}
+
+	return nil, v.validateSpec(ctx, signIn)
+}
+
+// ValidateDelete allows every deletion; removing a sign-in only ever revokes access.
+func (v *SigninValidationHandler) ValidateDelete(_ context.Context, _ *v1alpha1.TkaSignin) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// finalizersOnly reports whether an update changes nothing but the finalizers of the sign-in.
+// Fields the API server maintains itself are ignored.
+func finalizersOnly(oldSignIn, signIn *v1alpha1.TkaSignin) bool {
+	strip := func(s *v1alpha1.TkaSignin) *v1alpha1.TkaSignin {
+		s = s.DeepCopy()
+		s.Finalizers, s.ManagedFields, s.ResourceVersion = nil, nil, ""
+		s.DeletionTimestamp, s.DeletionGracePeriodSeconds = nil, nil
+		s.Status = v1alpha1.TkaSigninStatus{}
+		return s
+	}
+
+	return equality.Semantic.DeepEqual(strip(oldSignIn), strip(signIn))
+}
+
+// validateRequester ensures only the TKA server writes sign-ins.
+func (v *SigninValidationHandler) validateRequester(ctx context.Context, signIn *v1alpha1.TkaSignin) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return k8serrors.NewInternalError(err)
+	}
+
+	if slices.Contains(v.opts.AllowedUsers, req.UserInfo.Username) {
+		return nil
+	}
+
+	otelzap.L().WarnContext(ctx, "Rejected TkaSignin write by unauthorized user",
+		zap.String("requester", req.UserInfo.Username),
+		zap.String("signin", signIn.Name),
+		zap.String("operation", string(req.Operation)))
+
+	return k8serrors.NewForbidden(v1alpha1.GroupVersion.WithResource("tkasignins").GroupResource(), signIn.Name,
+		fmt.Errorf("only the TKA server may create or modify sign-ins, not %q", req.UserInfo.Username))
+}
+
+// validateSpec checks the sign-in's name, durations and role.
+func (v *SigninValidationHandler) validateSpec(ctx context.Context, signIn *v1alpha1.TkaSignin) error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+
+	if expected := k8s.FormatSigninObjectName(signIn.Spec.Username); signIn.Name != expected {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), signIn.Name, fmt.Sprintf("must be %q for username %q", expected, signIn.Spec.Username)))
+	}
+
+	errs = append(errs, v.validateValidity(specPath.Child("validity_period"), signIn.Spec.ValidityPeriod)...)
+
+	if signIn.Spec.TokenTTL != "" {
+		errs = append(errs, validateDuration(specPath.Child("token_ttl"), signIn.Spec.TokenTTL, k8s.MinSigninValidity, 0)...)
+	}
+
+	errs = append(errs, v.validateRole(ctx, specPath.Child("role"), signIn.Spec.Role)...)
+	errs = append(errs, validateAccessWindow(specPath, signIn.Spec.NotBefore, signIn.Spec.NotAfter)...)
+	errs = append(errs, validateNamespace(specPath.Child("namespace"), signIn.Spec.Namespace)...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return k8serrors.NewInvalid(v1alpha1.GroupVersion.WithKind("TkaSignin").GroupKind(), signIn.Name, errs)
+}
+
+func (v *SigninValidationHandler) validateValidity(path *field.Path, value string) field.ErrorList {
+	return validateDuration(path, value, k8s.MinSigninValidity, v.opts.MaxValidity)
+}
+
+func (v *SigninValidationHandler) validateRole(ctx context.Context, path *field.Path, role string) field.ErrorList {
+	if role == "" {
+		return field.ErrorList{field.Required(path, "a ClusterRole must be referenced")}
+	}
+
+	if len(v.opts.AllowedRoles) > 0 && !slices.Contains(v.opts.AllowedRoles, role) {
+		return field.ErrorList{field.NotSupported(path, role, v.opts.AllowedRoles)}
+	}
+
+	if _, err := v.clientset.RbacV1().ClusterRoles().Get(ctx, role, metav1.GetOptions{}); k8serrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(path, role)}
+	} else if err != nil {
+		return field.ErrorList{field.InternalError(path, fmt.Errorf("failed to look up ClusterRole: %w", err))}
+	}
+
+	return nil
+}
+
+// validateAccessWindow checks that a sign-in restricted to a time range ends after it starts.
+func validateAccessWindow(path *field.Path, notBefore, notAfter string) field.ErrorList {
+	if notBefore == "" || notAfter == "" {
+		return nil
+	}
+
+	start, startErr := time.Parse(time.RFC3339, notBefore)
+	end, endErr := time.Parse(time.RFC3339, notAfter)
+	if startErr == nil && endErr == nil && !end.After(start) {
+		return field.ErrorList{field.Invalid(path.Child("not_after"), notAfter, "must be after spec.not_before")}
+	}
+
+	return nil
+}
+
+// validateNamespace checks that a sign-in restricted to a namespace names a valid one.
+func validateNamespace(path *field.Path, namespace string) field.ErrorList {
+	if namespace == "" {
+		return nil
+	}
+
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(namespace) {
+		errs = append(errs, field.Invalid(path, namespace, msg))
+	}
+
+	return errs
+}
+
+// validateDuration checks that value is a Go duration within [minimum, maximum]. A zero maximum means unbounded.
+func validateDuration(path *field.Path, value string, minimum, maximum time.Duration) field.ErrorList {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, "must be a duration such as 30m or 8h")}
+	}
+
+	if duration < minimum {
+		return field.ErrorList{field.Invalid(path, value, fmt.Sprintf("must be at least %s", minimum))}
+	}
+
+	if maximum > 0 && duration > maximum {
+		return field.ErrorList{field.Invalid(path, value, fmt.Sprintf("must be at most %s", maximum))}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package operator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/api/v1alpha1"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/operator"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const serverServiceAccount = "system:serviceaccount:tka-dev:tka-controller"
+
+func newTestValidator() *operator.SigninValidationHandler {
+	clientset := fake.NewClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+	)
+
+	return operator.NewSigninValidationHandler(clientset, operator.WebhookOptions{
+		AllowedRoles: []string{"view", "edit"},
+		AllowedUsers: []string{serverServiceAccount},
+		MaxValidity:  operator.DefaultWebhookMaxValidity,
+	})
+}
+
+func requestContext(username string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		},
+	})
+}
+
+func newTestSignin(modify func(*v1alpha1.TkaSignin)) *v1alpha1.TkaSignin {
+	signIn := &v1alpha1.TkaSignin{
+		ObjectMeta: metav1.ObjectMeta{Name: k8s.FormatSigninObjectName("alice"), Namespace: "tka-dev"},
+		Spec: v1alpha1.TkaSigninSpec{
+			Username:       "alice",
+			Role:           "view",
+			ValidityPeriod: "1h",
+		},
+	}
+
+	if modify != nil {
+		modify(signIn)
+	}
+	return signIn
+}
+
+func TestSigninValidationHandler_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester string
+		modify    func(*v1alpha1.TkaSignin)
+		forbidden bool
+		invalid   string
+	}{
+		{name: "valid sign-in", requester: serverServiceAccount},
+		{name: "valid token ttl", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.TokenTTL = "15m" }},
+		{name: "requester not allowed", requester: "alice", forbidden: true},
+		{name: "unparsable validity", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.ValidityPeriod = "forever" }, invalid: "spec.validity_period"},
+		{name: "validity too short", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.ValidityPeriod = "5m" }, invalid: "spec.validity_period"},
+		{name: "validity too long", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.ValidityPeriod = "48h" }, invalid: "spec.validity_period"},
+		{name: "token ttl too short", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.TokenTTL = "1m" }, invalid: "spec.token_ttl"},
+		{name: "role not allowed", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.Role = "cluster-admin" }, invalid: "spec.role"},
+		{name: "role does not exist", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.Role = "edit" }, invalid: "spec.role"},
+		{name: "valid access window", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) {
+			s.Spec.NotBefore, s.Spec.NotAfter = "2030-01-01T08:00:00Z", "2030-01-01T16:00:00Z"
+		}},
+		{name: "access window ends before it starts", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) {
+			s.Spec.NotBefore, s.Spec.NotAfter = "2030-01-01T16:00:00Z", "2030-01-01T08:00:00Z"
+		}, invalid: "spec.not_after"},
+		{name: "valid namespace", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.Namespace = "team-a" }},
+		{name: "invalid namespace", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.Namespace = "Team_A" }, invalid: "spec.namespace"},
+		{name: "name does not match username", requester: serverServiceAccount, modify: func(s *v1alpha1.TkaSignin) { s.Spec.Username = "bob" }, invalid: "metadata.name"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newTestValidator().ValidateCreate(requestContext(tc.requester), newTestSignin(tc.modify))
+
+			switch {
+			case tc.forbidden:
+				require.True(t, k8serrors.IsForbidden(err), "expected forbidden, got %v", err)
+			case tc.invalid != "":
+				require.True(t, k8serrors.IsInvalid(err), "expected invalid, got %v", err)
+				require.Contains(t, err.Error(), tc.invalid)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSigninValidationHandler_ValidateUpdate(t *testing.T) {
+	validator := newTestValidator()
+	old := newTestSignin(nil)
+
+	_, err := validator.ValidateUpdate(requestContext("alice"), old, newTestSignin(func(s *v1alpha1.TkaSignin) { s.Spec.Role = "cluster-admin" }))
+	require.True(t, k8serrors.IsForbidden(err), "expected forbidden, got %v", err)
+
+	// Updates that only finalize a deletion must never be blocked
+	deletedAt := &metav1.Time{Time: time.Now()}
+	deleting := newTestSignin(func(s *v1alpha1.TkaSignin) {
+		s.DeletionTimestamp = deletedAt
+		s.Finalizers = []string{metav1.FinalizerDeleteDependents}
+	})
+	finalized := newTestSignin(func(s *v1alpha1.TkaSignin) { s.DeletionTimestamp = deletedAt })
+	_, err = validator.ValidateUpdate(requestContext("system:serviceaccount:kube-system:generic-garbage-collector"), deleting, finalized)
+	require.NoError(t, err)
+
+	// Other changes to a sign-in being deleted are still reserved to the server
+	escalated := newTestSignin(func(s *v1alpha1.TkaSignin) {
+		s.DeletionTimestamp = deletedAt
+		s.Spec.Role = "cluster-admin"
+	})
+	_, err = validator.ValidateUpdate(requestContext("alice"), deleting, escalated)
+	require.True(t, k8serrors.IsForbidden(err), "expected forbidden, got %v", err)
+
+	_, err = validator.ValidateUpdate(requestContext(serverServiceAccount), deleting, escalated)
+	require.True(t, k8serrors.IsInvalid(err), "expected invalid, got %v", err)
+}
+
+func TestSigninValidationHandler_ValidateDelete(t *testing.T) {
+	_, err := newTestValidator().ValidateDelete(requestContext("alice"), newTestSignin(nil))
+	require.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
+	globalModels "github.com/spechtlabs/tka/pkg/models"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// suspendSession handles suspending another user's session
+// @Summary       Suspend a user's session
+// @Description   Temporarily withdraws a user's access by removing their bindings, without ending the session. Requires a capability rule with admin set.
+// @Tags          admin
+// @Produce       application/json
+// @Param         username    path      string                    true  "Username of the session to suspend"
+// @Success       200         {object}  models.UserLoginResponse  "OK - The session is suspended"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - The caller is not an administrator"
+// @Failure       404         {object}  models.ErrorResponse      "Not Found - The user is not signed in"
+// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error updating the session"
+// @Router        /api/v1alpha1/admin/sessions/{username}/suspend [post]
+// @Security      TailscaleAuth
+func (t *TKAServer) suspendSession(ct *gin.Context) {
+	t.setSuspended(ct, true)
+}
+
+// resumeSession handles resuming another user's suspended session
+// @Summary       Resume a user's session
+// @Description   Restores the bindings of a suspended session. The session still expires at its usual time. Requires a capability rule with admin set.
+// @Tags          admin
+// @Produce       application/json
+// @Param         username    path      string                    true  "Username of the session to resume"
+// @Success       200         {object}  models.UserLoginResponse  "OK - The session is resumed"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - The caller is not an administrator"
+// @Failure       404         {object}  models.ErrorResponse      "Not Found - The user is not signed in"
+// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error updating the session"
+// @Router        /api/v1alpha1/admin/sessions/{username}/resume [post]
+// @Security      TailscaleAuth
+func (t *TKAServer) resumeSession(ct *gin.Context) {
+	t.setSuspended(ct, false)
+}
+
+//nolint:golint-sl // Logs are in mutually exclusive error branches, only one executes per request
+func (t *TKAServer) setSuspended(ct *gin.Context, suspended bool) {
+	admin := mwauth.GetUsername(ct)
+	userName := ct.Param("username")
+
+	ctx, span := t.tracer.Start(ct.Request.Context(), "TKAServer.setSuspended")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("admin.username", admin),
+		attribute.String("admin.target", userName),
+		attribute.Bool("admin.suspended", suspended),
+	)
+
+	if capRule := mwauth.GetCapability[capability.Rule](ct); capRule == nil || !capRule.Admin {
+		span.SetStatus(codes.Error, "not an administrator")
+		ct.JSON(http.StatusForbidden, globalModels.NewErrorResponse("Only administrators may suspend or resume sessions", nil))
+		return
+	}
+
+	signIn, err := t.client.GetStatus(ctx, userName)
+	if err == nil {
+		err = t.client.SetSuspended(ctx, userName, suspended)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, "error updating session")
+		span.RecordError(err)
+		otelzap.L().WithError(err).ErrorContext(ctx, "Error suspending or resuming session", zap.String("username", userName))
+		writeHumaneError(ct, err, http.StatusNotFound)
+		return
+	}
+
+	otelzap.L().InfoContext(ctx, "Session suspension changed",
+		zap.String("username", userName),
+		zap.String("admin", admin),
+		zap.Bool("suspended", suspended),
+	)
+
+	resp := models.NewUserLoginResponse(signIn.Username, signIn.Role, signIn.ValidUntil)
+	resp.Suspended = suspended
+	ct.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/client/k8s/mock"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminSuspendResume(t *testing.T) {
+	tests := []struct {
+		name              string
+		rule              capability.Rule
+		action            string
+		statusErr         humane.Error
+		suspendErr        humane.Error
+		expectedStatus    int
+		expectedMessage   string
+		expectedSuspended bool
+	}{
+		{
+			name:              "admin suspends",
+			rule:              capability.Rule{Role: "cluster-admin", Period: "10m", Admin: true},
+			action:            "suspend",
+			expectedStatus:    http.StatusOK,
+			expectedSuspended: true,
+		},
+		{
+			name:           "admin resumes",
+			rule:           capability.Rule{Role: "cluster-admin", Period: "10m", Admin: true},
+			action:         "resume",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "non-admin -> 403",
+			rule:            capability.Rule{Role: "dev", Period: "10m"},
+			action:          "suspend",
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "Only administrators may suspend or resume sessions",
+		},
+		{
+			name:            "target not signed in -> 404",
+			rule:            capability.Rule{Role: "cluster-admin", Period: "10m", Admin: true},
+			action:          "suspend",
+			statusErr:       noSigninError,
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "no signin",
+		},
+		{
+			name:            "update error -> 500",
+			rule:            capability.Rule{Role: "cluster-admin", Period: "10m", Admin: true},
+			action:          "resume",
+			suspendErr:      humane.New("fail", "check server logs for details"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "fail",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			_, ts := newTestServer(t, m, tc.rule)
+
+			var gotUser string
+			var gotSuspended *bool
+			m.StatusFn = func(username string) (*k8s.SignInInfo, humane.Error) {
+				if tc.statusErr != nil {
+					return nil, tc.statusErr
+				}
+				return &k8s.SignInInfo{Username: username, Role: "dev", ValidUntil: time.Now().Add(time.Hour).Format(time.RFC3339), Provisioned: true}, nil
+			}
+			m.SuspendFn = func(username string, suspended bool) humane.Error {
+				gotUser, gotSuspended = username, &suspended
+				return tc.suspendErr
+			}
+
+			path := api.ApiRouteV1Alpha1 + api.AdminSessionsApiRoute + "/bob@example.com/" + tc.action
+			resp, body := doReq(t, ts, http.MethodPost, path, nil, nil)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+				return
+			}
+
+			require.Equal(t, "bob@example.com", gotUser)
+			require.NotNil(t, gotSuspended)
+			require.Equal(t, tc.expectedSuspended, *gotSuspended)
+
+			var out models.UserLoginResponse
+			require.NoError(t, json.Unmarshal(body, &out))
+			require.Equal(t, "bob@example.com", out.Username)
+			require.Equal(t, tc.expectedSuspended, out.Suspended)
+		})
+	}
+}
@@ -26,5 +26,5 @@ func (t *TKAServer) getClusterInfo(c *gin.Context) {
 	_, span := t.tracer.Start(req.Context(), "TKAServer.getClusterInfo")
 	defer span.End()
 
-	c.JSON(http.StatusOK, t.clusterInfo)
+	c.JSON(http.StatusOK, t.clusterInfo.Load())
 }
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
+	globalModels "github.com/spechtlabs/tka/pkg/models"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// DefaultDelegationPeriod is how long a delegated credential is valid if the request does not say.
+const DefaultDelegationPeriod = 15 * time.Minute
+
+// delegate handles deriving a restricted credential from the session of the authenticated user
+// @Summary       Delegate a restricted credential
+// @Description   Creates a separate credential derived from the authenticated user's session, e.g. for a script. It may be restricted to a role that grants no more than the session's role and to a single namespace. It never outlives the session and is revoked together with it.
+// @Tags          delegation
+// @Accept        application/json
+// @Produce       application/json
+// @Param         request     body      models.UserDelegateRequest  false  "Restrictions of the delegated credential"
+// @Success       202         {object}  models.UserLoginResponse    "Accepted - The delegated credential is being provisioned"
+// @Failure       400         {object}  models.ErrorResponse        "Bad Request - Invalid duration"
+// @Failure       403         {object}  models.ErrorResponse        "Forbidden - Request from Funnel, device requirements not met or the role grants more than the session"
+// @Failure       404         {object}  models.ErrorResponse        "Not Found - User not signed in or namespace does not exist"
+// @Failure       409         {object}  models.ErrorResponse        "Conflict - Session not provisioned yet"
+// @Failure       422         {object}  models.ErrorResponse        "Unprocessable Entity - The ClusterRole does not exist"
+// @Failure       423         {object}  models.ErrorResponse        "Locked - The session is suspended by an administrator"
+// @Failure       500         {object}  models.ErrorResponse        "Internal Server Error - Error creating the delegated sign-in"
+// @Router        /api/v1alpha1/delegations [post]
+// @Security      TailscaleAuth
+//
+//nolint:golint-sl // Logs are in mutually exclusive error branches, only one executes per request
+func (t *TKAServer) delegate(ct *gin.Context) {
+	userName := mwauth.GetUsername(ct)
+
+	ctx, span := t.tracer.Start(ct.Request.Context(), "TKAServer.delegate")
+	defer span.End()
+	span.SetAttributes(attribute.String("delegate.username", userName))
+
+	opts, err := delegateOptions(ct)
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid delegate request")
+		span.RecordError(err)
+		writeHumaneError(ct, err, http.StatusNotFound)
+		return
+	}
+
+	info, err := t.client.Delegate(ctx, userName, opts)
+	if err != nil {
+		span.SetStatus(codes.Error, "error delegating credential")
+		span.RecordError(err)
+		otelzap.L().WithError(err).WarnContext(ctx, "Error delegating credential", zap.String("username", userName))
+		writeHumaneError(ct, err, http.StatusNotFound)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("delegate.delegation", info.Username),
+		attribute.String("delegate.role", info.Role),
+		attribute.String("delegate.namespace", info.Namespace),
+		attribute.String("delegate.valid_until", info.ValidUntil),
+	)
+	otelzap.L().InfoContext(ctx, "Credential delegated",
+		zap.String("username", userName),
+		zap.String("delegation", info.Username),
+		zap.String("role", info.Role),
+		zap.String("namespace", info.Namespace),
+		zap.String("valid_until", info.ValidUntil),
+	)
+
+	resp := models.NewUserLoginResponse(info.Username, info.Role, info.ValidUntil)
+	resp.Namespace = info.Namespace
+	ct.JSON(http.StatusAccepted, resp)
+}
+
+// delegateOptions reads the delegate request body. Every field is optional.
+func delegateOptions(ct *gin.Context) (k8s.DelegateOptions, humane.Error) {
+	var req models.UserDelegateRequest
+	if err := ct.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		return k8s.DelegateOptions{}, humane.Wrap(fmt.Errorf("%w: %w", errInvalidRequest, err), "Invalid delegate request body", `send a JSON object like {"role": "view", "namespace": "team-a", "for": "15m"}`)
+	}
+
+	opts := k8s.DelegateOptions{Role: req.Role, Namespace: req.Namespace, Period: DefaultDelegationPeriod}
+	if req.For != "" {
+		period, err := time.ParseDuration(req.For)
+		if err != nil || period < k8s.MinSigninValidity {
+			return opts, humane.Wrap(errInvalidRequest, fmt.Sprintf("Invalid duration %q", req.For), "use a duration of at least "+k8s.MinSigninValidity.String())
+		}
+		opts.Period = period
+	}
+
+	return opts, nil
+}
+
+// getDelegatedKubeconfig handles retrieving the kubeconfig of a delegated credential
+// @Summary       Get the kubeconfig of a delegated credential
+// @Description   Returns the kubeconfig of a credential the authenticated user delegated from their session
+// @Tags          delegation
+// @Produce       application/yaml
+// @Produce       application/json
+// @Param         username    path      string                    true  "Username of the delegated credential"
+// @Success       200         {file}    string                    "OK - Returns kubeconfig file"
+// @Header        200         {string}  X-Tka-Token-Expires-At    "RFC3339 expiry of the embedded token, if it expires before the delegation"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel or device requirements not met"
+// @Failure       404         {object}  models.ErrorResponse      "Not Found - No such delegation of the user"
+// @Failure       423         {object}  models.ErrorResponse      "Locked - The session is suspended by an administrator"
+// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error generating kubeconfig"
+// @Header        202         {integer} Retry-After               "Seconds until next poll recommended"
+// @Router        /api/v1alpha1/delegations/{username}/kubeconfig [get]
+// @Security      TailscaleAuth
+func (t *TKAServer) getDelegatedKubeconfig(ct *gin.Context) {
+	userName := mwauth.GetUsername(ct)
+	delegation := ct.Param("username")
+
+	ctx, span := t.tracer.Start(ct.Request.Context(), "TKAServer.getDelegatedKubeconfig")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("kubeconfig.username", userName),
+		attribute.String("kubeconfig.delegation", delegation),
+	)
+
+	// Only the user who delegated the credential may fetch it
+	info, err := t.client.GetStatus(ctx, delegation)
+	if err != nil || info.Parent != k8s.FormatSigninObjectName(userName) {
+		span.SetStatus(codes.Error, "delegation not found")
+		ct.JSON(http.StatusNotFound, globalModels.NewErrorResponse("Delegation not found", nil))
+		return
+	}
+
+	t.writeKubeconfig(ctx, ct, span, delegation)
+}
@@ -0,0 +1,142 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/client/k8s/mock"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/stretchr/testify/require"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDelegateHandler(t *testing.T) {
+	notDelegable := humane.Wrap(fmt.Errorf("%w: test", k8s.ErrRoleNotDelegable), "ClusterRole \"admin\" grants permissions your session does not have")
+
+	tests := []struct {
+		name            string
+		body            any
+		delegateErr     humane.Error
+		expectedStatus  int
+		expectedMessage string
+		expectedOpts    k8s.DelegateOptions
+	}{
+		{
+			name:           "defaults",
+			expectedStatus: http.StatusAccepted,
+			expectedOpts:   k8s.DelegateOptions{Period: api.DefaultDelegationPeriod},
+		},
+		{
+			name:           "restricted",
+			body:           models.UserDelegateRequest{Role: "view", Namespace: "foo", For: "30m"},
+			expectedStatus: http.StatusAccepted,
+			expectedOpts:   k8s.DelegateOptions{Role: "view", Namespace: "foo", Period: 30 * time.Minute},
+		},
+		{
+			name:            "invalid duration -> 400",
+			body:            models.UserDelegateRequest{For: "soon"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `Invalid duration "soon"`,
+		},
+		{
+			name:            "duration too short -> 400",
+			body:            models.UserDelegateRequest{For: "1m"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `Invalid duration "1m"`,
+		},
+		{
+			name:            "broader role -> 403",
+			body:            models.UserDelegateRequest{Role: "admin"},
+			delegateErr:     notDelegable,
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "ClusterRole \"admin\" grants permissions your session does not have",
+		},
+		{
+			name:            "not signed in -> 404",
+			delegateErr:     noSigninError,
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "no signin",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			_, ts := newTestServer(t, m, capability.Rule{Role: "edit", Period: "1h"})
+
+			var gotOpts k8s.DelegateOptions
+			m.DelegateFn = func(username string, opts k8s.DelegateOptions) (*k8s.SignInInfo, humane.Error) {
+				require.Equal(t, "alice", username)
+				gotOpts = opts
+				if tc.delegateErr != nil {
+					return nil, tc.delegateErr
+				}
+				return &k8s.SignInInfo{Username: "alice-delegate-abcde", Role: "view", Namespace: opts.Namespace, ValidUntil: "2030-01-01T00:00:00Z"}, nil
+			}
+
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.DelegationsApiRoute, nil, tc.body)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+				return
+			}
+
+			require.Equal(t, tc.expectedOpts, gotOpts)
+
+			var out models.UserLoginResponse
+			require.NoError(t, json.Unmarshal(body, &out))
+			require.Equal(t, "alice-delegate-abcde", out.Username)
+			require.Equal(t, tc.expectedOpts.Namespace, out.Namespace)
+			require.Equal(t, "2030-01-01T00:00:00Z", out.Until)
+		})
+	}
+}
+
+func TestDelegatedKubeconfigHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		parent         string
+		statusErr      humane.Error
+		expectedStatus int
+	}{
+		{name: "own delegation", parent: k8s.FormatSigninObjectName("alice"), expectedStatus: http.StatusOK},
+		{name: "delegation of another user -> 404", parent: k8s.FormatSigninObjectName("bob"), expectedStatus: http.StatusNotFound},
+		{name: "not a delegation -> 404", expectedStatus: http.StatusNotFound},
+		{name: "unknown delegation -> 404", statusErr: noSigninError, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			_, ts := newTestServer(t, m, capability.Rule{Role: "edit", Period: "1h"})
+
+			m.StatusFn = func(username string) (*k8s.SignInInfo, humane.Error) {
+				require.Equal(t, "alice-delegate-abcde", username)
+				return &k8s.SignInInfo{Username: username, Parent: tc.parent, Provisioned: true}, tc.statusErr
+			}
+			var gotUser string
+			m.KubeconfigFn = func(username string) (*clientcmdapi.Config, time.Time, humane.Error) {
+				gotUser = username
+				return clientcmdapi.NewConfig(), time.Time{}, nil
+			}
+
+			path := api.ApiRouteV1Alpha1 + api.DelegationsApiRoute + "/alice-delegate-abcde/kubeconfig"
+			resp, body := doReq(t, ts, http.MethodGet, path, nil, nil)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+
+			if tc.expectedStatus == http.StatusOK {
+				require.Equal(t, "alice-delegate-abcde", gotUser)
+			} else {
+				require.Empty(t, gotUser)
+				requireErrorMessage(t, body, "Delegation not found")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
+	globalModels "github.com/spechtlabs/tka/pkg/models"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxExtensionPerDay is the default for how much time a user's session extensions may add per day.
+const DefaultMaxExtensionPerDay = 8 * time.Hour
+
+// extendLogin handles extending the current session of a user
+// @Summary       Extend the current session
+// @Description   Pushes the end of the authenticated user's session forward without signing them out, keeping their credentials. The session ends at most the grant's period from now, and extensions add up to at most the server's daily limit across all of the user's sessions. Extensions are checked by the reason validator and the pre-signin webhook like sign-ins.
+// @Tags          authentication
+// @Accept        application/json
+// @Produce       application/json
+// @Param         request     body      models.UserExtendRequest  false  "Optional duration to extend the session by"
+// @Success       200         {object}  models.UserLoginResponse  "OK - The session was extended"
+// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Invalid duration or the session's reason is no longer accepted"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel, no capability rule or scheduled shift found, device requirements not met or denied by the pre-signin webhook"
+// @Failure       404         {object}  models.ErrorResponse      "Not Found - User not signed in"
+// @Failure       409         {object}  models.ErrorResponse      "Conflict - Session not provisioned yet, its role is no longer granted or it cannot be extended further"
+// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error loading schedules or updating the session"
+// @Failure       503         {object}  models.ErrorResponse      "Service Unavailable - The reason could not be validated or the pre-signin webhook failed"
+// @Router        /api/v1alpha1/login/extend [post]
+// @Security      TailscaleAuth
+//
+//nolint:golint-sl // Logs are in mutually exclusive error branches, only one executes per request
+func (t *TKAServer) extendLogin(ct *gin.Context) {
+	userName := mwauth.GetUsername(ct)
+
+	ctx, span := t.tracer.Start(ct.Request.Context(), "TKAServer.extendLogin")
+	defer span.End()
+	span.SetAttributes(attribute.String("extend.username", userName))
+
+	capRule, _, err := t.shiftGrant(ctx, userName, loginName(ct), mwauth.GetCapability[capability.Rule](ct), time.Now(), 0)
+	if err != nil {
+		span.SetStatus(codes.Error, "error loading schedules")
+		span.RecordError(err)
+		otelzap.L().WithError(err).ErrorContext(ctx, "Error loading schedules", zap.String("username", userName))
+		writeHumaneError(ct, err, http.StatusInternalServerError)
+		return
+	}
+
+	if capRule == nil {
+		span.SetStatus(codes.Error, "no capability rule found")
+		ct.JSON(http.StatusForbidden, globalModels.NewErrorResponse("No grant found for user", nil))
+		return
+	}
+
+	opts, err := t.extendOptions(ct, capRule)
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid extend request")
+		span.RecordError(err)
+		writeHumaneError(ct, err, http.StatusNotFound)
+		return
+	}
+
+	sessionRole, err := t.authorizeExtension(ctx, ct, userName, capRule, &opts)
+	if err != nil {
+		span.SetStatus(codes.Error, "extension denied")
+		span.RecordError(err)
+		otelzap.L().WithError(err).WarnContext(ctx, "Session extension denied", zap.String("username", userName), zap.String("role", opts.Role))
+		writeHumaneError(ct, err, http.StatusForbidden)
+		return
+	}
+
+	until, err := t.client.ExtendSignIn(ctx, userName, opts)
+	if err != nil {
+		span.SetStatus(codes.Error, "error extending session")
+		span.RecordError(err)
+		otelzap.L().WithError(err).WarnContext(ctx, "Error extending session", zap.String("username", userName))
+		writeHumaneError(ct, err, http.StatusNotFound)
+		return
+	}
+
+	span.SetAttributes(attribute.String("extend.valid_until", until.Format(time.RFC3339)))
+	otelzap.L().InfoContext(ctx, "Session extended",
+		zap.String("username", userName),
+		zap.String("role", sessionRole),
+		zap.String("valid_until", until.Format(time.RFC3339)),
+	)
+
+	ct.JSON(http.StatusOK, models.NewUserLoginResponse(userName, sessionRole, until.Format(time.RFC3339)))
+}
+
+// extendOptions reads the optional extend request body and combines it with the limits of the capability rule and the server.
+func (t *TKAServer) extendOptions(ct *gin.Context, capRule *capability.Rule) (k8s.ExtendOptions, humane.Error) {
+	opts := k8s.ExtendOptions{Role: capRule.Role, MaxPerDay: t.maxExtendPerDay}
+
+	period, err := time.ParseDuration(capRule.Period)
+	if err != nil {
+		return opts, humane.Wrap(err, "Error parsing duration", "check the period of your capability rule")
+	}
+	opts.MaxPeriod = period
+
+	var req models.UserExtendRequest
+	if err := ct.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		return opts, humane.Wrap(fmt.Errorf("%w: %w", errInvalidRequest, err), "Invalid extend request body", `send a JSON object like {"by": "30m"}`)
+	}
+
+	if req.By != "" {
+		by, err := time.ParseDuration(req.By)
+		if err != nil || by <= 0 {
+			return opts, humane.Wrap(errInvalidRequest, fmt.Sprintf("Invalid duration %q", req.By), "use a positive duration like 30m or 1h")
+		}
+		opts.By = by
+	}
+
+	return opts, nil
+}
+
+// authorizeExtension puts an extension through the checks of a sign-in, as it keeps the session going without one:
+// the session's reason must still pass the reason validator, e.g. while its ticket is open, and the pre-signin
+// webhook must still allow the session. The webhook may shorten the extension and lower the role it allows, like
+// it does for sign-ins; ExtendSignIn then only extends the session if that role still covers the session's role.
+// It returns the role of the session.
+func (t *TKAServer) authorizeExtension(ctx context.Context, ct *gin.Context, userName string, capRule *capability.Rule, opts *k8s.ExtendOptions) (string, humane.Error) {
+	info, herr := t.client.GetStatus(ctx, userName)
+	if herr != nil {
+		return "", herr
+	}
+
+	sessionRole := capRule.Role
+	var signInReason string
+	if info != nil {
+		sessionRole = info.Role
+		signInReason = info.Reason
+	}
+
+	if signInReason == "" {
+		if capRule.RequireReason {
+			return "", reason.NewRequiredError(capRule.Role)
+		}
+	} else if herr := t.reasonValidator.Validate(ctx, reason.Request{Username: userName, Role: capRule.Role, Reason: signInReason}); herr != nil {
+		return "", herr
+	}
+
+	if t.preSignin == nil {
+		return sessionRole, nil
+	}
+
+	decision, herr := t.preSignin.Authorize(ctx, preSigninRequest(userName, mwauth.GetWhoIs(ct), opts.Role, opts.MaxPeriod, signInReason))
+	if herr != nil {
+		return "", herr
+	}
+
+	opts.Role = decision.Role
+	opts.MaxPeriod = min(opts.MaxPeriod, decision.Period)
+
+	return sessionRole, nil
+}
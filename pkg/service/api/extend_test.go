@@ -0,0 +1,251 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/client/k8s/mock"
+	"github.com/spechtlabs/tka/pkg/service/api"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendHandler(t *testing.T) {
+	limitErr := humane.Wrap(fmt.Errorf("%w: test", k8s.ErrExtensionLimit), "Your session cannot be extended any further")
+
+	tests := []struct {
+		name            string
+		rule            capability.Rule
+		body            any
+		extendErr       humane.Error
+		expectedStatus  int
+		expectedMessage string
+		expectedOpts    k8s.ExtendOptions
+	}{
+		{
+			name:           "extends to the grant period",
+			rule:           capability.Rule{Role: "dev", Period: "1h"},
+			expectedStatus: http.StatusOK,
+			expectedOpts:   k8s.ExtendOptions{Role: "dev", MaxPeriod: time.Hour, MaxPerDay: 8 * time.Hour},
+		},
+		{
+			name:           "extends by the requested duration",
+			rule:           capability.Rule{Role: "dev", Period: "1h"},
+			body:           models.UserExtendRequest{By: "30m"},
+			expectedStatus: http.StatusOK,
+			expectedOpts:   k8s.ExtendOptions{Role: "dev", By: 30 * time.Minute, MaxPeriod: time.Hour, MaxPerDay: 8 * time.Hour},
+		},
+		{
+			name:            "no grant -> 403",
+			rule:            capability.Rule{},
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "No grant found for user",
+		},
+		{
+			name:            "invalid duration -> 400",
+			rule:            capability.Rule{Role: "dev", Period: "1h"},
+			body:            models.UserExtendRequest{By: "soon"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `Invalid duration "soon"`,
+		},
+		{
+			name:            "limit reached -> 409",
+			rule:            capability.Rule{Role: "dev", Period: "1h"},
+			extendErr:       limitErr,
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "Your session cannot be extended any further",
+		},
+		{
+			name:            "not provisioned -> 409",
+			rule:            capability.Rule{Role: "dev", Period: "1h"},
+			extendErr:       k8s.NotReadyYetError,
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: k8s.NotReadyYetError.Error(),
+		},
+		{
+			name:            "not signed in -> 404",
+			rule:            capability.Rule{Role: "dev", Period: "1h"},
+			extendErr:       noSigninError,
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "no signin",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			_, ts := newTestServer(t, m, tc.rule, api.WithMaxExtensionPerDay(8*time.Hour))
+
+			until := time.Now().Add(time.Hour).Truncate(time.Second)
+			var gotOpts k8s.ExtendOptions
+			m.ExtendFn = func(username string, opts k8s.ExtendOptions) (time.Time, humane.Error) {
+				require.Equal(t, "alice", username)
+				gotOpts = opts
+				return until, tc.extendErr
+			}
+
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.ExtendApiRoute, nil, tc.body)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+				return
+			}
+
+			require.Equal(t, tc.expectedOpts, gotOpts)
+
+			var out models.UserLoginResponse
+			require.NoError(t, json.Unmarshal(body, &out))
+			require.Equal(t, "alice", out.Username)
+			require.Equal(t, "dev", out.Role)
+			require.Equal(t, until.Format(time.RFC3339), out.Until)
+		})
+	}
+}
+
+func TestExtendHandlerShift(t *testing.T) {
+	m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+	_, ts := newTestServer(t, m, capability.Rule{})
+
+	now := time.Now()
+	m.ShiftFn = func(_ string, _ time.Time) (*k8s.Shift, humane.Error) {
+		return &k8s.Shift{Schedule: "sre", Role: "oncall", Start: now.Add(-time.Hour), End: now.Add(2 * time.Hour)}, nil
+	}
+
+	var gotOpts k8s.ExtendOptions
+	m.ExtendFn = func(_ string, opts k8s.ExtendOptions) (time.Time, humane.Error) {
+		gotOpts = opts
+		return now.Add(2 * time.Hour), nil
+	}
+
+	resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.ExtendApiRoute, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	require.Equal(t, "oncall", gotOpts.Role)
+	require.GreaterOrEqual(t, gotOpts.MaxPeriod, 2*time.Hour)
+}
+
+func TestExtendHandlerChecks(t *testing.T) {
+	rule := capability.Rule{Role: "dev", Period: "1h"}
+
+	tests := []struct {
+		name              string
+		rule              capability.Rule
+		sessionRole       string
+		reason            string
+		authorize         preSigninFunc
+		expectedStatus    int
+		expectedMessage   string
+		expectedMaxPeriod time.Duration
+		expectedRole      string
+	}{
+		{
+			name:              "allowed",
+			rule:              rule,
+			reason:            "INC-1234",
+			expectedStatus:    http.StatusOK,
+			expectedMaxPeriod: time.Hour,
+		},
+		{
+			name:            "reason no longer accepted -> 400",
+			rule:            rule,
+			reason:          "just because",
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "The reason does not reference a valid ticket",
+		},
+		{
+			name:            "reason required -> 400",
+			rule:            capability.Rule{Role: "dev", Period: "1h", RequireReason: true},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `A reason is required to sign in as "dev"`,
+		},
+		{
+			name:   "webhook shortens the extension",
+			rule:   rule,
+			reason: "INC-1234",
+			authorize: func(_ context.Context, req presignin.Request) (presignin.Decision, humane.Error) {
+				require.Equal(t, presignin.Request{Username: "alice", Role: "dev", Period: "1h0m0s", Reason: "INC-1234"}, req)
+				return presignin.Decision{Role: req.Role, Period: 15 * time.Minute}, nil
+			},
+			expectedStatus:    http.StatusOK,
+			expectedMaxPeriod: 15 * time.Minute,
+		},
+		{
+			name:   "webhook denies -> 403",
+			rule:   rule,
+			reason: "INC-1234",
+			authorize: func(context.Context, presignin.Request) (presignin.Decision, humane.Error) {
+				return presignin.Decision{}, humane.Wrap(presignin.ErrDenied, "alice is not on call")
+			},
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "alice is not on call",
+		},
+		{
+			name:        "session the webhook signed in with a lower role",
+			rule:        rule,
+			sessionRole: "view",
+			reason:      "INC-1234",
+			authorize: func(_ context.Context, req presignin.Request) (presignin.Decision, humane.Error) {
+				require.Equal(t, "dev", req.Role, "the webhook must see the request a sign-in would make")
+				return presignin.Decision{Role: "view", Period: time.Hour}, nil
+			},
+			expectedStatus:    http.StatusOK,
+			expectedMaxPeriod: time.Hour,
+			expectedRole:      "view",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			sessionRole := "dev"
+			if tc.sessionRole != "" {
+				sessionRole = tc.sessionRole
+			}
+			m.StatusFn = func(string) (*k8s.SignInInfo, humane.Error) {
+				return &k8s.SignInInfo{Username: "alice", Role: sessionRole, Reason: tc.reason}, nil
+			}
+			var gotOpts *k8s.ExtendOptions
+			m.ExtendFn = func(_ string, opts k8s.ExtendOptions) (time.Time, humane.Error) {
+				gotOpts = &opts
+				return time.Now().Add(time.Hour), nil
+			}
+
+			opts := []api.Option{api.WithReasonValidator(reason.NewValidator(reason.WithPattern(regexp.MustCompile(`^INC-[0-9]+`))))}
+			if tc.authorize != nil {
+				opts = append(opts, api.WithPreSigninAuthorizer(tc.authorize))
+			}
+			_, ts := newTestServer(t, m, tc.rule, opts...)
+
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.ExtendApiRoute, nil, nil)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+				require.Nil(t, gotOpts, "denied extensions must not touch the session")
+				return
+			}
+
+			require.NotNil(t, gotOpts)
+			require.Equal(t, tc.expectedMaxPeriod, gotOpts.MaxPeriod)
+
+			// The role the webhook allows is passed on, ExtendSignIn checks that it covers the session's role
+			expectedRole := "dev"
+			if tc.expectedRole != "" {
+				expectedRole = tc.expectedRole
+			}
+			require.Equal(t, expectedRole, gotOpts.Role)
+
+			var out models.UserLoginResponse
+			require.NoError(t, json.Unmarshal(body, &out))
+			require.Equal(t, sessionRole, out.Role)
+		})
+	}
+}
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
 	"github.com/spechtlabs/tka/pkg/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// errInvalidRequest marks request bodies that cannot be parsed.
+var errInvalidRequest = errors.New("invalid request")
+
 // writeHumaneError writes a humane.Error as a JSON models.ErrorResponse with a mapped status code.
 // notFoundStatus allows handlers to override the HTTP status for NotFound conditions (e.g., 401 vs 404).
 func writeHumaneError(c *gin.Context, err humane.Error, notFoundStatus int) {
@@ -25,6 +32,21 @@ func writeHumaneError(c *gin.Context, err humane.Error, notFoundStatus int) {
 		} else {
 			status = http.StatusNotFound
 		}
+	} else if errors.Is(cause, k8s.ErrRoleNotFound) {
+		// The grant is valid but points at a ClusterRole that does not exist
+		status = http.StatusUnprocessableEntity
+	} else if errors.Is(cause, reason.ErrRejected) || errors.Is(cause, errInvalidRequest) {
+		status = http.StatusBadRequest
+	} else if errors.Is(cause, k8s.ErrSessionSuspended) {
+		// Locked rather than Forbidden: the session continues once it is resumed
+		status = http.StatusLocked
+	} else if err == k8s.NotReadyYetError || errors.Is(cause, k8s.ErrExtensionLimit) || errors.Is(cause, k8s.ErrGrantChanged) || k8serrors.IsConflict(cause) {
+		// The request is fine but the session is not in a state that allows it
+		status = http.StatusConflict
+	} else if errors.Is(cause, presignin.ErrDenied) || errors.Is(cause, k8s.ErrRoleNotDelegable) || errors.Is(cause, k8s.ErrRoleNotCovered) {
+		status = http.StatusForbidden
+	} else if errors.Is(cause, reason.ErrUnavailable) || errors.Is(cause, presignin.ErrUnavailable) {
+		status = http.StatusServiceUnavailable
 	}
 
 	c.JSON(status, models.FromHumaneError(err))
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spechtlabs/go-otel-utils/otelzap"
@@ -11,6 +13,7 @@ import (
 	_ "github.com/spechtlabs/tka/pkg/models"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"sigs.k8s.io/yaml"
 )
@@ -22,15 +25,16 @@ import (
 // @Produce       application/yaml
 // @Produce       application/json
 // @Success       200         {file}    string                    "OK - Returns kubeconfig file"
-// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not supported or error unmarshaling capability or multiple capability rules"
-// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel or no capability rule found"
+// @Header        200         {string}  X-Tka-Token-Expires-At    "RFC3339 expiry of the embedded token, if it expires before the session"
+// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not allowed or error unmarshaling capability or multiple capability rules"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel, no capability rule found or device requirements not met"
 // @Failure       404         {object}  models.ErrorResponse      "Not Found - User not authenticated or credentials not ready"
+// @Failure       422         {object}  models.ErrorResponse      "Unprocessable Entity - The ClusterRole of the sign-in does not exist"
+// @Failure       423         {object}  models.ErrorResponse      "Locked - The session is suspended by an administrator"
 // @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error with WhoIs or generating kubeconfig"
 // @Header        202         {integer} Retry-After               "Seconds until next poll recommended"
 // @Router        /api/v1alpha1/kubeconfig [get]
 // @Security      TailscaleAuth
-//
-//nolint:golint-sl // Logs are in mutually exclusive branches (not_ready vs error), only one executes per request
 func (t *TKAServer) getKubeconfig(ct *gin.Context) {
 	req := ct.Request
 	userName := mwauth.GetUsername(ct)
@@ -41,9 +45,16 @@ func (t *TKAServer) getKubeconfig(ct *gin.Context) {
 	// Set initial span attributes
 	span.SetAttributes(attribute.String("kubeconfig.username", userName))
 
-	if kubecfg, err := t.client.GetKubeconfig(ctx, userName); err != nil || kubecfg == nil { //nolint:golint-sl // kubecfg used in else branch below
+	t.writeKubeconfig(ctx, ct, span, userName)
+}
+
+// writeKubeconfig responds with the kubeconfig of the given user, or 202 while it is still being provisioned.
+//
+//nolint:golint-sl // Logs are in mutually exclusive branches (not_ready vs error), only one executes per request
+func (t *TKAServer) writeKubeconfig(ctx context.Context, ct *gin.Context, span trace.Span, userName string) {
+	if kubecfg, expiresAt, err := t.client.GetKubeconfig(ctx, userName); err != nil || kubecfg == nil { //nolint:golint-sl // kubecfg used in else branch below
 		// Include Retry-After for other async/provisioning flows as a hint
-		ct.Header("Retry-After", strconv.Itoa(t.retryAfterSeconds))
+		ct.Header("Retry-After", strconv.Itoa(t.retryAfterSeconds()))
 
 		// If the operator indicates credentials are not ready yet, return 202
 		if err == k8s.NotReadyYetError {
@@ -73,6 +84,12 @@ func (t *TKAServer) getKubeconfig(ct *gin.Context) {
 			attribute.Int("kubeconfig.http_status", http.StatusOK),
 		)
 
+		// Tell clients when to re-fetch a short-lived token
+		if !expiresAt.IsZero() {
+			span.SetAttributes(attribute.String("kubeconfig.token_expires_at", expiresAt.Format(time.RFC3339)))
+			ct.Header(TokenExpiresAtHeader, expiresAt.Format(time.RFC3339))
+		}
+
 		// Content negotiation: YAML if explicitly requested, otherwise JSON
 		if acceptsYAML(ct) {
 			span.SetAttributes(attribute.String("kubeconfig.format", "yaml"))
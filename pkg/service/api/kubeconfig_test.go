@@ -3,6 +3,7 @@ package api_test
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
 	client "github.com/spechtlabs/tka/pkg/client/k8s"
@@ -18,6 +19,7 @@ func TestGetKubeconfigHandler(t *testing.T) {
 	_, ts := newTestServer(t, m, capability.Rule{Role: "dev", Period: "10m"})
 
 	cfg := &clientcmdapi.Config{Kind: "Config", APIVersion: "v1", CurrentContext: "x"}
+	expiry := time.Now().Add(15 * time.Minute).Truncate(time.Second)
 
 	tests := []struct {
 		name            string
@@ -28,11 +30,12 @@ func TestGetKubeconfigHandler(t *testing.T) {
 		expectedCT      string
 		contains        string
 		expectedMessage string
+		expectedExpiry  string
 	}{
 		{
 			name: "success JSON",
 			setup: func(m *mock.MockTkaClient) client.TkaClient {
-				m.KubeconfigFn = func(string) (*clientcmdapi.Config, humane.Error) { return cfg, nil }
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) { return cfg, time.Time{}, nil }
 				return m
 			},
 			expectedStatus: http.StatusOK,
@@ -41,7 +44,7 @@ func TestGetKubeconfigHandler(t *testing.T) {
 		{
 			name: "success YAML",
 			setup: func(m *mock.MockTkaClient) client.TkaClient {
-				m.KubeconfigFn = func(string) (*clientcmdapi.Config, humane.Error) { return cfg, nil }
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) { return cfg, time.Time{}, nil }
 				return m
 			},
 			headers:        map[string]string{"Accept": "application/yaml"},
@@ -49,21 +52,43 @@ func TestGetKubeconfigHandler(t *testing.T) {
 			expectedCT:     "application/yaml",
 			contains:       "kind:",
 		},
+		{
+			name: "short-lived token exposes expiry",
+			setup: func(m *mock.MockTkaClient) client.TkaClient {
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) { return cfg, expiry, nil }
+				return m
+			},
+			expectedStatus: http.StatusOK,
+			expectedCT:     "application/json",
+			expectedExpiry: expiry.Format(time.RFC3339),
+		},
 		{
 			name: "not found -> 401",
 			setup: func(m *mock.MockTkaClient) client.TkaClient {
-				m.KubeconfigFn = func(string) (*clientcmdapi.Config, humane.Error) { return nil, noSigninError }
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) { return nil, time.Time{}, noSigninError }
 				return m
 			},
 			expectedStatus:  http.StatusUnauthorized,
 			expectRetry:     true,
 			expectedMessage: "no signin",
 		},
+		{
+			name: "suspended -> 423",
+			setup: func(m *mock.MockTkaClient) client.TkaClient {
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) {
+					return nil, time.Time{}, client.NewSessionSuspendedError("alice")
+				}
+				return m
+			},
+			expectedStatus:  http.StatusLocked,
+			expectRetry:     true,
+			expectedMessage: "Session suspended",
+		},
 		{
 			name: "generic error -> 500",
 			setup: func(m *mock.MockTkaClient) client.TkaClient {
-				m.KubeconfigFn = func(string) (*clientcmdapi.Config, humane.Error) {
-					return nil, humane.New("boom", "check server logs for details")
+				m.KubeconfigFn = func(string) (*clientcmdapi.Config, time.Time, humane.Error) {
+					return nil, time.Time{}, humane.New("boom", "check server logs for details")
 				}
 				return m
 			},
@@ -90,6 +115,7 @@ func TestGetKubeconfigHandler(t *testing.T) {
 			if tc.expectedMessage != "" {
 				requireErrorMessage(t, body, tc.expectedMessage)
 			}
+			require.Equal(t, tc.expectedExpiry, resp.Header.Get(api.TokenExpiresAtHeader))
 		})
 	}
 }
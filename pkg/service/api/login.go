@@ -1,16 +1,25 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sierrasoftworks/humane-errors-go"
 	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
 	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
 	globalModels "github.com/spechtlabs/tka/pkg/models"
 	"github.com/spechtlabs/tka/pkg/service/capability"
 	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/spechtlabs/tka/pkg/tshttp"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.uber.org/zap"
@@ -18,15 +27,17 @@ import (
 
 // login handles user authentication through Tailscale for the TKA service
 // @Summary       Authenticate user and provision Kubernetes credentials
-// @Description   Authenticates a user through Tailscale, validates their capability rule, and provisions Kubernetes credentials
+// @Description   Authenticates a user through Tailscale, validates their capability rule or scheduled shift, and provisions Kubernetes credentials
 // @Tags          authentication
 // @Accept        application/json
 // @Produce       application/json
+// @Param         request     body      models.UserLoginRequest   false  "Optional reason for signing in"
 // @Success       202         {object}  models.UserLoginResponse  "Accepted - User authenticated and credentials are being provisioned"
-// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not supported or error unmarshaling capability or multiple capability rules"
-// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel or no capability rule found"
-// @Failure       422         {object}  models.ErrorResponse      "Unprocessable Entity - Invalid capability rule (period too short)"
-// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error with WhoIs, parsing duration, or signing in user"
+// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not allowed, error unmarshaling capability, multiple capability rules, or missing or rejected reason"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel, no capability rule or scheduled shift found, device requirements not met or denied by the pre-signin webhook"
+// @Failure       422         {object}  models.ErrorResponse      "Unprocessable Entity - Invalid capability rule (period too short) or ClusterRole does not exist"
+// @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error with WhoIs, loading schedules, parsing duration, or signing in user"
+// @Failure       503         {object}  models.ErrorResponse      "Service Unavailable - The reason could not be validated, the pre-signin webhook failed or the server is disconnected from the tailnet"
 // @Router        /api/v1alpha1/login [post]
 // @Security      TailscaleAuth
 //
@@ -42,6 +53,19 @@ func (t *TKAServer) login(ct *gin.Context) {
 	// Set initial span attributes
 	span.SetAttributes(attribute.String("login.username", userName))
 
+	now := time.Now() //nolint:golint-sl // captures request timestamp for valid_until calculation
+
+	// Scheduled shifts grant access in addition to the capability rules
+	capRule, shift, herr := t.shiftGrant(ctx, userName, loginName(ct), capRule, now, t.shiftLeadTime)
+	if herr != nil {
+		span.SetAttributes(attribute.String("login.status", "error"))
+		span.SetStatus(codes.Error, "error loading schedules")
+		span.RecordError(herr)
+		otelzap.L().WithError(herr).ErrorContext(ctx, "Error loading schedules", zap.String("username", userName))
+		writeHumaneError(ct, herr, http.StatusInternalServerError)
+		return
+	}
+
 	if capRule == nil {
 		span.SetAttributes(
 			attribute.String("login.status", "forbidden"),
@@ -52,12 +76,11 @@ func (t *TKAServer) login(ct *gin.Context) {
 			zap.String("username", userName),
 			zap.Int("http_status", http.StatusForbidden),
 		)
-		loginAttempts.WithLabelValues(userName, "unknown", "forbidden").Inc()
+		loginAttempts.WithLabelValues(t.metricsUsername(userName), "unknown", "forbidden").Inc()
 		ct.JSON(http.StatusForbidden, globalModels.NewErrorResponse("No grant found for user", nil))
 		return
 	}
 
-	now := time.Now() //nolint:golint-sl // captures request timestamp for valid_until calculation
 	role := capRule.Role
 	span.SetAttributes(attribute.String("login.role", role))
 
@@ -73,37 +96,179 @@ func (t *TKAServer) login(ct *gin.Context) {
 
 	span.SetAttributes(attribute.String("login.period", period.String()))
 
-	if err := t.client.NewSignIn(ctx, userName, role, period); err != nil {
+	opts, err := signInOptions(capRule, mwauth.GetWhoIs(ct))
+	if err != nil {
+		span.SetAttributes(attribute.String("login.status", "error"))
+		span.SetStatus(codes.Error, "error parsing token TTL")
+		span.RecordError(err)
+		otelzap.L().WithError(err).ErrorContext(ctx, "Error parsing token TTL")
+		ct.JSON(http.StatusInternalServerError, globalModels.NewErrorResponse("Error parsing token TTL", err))
+		return
+	}
+
+	signInReason, herr := t.loginReason(ct, userName, capRule)
+	if herr != nil {
+		span.SetAttributes(attribute.String("login.status", "reason_rejected"))
+		span.SetStatus(codes.Error, "sign-in reason rejected")
+		span.RecordError(herr)
+		otelzap.L().WithError(herr).WarnContext(ctx, "Sign-in reason rejected", zap.String("username", userName), zap.String("role", role))
+		loginAttempts.WithLabelValues(t.metricsUsername(userName), role, "reason_rejected").Inc()
+		writeHumaneError(ct, herr, http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(attribute.String("login.reason", signInReason))
+	opts = append(opts, k8s.WithReason(signInReason), k8s.WithShift(shift))
+	if shift != nil {
+		span.SetAttributes(attribute.String("login.schedule", shift.Schedule))
+	}
+
+	if t.preSignin != nil {
+		decision, herr := t.preSignin.Authorize(ctx, preSigninRequest(userName, mwauth.GetWhoIs(ct), role, period, signInReason))
+		if herr != nil {
+			span.SetAttributes(attribute.String("login.status", "denied"))
+			span.SetStatus(codes.Error, "sign-in denied by pre-signin webhook")
+			span.RecordError(herr)
+			otelzap.L().WithError(herr).WarnContext(ctx, "Sign-in denied by pre-signin webhook", zap.String("username", userName), zap.String("role", role))
+			loginAttempts.WithLabelValues(t.metricsUsername(userName), role, "denied").Inc()
+			writeHumaneError(ct, herr, http.StatusForbidden)
+			return
+		}
+
+		// The webhook may only downgrade the sign-in, see presignin.Response
+		if herr := t.client.CheckRoleCovered(ctx, role, decision.Role); herr != nil {
+			span.SetAttributes(attribute.String("login.status", "denied"))
+			span.SetStatus(codes.Error, "pre-signin webhook returned a role beyond the granted one")
+			span.RecordError(herr)
+			otelzap.L().WithError(herr).ErrorContext(ctx, "Pre-signin webhook returned a role beyond the granted one",
+				zap.String("username", userName), zap.String("role", role), zap.String("webhook_role", decision.Role))
+			loginAttempts.WithLabelValues(t.metricsUsername(userName), role, "denied").Inc()
+			writeHumaneError(ct, herr, http.StatusForbidden)
+			return
+		}
+
+		role, period = decision.Role, decision.Period
+		span.SetAttributes(attribute.String("login.role", role), attribute.String("login.period", period.String()))
+	}
+
+	if err := t.client.NewSignIn(ctx, userName, role, period, opts...); err != nil {
 		span.SetAttributes(attribute.String("login.status", "error"))
 		span.SetStatus(codes.Error, "error signing in user")
 		span.RecordError(err)
 		otelzap.L().WithError(err).ErrorContext(ctx, "Error signing in user")
-		loginAttempts.WithLabelValues(userName, role, "error").Inc()
+		loginAttempts.WithLabelValues(t.metricsUsername(userName), role, "error").Inc()
 		writeHumaneError(ct, err, http.StatusNotFound)
 		return
 	}
 
 	// Set success attributes
-	until := now.Add(period).Format(time.RFC3339)
+	notBefore, notAfter := shift.Bounds()
+	start, end := k8s.AccessWindow(now, period, notBefore, notAfter)
+	until := end.Format(time.RFC3339)
 	span.SetAttributes(
 		attribute.String("login.status", "success"),
 		attribute.String("login.valid_until", until),
 	)
 
 	// Track successful login metrics
-	loginAttempts.WithLabelValues(userName, role, "success").Inc()
+	loginAttempts.WithLabelValues(t.metricsUsername(userName), role, "success").Inc()
+	otelzap.L().InfoContext(ctx, "User signed in",
+		zap.String("username", userName),
+		zap.String("role", role),
+		zap.String("period", period.String()),
+		zap.String("reason", signInReason),
+	)
+
+	resp := models.NewUserLoginResponse(userName, role, until)
+	if start.After(now) {
+		resp.NotBefore = start.Format(time.RFC3339)
+	}
+	ct.JSON(http.StatusAccepted, resp)
+}
+
+// requireTailnet rejects the request while the server is disconnected from the tailnet, see WithRejectLoginsWhileDisconnected.
+func (t *TKAServer) requireTailnet(ct *gin.Context) {
+	if t.tailnet == nil || t.tailnet.IsConnected() {
+		ct.Next()
+		return
+	}
+
+	state := t.tailnet.BackendState()
+	otelzap.L().WarnContext(ct.Request.Context(), "Rejecting login while disconnected from the tailnet",
+		zap.String("username", mwauth.GetUsername(ct)),
+		zap.String("backend_state", state),
+	)
+	ct.Header("Retry-After", strconv.Itoa(t.retryAfterSeconds()))
+	ct.AbortWithStatusJSON(http.StatusServiceUnavailable, globalModels.NewErrorResponse("The server is disconnected from the tailnet ("+state+"), try again later", nil))
+}
 
-	ct.JSON(http.StatusAccepted, models.NewUserLoginResponse(userName, role, until))
+// signInOptions translates the optional settings of a capability rule and the caller's device into sign-in options.
+func signInOptions(capRule *capability.Rule, who *tshttp.WhoIsInfo) ([]k8s.SignInOption, error) {
+	opts := []k8s.SignInOption{k8s.WithDevice(who)}
+	if who != nil {
+		opts = append(opts, k8s.WithLoginName(who.LoginName))
+	}
+
+	if capRule.TokenTTL != "" {
+		ttl, err := time.ParseDuration(capRule.TokenTTL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, k8s.WithTokenTTL(ttl))
+	}
+
+	return opts, nil
+}
+
+// preSigninRequest describes a sign-in for the pre-signin webhook.
+func preSigninRequest(userName string, who *tshttp.WhoIsInfo, role string, period time.Duration, signInReason string) presignin.Request {
+	req := presignin.Request{Username: userName, Role: role, Period: period.String(), Reason: signInReason}
+	if who != nil {
+		req.Device = presignin.Device{
+			Name:          who.NodeName,
+			ID:            who.NodeID,
+			OS:            who.OS,
+			ClientVersion: who.ClientVersion,
+			Tags:          who.Tags,
+			Attributes:    who.NodeAttributes,
+		}
+	}
+
+	return req
+}
+
+// loginReason reads the reason from the optional login request body and checks it against
+// the capability rule and the server's reason validator.
+func (t *TKAServer) loginReason(ct *gin.Context, userName string, capRule *capability.Rule) (string, humane.Error) {
+	var req models.UserLoginRequest
+	if err := ct.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		return "", humane.Wrap(fmt.Errorf("%w: %w", reason.ErrRejected, err), "Invalid login request body", `send a JSON object like {"reason": "INC-1234 investigating pods"}`)
+	}
+
+	signInReason := strings.TrimSpace(req.Reason)
+	if signInReason == "" {
+		if capRule.RequireReason {
+			return "", reason.NewRequiredError(capRule.Role)
+		}
+		return "", nil
+	}
+
+	if err := t.reasonValidator.Validate(ct.Request.Context(), reason.Request{Username: userName, Role: capRule.Role, Reason: signInReason}); err != nil {
+		return "", err
+	}
+
+	return signInReason, nil
 }
 
 // getLogin handles retrieving login status through Tailscale for the TKA service
 // @Summary       Get user authentication status
-// @Description   Retrieves the current authentication status for a Tailscale user
+// @Description   Retrieves the current authentication status for a Tailscale user, including the permissions granted once provisioned
 // @Tags          authentication
 // @Produce       application/json
 // @Success       200         {object}  models.UserLoginResponse  "OK - Returns the current user authentication status"
-// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not supported or error unmarshaling capability or multiple capability rules"
-// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel or no capability rule found"
+// @Failure       400         {object}  models.ErrorResponse      "Bad Request - Tagged nodes not allowed or error unmarshaling capability or multiple capability rules"
+// @Failure       403         {object}  models.ErrorResponse      "Forbidden - Request from Funnel, no capability rule found or device requirements not met"
+// @Failure       422         {object}  models.ErrorResponse      "Unprocessable Entity - The ClusterRole of the sign-in does not exist"
 // @Failure       500         {object}  models.ErrorResponse      "Internal Server Error - Error with WhoIs or retrieving user status"
 // @Header        202         {integer} Retry-After               "Seconds until next poll recommended"
 // @Router        /api/v1alpha1/login [get]
@@ -139,7 +304,7 @@ func (t *TKAServer) getLogin(ct *gin.Context) {
 
 		if !signIn.Provisioned {
 			status = http.StatusAccepted
-			ct.Header("Retry-After", strconv.Itoa(t.retryAfterSeconds))
+			ct.Header("Retry-After", strconv.Itoa(t.retryAfterSeconds()))
 
 			validity, err := time.ParseDuration(signIn.ValidityPeriod)
 			if err != nil {
@@ -150,7 +315,8 @@ func (t *TKAServer) getLogin(ct *gin.Context) {
 				ct.JSON(http.StatusInternalServerError, globalModels.NewErrorResponse("Error parsing duration", err))
 				return
 			}
-			until = time.Now().Add(validity).Format(time.RFC3339)
+			_, end := k8s.AccessWindow(time.Now(), validity, signIn.NotBefore, signIn.NotAfter)
+			until = end.Format(time.RFC3339)
 		}
 
 		span.SetAttributes(
@@ -159,7 +325,14 @@ func (t *TKAServer) getLogin(ct *gin.Context) {
 			attribute.Int("get_login.http_status", status),
 		)
 
-		ct.JSON(status, models.NewUserLoginResponse(signIn.Username, signIn.Role, until))
+		resp := models.NewUserLoginResponse(signIn.Username, signIn.Role, until)
+		resp.Permissions = signIn.Permissions
+		resp.Suspended = signIn.Suspended
+		resp.LastActivity = signIn.LastActivity
+		if notBefore, err := time.Parse(time.RFC3339, signIn.NotBefore); err == nil && notBefore.After(time.Now()) {
+			resp.NotBefore = signIn.NotBefore
+		}
+		ct.JSON(status, resp)
 		return
 	}
 }
@@ -1,17 +1,29 @@
 package api_test
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/api/v1alpha1"
 	"github.com/spechtlabs/tka/pkg/client/k8s"
 	"github.com/spechtlabs/tka/pkg/client/k8s/mock"
+	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
 	"github.com/spechtlabs/tka/pkg/service/api"
 	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	tsMock "github.com/spechtlabs/tka/pkg/tshttp/mock"
 	"github.com/stretchr/testify/require"
+	"tailscale.com/tailcfg"
 )
 
 func TestLoginHandler(t *testing.T) {
@@ -41,6 +53,29 @@ func TestLoginHandler(t *testing.T) {
 			},
 			expectedStatus: http.StatusAccepted,
 		},
+		{
+			name: "rule token TTL is passed to the sign-in",
+			rule: capability.Rule{Role: "cluster-admin", Period: period, TokenTTL: "10m"},
+			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
+				m.SignInFn = nil
+				m.SignInSpecFn = func(signIn *v1alpha1.TkaSignin) {
+					require.Equal(t, "15m0s", signIn.Spec.ValidityPeriod)
+					require.Equal(t, "10m0s", signIn.Spec.TokenTTL)
+				}
+				return m
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "invalid token TTL",
+			rule: capability.Rule{Role: "dev", Period: "1h", TokenTTL: "garbage"},
+			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
+				m.SignInSpecFn = nil
+				return m
+			},
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "Error parsing token TTL",
+		},
 		{
 			name:           "no cap rule",
 			rule:           capability.Rule{},
@@ -64,6 +99,16 @@ func TestLoginHandler(t *testing.T) {
 			expectedStatus:  http.StatusNotFound,
 			expectedMessage: "missing",
 		},
+		{
+			name: "missing role maps to 422",
+			rule: rule,
+			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
+				m.SignInFn = func(_ string, r string, _ time.Duration) humane.Error { return k8s.NewRoleNotFoundError(r) }
+				return m
+			},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedMessage: `ClusterRole "cluster-admin" does not exist`,
+		},
 		{
 			name: "signin generic error maps to 500",
 			rule: rule,
@@ -101,22 +146,43 @@ func TestLoginHandler(t *testing.T) {
 
 func TestGetLoginHandler(t *testing.T) {
 	tests := []struct {
-		name            string
-		setup           func(m *mock.MockTkaClient) k8s.TkaClient
-		expectedStatus  int
-		expectRetry     bool
-		expectedMessage string
+		name                 string
+		setup                func(m *mock.MockTkaClient) k8s.TkaClient
+		expectedStatus       int
+		expectRetry          bool
+		expectedMessage      string
+		expectedPermissions  int
+		expectedLastActivity string
 	}{
 		{
 			name: "provisioned true -> 200",
 			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
 				m.StatusFn = func(string) (*k8s.SignInInfo, humane.Error) {
-					return &k8s.SignInInfo{Username: "alice", Role: "dev", ValidUntil: time.Now().Add(10 * time.Minute).Format(time.RFC3339), Provisioned: true}, nil
+					return &k8s.SignInInfo{
+						Username:     "alice",
+						Role:         "dev",
+						ValidUntil:   time.Now().Add(10 * time.Minute).Format(time.RFC3339),
+						Provisioned:  true,
+						Permissions:  []models.PermissionRule{{Verbs: []string{"get"}, Resources: []string{"pods"}}},
+						LastActivity: "2026-01-01T10:04:59Z",
+					}, nil
 				}
 
 				return m
 			},
-			expectedStatus: http.StatusOK,
+			expectedStatus:       http.StatusOK,
+			expectedPermissions:  1,
+			expectedLastActivity: "2026-01-01T10:04:59Z",
+		},
+		{
+			name: "missing role -> 422",
+			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
+				m.StatusFn = func(string) (*k8s.SignInInfo, humane.Error) { return nil, k8s.NewRoleNotFoundError("dev") }
+
+				return m
+			},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedMessage: `ClusterRole "dev" does not exist`,
 		},
 		{
 			name: "not provisioned -> 202 with Retry-After",
@@ -180,6 +246,409 @@ func TestGetLoginHandler(t *testing.T) {
 			if tc.expectedMessage != "" {
 				requireErrorMessage(t, body, tc.expectedMessage)
 			}
+			if tc.expectedPermissions > 0 {
+				var got models.UserLoginResponse
+				require.NoError(t, json.Unmarshal(body, &got))
+				require.Len(t, got.Permissions, tc.expectedPermissions)
+				require.Equal(t, tc.expectedLastActivity, got.LastActivity)
+			}
+		})
+	}
+}
+
+func TestLoginHandlerReason(t *testing.T) {
+	tests := []struct {
+		name            string
+		rule            capability.Rule
+		body            any
+		expectedStatus  int
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:           "reason is recorded on the sign-in",
+			rule:           capability.Rule{Role: "cluster-admin", Period: "15m"},
+			body:           models.UserLoginRequest{Reason: "  INC-1234 investigating pods  "},
+			expectedStatus: http.StatusAccepted,
+			expectedReason: "INC-1234 investigating pods",
+		},
+		{
+			name:           "reason is optional by default",
+			rule:           capability.Rule{Role: "cluster-admin", Period: "15m"},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:            "missing required reason",
+			rule:            capability.Rule{Role: "cluster-admin", Period: "15m", RequireReason: true},
+			body:            models.UserLoginRequest{Reason: "   "},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `A reason is required to sign in as "cluster-admin"`,
+		},
+		{
+			name:            "reason too long",
+			rule:            capability.Rule{Role: "cluster-admin", Period: "15m"},
+			body:            models.UserLoginRequest{Reason: strings.Repeat("x", reason.MaxLength+1)},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: fmt.Sprintf("The reason may not be longer than %d characters", reason.MaxLength),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient()
+			var got string
+			m.(*mock.MockTkaClient).SignInSpecFn = func(signIn *v1alpha1.TkaSignin) {
+				got = signIn.Spec.Reason
+			}
+
+			_, ts := newTestServer(t, m, tc.rule)
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute, nil, tc.body)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			require.Equal(t, tc.expectedReason, got)
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+			}
+		})
+	}
+}
+
+type preSigninFunc func(ctx context.Context, req presignin.Request) (presignin.Decision, humane.Error)
+
+func (f preSigninFunc) Authorize(ctx context.Context, req presignin.Request) (presignin.Decision, humane.Error) {
+	return f(ctx, req)
+}
+
+func TestLoginHandlerPreSignin(t *testing.T) {
+	rule := capability.Rule{Role: "cluster-admin", Period: "1h"}
+
+	tests := []struct {
+		name            string
+		rule            *capability.Rule
+		authorize       preSigninFunc
+		expectedStatus  int
+		expectedRole    string
+		expectedPeriod  string
+		expectedMessage string
+	}{
+		{
+			name: "allowed",
+			authorize: func(_ context.Context, req presignin.Request) (presignin.Decision, humane.Error) {
+				require.Equal(t, presignin.Request{Username: "alice", Role: "cluster-admin", Period: "1h0m0s", Reason: "INC-1234"}, req)
+				return presignin.Decision{Role: req.Role, Period: time.Hour}, nil
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedRole:   "cluster-admin",
+			expectedPeriod: "1h0m0s",
+		},
+		{
+			name: "downgraded",
+			authorize: func(context.Context, presignin.Request) (presignin.Decision, humane.Error) {
+				return presignin.Decision{Role: "view", Period: 15 * time.Minute}, nil
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedRole:   "view",
+			expectedPeriod: "15m0s",
+		},
+		{
+			name: "escalated",
+			rule: &capability.Rule{Role: "view", Period: "1h"},
+			authorize: func(context.Context, presignin.Request) (presignin.Decision, humane.Error) {
+				return presignin.Decision{Role: "cluster-admin", Period: time.Hour}, nil
+			},
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: `ClusterRole "cluster-admin" grants permissions that "view" does not`,
+		},
+		{
+			name: "denied",
+			authorize: func(context.Context, presignin.Request) (presignin.Decision, humane.Error) {
+				return presignin.Decision{}, humane.Wrap(presignin.ErrDenied, "alice is not on call")
+			},
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "alice is not on call",
+		},
+		{
+			name: "webhook unavailable",
+			authorize: func(context.Context, presignin.Request) (presignin.Decision, humane.Error) {
+				return presignin.Decision{}, humane.Wrap(fmt.Errorf("%w: timeout", presignin.ErrUnavailable), "Could not reach the pre-signin webhook")
+			},
+			expectedStatus:  http.StatusServiceUnavailable,
+			expectedMessage: "Could not reach the pre-signin webhook",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient()
+			var gotRole, gotPeriod string
+			m.(*mock.MockTkaClient).SignInSpecFn = func(signIn *v1alpha1.TkaSignin) {
+				gotRole, gotPeriod = signIn.Spec.Role, signIn.Spec.ValidityPeriod
+			}
+			m.(*mock.MockTkaClient).RoleCoveredFn = func(granted, role string) humane.Error {
+				if granted == "view" && role != "view" {
+					return humane.Wrap(k8s.ErrRoleNotCovered, fmt.Sprintf("ClusterRole %q grants permissions that %q does not", role, granted))
+				}
+				return nil
+			}
+
+			capRule := rule
+			if tc.rule != nil {
+				capRule = *tc.rule
+			}
+			_, ts := newTestServer(t, m, capRule, api.WithPreSigninAuthorizer(tc.authorize))
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute, nil, models.UserLoginRequest{Reason: "INC-1234"})
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			require.Equal(t, tc.expectedRole, gotRole)
+			require.Equal(t, tc.expectedPeriod, gotPeriod)
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestLoginHandlerShift(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	active := &k8s.Shift{Schedule: "sre-oncall", Role: "cluster-admin", Start: now.Add(-time.Hour), End: now.Add(2 * time.Hour)}
+	upcoming := &k8s.Shift{Schedule: "sre-oncall", Role: "cluster-admin", Start: now.Add(30 * time.Minute), End: now.Add(8 * time.Hour)}
+
+	tests := []struct {
+		name              string
+		rule              capability.Rule
+		shift             *k8s.Shift
+		shiftErr          humane.Error
+		notCovered        bool
+		expectedStatus    int
+		expectedRole      string
+		expectedNotAfter  string
+		expectedNotBefore bool
+		expectedMessage   string
+	}{
+		{
+			name:            "no rule and no shift",
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "No grant found for user",
+		},
+		{
+			name:             "active shift without a rule",
+			shift:            active,
+			expectedStatus:   http.StatusAccepted,
+			expectedRole:     "cluster-admin",
+			expectedNotAfter: active.End.Format(time.RFC3339),
+		},
+		{
+			name:             "active shift elevates the rule's role",
+			rule:             capability.Rule{Role: "view", Period: "8h"},
+			shift:            active,
+			expectedStatus:   http.StatusAccepted,
+			expectedRole:     "cluster-admin",
+			expectedNotAfter: active.End.Format(time.RFC3339),
+		},
+		{
+			name:           "the rule's role outranks the shift's",
+			rule:           capability.Rule{Role: "admin", Period: "8h"},
+			shift:          active,
+			notCovered:     true,
+			expectedStatus: http.StatusAccepted,
+			expectedRole:   "admin",
+		},
+		{
+			name:           "shift with the rule's role",
+			rule:           capability.Rule{Role: "cluster-admin", Period: "8h"},
+			shift:          active,
+			expectedStatus: http.StatusAccepted,
+			expectedRole:   "cluster-admin",
+		},
+		{
+			name:              "sign-in ahead of a shift",
+			shift:             upcoming,
+			expectedStatus:    http.StatusAccepted,
+			expectedRole:      "cluster-admin",
+			expectedNotAfter:  upcoming.End.Format(time.RFC3339),
+			expectedNotBefore: true,
+		},
+		{
+			name:           "schedules cannot be loaded",
+			rule:           capability.Rule{Role: "view", Period: "1h"},
+			shiftErr:       humane.New("Failed to load schedules", "check that the TKA server may list TkaSchedules"),
+			expectedStatus: http.StatusAccepted,
+			expectedRole:   "view",
+		},
+		{
+			name:            "schedules cannot be loaded without a rule",
+			shiftErr:        humane.New("Failed to load schedules", "check that the TKA server may list TkaSchedules"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "Failed to load schedules",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			m.ShiftFn = func(string, time.Time) (*k8s.Shift, humane.Error) { return tc.shift, tc.shiftErr }
+			m.RoleCoveredFn = func(granted, role string) humane.Error {
+				if tc.notCovered {
+					return humane.Wrap(k8s.ErrRoleNotCovered, "ClusterRole grants permissions that the shift's does not")
+				}
+				return nil
+			}
+			var got *v1alpha1.TkaSignin
+			m.SignInSpecFn = func(signIn *v1alpha1.TkaSignin) { got = signIn }
+
+			_, ts := newTestServer(t, m, tc.rule)
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute, nil, nil)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, body, tc.expectedMessage)
+				require.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			require.Equal(t, tc.expectedRole, got.Spec.Role)
+			require.Equal(t, tc.expectedNotAfter, got.Spec.NotAfter)
+
+			var out models.UserLoginResponse
+			require.NoError(t, json.Unmarshal(body, &out))
+			if tc.expectedNotAfter != "" {
+				require.Equal(t, tc.expectedNotAfter, out.Until)
+			}
+			if tc.expectedNotBefore {
+				require.Equal(t, tc.shift.Start.Format(time.RFC3339), out.NotBefore)
+			} else {
+				require.Empty(t, out.NotBefore)
+			}
+		})
+	}
+}
+
+func TestLoginHandlerShiftWithoutRule(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	active := &k8s.Shift{Schedule: "sre-oncall", Role: "cluster-admin", Start: now.Add(-time.Hour), End: now.Add(2 * time.Hour)}
+	capName := tailcfg.PeerCapability("specht-labs.de/cap/tka")
+	remoteAddr := "100.64.0.1:41641"
+
+	tests := []struct {
+		name            string
+		method          string
+		route           string
+		shift           *k8s.Shift
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			name:           "active shift",
+			method:         http.MethodPost,
+			route:          api.LoginApiRoute,
+			shift:          active,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:            "no shift",
+			method:          http.MethodPost,
+			route:           api.LoginApiRoute,
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "No grant found for user",
+		},
+		{
+			name:            "other routes still need a rule",
+			method:          http.MethodGet,
+			route:           api.KubeconfigApiRoute,
+			shift:           active,
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "User not authorized",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient().(*mock.MockTkaClient)
+			// Schedules list users by their login name, not the TKA username
+			m.ShiftFn = func(loginName string, _ time.Time) (*k8s.Shift, humane.Error) {
+				if loginName != "alice@example.com" {
+					return nil, nil
+				}
+				return tc.shift, nil
+			}
+			var got *v1alpha1.TkaSignin
+			m.SignInSpecFn = func(signIn *v1alpha1.TkaSignin) { got = signIn }
+
+			resolver := tsMock.NewMockWhoIsResolver(tsMock.WithWhoIsResponse(remoteAddr, &tshttp.WhoIsInfo{LoginName: "alice@example.com"}))
+			srv := api.NewTKAServer(
+				api.WithAuthMiddleware(mwauth.NewGinAuthMiddleware(resolver, capName,
+					mwauth.AllowWithoutRules[capability.Rule](http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute),
+				)),
+				api.WithPrometheusMiddleware(sharedPrometheus),
+			)
+			require.NoError(t, srv.LoadApiRoutes(m))
+
+			req := httptest.NewRequest(tc.method, api.ApiRouteV1Alpha1+tc.route, nil)
+			req.RemoteAddr = remoteAddr
+			w := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedMessage != "" {
+				requireErrorMessage(t, w.Body.Bytes(), tc.expectedMessage)
+				require.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			require.Equal(t, "alice", got.Spec.Username)
+			require.Equal(t, active.Role, got.Spec.Role)
+			require.Equal(t, active.End.Format(time.RFC3339), got.Spec.NotAfter)
+		})
+	}
+}
+
+type fakeTailnet struct{ state string }
+
+func (f fakeTailnet) IsConnected() bool    { return f.state == "Running" }
+func (f fakeTailnet) BackendState() string { return f.state }
+
+func TestLoginHandlerTailnet(t *testing.T) {
+	rule := capability.Rule{Role: "view", Period: "1h"}
+
+	tests := []struct {
+		name            string
+		opts            []api.Option
+		expectedStatus  int
+		expectedSignIn  bool
+		expectedMessage string
+	}{
+		{
+			name:           "disconnected without the option",
+			expectedStatus: http.StatusAccepted,
+			expectedSignIn: true,
+		},
+		{
+			name:           "connected",
+			opts:           []api.Option{api.WithRejectLoginsWhileDisconnected(fakeTailnet{state: "Running"})},
+			expectedStatus: http.StatusAccepted,
+			expectedSignIn: true,
+		},
+		{
+			name:            "needs login",
+			opts:            []api.Option{api.WithRejectLoginsWhileDisconnected(fakeTailnet{state: "NeedsLogin"})},
+			expectedStatus:  http.StatusServiceUnavailable,
+			expectedMessage: "The server is disconnected from the tailnet (NeedsLogin), try again later",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mock.NewMockTkaClient()
+			signedIn := false
+			m.(*mock.MockTkaClient).SignInSpecFn = func(*v1alpha1.TkaSignin) { signedIn = true }
+
+			_, ts := newTestServer(t, m, rule, tc.opts...)
+			resp, body := doReq(t, ts, http.MethodPost, api.ApiRouteV1Alpha1+api.LoginApiRoute, nil, nil)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode, string(body))
+			require.Equal(t, tc.expectedSignIn, signedIn)
+			if tc.expectedMessage != "" {
+				require.NotEmpty(t, resp.Header.Get("Retry-After"))
+				requireErrorMessage(t, body, tc.expectedMessage)
+			}
 		})
 	}
 }
@@ -19,9 +19,10 @@ import (
 // @Tags          authentication
 // @Produce       application/json
 // @Success       200         {object}  models.UserLoginResponse       "OK - User successfully logged out with login info"
-// @Failure       400         {object}  models.ErrorResponse           "Bad Request - Tagged nodes not supported or error unmarshaling capability or multiple capability rules"
-// @Failure       403         {object}  models.ErrorResponse           "Forbidden - Request from Funnel or no capability rule found"
+// @Failure       400         {object}  models.ErrorResponse           "Bad Request - Tagged nodes not allowed or error unmarshaling capability or multiple capability rules"
+// @Failure       403         {object}  models.ErrorResponse           "Forbidden - Request from Funnel, no capability rule found or device requirements not met"
 // @Failure       404         {object}  models.ErrorResponse           "Not Found - User not authenticated"
+// @Failure       423         {object}  models.ErrorResponse           "Locked - The session is suspended"
 // @Failure       500         {object}  models.ErrorResponse           "Internal Server Error - Error with WhoIs, parsing duration, or during logout process"
 // @Router        /api/v1alpha1/logout [post]
 // @Security      TailscaleAuth
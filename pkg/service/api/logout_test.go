@@ -67,6 +67,19 @@ func TestLogoutHandler(t *testing.T) {
 			expectedStatus:  http.StatusInternalServerError,
 			expectedMessage: "fail",
 		},
+		{
+			name: "suspended -> 423",
+			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
+				m.StatusFn = func(string) (*k8s.SignInInfo, humane.Error) {
+					return &k8s.SignInInfo{Username: "alice", Role: "dev", ValidUntil: time.Now().Add(30 * time.Minute).Format(time.RFC3339), Provisioned: true, Suspended: true}, nil
+				}
+				m.LogoutFn = func(username string) humane.Error { return k8s.NewSessionSuspendedError(username) }
+
+				return m
+			},
+			expectedStatus:  http.StatusLocked,
+			expectedMessage: "Session suspended",
+		},
 		{
 			name: "invalid duration -> 500",
 			setup: func(m *mock.MockTkaClient) k8s.TkaClient {
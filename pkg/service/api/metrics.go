@@ -11,7 +11,7 @@ var loginAttempts = prometheus.NewCounterVec(
 		Help: "Total number of login attempts by cluster role and outcome",
 	},
 	[]string{
-		"username",
+		"username", // empty unless username metrics are enabled, see WithUsernameMetrics
 		"cluster_role",
 		"outcome", // success, forbidden, error
 	},
@@ -20,3 +20,11 @@ var loginAttempts = prometheus.NewCounterVec(
 func init() {
 	prometheus.MustRegister(loginAttempts)
 }
+
+// metricsUsername returns the username label value for userName, see WithUsernameMetrics.
+func (t *TKAServer) metricsUsername(userName string) string {
+	if t.hideUsernameMetrics {
+		return ""
+	}
+	return userName
+}
@@ -1,8 +1,13 @@
 package api
 
 import (
+	"time"
+
 	mw "github.com/spechtlabs/tka/pkg/middleware"
 	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/spechtlabs/tka/pkg/tshttp"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
 )
 
@@ -25,6 +30,16 @@ type Option func(*TKAServer)
 func WithRetryAfterSeconds(seconds int) Option {
 	return func(tka *TKAServer) {
 		if seconds > 0 {
+			tka.retryAfterSeconds = func() int { return seconds }
+		}
+	}
+}
+
+// WithRetryAfterSecondsFunc is like WithRetryAfterSeconds, but asks seconds for the value of every response,
+// so it can change while the server runs. seconds must return a positive value.
+func WithRetryAfterSecondsFunc(seconds func() int) Option {
+	return func(tka *TKAServer) {
+		if seconds != nil {
 			tka.retryAfterSeconds = seconds
 		}
 	}
@@ -48,12 +63,76 @@ func WithPrometheusMiddleware(p *ginprometheus.Prometheus) Option {
 	}
 }
 
+// WithReasonValidator checks the reasons users give when signing in, e.g. that they reference a valid ticket.
+// By default only the length of reasons is checked.
+func WithReasonValidator(v reason.Validator) Option {
+	return func(tka *TKAServer) {
+		if v != nil {
+			tka.reasonValidator = v
+		}
+	}
+}
+
+// WithPreSigninAuthorizer asks a, e.g. a pre-signin webhook, to approve every sign-in before credentials
+// are provisioned. It can deny the sign-in or downgrade its role and period. Without it, sign-ins are
+// only checked against the capability rules.
+func WithPreSigninAuthorizer(a presignin.Authorizer) Option {
+	return func(tka *TKAServer) {
+		tka.preSignin = a
+	}
+}
+
+// WithShiftLeadTime sets how long before a scheduled shift starts its users may sign in for it.
+// Such sign-ins are provisioned when the shift begins.
+func WithShiftLeadTime(lead time.Duration) Option {
+	return func(tka *TKAServer) {
+		if lead >= 0 {
+			tka.shiftLeadTime = lead
+		}
+	}
+}
+
+// WithMaxExtensionPerDay caps how much time a user's session extensions may add per day (UTC), across
+// all of their sessions. Zero removes the cap; extensions are still bounded by the grant's period.
+func WithMaxExtensionPerDay(limit time.Duration) Option {
+	return func(tka *TKAServer) {
+		if limit >= 0 {
+			tka.maxExtendPerDay = limit
+		}
+	}
+}
+
+// WithUsernameMetrics controls whether metrics carry a username label. On by default; turning it off
+// leaves the label empty, which keeps the number of series independent of the number of users.
+func WithUsernameMetrics(enabled bool) Option {
+	return func(tka *TKAServer) {
+		tka.hideUsernameMetrics = !enabled
+	}
+}
+
+// WithRejectLoginsWhileDisconnected rejects sign-ins with 503 Service Unavailable while tailnet is not
+// connected, e.g. because the server needs to log in again. Without it, sign-ins are accepted in any
+// backend state, although users may not be able to reach the server again to fetch their kubeconfig.
+func WithRejectLoginsWhileDisconnected(tailnet tshttp.TailscaleServer) Option {
+	return func(tka *TKAServer) {
+		tka.tailnet = tailnet
+	}
+}
+
 // WithClusterInfo configures the TKA server with cluster connection information.
 // This information is exposed to authenticated users via the cluster-info API endpoint
 // and is used by clients to configure their kubeconfig files for connecting to the cluster.
 func WithClusterInfo(info *models.TkaClusterInfo) Option {
 	return func(tka *TKAServer) {
-		tka.clusterInfo = info
+		tka.clusterInfo = models.NewClusterInfoStore(info)
+	}
+}
+
+// WithClusterInfoStore is like WithClusterInfo, but serves whatever cluster information the store
+// currently holds, so it can be replaced while the server runs.
+func WithClusterInfoStore(store *models.ClusterInfoStore) Option {
+	return func(tka *TKAServer) {
+		tka.clusterInfo = store
 	}
 }
 
@@ -62,11 +141,11 @@ func WithClusterInfo(info *models.TkaClusterInfo) Option {
 // rather than creating a separate TkaClusterInfo struct.
 func WithNewClusterInfo(serverURL string, caData string, labels map[string]string) Option {
 	return func(tka *TKAServer) {
-		tka.clusterInfo = &models.TkaClusterInfo{
+		tka.clusterInfo = models.NewClusterInfoStore(&models.TkaClusterInfo{
 			ServerURL:             serverURL,
 			CAData:                caData,
 			InsecureSkipTLSVerify: false, // Default to secure TLS verification
 			Labels:                labels,
-		}
+		})
 	}
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	// gin
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,9 @@ import (
 	client "github.com/spechtlabs/tka/pkg/client/k8s"
 	mw "github.com/spechtlabs/tka/pkg/middleware"
 	"github.com/spechtlabs/tka/pkg/service/models"
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/spechtlabs/tka/pkg/tshttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
@@ -32,10 +36,20 @@ const (
 	LoginApiRoute = "/login"
 	// KubeconfigApiRoute is the path for retrieving kubeconfig files.
 	KubeconfigApiRoute = "/kubeconfig"
+	// ExtendApiRoute is the path for extending the current session.
+	ExtendApiRoute = LoginApiRoute + "/extend"
 	// LogoutApiRoute is the path for user logout operations.
 	LogoutApiRoute = "/logout"
 	// ClusterInfoApiRoute is the path for retrieving cluster information.
 	ClusterInfoApiRoute = "/cluster-info"
+	// DelegationsApiRoute is the path for credentials delegated from the current session.
+	DelegationsApiRoute = "/delegations"
+	// AdminSessionsApiRoute is the base path for managing other users' sessions.
+	AdminSessionsApiRoute = "/admin/sessions"
+
+	// TokenExpiresAtHeader carries the RFC3339 expiry of the token in a kubeconfig response.
+	// It is omitted if the token does not expire before the user signs out.
+	TokenExpiresAtHeader = "X-Tka-Token-Expires-At"
 )
 
 // TKAServer represents the main HTTP server for Tailscale Kubernetes Auth.
@@ -61,14 +75,22 @@ type TKAServer struct {
 	router           *gin.Engine
 	tracer           trace.Tracer
 	sharedPrometheus *ginprometheus.Prometheus
-	clusterInfo      *models.TkaClusterInfo
+	clusterInfo      *models.ClusterInfoStore
 
 	// Auth service
 	client         client.TkaClient
 	authMiddleware mw.Middleware
 
 	// API behavior
-	retryAfterSeconds int
+	retryAfterSeconds func() int
+	reasonValidator   reason.Validator
+	preSignin         presignin.Authorizer
+	shiftLeadTime     time.Duration
+	maxExtendPerDay   time.Duration
+	tailnet           tshttp.TailscaleServer // rejects logins while disconnected if set
+
+	// Observability
+	hideUsernameMetrics bool
 }
 
 // NewTKAServer creates a new TKAServer instance with the provided Tailscale server and options.
@@ -104,7 +126,10 @@ func NewTKAServer(opts ...Option) *TKAServer {
 		tracer:            otel.Tracer("tka"),
 		client:            nil,
 		authMiddleware:    nil,
-		retryAfterSeconds: 1,
+		retryAfterSeconds: func() int { return 1 },
+		reasonValidator:   reason.NewValidator(),
+		shiftLeadTime:     client.DefaultShiftLeadTime,
+		maxExtendPerDay:   DefaultMaxExtensionPerDay,
 		sharedPrometheus:  nil,
 		clusterInfo:       nil,
 	}
@@ -144,10 +169,15 @@ func (t *TKAServer) loadStaticRoutes() {
 //   - humane.Error: Error if service is nil or route registration fails
 //
 // Registered endpoints:
-//   - POST /api/v1alpha1/login - Authenticate user and provision credentials
+//   - POST /api/v1alpha1/login - Authenticate user and provision credentials, see WithRejectLoginsWhileDisconnected
 //   - GET /api/v1alpha1/login - Check current authentication status
+//   - POST /api/v1alpha1/login/extend - Extend the current session without signing in again
 //   - GET /api/v1alpha1/kubeconfig - Retrieve kubeconfig for authenticated user
 //   - POST /api/v1alpha1/logout - Revoke user credentials
+//   - POST /api/v1alpha1/delegations - Derive a restricted credential from the current session
+//   - GET /api/v1alpha1/delegations/{username}/kubeconfig - Retrieve the kubeconfig of a delegated credential
+//   - POST /api/v1alpha1/admin/sessions/{username}/suspend - Suspend a user's session (admins only)
+//   - POST /api/v1alpha1/admin/sessions/{username}/resume - Resume a user's session (admins only)
 //
 // Example:
 //
@@ -168,11 +198,16 @@ func (t *TKAServer) LoadApiRoutes(svc client.TkaClient) humane.Error {
 		t.authMiddleware.UseGroup(v1alpha1Grpup, t.tracer)
 	}
 
-	v1alpha1Grpup.POST(LoginApiRoute, t.login)
+	v1alpha1Grpup.POST(LoginApiRoute, t.requireTailnet, t.login)
 	v1alpha1Grpup.GET(LoginApiRoute, t.getLogin)
+	v1alpha1Grpup.POST(ExtendApiRoute, t.extendLogin)
 	v1alpha1Grpup.GET(KubeconfigApiRoute, t.getKubeconfig)
 	v1alpha1Grpup.POST(LogoutApiRoute, t.logout)
 	v1alpha1Grpup.GET(ClusterInfoApiRoute, t.getClusterInfo)
+	v1alpha1Grpup.POST(DelegationsApiRoute, t.delegate)
+	v1alpha1Grpup.GET(DelegationsApiRoute+"/:username/kubeconfig", t.getDelegatedKubeconfig)
+	v1alpha1Grpup.POST(AdminSessionsApiRoute+"/:username/suspend", t.suspendSession)
+	v1alpha1Grpup.POST(AdminSessionsApiRoute+"/:username/resume", t.resumeSession)
 
 	return nil
 }
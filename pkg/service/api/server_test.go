@@ -25,15 +25,15 @@ import (
 
 var sharedPrometheus = ginprometheus.NewPrometheus("tka")
 
-func newTestServer(t *testing.T, auth k8s.TkaClient, rule capability.Rule) (*api.TKAServer, *httptest.Server) {
+func newTestServer(t *testing.T, auth k8s.TkaClient, rule capability.Rule, opts ...api.Option) (*api.TKAServer, *httptest.Server) {
 	t.Helper()
 	gin.SetMode(gin.TestMode)
 	authMwMock := &mwMock.AuthMiddleware{Username: "alice", Rule: rule, OmitRule: rule.Role == "" && rule.Period == ""}
 
-	srv := api.NewTKAServer(
+	srv := api.NewTKAServer(append([]api.Option{
 		api.WithAuthMiddleware(authMwMock),
 		api.WithPrometheusMiddleware(sharedPrometheus),
-	)
+	}, opts...)...)
 
 	if err := srv.LoadApiRoutes(auth); err != nil {
 		t.Fatalf("failed to load api routes: %v", err)
@@ -94,13 +94,18 @@ func TestNewTKAServer_RoutesRegistered(t *testing.T) {
 		Expected bool
 		Seen     bool
 	}{
-		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.LoginApiRoute:     {Expected: true, Seen: false},
-		http.MethodGet + " " + api.ApiRouteV1Alpha1 + api.LoginApiRoute:      {Expected: true, Seen: false},
-		http.MethodGet + " " + api.ApiRouteV1Alpha1 + api.KubeconfigApiRoute: {Expected: true, Seen: false},
-		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.LogoutApiRoute:    {Expected: true, Seen: false},
-		http.MethodGet + " /orchestrator/v1alpha1/clusters":                  {Expected: false, Seen: false},
-		http.MethodPost + " /orchestrator/v1alpha1/clusters":                 {Expected: false, Seen: false},
-		http.MethodGet + " /swagger":                                         {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.LoginApiRoute:                                {Expected: true, Seen: false},
+		http.MethodGet + " " + api.ApiRouteV1Alpha1 + api.LoginApiRoute:                                 {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.ExtendApiRoute:                               {Expected: true, Seen: false},
+		http.MethodGet + " " + api.ApiRouteV1Alpha1 + api.KubeconfigApiRoute:                            {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.LogoutApiRoute:                               {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.DelegationsApiRoute:                          {Expected: true, Seen: false},
+		http.MethodGet + " " + api.ApiRouteV1Alpha1 + api.DelegationsApiRoute + "/:username/kubeconfig": {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.AdminSessionsApiRoute + "/:username/suspend": {Expected: true, Seen: false},
+		http.MethodPost + " " + api.ApiRouteV1Alpha1 + api.AdminSessionsApiRoute + "/:username/resume":  {Expected: true, Seen: false},
+		http.MethodGet + " /orchestrator/v1alpha1/clusters":                                             {Expected: false, Seen: false},
+		http.MethodPost + " /orchestrator/v1alpha1/clusters":                                            {Expected: false, Seen: false},
+		http.MethodGet + " /swagger":                                                                    {Expected: true, Seen: false},
 	}
 
 	for _, r := range s.Engine().Routes() {
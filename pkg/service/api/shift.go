@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	mwauth "github.com/spechtlabs/tka/pkg/middleware/auth"
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"go.uber.org/zap"
+)
+
+// loginName returns the caller's Tailscale login name, e.g. alice@example.com, by which schedules list users.
+func loginName(ct *gin.Context) string {
+	if who := mwauth.GetWhoIs(ct); who != nil {
+		return who.LoginName
+	}
+	return ""
+}
+
+// shiftGrant combines the caller's capability rule with their scheduled shift, see shiftRule. It returns the rule
+// to sign in with and the shift that bounds the sign-in, which is nil unless the shift's role was granted.
+// If the schedules cannot be loaded, callers with a capability rule fall back to it.
+func (t *TKAServer) shiftGrant(ctx context.Context, userName, loginName string, capRule *capability.Rule, now time.Time, lead time.Duration) (*capability.Rule, *k8s.Shift, humane.Error) {
+	shift, herr := t.client.GetShift(ctx, loginName, now, lead)
+	if herr != nil {
+		if capRule == nil {
+			return nil, nil, herr
+		}
+		otelzap.L().WithError(herr).WarnContext(ctx, "Error loading schedules, falling back to the capability rule", zap.String("username", userName))
+		return capRule, nil, nil
+	}
+
+	rule, shift := t.shiftRule(ctx, capRule, shift, now)
+	return rule, shift, nil
+}
+
+// shiftRule combines the caller's capability rule with their scheduled shift. The higher of the two roles is
+// granted: the schedule's role if it covers the role of the capability rule, which then ends with the shift,
+// and the capability rule otherwise. Callers without a capability rule keep the schedule's role until the shift
+// ends. The other settings of the capability rule, e.g. requireReason, still apply.
+//
+//nolint:golint-sl // Logs only if the roles cannot be compared
+func (t *TKAServer) shiftRule(ctx context.Context, capRule *capability.Rule, shift *k8s.Shift, now time.Time) (*capability.Rule, *k8s.Shift) {
+	if shift == nil {
+		return capRule, nil
+	}
+
+	rule := capability.Rule{}
+	if capRule != nil {
+		if capRule.Role == shift.Role {
+			return capRule, nil
+		}
+
+		if herr := t.client.CheckRoleCovered(ctx, shift.Role, capRule.Role); herr != nil {
+			if !errors.Is(herr.Cause(), k8s.ErrRoleNotCovered) {
+				otelzap.L().WithError(herr).WarnContext(ctx, "Error comparing the roles of the capability rule and the shift",
+					zap.String("role", capRule.Role), zap.String("schedule", shift.Schedule), zap.String("schedule_role", shift.Role))
+			}
+			return capRule, nil
+		}
+		rule = *capRule
+	} else {
+		start := now
+		if shift.Start.After(now) {
+			start = shift.Start
+		}
+		// Rounded up to whole minutes; the sign-in's NotAfter ends the access with the shift
+		period := shift.End.Sub(start).Truncate(time.Minute) + time.Minute
+		rule.Period = max(period, k8s.MinSigninValidity).String()
+	}
+	rule.Role = shift.Role
+
+	return &rule, shift
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+)
+
+// CorrelationKey is the field added to audit events made with the credentials of a TKA session.
+const CorrelationKey = "tka"
+
+// Correlation ties an audit event to the person and session behind a TKA-managed ServiceAccount.
+type Correlation struct {
+	// Username is the TKA username the ServiceAccount was created for.
+	Username string `json:"username"`
+	// Session describes the sign-in the request was made with. It is nil if the session ended and its
+	// ServiceAccount is gone, or the ServiceAccount was since replaced by a new session of the same user.
+	Session *k8s.Session `json:"session,omitempty"`
+}
+
+// Correlator enriches audit events with the metadata of the TKA sessions they were made with.
+type Correlator struct {
+	namespace string
+	sessions  map[string]k8s.Session
+}
+
+// NewCorrelator creates a Correlator for the sessions of the ServiceAccounts in namespace, see k8s.ListSessions.
+func NewCorrelator(namespace string, sessions map[string]k8s.Session) *Correlator {
+	return &Correlator{namespace: namespace, sessions: sessions}
+}
+
+// Correlate returns the session behind the event, or nil if it was not made with the credentials of a TKA session.
+func (c *Correlator) Correlate(event *Event) *Correlation {
+	user := event.EffectiveUser()
+	sa, ok := ParseServiceAccount(user.Username)
+	if !ok || sa.Namespace != c.namespace || !strings.HasPrefix(sa.Name, k8s.DefaultUserEntryPrefix) {
+		return nil
+	}
+
+	correlation := &Correlation{Username: strings.TrimPrefix(sa.Name, k8s.DefaultUserEntryPrefix)}
+	if session, ok := c.sessions[sa.Name]; ok && (user.UID == "" || user.UID == string(session.UID)) {
+		correlation.Username = session.Username
+		correlation.Session = &session
+	}
+
+	return correlation
+}
+
+// Enrich copies the audit log in (JSON lines, as written by the API server's log backend) to out and adds
+// the CorrelationKey field to every event made with the credentials of a TKA session. Lines that are not
+// audit events are left out; their number is returned.
+func (c *Correlator) Enrich(in io.Reader, out io.Writer) (int, humane.Error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxBodyBytes)
+
+	skipped := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		enriched, err := c.enrichLine(line)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		if _, err := out.Write(append(enriched, '\n')); err != nil {
+			return skipped, humane.Wrap(err, "Failed to write enriched audit event", "check that the output is writable")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return skipped, humane.Wrap(err, "Failed to read audit log", "check that the file is a JSON lines audit log and readable")
+	}
+
+	return skipped, nil
+}
+
+// enrichLine adds the correlation to a single audit event, keeping all of its fields.
+func (c *Correlator) enrichLine(line []byte) ([]byte, error) {
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, err
+	}
+
+	correlation := c.Correlate(&event)
+	if correlation == nil {
+		return line, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(correlation)
+	if err != nil {
+		return nil, err
+	}
+	fields[CorrelationKey] = raw
+
+	return json.Marshal(fields)
+}
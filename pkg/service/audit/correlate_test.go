@@ -0,0 +1,93 @@
+package audit_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/spechtlabs/tka/pkg/client/k8s"
+	"github.com/spechtlabs/tka/pkg/service/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelator_Enrich(t *testing.T) {
+	alice := k8s.Session{
+		ServiceAccount: "tka-user-alice",
+		UID:            "sa-uid-alice-2",
+		Username:       "alice",
+		LoginName:      "alice@example.com",
+		SessionID:      "0f8e2c6a-signin",
+		Device:         "laptop",
+		DeviceID:       "nXXXX",
+		Reason:         "INC-1234",
+		Role:           "view",
+	}
+	correlator := audit.NewCorrelator("tka-dev", map[string]k8s.Session{alice.ServiceAccount: alice})
+
+	in, err := os.Open("testdata/audit.log")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = in.Close() })
+
+	var out bytes.Buffer
+	skipped, herr := correlator.Enrich(in, &out)
+	require.Nil(t, herr)
+	require.Equal(t, 1, skipped)
+
+	type enriched struct {
+		AuditID string             `json:"auditID"`
+		TKA     *audit.Correlation `json:"tka"`
+	}
+
+	var got []enriched
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e enriched
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		got = append(got, e)
+	}
+
+	// The UID of a ServiceAccount is not part of the output
+	alice.UID = ""
+	require.Equal(t, []enriched{
+		{AuditID: "9c1e0d52-0001", TKA: &audit.Correlation{Username: "alice", Session: &alice}},
+		{AuditID: "9c1e0d52-0002", TKA: &audit.Correlation{Username: "alice"}},
+		{AuditID: "9c1e0d52-0003", TKA: &audit.Correlation{Username: "bob"}},
+		{AuditID: "9c1e0d52-0004"},
+		{AuditID: "9c1e0d52-0005", TKA: &audit.Correlation{Username: "alice", Session: &alice}},
+	}, got)
+}
+
+func TestCorrelator_KeepsOtherEvents(t *testing.T) {
+	line := `{"kind":"Event","auditID":"1","user":{"username":"system:serviceaccount:kube-system:coredns"},"verb":"list"}`
+
+	var out bytes.Buffer
+	skipped, herr := audit.NewCorrelator("tka-dev", nil).Enrich(bytes.NewBufferString(line+"\n"), &out)
+	require.Nil(t, herr)
+	require.Zero(t, skipped)
+	require.Equal(t, line+"\n", out.String())
+}
+
+func TestCorrelator_Correlate(t *testing.T) {
+	correlator := audit.NewCorrelator("tka-dev", nil)
+
+	tests := []struct {
+		name     string
+		username string
+		want     *audit.Correlation
+	}{
+		{name: "tka session", username: "system:serviceaccount:tka-dev:tka-user-alice", want: &audit.Correlation{Username: "alice"}},
+		{name: "delegated credential", username: "system:serviceaccount:tka-dev:tka-user-alice-delegate-x7k2p", want: &audit.Correlation{Username: "alice-delegate-x7k2p"}},
+		{name: "other namespace", username: "system:serviceaccount:default:tka-user-alice"},
+		{name: "other service account", username: "system:serviceaccount:tka-dev:tka-controller"},
+		{name: "person", username: "alice@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, correlator.Correlate(&audit.Event{User: audit.UserInfo{Username: tt.username}}))
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// Package audit receives Kubernetes audit events from the API server's webhook backend
+// and records when the ServiceAccounts backing TKA sign-ins were last used.
+// The operator uses this to revoke sessions that sat idle for too long.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+)
+
+// Recorder stores the last activity of ServiceAccounts.
+type Recorder interface {
+	// RecordActivity notes that the ServiceAccount made a request to the API server at the given time.
+	// It must ignore ServiceAccounts it does not manage.
+	RecordActivity(ctx context.Context, namespace, serviceAccount string, at time.Time) humane.Error
+}
@@ -0,0 +1,27 @@
+package audit
+
+// DefaultPath is where the receiver is served on the local health server.
+const DefaultPath = "/audit/events"
+
+// DefaultMaxBodyBytes is the largest batch of audit events the receiver accepts.
+const DefaultMaxBodyBytes = 16 << 20
+
+// Option configures the Receiver returned by NewReceiver.
+type Option func(*Receiver)
+
+// WithBearerToken requires the API server to authenticate with the given token, set as `token`
+// in the kubeconfig passed to --audit-webhook-config-file. Empty accepts unauthenticated requests.
+func WithBearerToken(token string) Option {
+	return func(r *Receiver) {
+		r.token = token
+	}
+}
+
+// WithMaxBodyBytes limits the size of the batches of audit events the receiver accepts.
+func WithMaxBodyBytes(limit int64) Option {
+	return func(r *Receiver) {
+		if limit > 0 {
+			r.maxBodyBytes = limit
+		}
+	}
+}
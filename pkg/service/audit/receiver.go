@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	globalModels "github.com/spechtlabs/tka/pkg/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// serviceAccountPrefix starts the usernames the API server assigns to ServiceAccounts.
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// EventList is the batch of audit events the API server's webhook backend POSTs (audit.k8s.io/v1).
+// Only the fields needed to track activity are decoded.
+type EventList struct {
+	Kind       string  `json:"kind"`
+	APIVersion string  `json:"apiVersion"`
+	Items      []Event `json:"items"`
+}
+
+// Event is a single audit event.
+type Event struct {
+	AuditID                  string    `json:"auditID"`
+	Stage                    string    `json:"stage"`
+	User                     UserInfo  `json:"user"`
+	ImpersonatedUser         *UserInfo `json:"impersonatedUser,omitempty"`
+	RequestReceivedTimestamp time.Time `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time `json:"stageTimestamp"`
+}
+
+// UserInfo identifies who made a request.
+type UserInfo struct {
+	Username string `json:"username"`
+	UID      string `json:"uid,omitempty"`
+}
+
+// ServiceAccount identifies a ServiceAccount by namespace and name.
+type ServiceAccount struct {
+	Namespace string
+	Name      string
+}
+
+// ParseServiceAccount extracts the ServiceAccount from a username like system:serviceaccount:<namespace>:<name>.
+func ParseServiceAccount(username string) (ServiceAccount, bool) {
+	rest, ok := strings.CutPrefix(username, serviceAccountPrefix)
+	if !ok {
+		return ServiceAccount{}, false
+	}
+
+	namespace, name, ok := strings.Cut(rest, ":")
+	if !ok || namespace == "" || name == "" {
+		return ServiceAccount{}, false
+	}
+
+	return ServiceAccount{Namespace: namespace, Name: name}, true
+}
+
+// EffectiveUser returns who a request was made as: the impersonated user if there is one.
+func (e *Event) EffectiveUser() UserInfo {
+	if e.ImpersonatedUser != nil {
+		return *e.ImpersonatedUser
+	}
+	return e.User
+}
+
+// LastActivity returns the latest request of every ServiceAccount in the events. Impersonated requests
+// count for the impersonated ServiceAccount.
+func LastActivity(events []Event) map[ServiceAccount]time.Time {
+	last := make(map[ServiceAccount]time.Time)
+	for _, event := range events {
+		sa, ok := ParseServiceAccount(event.EffectiveUser().Username)
+		if !ok {
+			continue
+		}
+
+		at := event.StageTimestamp
+		if at.IsZero() {
+			at = event.RequestReceivedTimestamp
+		}
+		if at.After(last[sa]) {
+			last[sa] = at
+		}
+	}
+
+	return last
+}
+
+// Receiver is the HTTP endpoint the API server's audit webhook backend sends events to.
+type Receiver struct {
+	recorder     Recorder
+	tracer       trace.Tracer
+	token        string
+	maxBodyBytes int64
+}
+
+// NewReceiver creates a Receiver that hands the last activity of every ServiceAccount to the recorder.
+func NewReceiver(recorder Recorder, opts ...Option) *Receiver {
+	r := &Receiver{
+		recorder:     recorder,
+		tracer:       otel.Tracer("tka_audit"),
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+//nolint:golint-sl // Logs are in mutually exclusive error branches, only one executes per request
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx, span := r.tracer.Start(req.Context(), "AuditReceiver.ServeHTTP")
+	defer span.End()
+
+	if req.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, globalModels.NewErrorResponse("Audit events must be POSTed", nil))
+		return
+	}
+
+	if !r.authorized(req) {
+		span.SetStatus(codes.Error, "unauthorized")
+		writeJSON(w, http.StatusUnauthorized, globalModels.NewErrorResponse("Invalid bearer token", nil))
+		return
+	}
+
+	var events EventList
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, r.maxBodyBytes)).Decode(&events); err != nil {
+		span.SetStatus(codes.Error, "invalid event list")
+		span.RecordError(err)
+		otelzap.L().WithError(err).WarnContext(ctx, "Received invalid audit events")
+		writeJSON(w, http.StatusBadRequest, globalModels.NewErrorResponse("Invalid audit event list", err))
+		return
+	}
+
+	activity := LastActivity(events.Items)
+	span.SetAttributes(
+		attribute.Int("audit.events", len(events.Items)),
+		attribute.Int("audit.service_accounts", len(activity)),
+	)
+
+	failed := 0
+	for sa, at := range activity {
+		if err := r.recorder.RecordActivity(ctx, sa.Namespace, sa.Name, at); err != nil {
+			failed++
+			span.RecordError(err)
+			otelzap.L().WithError(err).ErrorContext(ctx, "Failed to record activity",
+				zap.String("namespace", sa.Namespace), zap.String("service_account", sa.Name))
+		}
+	}
+
+	// The API server retries the batch, recording activity again is harmless
+	if failed > 0 {
+		span.SetStatus(codes.Error, "failed to record activity")
+		writeJSON(w, http.StatusInternalServerError, globalModels.NewErrorResponse("Failed to record activity", nil))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized checks the bearer token of the request if one is required.
+func (r *Receiver) authorized(req *http.Request) bool {
+	if r.token == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(r.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
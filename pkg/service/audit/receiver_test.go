@@ -0,0 +1,173 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/service/audit"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRecorder remembers the activity handed to it
+type fakeRecorder struct {
+	mu       sync.Mutex
+	activity map[audit.ServiceAccount]time.Time
+	err      humane.Error
+}
+
+func (f *fakeRecorder) RecordActivity(_ context.Context, namespace, serviceAccount string, at time.Time) humane.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.activity == nil {
+		f.activity = make(map[audit.ServiceAccount]time.Time)
+	}
+	f.activity[audit.ServiceAccount{Namespace: namespace, Name: serviceAccount}] = at
+	return f.err
+}
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339Nano, s)
+	require.NoError(t, err)
+	return at
+}
+
+func send(receiver http.Handler, method string, body []byte, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, audit.DefaultPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, req)
+	return w
+}
+
+// replay POSTs the recorded audit events in testdata/events.json to the receiver, like the API server's webhook backend does
+func replay(t *testing.T, receiver http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := os.ReadFile("testdata/events.json")
+	require.NoError(t, err)
+
+	return send(receiver, http.MethodPost, body, token)
+}
+
+func TestReceiver_Replay(t *testing.T) {
+	recorder := &fakeRecorder{}
+	w := replay(t, audit.NewReceiver(recorder), "")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, map[audit.ServiceAccount]time.Time{
+		{Namespace: "tka-dev", Name: "tka-user-alice"}: mustTime(t, "2026-01-01T10:04:59.5Z"),
+		{Namespace: "tka-dev", Name: "tka-user-bob"}:   mustTime(t, "2026-01-01T10:02:00.004Z"),
+		{Namespace: "tka-dev", Name: "tka-user-carol"}: mustTime(t, "2026-01-01T10:01:00.01Z"),
+		{Namespace: "kube-system", Name: "coredns"}:    mustTime(t, "2026-01-01T10:03:00Z"),
+	}, recorder.activity)
+}
+
+func TestReceiver_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		sendToken   string
+		method      string
+		body        string
+		recordErr   humane.Error
+		wantStatus  int
+		wantRecords int
+	}{
+		{
+			name:        "token accepted",
+			token:       "s3cret",
+			sendToken:   "s3cret",
+			wantStatus:  http.StatusOK,
+			wantRecords: 4,
+		},
+		{
+			name:       "token missing",
+			token:      "s3cret",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "token wrong",
+			token:      "s3cret",
+			sendToken:  "guess",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "not a POST",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "invalid body",
+			body:       `{"items": [`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "recording fails",
+			recordErr:   humane.New("conflict", "try again"),
+			wantStatus:  http.StatusInternalServerError,
+			wantRecords: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &fakeRecorder{err: tt.recordErr}
+			receiver := audit.NewReceiver(recorder, audit.WithBearerToken(tt.token))
+
+			var w *httptest.ResponseRecorder
+			switch {
+			case tt.method != "":
+				w = send(receiver, tt.method, nil, tt.sendToken)
+			case tt.body != "":
+				w = send(receiver, http.MethodPost, []byte(tt.body), tt.sendToken)
+			default:
+				w = replay(t, receiver, tt.sendToken)
+			}
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			require.Len(t, recorder.activity, tt.wantRecords)
+		})
+	}
+}
+
+func TestReceiver_MaxBodyBytes(t *testing.T) {
+	recorder := &fakeRecorder{}
+	w := replay(t, audit.NewReceiver(recorder, audit.WithMaxBodyBytes(64)), "")
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Empty(t, recorder.activity)
+}
+
+func TestParseServiceAccount(t *testing.T) {
+	tests := []struct {
+		username string
+		want     audit.ServiceAccount
+		wantOK   bool
+	}{
+		{username: "system:serviceaccount:tka-dev:tka-user-alice", want: audit.ServiceAccount{Namespace: "tka-dev", Name: "tka-user-alice"}, wantOK: true},
+		{username: "alice@example.com"},
+		{username: "system:serviceaccount:tka-dev"},
+		{username: "system:serviceaccount::tka-user-alice"},
+		{username: "system:serviceaccount:tka-dev:"},
+		{username: "system:node:worker-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			got, ok := audit.ParseServiceAccount(tt.username)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
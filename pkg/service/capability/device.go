@@ -0,0 +1,69 @@
+package capability
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"tailscale.com/util/cmpver"
+)
+
+// DeviceRequirements restricts a rule to devices in a certain posture.
+// All requirements that are set must be met for the rule to apply.
+type DeviceRequirements struct {
+	// OS lists the operating systems the device may run (e.g., "macOS", "linux"), compared case-insensitively.
+	OS []string `json:"os,omitempty"`
+	// MinClientVersion is the oldest Tailscale client version allowed (e.g., "1.80.0").
+	MinClientVersion string `json:"minClientVersion,omitempty"`
+	// MinKeyValidity is how long the device's node key must remain valid (e.g., "24h").
+	// Devices with key expiry disabled always meet it.
+	MinKeyValidity string `json:"minKeyValidity,omitempty"`
+	// NodeAttributes lists node attributes the device must have been granted in the ACL's nodeAttrs.
+	NodeAttributes []string `json:"nodeAttributes,omitempty"`
+}
+
+// CheckDevice returns an error explaining which device requirement of the rule is not met.
+func (r Rule) CheckDevice(who *tshttp.WhoIsInfo, now time.Time) error {
+	if r.Device == nil {
+		return nil
+	}
+
+	return r.Device.Check(who, now)
+}
+
+// Check returns an error explaining the first requirement the device fails, or nil if it meets all of them.
+func (d DeviceRequirements) Check(who *tshttp.WhoIsInfo, now time.Time) error {
+	if len(d.OS) > 0 && !slices.ContainsFunc(d.OS, func(os string) bool { return strings.EqualFold(os, who.OS) }) {
+		return fmt.Errorf("OS %q is not one of [%s]", who.OS, strings.Join(d.OS, ", "))
+	}
+
+	if d.MinClientVersion != "" && cmpver.Compare(clientVersion(who.ClientVersion), d.MinClientVersion) < 0 {
+		return fmt.Errorf("client version %q is older than %s", who.ClientVersion, d.MinClientVersion)
+	}
+
+	if d.MinKeyValidity != "" && !who.KeyExpiry.IsZero() {
+		minValidity, err := time.ParseDuration(d.MinKeyValidity)
+		if err != nil {
+			return fmt.Errorf("invalid minKeyValidity %q: %w", d.MinKeyValidity, err)
+		}
+		if who.KeyExpiry.Sub(now) < minValidity {
+			return fmt.Errorf("node key expires at %s, within %s", who.KeyExpiry.Format(time.RFC3339), minValidity)
+		}
+	}
+
+	for _, attr := range d.NodeAttributes {
+		if !slices.Contains(who.NodeAttributes, attr) {
+			return fmt.Errorf("node attribute %q is not granted", attr)
+		}
+	}
+
+	return nil
+}
+
+// clientVersion strips the build suffix of a Tailscale version, e.g. "1.80.0-t1234abcde-g5678" becomes "1.80.0".
+func clientVersion(version string) string {
+	short, _, _ := strings.Cut(version, "-")
+	return short
+}
@@ -0,0 +1,105 @@
+package capability_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/pkg/service/capability"
+	"github.com/spechtlabs/tka/pkg/tshttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceRequirements_Check(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	device := &tshttp.WhoIsInfo{
+		OS:             "linux",
+		ClientVersion:  "1.80.2-t0123456789-g0123456789",
+		KeyExpiry:      now.Add(48 * time.Hour),
+		NodeAttributes: []string{"mullvad", "tka:managed"},
+	}
+
+	tests := []struct {
+		name         string
+		requirements capability.DeviceRequirements
+		device       *tshttp.WhoIsInfo
+		wantErr      string
+	}{
+		{
+			name:         "no requirements",
+			requirements: capability.DeviceRequirements{},
+			device:       device,
+		},
+		{
+			name:         "OS matches case-insensitively",
+			requirements: capability.DeviceRequirements{OS: []string{"macOS", "Linux"}},
+			device:       device,
+		},
+		{
+			name:         "OS not allowed",
+			requirements: capability.DeviceRequirements{OS: []string{"macOS"}},
+			device:       device,
+			wantErr:      `OS "linux" is not one of [macOS]`,
+		},
+		{
+			name:         "client version recent enough",
+			requirements: capability.DeviceRequirements{MinClientVersion: "1.80.2"},
+			device:       device,
+		},
+		{
+			name:         "client version too old",
+			requirements: capability.DeviceRequirements{MinClientVersion: "1.82.0"},
+			device:       device,
+			wantErr:      `client version "1.80.2-t0123456789-g0123456789" is older than 1.82.0`,
+		},
+		{
+			name:         "key valid long enough",
+			requirements: capability.DeviceRequirements{MinKeyValidity: "24h"},
+			device:       device,
+		},
+		{
+			name:         "key expires too soon",
+			requirements: capability.DeviceRequirements{MinKeyValidity: "72h"},
+			device:       device,
+			wantErr:      "node key expires at 2025-06-03T12:00:00Z, within 72h0m0s",
+		},
+		{
+			name:         "key expiry disabled",
+			requirements: capability.DeviceRequirements{MinKeyValidity: "72h"},
+			device:       &tshttp.WhoIsInfo{},
+		},
+		{
+			name:         "invalid key validity",
+			requirements: capability.DeviceRequirements{MinKeyValidity: "a day"},
+			device:       device,
+			wantErr:      `invalid minKeyValidity "a day"`,
+		},
+		{
+			name:         "node attribute granted",
+			requirements: capability.DeviceRequirements{NodeAttributes: []string{"tka:managed"}},
+			device:       device,
+		},
+		{
+			name:         "node attribute missing",
+			requirements: capability.DeviceRequirements{NodeAttributes: []string{"tka:compliant"}},
+			device:       device,
+			wantErr:      `node attribute "tka:compliant" is not granted`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.requirements.Check(tt.device, now)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRule_CheckDevice_WithoutRequirements(t *testing.T) {
+	rule := capability.Rule{Role: "view", Period: "1h"}
+	require.NoError(t, rule.CheckDevice(&tshttp.WhoIsInfo{OS: "windows"}, time.Now()))
+}
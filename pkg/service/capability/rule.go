@@ -3,16 +3,53 @@
 // capabilities and access rules that determine what actions users can perform.
 package capability
 
+import "slices"
+
 // Rule describes the capability extracted from identity middleware.
 type Rule struct {
 	// Role is the Kubernetes ClusterRole name to be granted to the user.
 	Role string `json:"role"`
 	// Period is the duration for which the role is granted.
 	Period string `json:"period"`
+	// TokenTTL optionally caps the lifetime of each issued token below the server-wide maximum.
+	TokenTTL string `json:"tokenTTL,omitempty"`
 	// RulePriority is the priority of the rule. Higher priority rules override lower priority rules.
 	RulePriority int `json:"priority"`
+	// Tags scopes the rule to tagged devices carrying at least one of these tags (e.g. "tag:ci").
+	// Rules without tags only apply to user-owned devices.
+	Tags []string `json:"tags,omitempty"`
+	// Device optionally restricts the rule to devices meeting posture requirements.
+	Device *DeviceRequirements `json:"device,omitempty"`
+	// RequireReason makes users give a reason, e.g. a ticket reference, when signing in with this rule.
+	RequireReason bool `json:"requireReason,omitempty"`
+	// Admin allows managing other users' sessions through the admin API, e.g. suspending them.
+	Admin bool `json:"admin,omitempty"`
+	// Condition is an optional CEL expression that must evaluate to true for the rule to apply,
+	// e.g. `now.getDayOfWeek("UTC") in [1, 2, 3, 4, 5]`.
+	Condition string `json:"condition,omitempty"`
 }
 
 func (r Rule) Priority() int {
 	return r.RulePriority
 }
+
+// ConditionExpression returns the rule's CEL condition.
+func (r Rule) ConditionExpression() string {
+	return r.Condition
+}
+
+// GrantedRole returns the ClusterRole the rule grants.
+func (r Rule) GrantedRole() string {
+	return r.Role
+}
+
+// AppliesTo reports whether the rule applies to a device with the given tags.
+func (r Rule) AppliesTo(tags []string) bool {
+	if len(r.Tags) == 0 {
+		return len(tags) == 0
+	}
+
+	return slices.ContainsFunc(tags, func(tag string) bool {
+		return slices.Contains(r.Tags, tag)
+	})
+}
@@ -1,5 +1,7 @@
 package models
 
+import "sync/atomic"
+
 // TkaClusterInfo represents the cluster information that is exposed to users when they query the cluster-info endpoint.
 // This information is used by users to configure their kubeconfig files and to understand the cluster they are connecting to.
 // @Description Contains cluster information including API endpoint, CA data, TLS settings, and identifying labels
@@ -27,3 +29,29 @@ type TkaClusterInfo struct {
 	// Common examples: environment (dev/staging/prod), region, project, team ownership, etc.
 	Labels map[string]string `json:"labels"`
 }
+
+// ClusterInfoStore holds the cluster information the server hands out. It can be replaced while the server runs,
+// e.g. when the cluster CA rotates, so readers call Load for every use instead of keeping the result.
+type ClusterInfoStore struct {
+	current atomic.Pointer[TkaClusterInfo]
+}
+
+// NewClusterInfoStore returns a store that holds info.
+func NewClusterInfoStore(info *TkaClusterInfo) *ClusterInfoStore {
+	s := &ClusterInfoStore{}
+	s.current.Store(info)
+	return s
+}
+
+// Load returns the current cluster information, or nil if there is none. Callers must not modify it.
+func (s *ClusterInfoStore) Load() *TkaClusterInfo {
+	if s == nil {
+		return nil
+	}
+	return s.current.Load()
+}
+
+// Store replaces the cluster information. Requests that already loaded the previous one keep using it.
+func (s *ClusterInfoStore) Store(info *TkaClusterInfo) {
+	s.current.Store(info)
+}
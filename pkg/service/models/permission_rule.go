@@ -0,0 +1,24 @@
+package models
+
+// PermissionRule summarizes one rule of the ClusterRole a user is bound to
+// @Description Describes a set of verbs the user may perform on resources or non-resource URLs
+type PermissionRule struct {
+	// Verbs the rule allows
+	// example: get,list,watch
+	Verbs []string `json:"verbs"`
+
+	// API groups of the resources, "" being the core group
+	// example: ,apps
+	APIGroups []string `json:"api_groups,omitempty"`
+
+	// Resources the rule applies to
+	// example: pods,deployments
+	Resources []string `json:"resources,omitempty"`
+
+	// ResourceNames restricts the rule to individual objects
+	ResourceNames []string `json:"resource_names,omitempty"`
+
+	// NonResourceURLs the rule applies to
+	// example: /healthz
+	NonResourceURLs []string `json:"non_resource_urls,omitempty"`
+}
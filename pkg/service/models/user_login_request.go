@@ -0,0 +1,33 @@
+package models
+
+// UserLoginRequest is the optional body of a login request
+// @Description Additional information about a sign-in
+type UserLoginRequest struct {
+	// Justification for signing in, e.g. a ticket reference. Required for roles whose capability rule sets requireReason.
+	// example: INC-1234 investigating crashing pods
+	Reason string `json:"reason,omitempty"`
+}
+
+// UserExtendRequest is the optional body of a request to extend a session
+// @Description How far to extend a session
+type UserExtendRequest struct {
+	// Duration to add to the session, e.g. 30m. Omit to extend as far as the grant allows.
+	// example: 30m
+	By string `json:"by,omitempty"`
+}
+
+// UserDelegateRequest is the body of a request to delegate a credential derived from the user's session
+// @Description Restrictions of a delegated credential
+type UserDelegateRequest struct {
+	// ClusterRole of the delegated credential. It must not grant more than the role of the session. Omit to use the role of the session.
+	// example: view
+	Role string `json:"role,omitempty"`
+
+	// Namespace the delegated credential is restricted to. Omit to grant the role cluster-wide.
+	// example: team-a
+	Namespace string `json:"namespace,omitempty"`
+
+	// How long the delegated credential is valid, e.g. 15m. It never outlives the session.
+	// example: 15m
+	For string `json:"for,omitempty"`
+}
@@ -14,6 +14,24 @@ type UserLoginResponse struct {
 	// Expiration timestamp of the authentication credentials in RFC3339 format
 	// example: 2023-12-31T23:59:59Z
 	Until string `json:"until"`
+
+	// Permissions granted by the role, only reported once the credentials are provisioned
+	Permissions []PermissionRule `json:"permissions,omitempty"`
+
+	// Start of the access in RFC3339 format if it begins later, e.g. with a scheduled shift
+	// example: 2023-12-31T08:00:00Z
+	NotBefore string `json:"notBefore,omitempty"`
+
+	// Suspended is true while an administrator has temporarily withdrawn the user's access
+	Suspended bool `json:"suspended,omitempty"`
+
+	// Namespace a delegated credential is restricted to
+	// example: team-a
+	Namespace string `json:"namespace,omitempty"`
+
+	// Last time the credentials were used against the Kubernetes API in RFC3339 format, if the server receives audit events
+	// example: 2023-12-31T12:34:56Z
+	LastActivity string `json:"lastActivity,omitempty"`
 }
 
 // NewUserLoginResponse creates a new UserLoginResponse with the provided details.
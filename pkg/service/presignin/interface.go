@@ -0,0 +1,17 @@
+// Package presignin asks an external authorization webhook to approve sign-ins
+// before credentials are provisioned. The webhook can allow a sign-in, deny it
+// with a message, or downgrade the role or period granted by the capability rule.
+package presignin
+
+import (
+	"context"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+)
+
+// Authorizer decides whether a sign-in may go ahead.
+type Authorizer interface {
+	// Authorize returns the role and period to sign in with. Errors for denied sign-ins wrap ErrDenied,
+	// errors for unreachable or misbehaving webhooks wrap ErrUnavailable.
+	Authorize(ctx context.Context, req Request) (Decision, humane.Error)
+}
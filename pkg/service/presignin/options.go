@@ -0,0 +1,50 @@
+package presignin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout is how long the webhook may take to answer.
+const DefaultTimeout = 5 * time.Second
+
+// Option configures the Authorizer returned by NewWebhookAuthorizer.
+type Option func(*webhookClient)
+
+// WithTimeout limits how long the webhook may take to answer.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *webhookClient) {
+		if timeout > 0 {
+			c.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithFailOpen lets sign-ins go ahead unchanged if the webhook cannot be reached or answers
+// with an invalid response. By default such sign-ins are rejected.
+func WithFailOpen(failOpen bool) Option {
+	return func(c *webhookClient) {
+		c.failOpen = failOpen
+	}
+}
+
+// WithHMACSecret signs every request with HMAC-SHA256 so the webhook can verify it came from TKA.
+// The signature covers the SignatureTimestampHeader and the body, see Sign.
+func WithHMACSecret(secret []byte) Option {
+	return func(c *webhookClient) {
+		c.hmacSecret = secret
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to reach the webhook, e.g. a client certificate
+// for mutual TLS and the CA that issued the webhook's certificate.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *webhookClient) {
+		if cfg != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = cfg
+			c.httpClient.Transport = transport
+		}
+	}
+}
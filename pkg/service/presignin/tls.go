@@ -0,0 +1,42 @@
+package presignin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+)
+
+// NewTLSConfig builds the TLS configuration for reaching the webhook. certFile and keyFile hold the
+// client certificate for mutual TLS, caFile the CA bundle the webhook's certificate is verified against.
+// All files are optional; without them the system defaults are used.
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, humane.Error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, humane.Wrap(err, "Failed to load the pre-signin webhook client certificate",
+				"set both api.preSignin.tls.certFile and api.preSignin.tls.keyFile to PEM files")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, humane.Wrap(err, "Failed to read the pre-signin webhook CA bundle", "check api.preSignin.tls.caFile")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, humane.Wrap(fmt.Errorf("no certificates found in %s", caFile), "Invalid pre-signin webhook CA bundle",
+				"api.preSignin.tls.caFile must contain PEM encoded certificates")
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,187 @@
+package presignin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"go.uber.org/zap"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of a request as "sha256=<hex>".
+	SignatureHeader = "X-Tka-Signature"
+	// SignatureTimestampHeader carries the Unix time a request was signed at.
+	SignatureTimestampHeader = "X-Tka-Timestamp"
+)
+
+var (
+	// ErrDenied is the cause of errors returned for sign-ins the webhook denied.
+	ErrDenied = errors.New("sign-in denied by pre-signin webhook")
+	// ErrUnavailable is the cause of errors returned when the webhook cannot be reached or answers with an invalid response.
+	ErrUnavailable = errors.New("pre-signin webhook unavailable")
+)
+
+// Device describes the device a sign-in comes from.
+type Device struct {
+	Name          string   `json:"name"`
+	ID            string   `json:"id,omitempty"`
+	OS            string   `json:"os,omitempty"`
+	ClientVersion string   `json:"clientVersion,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Attributes    []string `json:"attributes,omitempty"`
+}
+
+// Request is the sign-in sent to the webhook for approval.
+type Request struct {
+	Username string `json:"username"`
+	Device   Device `json:"device"`
+	Role     string `json:"role"`
+	Period   string `json:"period"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Response is the webhook's answer. Role and Period are optional and downgrade the sign-in;
+// the period may not be longer than the one requested. The API server rejects roles that grant
+// more than the requested one.
+type Response struct {
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Period  string `json:"period,omitempty"`
+}
+
+// Decision is the role and period a sign-in goes ahead with.
+type Decision struct {
+	Role   string
+	Period time.Duration
+}
+
+type webhookClient struct {
+	url        string
+	httpClient *http.Client
+	failOpen   bool
+	hmacSecret []byte
+}
+
+// NewWebhookAuthorizer creates an Authorizer that POSTs every sign-in as a JSON Request to url.
+func NewWebhookAuthorizer(url string, opts ...Option) Authorizer {
+	c := &webhookClient{
+		url:        url,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *webhookClient) Authorize(ctx context.Context, req Request) (Decision, humane.Error) {
+	requested, err := time.ParseDuration(req.Period)
+	if err != nil {
+		return Decision{}, humane.Wrap(err, "Error parsing duration", "this is a bug in TKA; please report it")
+	}
+
+	resp, herr := c.call(ctx, req)
+	if herr == nil {
+		return decide(resp, Decision{Role: req.Role, Period: requested})
+	}
+
+	if c.failOpen && errors.Is(herr.Cause(), ErrUnavailable) {
+		otelzap.L().WithError(herr).WarnContext(ctx, "Pre-signin webhook failed, allowing sign-in unchanged",
+			zap.String("username", req.Username), zap.String("role", req.Role))
+		return Decision{Role: req.Role, Period: requested}, nil
+	}
+
+	return Decision{}, herr
+}
+
+// call sends the sign-in to the webhook and decodes its answer.
+func (c *webhookClient) call(ctx context.Context, req Request) (*Response, humane.Error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, humane.Wrap(err, "Failed to encode sign-in for the pre-signin webhook", "this is a bug in TKA; please report it")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, unavailableError(err, "Invalid pre-signin webhook URL")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if len(c.hmacSecret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		httpReq.Header.Set(SignatureTimestampHeader, timestamp)
+		httpReq.Header.Set(SignatureHeader, Sign(c.hmacSecret, timestamp, body))
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, unavailableError(err, "Could not reach the pre-signin webhook")
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, unavailableError(fmt.Errorf("webhook returned %s", httpResp.Status), "The pre-signin webhook failed")
+	}
+
+	var resp Response
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 64*1024)).Decode(&resp); err != nil {
+		return nil, unavailableError(err, "Invalid response from the pre-signin webhook")
+	}
+
+	return &resp, nil
+}
+
+// decide applies the webhook's answer to the requested sign-in.
+func decide(resp *Response, requested Decision) (Decision, humane.Error) {
+	if !resp.Allowed {
+		message := resp.Message
+		if message == "" {
+			message = "Sign-in denied"
+		}
+		return Decision{}, humane.Wrap(ErrDenied, message, "contact your cluster administrator if you need access")
+	}
+
+	decision := requested
+	if resp.Role != "" {
+		decision.Role = resp.Role
+	}
+
+	if resp.Period != "" {
+		period, err := time.ParseDuration(resp.Period)
+		if err != nil || period <= 0 || period > requested.Period {
+			return Decision{}, unavailableError(fmt.Errorf("invalid period %q, must be positive and at most %s", resp.Period, requested.Period),
+				"Invalid response from the pre-signin webhook")
+		}
+		decision.Period = period
+	}
+
+	return decision, nil
+}
+
+// Sign computes the value of the SignatureHeader for a request body signed at timestamp.
+// The signature is the hex-encoded HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(timestamp + "."))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func unavailableError(err error, message string) humane.Error {
+	return humane.Wrap(fmt.Errorf("%w: %w", ErrUnavailable, err), message,
+		"try again later", "ask your administrator to check the pre-signin webhook")
+}
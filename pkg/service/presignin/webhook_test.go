@@ -0,0 +1,141 @@
+package presignin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spechtlabs/tka/pkg/service/presignin"
+	"github.com/stretchr/testify/require"
+)
+
+func newWebhook(t *testing.T, status int, resp string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req presignin.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "alice", req.Username)
+		require.Equal(t, "laptop", req.Device.Name)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		resp     string
+		failOpen bool
+		want     presignin.Decision
+		wantErr  error
+		wantMsg  string
+	}{
+		{
+			name:   "allowed unchanged",
+			status: http.StatusOK,
+			resp:   `{"allowed": true}`,
+			want:   presignin.Decision{Role: "cluster-admin", Period: time.Hour},
+		},
+		{
+			name:   "downgraded",
+			status: http.StatusOK,
+			resp:   `{"allowed": true, "role": "view", "period": "15m"}`,
+			want:   presignin.Decision{Role: "view", Period: 15 * time.Minute},
+		},
+		{
+			name:    "denied with message",
+			status:  http.StatusOK,
+			resp:    `{"allowed": false, "message": "alice is not on call"}`,
+			wantErr: presignin.ErrDenied,
+			wantMsg: "alice is not on call",
+		},
+		{
+			name:    "period may not be extended",
+			status:  http.StatusOK,
+			resp:    `{"allowed": true, "period": "2h"}`,
+			wantErr: presignin.ErrUnavailable,
+		},
+		{
+			name:    "webhook failure fails closed",
+			status:  http.StatusInternalServerError,
+			wantErr: presignin.ErrUnavailable,
+		},
+		{
+			name:     "webhook failure fails open",
+			status:   http.StatusInternalServerError,
+			failOpen: true,
+			want:     presignin.Decision{Role: "cluster-admin", Period: time.Hour},
+		},
+		{
+			name:     "denial is final when failing open",
+			status:   http.StatusOK,
+			resp:     `{"allowed": false}`,
+			failOpen: true,
+			wantErr:  presignin.ErrDenied,
+			wantMsg:  "Sign-in denied",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newWebhook(t, tc.status, tc.resp)
+			a := presignin.NewWebhookAuthorizer(ts.URL, presignin.WithFailOpen(tc.failOpen))
+
+			got, err := a.Authorize(context.Background(), presignin.Request{
+				Username: "alice",
+				Device:   presignin.Device{Name: "laptop"},
+				Role:     "cluster-admin",
+				Period:   "1h0m0s",
+			})
+			if tc.wantErr != nil {
+				require.NotNil(t, err)
+				require.True(t, errors.Is(err.Cause(), tc.wantErr), err.Display())
+				if tc.wantMsg != "" {
+					require.Equal(t, tc.wantMsg, err.Error())
+				}
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAuthorizeSignsRequests(t *testing.T) {
+	secret := []byte("s3cr3t")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp := r.Header.Get(presignin.SignatureTimestampHeader)
+		require.NotEmpty(t, timestamp)
+		require.Equal(t, presignin.Sign(secret, timestamp, body), r.Header.Get(presignin.SignatureHeader))
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	a := presignin.NewWebhookAuthorizer(ts.URL, presignin.WithHMACSecret(secret))
+	_, err := a.Authorize(context.Background(), presignin.Request{Username: "alice", Role: "view", Period: "1h"})
+	require.Nil(t, err)
+}
+
+func TestAuthorizeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(release) })
+
+	a := presignin.NewWebhookAuthorizer(ts.URL, presignin.WithTimeout(50*time.Millisecond))
+	_, err := a.Authorize(context.Background(), presignin.Request{Username: "alice", Role: "view", Period: "1h"})
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err.Cause(), presignin.ErrUnavailable), err.Display())
+}
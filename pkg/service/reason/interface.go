@@ -0,0 +1,17 @@
+// Package reason validates the justifications users give when signing in.
+// Reasons can be checked against a pattern, e.g. requiring a ticket reference,
+// and against an HTTP callback that knows which tickets are valid.
+package reason
+
+import (
+	"context"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+)
+
+// Validator checks the reason given for a sign-in.
+type Validator interface {
+	// Validate returns an error explaining why the reason is not acceptable, or nil if it is.
+	// Errors caused by an unacceptable reason wrap ErrRejected.
+	Validate(ctx context.Context, req Request) humane.Error
+}
@@ -0,0 +1,32 @@
+package reason
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// DefaultCallbackTimeout is how long the validation callback may take to answer.
+const DefaultCallbackTimeout = 5 * time.Second
+
+// Option configures the Validator returned by NewValidator.
+type Option func(*validationService)
+
+// WithPattern requires reasons to match pattern, e.g. `^(INC|CHG)-[0-9]+\b`.
+func WithPattern(pattern *regexp.Regexp) Option {
+	return func(v *validationService) {
+		v.pattern = pattern
+	}
+}
+
+// WithCallback asks the HTTP endpoint at url to approve every reason. The endpoint receives the
+// Request as JSON and accepts the reason with a 2xx status; any 4xx status rejects it.
+func WithCallback(url string, timeout time.Duration) Option {
+	return func(v *validationService) {
+		if timeout <= 0 {
+			timeout = DefaultCallbackTimeout
+		}
+		v.callbackURL = url
+		v.httpClient = &http.Client{Timeout: timeout}
+	}
+}
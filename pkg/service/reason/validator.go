@@ -0,0 +1,122 @@
+package reason
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/sierrasoftworks/humane-errors-go"
+	"github.com/spechtlabs/tka/pkg/models"
+)
+
+// MaxLength is the longest reason accepted, in characters.
+const MaxLength = 512
+
+var (
+	// ErrRejected is the cause of errors returned for missing or unacceptable reasons.
+	ErrRejected = errors.New("sign-in reason rejected")
+	// ErrUnavailable is the cause of errors returned when the validation callback cannot be reached.
+	ErrUnavailable = errors.New("sign-in reason validation unavailable")
+)
+
+// Request is a reason to validate, together with the sign-in it was given for.
+type Request struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Reason   string `json:"reason"`
+}
+
+// NewRequiredError explains that signing in to role requires a reason.
+func NewRequiredError(role string) humane.Error {
+	return humane.Wrap(fmt.Errorf("%w: no reason given", ErrRejected),
+		fmt.Sprintf("A reason is required to sign in as %q", role),
+		`run 'tka login --reason "<ticket> <what you are about to do>"'`)
+}
+
+type validationService struct {
+	pattern     *regexp.Regexp
+	callbackURL string
+	httpClient  *http.Client
+}
+
+// NewValidator creates a Validator that enforces MaxLength and the configured checks.
+func NewValidator(opts ...Option) Validator {
+	v := &validationService{}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+func (v *validationService) Validate(ctx context.Context, req Request) humane.Error {
+	if utf8.RuneCountInString(req.Reason) > MaxLength {
+		return humane.Wrap(fmt.Errorf("%w: too long", ErrRejected),
+			fmt.Sprintf("The reason may not be longer than %d characters", MaxLength),
+			"reference the ticket instead of describing the work in detail")
+	}
+
+	if v.pattern != nil && !v.pattern.MatchString(req.Reason) {
+		return humane.Wrap(fmt.Errorf("%w: %q does not match %s", ErrRejected, req.Reason, v.pattern),
+			"The reason does not reference a valid ticket",
+			"the reason must match the pattern "+v.pattern.String())
+	}
+
+	if v.callbackURL != "" {
+		return v.callback(ctx, req)
+	}
+
+	return nil
+}
+
+// callback asks the validation endpoint whether the reason is acceptable.
+func (v *validationService) callback(ctx context.Context, req Request) humane.Error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return humane.Wrap(err, "Failed to encode reason for validation", "this is a bug in TKA; please report it")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, v.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return humane.Wrap(fmt.Errorf("%w: %w", ErrUnavailable, err), "Invalid reason validation callback URL", "check api.reason.callbackURL in the server configuration")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.httpClient.Do(httpReq)
+	if err != nil {
+		return humane.Wrap(fmt.Errorf("%w: %w", ErrUnavailable, err), "Could not validate the reason", "try again later", "ask your administrator to check the reason validation service")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return humane.Wrap(fmt.Errorf("%w: %s", ErrRejected, callbackMessage(resp.Body)),
+			"The reason was rejected", "reference a ticket that is open and assigned to you")
+	default:
+		return humane.Wrap(fmt.Errorf("%w: callback returned %s", ErrUnavailable, resp.Status), "Could not validate the reason", "try again later", "ask your administrator to check the reason validation service")
+	}
+}
+
+// callbackMessage extracts the explanation of a rejection. The callback may answer with an
+// ErrorResponse or plain text.
+func callbackMessage(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(data, &errResp); err == nil && errResp.Message != "" {
+		return errResp.Message
+	}
+
+	if msg := string(bytes.TrimSpace(data)); msg != "" {
+		return msg
+	}
+	return "rejected by the validation callback"
+}
@@ -0,0 +1,104 @@
+package reason_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spechtlabs/tka/pkg/service/reason"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []reason.Option
+		reason  string
+		wantErr error
+	}{
+		{
+			name:   "any reason by default",
+			reason: "looking around",
+		},
+		{
+			name:    "too long",
+			reason:  strings.Repeat("x", reason.MaxLength+1),
+			wantErr: reason.ErrRejected,
+		},
+		{
+			name:   "matches pattern",
+			opts:   []reason.Option{reason.WithPattern(regexp.MustCompile(`^INC-[0-9]+\b`))},
+			reason: "INC-1234 investigating pods",
+		},
+		{
+			name:    "does not match pattern",
+			opts:    []reason.Option{reason.WithPattern(regexp.MustCompile(`^INC-[0-9]+\b`))},
+			reason:  "investigating pods",
+			wantErr: reason.ErrRejected,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := reason.NewValidator(tc.opts...).Validate(context.Background(), reason.Request{Username: "alice", Role: "dev", Reason: tc.reason})
+			if tc.wantErr == nil {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.True(t, errors.Is(err.Cause(), tc.wantErr), err.Display())
+		})
+	}
+}
+
+func TestValidatorCallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantErr     error
+		wantMessage string
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "rejected with error response", status: http.StatusUnprocessableEntity, body: `{"message":"INC-1234 is closed"}`, wantErr: reason.ErrRejected, wantMessage: "INC-1234 is closed"},
+		{name: "rejected with plain text", status: http.StatusForbidden, body: "not assigned to alice", wantErr: reason.ErrRejected, wantMessage: "not assigned to alice"},
+		{name: "unavailable", status: http.StatusBadGateway, wantErr: reason.ErrUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req reason.Request
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				require.Equal(t, reason.Request{Username: "alice", Role: "dev", Reason: "INC-1234"}, req)
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			t.Cleanup(ts.Close)
+
+			v := reason.NewValidator(reason.WithCallback(ts.URL, 0))
+			err := v.Validate(context.Background(), reason.Request{Username: "alice", Role: "dev", Reason: "INC-1234"})
+			if tc.wantErr == nil {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.True(t, errors.Is(err.Cause(), tc.wantErr), err.Display())
+			require.Contains(t, err.Cause().Error(), tc.wantMessage)
+		})
+	}
+}
+
+func TestValidatorCallbackUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	err := reason.NewValidator(reason.WithCallback(ts.URL, 0)).Validate(context.Background(), reason.Request{Reason: "INC-1234"})
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err.Cause(), reason.ErrUnavailable), err.Display())
+}
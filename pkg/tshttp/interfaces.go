@@ -6,6 +6,7 @@ package tshttp
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/sierrasoftworks/humane-errors-go"
 	"tailscale.com/ipn/ipnstate"
@@ -26,6 +27,25 @@ type WhoIsInfo struct {
 	// Tags contains the Tailscale ACL tags assigned to this device.
 	// Tagged devices represent service accounts rather than human users.
 	Tags []string
+
+	// NodeName is the device's machine name, i.e. the first label of its MagicDNS name.
+	// Unlike LoginName it tells apart the individual devices sharing the same tags.
+	NodeName string
+
+	// NodeID is the device's stable node ID.
+	NodeID string
+
+	// OS is the operating system reported by the device (e.g., "linux", "macOS", "windows").
+	OS string
+
+	// ClientVersion is the version of the Tailscale client running on the device.
+	ClientVersion string
+
+	// KeyExpiry is when the device's node key expires. It is zero if key expiry is disabled.
+	KeyExpiry time.Time
+
+	// NodeAttributes lists the node attributes granted to the device by the ACL's nodeAttrs.
+	NodeAttributes []string
 }
 
 // IsTagged indicates whether the source connection is from a tagged device.
@@ -64,6 +84,30 @@ type TailscaleCapability interface {
 	Priority() int
 }
 
+// ScopedCapability is implemented by capabilities that only apply to some callers,
+// e.g. rules meant for tagged devices. Capabilities that don't implement it apply to every caller.
+type ScopedCapability interface {
+	// AppliesTo reports whether the capability applies to a caller with the given tags.
+	// Callers without tags are user-owned devices.
+	AppliesTo(tags []string) bool
+}
+
+// DeviceCapability is implemented by capabilities that only apply to devices meeting some
+// requirements, e.g. a minimum client version. Capabilities that don't implement it apply to every device.
+type DeviceCapability interface {
+	// CheckDevice returns an error explaining which requirement the device fails, or nil if it meets all of them.
+	CheckDevice(who *WhoIsInfo, now time.Time) error
+}
+
+// ConditionalCapability is implemented by capabilities that only apply while a CEL condition holds,
+// e.g. only during business hours. Capabilities that don't implement it apply unconditionally.
+type ConditionalCapability interface {
+	// ConditionExpression returns the CEL expression that must evaluate to true, or "" if there is none.
+	ConditionExpression() string
+	// GrantedRole returns the role the capability grants, which the condition may refer to.
+	GrantedRole() string
+}
+
 // TailscaleServer represents the core functionality of a Tailscale server.
 // This interface provides methods to query the server's connection state.
 type TailscaleServer interface {
@@ -87,12 +131,23 @@ type TSNet interface {
 	ListenTLS(network, addr string) (net.Listener, error)
 	// ListenFunnel creates a Funnel listener that accepts public internet traffic.
 	ListenFunnel(network, addr string) (net.Listener, error)
+	// ListenService advertises the node as a host of the Tailscale Service name and returns a listener
+	// for its port together with the Service's MagicDNS name. If terminateTLS is set, the listener
+	// accepts the TLS connections terminated for the Service's name.
+	ListenService(name string, port int, terminateTLS bool) (net.Listener, string, error)
 
 	// LocalWhoIs returns a WhoIsResolver for identity lookups.
 	LocalWhoIs() (WhoIsResolver, error)
 
+	// WatchBackendState calls onState with the current backend state and again whenever it changes.
+	// It blocks until ctx is done or the watch fails.
+	WatchBackendState(ctx context.Context, onState func(state string)) error
+
 	// SetDir sets the directory for Tailscale state storage.
 	SetDir(dir string)
+	// SetStateSecret stores the Tailscale state in the named Kubernetes Secret instead of the state directory.
+	// It only works inside a cluster and must be called before Up.
+	SetStateSecret(name string) error
 	// SetLogf sets the logging function for Tailscale operations.
 	SetLogf(logf func(string, ...any))
 }
@@ -6,6 +6,7 @@ package mock
 import (
 	"context"
 	"net"
+	"strings"
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
 	ts "github.com/spechtlabs/tka/pkg/tshttp"
@@ -27,16 +28,24 @@ type MockTSNet struct {
 	ListenErr    error          // Error to return from Listen()
 	TLSErr       error          // Error to return from ListenTLS()
 	FunnelErr    error          // Error to return from ListenFunnel()
+	ServiceErr   error          // Error to return from ListenService()
 	ListenCalled map[string]int // Tracks calls by network type
+	Services     []string       // Services advertised via ListenService()
 
 	// WhoIs configuration
 	Whois       ts.WhoIsResolver // WhoIsResolver to return from LocalWhoIs()
 	WhoIsErr    error            // Error to return from LocalWhoIs()
 	WhoIsCalled bool             // Whether LocalWhoIs() was called
 
+	// WatchBackendState configuration
+	States   chan string // Backend states to report from WatchBackendState(), blocks until ctx is done if nil
+	WatchErr error       // Error to return from WatchBackendState() once States is closed
+
 	// Configuration tracking
-	Dir  string               // Directory set via SetDir()
-	Logf func(string, ...any) // Log function set via SetLogf()
+	Dir            string               // Directory set via SetDir()
+	StateSecret    string               // Secret set via SetStateSecret()
+	StateSecretErr error                // Error to return from SetStateSecret()
+	Logf           func(string, ...any) // Log function set via SetLogf()
 }
 
 // NewMockTSNet creates a new MockTSNet with sensible defaults for testing.
@@ -81,6 +90,14 @@ func (m *MockTSNet) ListenFunnel(network, addr string) (net.Listener, error) {
 	return &nopListener{}, m.FunnelErr
 }
 
+// ListenService simulates advertising a Tailscale Service.
+// It increments the "service" call counter, records the Service and returns a nopListener or ServiceErr.
+func (m *MockTSNet) ListenService(name string, port int, terminateTLS bool) (net.Listener, string, error) {
+	m.ListenCalled["service"]++
+	m.Services = append(m.Services, name)
+	return &nopListener{}, strings.TrimPrefix(name, "svc:") + ".tailnet.ts.net", m.ServiceErr
+}
+
 // LocalWhoIs simulates getting a WhoIsResolver.
 // It marks WhoIsCalled as true and returns the configured Whois resolver or WhoIsErr.
 func (m *MockTSNet) LocalWhoIs() (ts.WhoIsResolver, error) {
@@ -94,11 +111,34 @@ func (m *MockTSNet) LocalWhoIs() (ts.WhoIsResolver, error) {
 	return &MockWhoIs{}, nil
 }
 
+// WatchBackendState simulates watching the IPN bus.
+// It reports every state sent on States and returns WatchErr once States is closed, or ctx.Err() when ctx is done.
+func (m *MockTSNet) WatchBackendState(ctx context.Context, onState func(state string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case state, ok := <-m.States:
+			if !ok {
+				return m.WatchErr
+			}
+			onState(state)
+		}
+	}
+}
+
 // SetDir simulates setting the Tailscale state directory.
 func (m *MockTSNet) SetDir(dir string) {
 	m.Dir = dir
 }
 
+// SetStateSecret simulates storing the Tailscale state in a Kubernetes Secret.
+// It records the Secret name and returns StateSecretErr.
+func (m *MockTSNet) SetStateSecret(name string) error {
+	m.StateSecret = name
+	return m.StateSecretErr
+}
+
 // SetLogf simulates setting the logging function.
 func (m *MockTSNet) SetLogf(logf func(string, ...any)) {
 	m.Logf = logf
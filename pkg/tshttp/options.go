@@ -46,6 +46,27 @@ func WithStateDir(dir string) Option {
 	}
 }
 
+// WithStateSecret keeps the Tailscale state in the named Kubernetes Secret instead
+// of the state directory, so the node survives pod restarts without a volume.
+// The Secret is created if it doesn't exist. Every node needs its own Secret, and
+// the server must run inside the cluster with permission to get, create, update
+// and patch it.
+func WithStateSecret(name string) Option {
+	return func(s *Server) {
+		s.stateSecret = name
+	}
+}
+
+// WithService also serves as a host of the Tailscale Service name (e.g. "svc:myapp").
+// Several servers, each with its own hostname, can host the same Service, and
+// clients reach whichever is available by the Service's MagicDNS name. Service
+// hosts must be tagged nodes and the Service must be defined in the tailnet policy.
+func WithService(name string) Option {
+	return func(s *Server) {
+		s.service = name
+	}
+}
+
 // WithReadTimeout sets the maximum duration for reading the entire request,
 // including the body. A zero or negative value means there will be no timeout.
 func WithReadTimeout(timeout time.Duration) Option {
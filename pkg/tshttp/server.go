@@ -80,6 +80,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	humane "github.com/sierrasoftworks/humane-errors-go"
@@ -119,12 +120,25 @@ type Server struct {
 	stateDir string
 	hostname string
 
+	// stateSecret names the Kubernetes Secret to keep the Tailscale state in instead of stateDir.
+	stateSecret string
+
+	// service is the Tailscale Service the server also serves, e.g. "svc:tka". Every replica
+	// advertises itself as a host of the Service so clients reach any of them by its name.
+	service string
+
 	// Tailscale components
 	ts        TSNet            // Abstracted tsnet server for testability
 	whois     WhoIsResolver    // Resolver for WhoIs lookups
-	st        *ipnstate.Status // Connection status
+	st        *ipnstate.Status // Connection status when the server started
 	serverURL string           // Full server URL (e.g., "https://myapp.tailnet.ts.net:443")
 	started   bool             // Track if Start() has been called
+
+	// Live backend state, kept current by watchBackendState
+	mu             sync.RWMutex
+	state          string
+	stateCallbacks []StateChangeFunc
+	stopWatch      context.CancelFunc
 }
 
 // NewServer creates a new Tailscale HTTP server with the given hostname and options.
@@ -136,6 +150,8 @@ type Server struct {
 //   - WithPort: Set the listening port (default: 443)
 //   - WithDebug: Enable debug logging
 //   - WithStateDir: Set Tailscale state directory
+//   - WithStateSecret: Keep the Tailscale state in a Kubernetes Secret instead
+//   - WithService: Also serve as a host of a Tailscale Service
 //   - HTTP timeout options: WithReadTimeout, WithWriteTimeout, etc.
 //
 // Example:
@@ -228,6 +244,12 @@ func (s *Server) Start(ctx context.Context) humane.Error {
 	if err := s.connectTailnet(ctx); err != nil {
 		return humane.Wrap(err, "failed to connect to tailnet", "check TS_AUTH_KEY and network connectivity")
 	}
+	s.setState(s.st.BackendState)
+
+	// The watcher outlives ctx, which may only cover the startup, and is stopped by Shutdown
+	watchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.stopWatch = cancel
+	go s.watchBackendState(watchCtx)
 
 	s.started = true
 	return nil
@@ -341,6 +363,34 @@ func (s *Server) ListenFunnel(address string) (net.Listener, humane.Error) {
 	return listener, nil
 }
 
+// ListenService advertises the server as a host of the Tailscale Service name and creates a listener
+// for the server's port. If terminateTLS is set, the connections arrive with TLS already terminated
+// for the Service's MagicDNS name.
+//
+// The server must be started with Start() before calling this method. Service hosts must be tagged
+// nodes, and the Service must be defined and the host approved in the tailnet policy.
+//
+// Example:
+//
+//	listener, err := server.ListenService("svc:myapp", true)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	httpServer := &http.Server{Handler: myHandler}
+//	go httpServer.Serve(listener)
+func (s *Server) ListenService(name string, terminateTLS bool) (net.Listener, humane.Error) {
+	if !s.started {
+		return nil, humane.New("server not started: call Start() before ListenService()", "ensure Start() is called before ListenService()")
+	}
+	listener, fqdn, err := s.ts.ListenService(name, s.port, terminateTLS)
+	if err != nil {
+		return nil, humane.Wrap(err, fmt.Sprintf("failed to advertise the tailscale service %s", name), "service hosts must be tagged nodes; check the tags of the auth key and that the service is defined in the tailnet policy")
+	}
+	otelzap.L().Info("tka tailscale service advertised", zap.String("service", name), zap.String("fqdn", fqdn)) //nolint:golint-sl // advertised once during startup
+	return listener, nil
+}
+
 // Stop gracefully stops the Tailscale server.
 //
 // Example:
@@ -406,6 +456,17 @@ func (s *Server) Serve(ctx context.Context, handler http.Handler, network string
 
 	// Set handler and serve
 	s.Handler = handler
+
+	// As a host of a Tailscale Service, also accept the connections to the Service's name
+	if s.service != "" {
+		serviceListener, err := s.ListenService(s.service, network == "tls")
+		if err != nil {
+			_ = listener.Close()
+			return humane.Wrap(err, "failed to create service listener", "check the tailscale service configuration")
+		}
+		go s.serveService(ctx, serviceListener)
+	}
+
 	if err := s.Server.Serve(listener); err != nil {
 		if errors.Is(err, http.ErrServerClosed) {
 			return nil
@@ -416,6 +477,13 @@ func (s *Server) Serve(ctx context.Context, handler http.Handler, network string
 	return nil
 }
 
+// serveService serves the Tailscale Service's listener until the server shuts down.
+func (s *Server) serveService(ctx context.Context, listener net.Listener) {
+	if err := s.Server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		otelzap.L().WithError(err).ErrorContext(ctx, "failed to serve the tailscale service", zap.String("service", s.service))
+	}
+}
+
 // ServeTLS serves over the Tailscale network using the TLS protocol.
 func (s *Server) ServeTLS(ctx context.Context, handler http.Handler) humane.Error {
 	if err := s.Serve(ctx, handler, "tls"); err != nil {
@@ -440,6 +508,10 @@ func (s *Server) Shutdown(ctx context.Context) humane.Error {
 			return humane.Wrap(err, "failed to shutdown HTTP server", "consider extending the shutdown timeout")
 		}
 	}
+	if s.stopWatch != nil {
+		s.stopWatch()
+		s.stopWatch = nil
+	}
 	s.started = false
 	return nil
 }
@@ -449,6 +521,13 @@ func (s *Server) connectTailnet(ctx context.Context) humane.Error {
 	ctx, span := tracer.Start(ctx, "Server.connectTailnet")
 	defer span.End()
 
+	if s.stateSecret != "" {
+		if err := s.ts.SetStateSecret(s.stateSecret); err != nil {
+			span.RecordError(err)
+			return humane.Wrap(err, "failed to keep the tailscale state in a secret", "the state secret only works inside a cluster; check that the service account may get, create, update and patch it")
+		}
+	}
+
 	var err error
 	s.st, err = s.ts.Up(ctx)
 	if err != nil {
@@ -522,20 +601,18 @@ func (s *Server) WhoIs(ctx context.Context, remoteAddr string) (*WhoIsInfo, huma
 // IsConnected reports whether the server is connected to the Tailscale network.
 // Returns true only when the backend state is "Running".
 func (s *Server) IsConnected() bool {
-	if s.st == nil {
-		return false
-	}
-
-	return s.st.BackendState == "Running"
+	return s.BackendState() == backendStateRunning
 }
 
 // BackendState returns the current Tailscale backend state.
 // Possible values: "NoState", "NeedsLogin", "NeedsMachineAuth", "Stopped",
-// "Starting", "Running".
+// "Starting", "Running". The state is kept current from the IPN bus once the server started.
 func (s *Server) BackendState() string {
-	if s.st == nil {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.state == "" {
 		return "NoState"
 	}
-
-	return s.st.BackendState
+	return s.state
 }
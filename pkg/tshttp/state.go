@@ -0,0 +1,108 @@
+// Package tshttp provides live monitoring of the Tailscale backend state.
+// This file contains the IPN bus watcher, state-change callbacks and the state metrics.
+package tshttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spechtlabs/go-otel-utils/otelzap"
+	"go.uber.org/zap"
+)
+
+// backendStateRunning is the backend state of a server that is connected to the tailnet.
+const backendStateRunning = "Running"
+
+// watchRetryInterval is how long to wait before watching the IPN bus again after the watch failed.
+const watchRetryInterval = 5 * time.Second
+
+// backendStateGauge reports the current backend state, 1 for the current state and absent otherwise
+var backendStateGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tka_tailscale_backend_state",
+		Help: "Current Tailscale backend state of the server, 1 for the current state",
+	},
+	[]string{
+		"state",
+	},
+)
+
+// backendStateChanges counts how often the backend entered each state
+var backendStateChanges = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tka_tailscale_backend_state_changes_total",
+		Help: "Total number of Tailscale backend state changes by the state entered",
+	},
+	[]string{
+		"state",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(backendStateGauge)
+	prometheus.MustRegister(backendStateChanges)
+}
+
+// StateChangeFunc is called with the previous and the current backend state whenever the state changes.
+type StateChangeFunc func(previous, current string)
+
+// OnStateChange registers fn to be called whenever the backend state changes, e.g. when the server needs
+// to log in again or loses its connection to the tailnet. Callbacks run one after another on the watcher
+// goroutine and must not block.
+func (s *Server) OnStateChange(fn StateChangeFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stateCallbacks = append(s.stateCallbacks, fn)
+}
+
+// setState records the backend state and notifies the callbacks if it changed.
+func (s *Server) setState(state string) {
+	s.mu.Lock()
+	previous := s.state
+	if previous == state {
+		s.mu.Unlock()
+		return
+	}
+	s.state = state
+	callbacks := append([]StateChangeFunc(nil), s.stateCallbacks...)
+	s.mu.Unlock()
+
+	backendStateGauge.Reset()
+	backendStateGauge.WithLabelValues(state).Set(1)
+	backendStateChanges.WithLabelValues(state).Inc()
+
+	if previous != "" {
+		log := otelzap.L().Info
+		if state != backendStateRunning {
+			log = otelzap.L().Warn
+		}
+		log("tailscale backend state changed", zap.String("previous", previous), zap.String("current", state)) //nolint:golint-sl // state changes happen outside of any request
+	}
+
+	for _, fn := range callbacks {
+		fn(previous, state)
+	}
+}
+
+// watchBackendState keeps the backend state current until ctx is done. If the IPN bus fails, the last
+// known state is kept until the watch is re-established.
+func (s *Server) watchBackendState(ctx context.Context) {
+	for {
+		err := s.ts.WatchBackendState(ctx, s.setState)
+		if ctx.Err() != nil {
+			return
+		}
+
+		otelzap.L().WarnContext(ctx, "lost the tailscale IPN bus, watching it again", //nolint:golint-sl // background watcher has no request to attach to
+			zap.Error(err),
+			zap.Duration("retry_in", watchRetryInterval),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
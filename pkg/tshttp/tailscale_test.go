@@ -275,6 +275,89 @@ func TestServer_ConnectionState(t *testing.T) {
 	}
 }
 
+func TestServer_BackendStateWatch(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+
+	mockTS := mock.NewMockTSNet()
+	mockTS.States = make(chan string)
+
+	s := tshttp.NewServer("myapp", tshttp.WithTSNet(mockTS))
+
+	type change struct{ previous, current string }
+	changes := make(chan change, 10)
+	s.OnStateChange(func(previous, current string) {
+		changes <- change{previous, current}
+	})
+
+	require.NoError(t, s.Start(context.Background()))
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+	require.Equal(t, change{"", "Running"}, <-changes)
+	require.True(t, s.IsConnected())
+
+	// The node key expired
+	mockTS.States <- "NeedsLogin"
+	require.Equal(t, change{"Running", "NeedsLogin"}, <-changes)
+	require.False(t, s.IsConnected())
+	require.Equal(t, "NeedsLogin", s.BackendState())
+
+	// Repeated states are not reported again
+	mockTS.States <- "NeedsLogin"
+	mockTS.States <- "Running"
+	require.Equal(t, change{"NeedsLogin", "Running"}, <-changes)
+	require.True(t, s.IsConnected())
+	require.Empty(t, changes)
+}
+
+func TestServer_StateSecret(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+
+	mockTS := mock.NewMockTSNet()
+	s := tshttp.NewServer("myapp-0", tshttp.WithTSNet(mockTS), tshttp.WithStateSecret("tka-state-0"))
+	require.NoError(t, s.Start(context.Background()))
+	require.Equal(t, "tka-state-0", mockTS.StateSecret)
+
+	// Outside of a cluster the secret can't be opened and the server must not fall back to a fresh node
+	mockTS = mock.NewMockTSNet()
+	mockTS.StateSecretErr = humane.New("not running in a cluster", "run the server in a pod")
+	s = tshttp.NewServer("myapp-0", tshttp.WithTSNet(mockTS), tshttp.WithStateSecret("tka-state-0"))
+	err := s.Start(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to connect to tailnet")
+	require.False(t, mockTS.UpCalled)
+}
+
+func TestServer_Service(t *testing.T) {
+	t.Helper()
+	t.Parallel()
+
+	mockTS := mock.NewMockTSNet()
+	s := tshttp.NewServer("myapp-0", tshttp.WithTSNet(mockTS))
+
+	_, err := s.ListenService("svc:myapp", true)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "server not started")
+
+	require.NoError(t, s.Start(context.Background()))
+	listener, err := s.ListenService("svc:myapp", true)
+	require.NoError(t, err)
+	require.NotNil(t, listener)
+	require.Equal(t, []string{"svc:myapp"}, mockTS.Services)
+
+	// Serve fails if the node can't host the service, e.g. because it isn't tagged
+	mockTS = mock.NewMockTSNet()
+	mockTS.ServiceErr = humane.New("service hosts must be tagged nodes", "tag the node")
+	s = tshttp.NewServer("myapp-0", tshttp.WithTSNet(mockTS), tshttp.WithService("svc:myapp"))
+	require.NoError(t, s.Start(context.Background()))
+
+	err = s.Serve(context.Background(), http.NotFoundHandler(), "tls")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to create service listener")
+	require.Equal(t, 1, mockTS.ListenCalled["tls"])
+	require.Equal(t, 1, mockTS.ListenCalled["service"])
+}
+
 func TestServer_ListenMethods(t *testing.T) {
 	t.Helper()
 	t.Parallel()
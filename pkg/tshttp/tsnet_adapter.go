@@ -6,11 +6,16 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"slices"
+	"strings"
 
 	"github.com/sierrasoftworks/humane-errors-go"
 	"tailscale.com/client/local"
+	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/ipn/store/kubestore"
 	"tailscale.com/tsnet"
+	"tailscale.com/types/logger"
 )
 
 // tsnetAdapter implements TSNet by delegating to *tsnet.Server.
@@ -33,6 +38,14 @@ func (a *tsnetAdapter) ListenFunnel(network, addr string) (net.Listener, error)
 	return a.s.ListenFunnel(network, addr)
 }
 
+func (a *tsnetAdapter) ListenService(name string, port int, terminateTLS bool) (net.Listener, string, error) {
+	ln, err := a.s.ListenService(name, tsnet.ServiceModeTCP{Port: uint16(port), TerminateTLS: terminateTLS})
+	if err != nil {
+		return nil, "", err
+	}
+	return ln, ln.FQDN, nil
+}
+
 func (a *tsnetAdapter) LocalWhoIs() (WhoIsResolver, error) {
 	lc, err := a.s.LocalClient()
 	if err != nil {
@@ -41,7 +54,45 @@ func (a *tsnetAdapter) LocalWhoIs() (WhoIsResolver, error) {
 	return &localWhoIsResolver{lc: lc}, nil
 }
 
-func (a *tsnetAdapter) SetDir(dir string)                 { a.s.Dir = dir }
+func (a *tsnetAdapter) WatchBackendState(ctx context.Context, onState func(state string)) error {
+	lc, err := a.s.LocalClient()
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale local client: %w", err)
+	}
+
+	watcher, err := lc.WatchIPNBus(ctx, ipn.NotifyInitialState)
+	if err != nil {
+		return fmt.Errorf("failed to watch the tailscale IPN bus: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	for {
+		n, err := watcher.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read from the tailscale IPN bus: %w", err)
+		}
+		if n.State != nil {
+			onState(n.State.String())
+		}
+	}
+}
+
+func (a *tsnetAdapter) SetDir(dir string) { a.s.Dir = dir }
+
+func (a *tsnetAdapter) SetStateSecret(name string) error {
+	logf := a.s.Logf
+	if logf == nil {
+		logf = logger.Discard
+	}
+
+	store, err := kubestore.New(logf, name)
+	if err != nil {
+		return fmt.Errorf("failed to open the state secret %s: %w", name, err)
+	}
+	a.s.Store = store
+	return nil
+}
+
 func (a *tsnetAdapter) SetLogf(logf func(string, ...any)) { a.s.Logf = logf }
 
 // localWhoIsResolver adapts *local.Client to our WhoIsResolver interface.
@@ -53,9 +104,23 @@ func (l *localWhoIsResolver) WhoIs(ctx context.Context, remoteAddr string) (*Who
 	if err != nil {
 		return nil, humane.Wrap(err, "failed to get WhoIs", "check (debug) logs for more details")
 	}
+	nodeName, _, _ := strings.Cut(who.Node.Name, ".")
+
+	nodeAttributes := make([]string, 0, len(who.Node.CapMap))
+	for attr := range who.Node.CapMap {
+		nodeAttributes = append(nodeAttributes, string(attr))
+	}
+	slices.Sort(nodeAttributes)
+
 	return &WhoIsInfo{
-		LoginName: who.UserProfile.LoginName,
-		CapMap:    who.CapMap,
-		Tags:      who.Node.Tags,
+		LoginName:      who.UserProfile.LoginName,
+		CapMap:         who.CapMap,
+		Tags:           who.Node.Tags,
+		NodeName:       nodeName,
+		NodeID:         string(who.Node.StableID),
+		OS:             who.Node.Hostinfo.OS(),
+		ClientVersion:  who.Node.Hostinfo.IPNVersion(),
+		KeyExpiry:      who.Node.KeyExpiry,
+		NodeAttributes: nodeAttributes,
 	}, nil
 }